@@ -0,0 +1,86 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ultramozg/golang-blog-engine/model"
+)
+
+//postAge returns the age of the post in full years if it's old enough to show the age banner, zero otherwise.
+//The threshold of the post wins over AGE_BANNER_YEARS
+func (a *App) postAge(p model.Post) int {
+	threshold := a.Config.Posts.AgeBanner
+	if p.AgeBanner != model.AgeBannerDefault {
+		threshold = p.AgeBanner
+	}
+	if threshold <= 0 {
+		return 0
+	}
+	published, err := a.parseDate(p.Date)
+	if err != nil {
+		return 0
+	}
+	now := a.now()
+	years := now.Year() - published.Year()
+	if published.AddDate(years, 0, 0).After(now) {
+		years--
+	}
+	if years < threshold {
+		return 0
+	}
+	return years
+}
+
+//ageBanner returns the text of the banner shown above old posts, it's empty if the post isn't old enough
+func (a *App) ageBanner(p model.Post) string {
+	years := a.postAge(p)
+	if years == 0 {
+		return ""
+	}
+	text := fmt.Sprintf("This post is %d years old", years)
+	if years == 1 {
+		text = "This post is a year old"
+	}
+	if p.Updated > 0 {
+		text += ", it was last updated on " + time.Unix(p.Updated, 0).In(a.location).Format("January 2, 2006")
+	}
+	return text + ". Some of its content may be out of date."
+}
+
+//readAgeBanner reads the age banner threshold of the post form: empty is the site's setting,
+//"never" hides the banner and a number is the age in years
+func readAgeBanner(r *http.Request, p *model.Post) error {
+	v := strings.TrimSpace(r.FormValue("age_banner"))
+	switch v {
+	case "":
+		p.AgeBanner = model.AgeBannerDefault
+	case "never":
+		p.AgeBanner = model.AgeBannerNever
+	default:
+		years, err := strconv.Atoi(v)
+		if err != nil || years < 1 {
+			return errors.New("Age banner must be a number of years or never")
+		}
+		p.AgeBanner = years
+	}
+	return nil
+}
+
+//postDates returns datePublished and dateModified of the post for schema.org, the post is modified
+//when it's published unless it has been updated since
+func (a *App) postDates(p model.Post) (published, modified string) {
+	t, err := a.parseDate(p.Date)
+	if err != nil {
+		return "", ""
+	}
+	published, modified = t.Format(time.RFC3339), t.Format(time.RFC3339)
+	if p.Updated > t.Unix() {
+		modified = time.Unix(p.Updated, 0).In(a.location).Format(time.RFC3339)
+	}
+	return published, modified
+}
@@ -0,0 +1,156 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ultramozg/golang-blog-engine/integrations"
+	"github.com/ultramozg/golang-blog-engine/model"
+)
+
+const (
+	DefaultAnnounceTemplate = "{{title}} {{url}}"
+	//AnnounceAttempts is how many times delivery is tried before the announcement is given up
+	AnnounceAttempts   = 5
+	AnnouncementsLimit = 100
+)
+
+//announceChannel is a configured social network together with the template of its announcements
+type announceChannel struct {
+	integrations.Channel
+	Template string
+}
+
+//announceChannels returns the channels having credentials configured
+func announceChannels(c Announce) map[string]announceChannel {
+	channels := map[string]announceChannel{}
+	add := func(ch integrations.Channel, template string) {
+		channels[ch.Name()] = announceChannel{ch, template}
+	}
+	if c.MastodonURL != "" && c.MastodonToken != "" {
+		add(integrations.Mastodon{URL: c.MastodonURL, Token: c.MastodonToken}, c.MastodonTemplate)
+	}
+	if c.BlueskyHandle != "" && c.BlueskyPassword != "" {
+		add(integrations.Bluesky{PDS: c.BlueskyPDS, Handle: c.BlueskyHandle, Password: c.BlueskyPassword}, c.BlueskyTemplate)
+	}
+	if c.TelegramToken != "" && c.TelegramChat != "" {
+		add(integrations.Telegram{API: c.TelegramAPI, Token: c.TelegramToken, Chat: c.TelegramChat}, c.TelegramTemplate)
+	}
+	return channels
+}
+
+//announce queues announcements of the just published post on every configured channel,
+//members only posts aren't announced and a post is announced on a channel only once
+func (a *App) announce(r *http.Request, p model.Post) {
+	if len(a.channels) == 0 || !p.Published() || p.Visibility == model.VisibilityMembers {
+		return
+	}
+
+	values := map[string]string{"title": p.Title, "url": a.postURL(r, p.ID)}
+	for name, ch := range a.channels {
+		an := model.Announcement{
+			PostID:  p.ID,
+			Channel: name,
+			Text:    expandPlaceholders(ch.Template, values),
+			SendAt:  time.Now().Add(a.Config.Announce.Delay).Unix(),
+		}
+		if _, err := an.QueueAnnouncement(a.DB); err != nil {
+			log.Printf("Unable to queue announcement of post %d on %s: %v", p.ID, name, err)
+		}
+	}
+}
+
+//sendAnnouncements delivers due announcements, the admin is notified about the ones given up
+func (a *App) sendAnnouncements() error {
+	due, err := model.GetDueAnnouncements(a.DB, time.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("unable to fetch announcements: %v", err)
+	}
+
+	failed := 0
+	for _, an := range due {
+		ch, ok := a.channels[an.Channel]
+		if !ok {
+			err = errors.New("channel is not configured")
+		} else {
+			err = ch.Send(an.Text)
+		}
+		if err == nil {
+			if err := an.MarkSent(a.DB); err != nil {
+				return err
+			}
+			continue
+		}
+
+		failed++
+		final := !ok || an.Attempts+1 >= AnnounceAttempts
+		if err := an.MarkFailed(a.DB, err.Error(), final); err != nil {
+			return err
+		}
+		if final {
+			notify(a.DB, NotifyAnnounceFailed, fmt.Sprintf("Announcement of post %d on %s failed: %v", an.PostID, an.Channel, err), "/admin/announcements")
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d announcements failed", failed, len(due))
+	}
+	return nil
+}
+
+//announcements renders the delivery log of the announcements
+func (a *App) announcements(w http.ResponseWriter, r *http.Request) {
+	if !a.Sessions.IsAdmin(r) {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
+
+	list, err := model.GetAnnouncements(a.DB, AnnouncementsLimit)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to fetch announcements: %v", err))
+		return
+	}
+	channels := []string{}
+	for name := range a.channels {
+		channels = append(channels, name)
+	}
+
+	data := struct {
+		PageData
+		Announcements []model.Announcement
+		Channels      []string
+	}{
+		a.pageData(r).WithRobots("noindex"),
+		list,
+		channels,
+	}
+	a.renderTemplate(w, r, "announcements.gohtml", data)
+}
+
+//cancelAnnouncement cancels the queued announcement
+func (a *App) cancelAnnouncement(w http.ResponseWriter, r *http.Request) {
+	if !a.Sessions.IsAdmin(r) {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
+
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		a.renderError(w, r, http.StatusBadRequest, errors.New("Invalid announcement id"))
+		return
+	}
+	ok, err := model.CancelAnnouncement(a.DB, id)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to cancel announcement: %v", err))
+		return
+	}
+	if !ok {
+		a.renderError(w, r, http.StatusConflict, errors.New("Announcement is not queued anymore"))
+		return
+	}
+	a.audit(r, "announcement cancel", fmt.Sprintf("announcement %d", id))
+	http.Redirect(w, r, "/admin/announcements", http.StatusSeeOther)
+}
@@ -0,0 +1,62 @@
+package app
+
+import (
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/ultramozg/golang-blog-engine/model"
+	"github.com/ultramozg/golang-blog-engine/render"
+	"github.com/ultramozg/golang-blog-engine/router"
+)
+
+//withPost loads post given by {id} path parameter and passes it to the handler,
+//posts the reader can't see yet are not found
+func (a *App) withPost(h func(http.ResponseWriter, *http.Request, model.Post)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, err := a.loadPost(router.Param(r, "id"))
+		if err != nil {
+			a.renderError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		if a.hidden(r, p) {
+			a.renderError(w, r, http.StatusNotFound, nil)
+			return
+		}
+		a.restrict(r, &p)
+		h(w, r, p)
+	}
+}
+
+//postGallery serves /api/posts/{id}/gallery with full size images of the post galleries for the lightbox
+func (a *App) postGallery(w http.ResponseWriter, r *http.Request, p model.Post) {
+	images := render.GalleryImages(p.Body)
+	for i := range images {
+		images[i].Width, images[i].Height = a.localImageSize(images[i].URL)
+	}
+	writeJSON(w, images)
+}
+
+//localImageSize returns dimensions of the image served from /public/, zeros if unknown
+func (a *App) localImageSize(u string) (int, int) {
+	if !strings.HasPrefix(u, "/public/") {
+		return 0, 0
+	}
+	name := strings.TrimPrefix(path.Clean(u), "/public/")
+
+	f, err := a.public.Open(name)
+	if err != nil {
+		return 0, 0
+	}
+	defer f.Close()
+
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, 0
+	}
+	return cfg.Width, cfg.Height
+}
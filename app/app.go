@@ -4,12 +4,17 @@ import (
 	"context"
 	"crypto/tls"
 	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"regexp"
 	"strconv"
+	"strings"
 	"syscall"
 	"text/template"
 	"time"
@@ -18,7 +23,11 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/ultramozg/golang-blog-engine/middleware"
 	"github.com/ultramozg/golang-blog-engine/model"
+	"github.com/ultramozg/golang-blog-engine/render"
+	"github.com/ultramozg/golang-blog-engine/router"
 	"github.com/ultramozg/golang-blog-engine/session"
+	"github.com/ultramozg/golang-blog-engine/systemd"
+	"github.com/ultramozg/golang-blog-engine/tracing"
 	"golang.org/x/crypto/acme/autocert"
 	"golang.org/x/crypto/bcrypt"
 	"golang.org/x/oauth2"
@@ -38,15 +47,40 @@ a.Initialize(conf)
 a.Run()
 */
 type App struct {
-	Router   http.Handler
-	DB       *sql.DB
-	Temp     *template.Template
-	Sessions session.SessionDB
-	Config   *Config
-	stop     chan os.Signal
-	OAuth    *oauth2.Config
-	Courses  model.Infos
-	Links    model.Infos
+	Router    http.Handler
+	DB        *sql.DB
+	Temp      *template.Template
+	Sessions  *session.SessionDB
+	Config    *Config
+	stop      chan os.Signal
+	OAuth     *oauth2.Config
+	Courses   model.Infos
+	Links     model.Infos
+	reacts    *rateLimiter
+	jobs      *scheduler
+	render    *render.Pipeline
+	sanitizer *render.Sanitizer
+	mail      *mailer
+	channels  map[string]announceChannel
+	tracer    *tracing.Tracer
+	cache     *caches
+	caching   cachePolicy
+	purges    *purgeQueue
+	events    *commentStreams
+	previews  *previewStreams
+	pprof     http.Handler
+	cookies   *session.Cookies
+	settings  *settingsStore
+	readOnly  readOnlySwitch
+	firewall  *firewall
+	banLog    *banLog
+	//location is the timezone of the site, see Config.Timezone
+	location *time.Location
+	//ConfigSource tells where the configuration is read from besides the environment
+	ConfigSource ConfigSource
+	//Assets holds templates/ and public/ directories embedded in the binary, see main.go
+	Assets fs.FS
+	public fs.FS
 }
 
 //NewApp return App struct
@@ -57,15 +91,34 @@ func NewApp() App {
 //Initialize Is using to initialize the app(connect to DB, initialize routes,logs, sessions and etc.
 func (a *App) Initialize() {
 	var err error
-	a.Config = newConfig()
+	if a.Config, err = loadConfig(a.ConfigSource); err != nil {
+		log.Fatal(err)
+	}
+	if err := a.Config.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	if a.location, err = loadLocation(a.Config.Timezone); err != nil {
+		log.Fatal("Unable to load timezone: ", err)
+	}
 
 	a.DB, err = sql.Open("sqlite3", a.Config.DBURI)
 	log.Println("Trying connect to DB:", a.Config.DBURI)
 	if err != nil {
 		log.Fatal("Error connecting to dabase", err)
 	}
+	if err = model.ConfigureDB(a.DB, a.Config.Database.MaxOpenConns, a.Config.Database.MaxIdleConns, a.Config.Database.JournalMode); err != nil {
+		log.Fatal("Unable to configure database: ", err)
+	}
+
+	a.tracer = tracing.New(tracing.Config{
+		Endpoint:    a.Config.Tracing.Endpoint,
+		Service:     a.Config.Tracing.Service,
+		SampleRatio: a.Config.Tracing.SampleRatio,
+	})
 
 	model.MigrateDatabase(a.DB)
+	a.settings = newSettingsStore(a.DB, defaultSettings(a.Config))
 
 	u := &model.User{Name: "admin", Type: session.ADMIN}
 
@@ -89,10 +142,56 @@ func (a *App) Initialize() {
 		log.Println(err)
 	}
 
+	a.firewall, err = newFirewall(a.Config.Firewall)
+	if err != nil {
+		log.Fatal("Unable to set up firewall: ", err)
+	}
+	a.public = a.publicFiles()
 	a.initializeRoutes()
 
-	a.Temp = template.Must(template.ParseGlob(a.Config.Templates))
-	a.Sessions = session.NewSessionDB()
+	a.sanitizer = render.NewSanitizer(render.SanitizePolicy{
+		Strict: a.Config.Sanitize.Strict,
+		Tags:   a.Config.Sanitize.Tags,
+		Attrs:  a.Config.Sanitize.Attrs,
+	})
+	a.render = &render.Pipeline{Shortcodes: render.DefaultShortcodes()}
+	if a.Config.Sanitize.Posts {
+		a.render.Sanitizer = a.sanitizer
+	}
+	if a.Config.Embed.Enabled {
+		a.render.Embedder = render.NewEmbedder(render.DefaultProviders, a.Config.Embed.TTL, a.Config.Embed.CacheSize)
+	}
+	a.Temp, err = a.parseTemplates()
+	if err != nil {
+		log.Fatal("Unable to load templates: ", err)
+	}
+	a.cookies, err = session.NewCookies(session.CookieOptions{
+		Secret:   a.Config.Cookie.Secret,
+		Domain:   a.Config.Cookie.Domain,
+		Path:     a.Config.Cookie.Path,
+		Secure:   a.Config.Cookie.Secure,
+		SameSite: sameSite(a.Config.Cookie.SameSite),
+	})
+	if err != nil {
+		log.Fatal("Unable to set up cookies: ", err)
+	}
+	if a.Config.Cookie.Secret == "" {
+		log.Println("COOKIE_SECRET is not set, cookies will be invalidated on restart")
+	}
+	a.Sessions = session.NewSessionDB(a.cookies)
+	a.reacts = newRateLimiter(2 * time.Second)
+	a.banLog = newBanLog(a.Config.BanLog.Path, a.Config.BanLog.Format)
+	a.mail = newMailer(a.Config.Mail)
+	a.channels = announceChannels(a.Config.Announce)
+	a.cache = newCaches(a.DB, a.Config.Cache.TTL)
+	a.caching = newCachePolicy(a.Config.HTTPCache)
+	a.events = newCommentStreams(a.Config.Events.MaxConnections, a.Config.Events.MaxPerClient)
+	a.previews = newPreviewStreams()
+	//changed pages are purged from the CDN on every write
+	if purgers := cdnPurgers(a.Config.Purge); len(purgers) > 0 {
+		a.purges = newPurgeQueue(a.DB, purgers, PurgeBackoff)
+		model.OnChange(a.DB, a.purgeChanged)
+	}
 
 	//Setting up OAuth authentication via github
 	a.OAuth = &oauth2.Config{
@@ -107,6 +206,39 @@ func (a *App) Initialize() {
 	}
 	//======END OAUTH CONFIGURATION======
 
+	a.readOnly.Set(a.Config.ReadOnly)
+
+	//Register periodic jobs, they are started along with the servers
+	a.jobs = newScheduler(a.DB, a.Config.Scheduler.Jitter, a.location)
+	a.jobs.paused = a.readOnly.Enabled
+	a.jobs.tracer = a.tracer
+	checker := newLinkChecker(a.DB, a.Config.LinkCheck.Delay, a.Config.LinkCheck.TTL)
+	a.jobs.Register("link-check", a.Config.LinkCheck.Interval, a.Config.LinkCheck.Enabled, func() error {
+		return checker.Run(a.jobs.Context())
+	})
+	a.jobs.Register("login-cleanup", 24*time.Hour, a.Config.Jobs.LoginCleanup, func() error {
+		if err := model.DeleteExpiredRememberTokens(a.DB, time.Now().Unix()); err != nil {
+			return err
+		}
+		return model.DeleteLoginAttempts(a.DB, time.Now().Add(-7*24*time.Hour).Unix())
+	})
+	a.jobs.Register("reply-notifications", a.Config.Mail.ReplyInterval, a.mail != nil, a.sendReplyNotifications)
+	a.jobs.Register("announcements", a.Config.Announce.Interval, len(a.channels) > 0, a.sendAnnouncements)
+	a.jobs.Register("cache-prune", 10*time.Minute, a.Config.Cache.TTL > 0, a.cache.prune)
+	a.jobs.RegisterDaily("db-maintenance", a.Config.Database.MaintenanceHour, a.Config.Database.MaintenanceHour >= 0, a.maintainDatabase)
+	a.jobs.Register("data-retention", 24*time.Hour, a.Config.Privacy.Retention > 0, a.pruneAnalytics)
+	a.jobs.Register("notifications-cleanup", 24*time.Hour, a.Config.Jobs.NotificationsCleanup, func() error {
+		return model.DeleteReadNotifications(a.DB, NotificationsToKeep)
+	})
+	a.jobs.Register("post-expiry", time.Minute, a.Config.Jobs.PostExpiry, a.expirePosts)
+	a.jobs.Register("comments-recount", 24*time.Hour, a.Config.Jobs.CommentsRecount, func() error {
+		n, err := model.RecountComments(a.DB)
+		if n > 0 {
+			log.Printf("Corrected comment counts of %d posts", n)
+		}
+		return err
+	})
+
 	//setting up signal capturing
 	a.stop = make(chan os.Signal, 1)
 	signal.Notify(a.stop, os.Interrupt)
@@ -118,8 +250,8 @@ func (a *App) Run() {
 	//Get the cert
 	cert := autocert.Manager{
 		Prompt:     autocert.AcceptTOS,
-		HostPolicy: autocert.HostWhitelist(a.Config.Domain),
-		Cache:      autocert.DirCache("cert"),
+		HostPolicy: autocert.HostWhitelist(a.Config.Hosts()...),
+		Cache:      autocert.DirCache(a.Config.CertDir),
 	}
 
 	secureServer := &http.Server{
@@ -149,125 +281,318 @@ func (a *App) Run() {
 	log.Println("Listening on the addr", a.Config.Server.Addr+a.Config.Server.Http)
 	log.Println("Listening TLS on the addr", a.Config.Server.Addr+a.Config.Server.Https)
 
+	//Listen before reporting readiness, so systemd starts dependent units once the ports accept connections
+	httpListener, err := net.Listen("tcp", httpServer.Addr)
+	if err != nil {
+		log.Fatal("Unable to listen on http port: ", err)
+	}
+	secureListener, err := net.Listen("tcp", secureServer.Addr)
+	if err != nil {
+		log.Fatal("Unable to listen on https port: ", err)
+	}
+
 	//Launch standart http, to fetch cert Let's Encrypt with 301 -> https
 	go func() {
-		if err := httpServer.ListenAndServe(); err != nil {
-			log.Fatal("Unable to listen on http port: ", err)
+		if err := httpServer.Serve(httpListener); err != http.ErrServerClosed {
+			log.Fatal("Unable to serve http: ", err)
 		}
 	}()
 
 	//Launch https
 	go func() {
-		if err := secureServer.ListenAndServeTLS("", ""); err != nil {
-			log.Fatal("Unable to listen on https port: ", err)
+		if err := secureServer.ServeTLS(secureListener, "", ""); err != http.ErrServerClosed {
+			log.Fatal("Unable to serve https: ", err)
+		}
+	}()
+
+	//Profiles on the loopback listener aren't limited by the write timeout
+	var pprofServer *http.Server
+	if a.Config.Pprof.Addr != "" {
+		pprofServer = &http.Server{Addr: a.Config.Pprof.Addr, Handler: pprofMux()}
+		go func() {
+			if err := pprofServer.ListenAndServe(); err != http.ErrServerClosed {
+				log.Println("Unable to serve pprof: ", err)
+			}
+		}()
+		log.Println("Serving pprof on the addr", a.Config.Pprof.Addr)
+	}
+
+	//Launch periodic jobs
+	a.jobs.Start()
+	if a.purges != nil {
+		a.purges.Start()
+	}
+
+	if a.Config.PIDFile != "" {
+		if err := systemd.WritePIDFile(a.Config.PIDFile); err != nil {
+			log.Fatal("Unable to write PID file: ", err)
+		}
+	}
+	if _, err := systemd.Notify("READY=1"); err != nil {
+		log.Println("Unable to notify systemd: ", err)
+	}
+	go a.watchdog()
+
+	//SIGUSR1 toggles read-only mode, e.g. around online backups
+	usr := make(chan os.Signal, 1)
+	signal.Notify(usr, syscall.SIGUSR1)
+	go func() {
+		for range usr {
+			log.Printf("Caught SIGUSR1, read-only mode set to %v", a.readOnly.Toggle())
 		}
 	}()
 
 	//Listen to catch sigint signal to gracefully stop the app
 	<-a.stop
 	log.Println("Caught SIGINT or SIGTERM stopping the app")
+	systemd.Notify("STOPPING=1")
 
-	//close all connections
-	ctx, _ := context.WithTimeout(context.Background(), time.Second*5)
+	//close all connections, live streams first since they never become idle
+	a.events.Close()
+	a.previews.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
 	if err := secureServer.Shutdown(ctx); err != nil {
 		log.Println("Unable to shutdown http server")
 	}
 	if err := httpServer.Shutdown(ctx); err != nil {
 		log.Println("Unable to shutdown http server")
 	}
+	if pprofServer != nil {
+		pprofServer.Close()
+	}
+	a.jobs.Stop()
+	if a.purges != nil {
+		a.purges.Stop()
+	}
+	a.tracer.Shutdown()
+	model.CloseStatements(a.DB)
 	a.DB.Close()
+	if a.Config.PIDFile != "" {
+		os.Remove(a.Config.PIDFile)
+	}
 	os.Exit(0)
 }
 
+//watchdog keeps systemd watchdog from restarting the blog while the database responds,
+//WatchdogSec= of the unit enables it
+func (a *App) watchdog() {
+	interval, err := systemd.WatchdogInterval()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if interval == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), interval/4)
+		err := a.DB.PingContext(ctx)
+		cancel()
+		if err != nil {
+			log.Println("Watchdog: database doesn't respond: ", err)
+			continue
+		}
+		systemd.Notify("WATCHDOG=1")
+	}
+}
+
 func (a *App) initializeRoutes() {
-	mux := http.NewServeMux()
-
-	mux.HandleFunc("/", a.root)
-	mux.HandleFunc("/page", a.getPage)
-	mux.HandleFunc("/login", a.login)
-	mux.HandleFunc("/logout", a.logout)
-	mux.HandleFunc("/post", a.getPost)
-	mux.HandleFunc("/update", a.updatePost)
-	mux.HandleFunc("/create", a.createPost)
-	mux.HandleFunc("/delete", a.deletePost)
-	mux.HandleFunc("/about", a.about)
-	mux.HandleFunc("/links", a.links)
-	mux.HandleFunc("/courses", a.courses)
-	mux.HandleFunc("/auth-callback", a.oauth)
-	mux.HandleFunc("/create-comment", a.createComment)
-	mux.HandleFunc("/delete-comment", a.deleteComment)
+	rt := router.New()
+	rt.MethodNotAllowed = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.renderError(w, r, http.StatusMethodNotAllowed, nil)
+	})
 
-	//Register Fileserver
-	fs := http.FileServer(http.Dir("public/"))
-	mux.Handle("/public/", http.StripPrefix("/public/", middleware.CacheControlMiddleware(fs)))
+	//HTML pages are not served in headless mode
+	if !a.Config.Headless.Enabled {
+		rt.NotFound = http.HandlerFunc(a.notFound)
+		rt.Get("/", a.root)
+		rt.Get("/page", a.cacheable(CacheLists, a.getPage))
+		rt.Get("/post", a.cacheable(CachePosts, a.getPost))
+		rt.Get("/post/print", a.cacheable(CachePosts, a.printPost))
+		rt.Get("/post/comments.rss", a.cacheable(CacheFeeds, a.postCommentsFeed))
+		if a.Config.Events.MaxConnections > 0 {
+			rt.Get("/events/post/{id}", a.withPost(a.postEvents))
+		}
+		rt.Get("/comments.rss", a.cacheable(CacheFeeds, a.commentsFeed))
+		if !a.Config.Robots.Discourage {
+			rt.Get("/sitemap.xml", a.cacheable(CacheFeeds, a.sitemap))
+		}
+		rt.Get("/comments/unsubscribe", a.unsubscribeReplies)
+		rt.Get("/update", a.updatePostForm)
+		rt.Post("/update", a.updatePost)
+		rt.Get("/create", a.createPostForm)
+		rt.Post("/create", a.createPost)
+		rt.Get("/delete", a.deletePost)
+		rt.Get("/about", a.cacheable(CacheLists, a.about))
+		rt.Get("/links", a.cacheable(CacheLists, a.links))
+		rt.Get("/courses", a.cacheable(CacheLists, a.courses))
+		rt.Get("/login", a.loginPage)
+		rt.Get("/search", a.cacheable(CacheLists, a.search))
+		rt.Get("/opensearch.xml", a.cacheable(CacheFeeds, a.openSearch))
+		rt.Post("/create-comment", a.createComment)
+		rt.Get("/delete-comment", a.deleteComment)
+		rt.Post("/like-comment", a.likeComment)
+		rt.Get("/admin/audit", a.auditLog)
+		rt.Get("/admin/likes", a.mostLiked)
+		rt.Get("/admin/seo-audit", a.seoAudit)
+		rt.Get("/admin/seo-audit.json", a.seoAudit)
+		rt.Get("/admin/broken-links", a.brokenLinks)
+		rt.Get("/admin/jobs", a.jobRuns)
+		rt.Get("/admin/diagnostics", a.diagnostics)
+		rt.Get("/admin/sessions", a.sessions)
+		rt.Post("/admin/sessions", a.sessionAction)
+		rt.Get("/admin/posts", a.adminPosts)
+		rt.Post("/admin/posts/bulk", a.bulkPosts)
+		rt.Post("/admin/posts/clone", a.clonePost)
+		rt.Get("/admin/workflow", a.workflow)
+		rt.Post("/admin/workflow", a.workflowAction)
+		rt.Get("/admin/notifications", a.notificationCenter)
+		rt.Get("/admin/settings", a.siteSettings)
+		rt.Post("/admin/visitor", a.readAsVisitor)
+		rt.Post("/admin/settings", a.saveSiteSettings)
+		rt.Get("/admin/featured", a.featuredCuration)
+		rt.Get("/admin/not-found", a.notFoundLog)
+		rt.Get("/admin/redirects", a.redirects)
+		rt.Post("/admin/redirects", a.saveRedirect)
+		rt.Get("/admin/snippets", a.snippets)
+		rt.Post("/admin/snippets", a.saveSnippet)
+		rt.Get("/admin/announcements", a.announcements)
+		rt.Post("/admin/announcements/cancel", a.cancelAnnouncement)
+		rt.Get("/public/css/code.css", a.cacheable(CacheStatic, a.codeCSS))
+	}
+	if !a.Config.Headless.Enabled || a.Config.Headless.Robots {
+		rt.Get("/robots.txt", a.cacheable(CacheFeeds, a.robotsTxt))
+	}
+	if len(a.Config.WellKnown.SecurityContacts) > 0 {
+		rt.Get("/.well-known/security.txt", a.cacheable(CacheFeeds, a.securityTxt))
+	}
+	if a.Config.WellKnown.NodeInfo {
+		rt.Get("/.well-known/nodeinfo", a.cacheable(CacheFeeds, a.nodeInfoWellKnown))
+		rt.Get("/nodeinfo/2.1", a.cacheable(CacheFeeds, a.nodeInfo))
+	}
+	if a.Config.Pprof.Enabled {
+		a.pprof = pprofMux()
+		rt.Get("/debug/pprof/{name...}", a.debugPprof)
+		rt.Post("/debug/pprof/symbol", a.debugPprof)
+	}
 
-	a.Router = middleware.LogMiddleware(a.securityMiddleware(middleware.GzipMiddleware(middleware.SetHeaderMiddleware(mux))))
+	//Authentication and JSON API, see apiRoutes
+	for _, op := range a.apiRoutes() {
+		rt.HandleFunc(op.Method, op.Path, op.Handler)
+		if op.Auth == "admin" {
+			a.firewall.adminPaths[op.Path] = true
+		}
+	}
+
+	//Register Fileserver
+	fs := http.FileServer(http.FS(a.public))
+	rt.Handle(http.MethodGet, "/public/{file...}", http.StripPrefix("/public/", a.cacheable(CacheStatic, fs.ServeHTTP)))
+
+	cors := middleware.CORSMiddleware(middleware.CORSOptions{
+		Origins:     a.Config.CORS.Origins,
+		Methods:     a.Config.CORS.Methods,
+		Headers:     a.Config.CORS.Headers,
+		Credentials: a.Config.CORS.Credentials,
+		MaxAge:      int(a.Config.CORS.MaxAge.Seconds()),
+		Prefixes:    []string{"/api/", "/graphql"},
+	})
+	normalize := middleware.NormalizeMiddleware(middleware.NormalizeOptions{
+		Skip: []string{"/public/", "/api/", "/graphql", "/debug/pprof/"},
+	})
+	a.Router = a.tracer.Middleware(middleware.RequestIDMiddleware(a.noIndexMiddleware(middleware.LogMiddleware(a.firewallMiddleware(a.redirectMiddleware(normalize(cors(a.readOnlyMiddleware(a.rememberMiddleware(a.securityMiddleware(middleware.GzipMiddleware(middleware.SetHeaderMiddleware(rt)))))))))))))
 }
 
-func (a *App) root(w http.ResponseWriter, r *http.Request) {
-	if r.URL.Path != "/" {
-		http.Error(w, "Opps something did wrong", http.StatusNotFound)
+//codeCSS serves stylesheet for the server side highlighted code blocks
+func (a *App) codeCSS(w http.ResponseWriter, r *http.Request) {
+	css, err := render.CodeCSS()
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to generate code stylesheet: %v", err))
 		return
 	}
+	w.Header().Set("Content-Type", "text/css; charset=utf-8")
+	w.Write([]byte(css))
+}
+
+func (a *App) root(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/page?p=0", http.StatusFound)
-	return
 }
 
-func (a *App) getPost(w http.ResponseWriter, r *http.Request) {
-	id, err := strconv.Atoi(r.FormValue("id"))
+//loadPost returns post with the id, errors are Error with 400 status if the id is invalid
+//and 404 if there is no such post
+func (a *App) loadPost(id string) (model.Post, error) {
+	n, err := strconv.Atoi(id)
 	if err != nil {
-		http.Error(w, "Invalid Blog id", http.StatusBadRequest)
-		return
+		return model.Post{}, newError(http.StatusBadRequest, "Invalid post id")
 	}
 
-	p := model.Post{ID: id}
-	if err = p.GetPost(a.DB); err != nil {
-		switch err {
-		case sql.ErrNoRows:
-			http.Error(w, "Not Found", http.StatusNotFound)
-		default:
-			http.Error(w, "Internal error", http.StatusInternalServerError)
+	p := model.Post{ID: n}
+	if err := p.GetPost(a.DB); err != nil {
+		if err == sql.ErrNoRows {
+			return model.Post{}, newError(http.StatusNotFound, "Post not found")
 		}
+		return model.Post{}, err
+	}
+	return p, nil
+}
+
+func (a *App) getPost(w http.ResponseWriter, r *http.Request) {
+	p, err := a.cachedPost(r.FormValue("id"))
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if a.hidden(r, p) {
+		a.notFound(w, r)
 		return
 	}
 
-	switch r.Method {
-	case http.MethodGet:
+	if !p.Published() {
+		w.Header().Add("X-Robots-Tag", "noindex")
+	} else if robots := p.Robots(); robots != "" {
+		w.Header().Add("X-Robots-Tag", robots)
+	}
+	w.Header().Set("Link", "<"+a.canonicalURL(r, p)+`>; rel="canonical"`)
 
-		comms, err := model.GetComments(a.DB, id)
-		if err != nil {
-			log.Println("Grab comment error: ", err.Error())
-		}
+	comms, err := a.cachedComments(p.ID)
+	if err != nil {
+		log.Println("Grab comment error: ", err.Error())
+	}
 
-		data := struct {
-			Post        model.Post
-			Comms       []model.Comment
-			LogAsAdmin  bool
-			LogAsUser   bool
-			AuthURL     string
-			ClientID    string
-			RedirectURL string
-		}{
-			p,
-			comms,
-			a.Sessions.IsAdmin(r),
-			a.Sessions.IsLoggedin(r),
-			a.Config.OAuth.GithubAuthorizeURL,
-			a.Config.OAuth.ClientID,
-			a.Config.OAuth.RedirectURL,
-		}
-		err = a.Temp.ExecuteTemplate(w, "post.gohtml", data)
-		if err != nil {
-			log.Println(err.Error())
-		}
-	case http.MethodHead:
-		w.WriteHeader(http.StatusOK)
+	//pages of logged in users carry their own controls, only pages of anonymous readers are revalidated
+	if _, loggedIn := a.Sessions.GetUser(r); !loggedIn && err == nil && notModified(w, r, a.postETag(r, p, comms)) {
+		w.WriteHeader(http.StatusNotModified)
 		return
+	}
 
-	default:
-		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
-		return
+	//the head is built from the restricted post, structured data of how-tos and FAQs is taken from the body
+	restricted := a.restrict(r, &p)
+	h := a.postHead(r, p)
+
+	data := struct {
+		PageData
+		Post       model.Post
+		Restricted bool
+		//AgeBanner warns readers of old posts, it's computed on every request as the post ages
+		AgeBanner string
+		Comms     []model.Comment
+		//RepliesByMail offers commenters emails about new comments
+		RepliesByMail bool
+		//LiveComments appends new comments to the page as they are posted
+		LiveComments bool
+	}{
+		a.pageData(r).WithHead(h),
+		p,
+		restricted,
+		a.ageBanner(p),
+		comms,
+		a.mail != nil,
+		a.Config.Events.MaxConnections > 0,
 	}
+	a.renderTemplate(w, r, "post.gohtml", data)
 }
 
 func (a *App) getPage(w http.ResponseWriter, r *http.Request) {
@@ -275,382 +600,475 @@ func (a *App) getPage(w http.ResponseWriter, r *http.Request) {
 	var err error
 	page, err = strconv.Atoi(r.FormValue("p"))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		a.renderError(w, r, http.StatusBadRequest, err)
 		return
 	}
-	posts, err := model.GetPosts(a.DB, PostsPerPage, page*PostsPerPage)
-
+	if page < 0 {
+		a.renderError(w, r, http.StatusNotFound, nil)
+		return
+	}
+	settings := a.settings.Get()
+	perPage := settings.PostsPerPage
+	posts, total, err := model.GetPostSummaries(a.DB, page, perPage, settings.SortOrder)
+	a.restrictAll(r, posts)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	switch r.Method {
-	case http.MethodGet:
-		data := struct {
-			Posts      []model.Post
-			LoggedIn   bool
-			IsNextPage bool
-			PrevPage   int
-			NextPage   int
-		}{
-			posts,
-			a.Sessions.IsAdmin(r),
-			isNextPage(page, model.CountPosts(a.DB)),
-			absolute(page - 1),
-			absolute(page + 1),
-		}
-		a.Temp.ExecuteTemplate(w, "posts.gohtml", data)
-
-	case http.MethodHead:
-		w.WriteHeader(http.StatusOK)
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to fetch posts page: %v", err))
 		return
-
-	default:
-		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+	//pages past the last one would be empty duplicates of each other
+	if page > 0 && len(posts) == 0 {
+		a.renderError(w, r, http.StatusNotFound, nil)
 		return
 	}
-}
-
-func (a *App) createPost(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		a.Temp.ExecuteTemplate(w, "create.gohtml", a.Sessions.IsAdmin(r))
 
-	case http.MethodPost:
-		if err := r.ParseForm(); err != nil {
-			http.Error(w, "Invalid payload", http.StatusBadRequest)
-			return
+	var featured []model.Post
+	if page == 0 {
+		if featured, err = model.GetFeaturedPosts(a.DB); err != nil {
+			log.Println("Unable to fetch featured posts: ", err)
 		}
+		a.restrictAll(r, featured)
+	}
 
-		title := r.FormValue("title")
-		body := r.FormValue("body")
-		if title == "" || body == "" {
-			http.Error(w, "Bad Request", 400)
-			return
-		}
+	data := struct {
+		PageData
+		Featured   []model.Post
+		Posts      []model.Post
+		IsNextPage bool
+		PrevPage   int
+		NextPage   int
+		NextCursor string
+	}{
+		a.listPageData(r, page, isNextPage(page, total, perPage)),
+		featured,
+		posts,
+		isNextPage(page, total, perPage),
+		absolute(page - 1),
+		absolute(page + 1),
+		encodeCursor(page + 1),
+	}
+	a.renderTemplate(w, r, "posts.gohtml", data)
+}
 
-		p := model.Post{Title: title, Body: body, Date: time.Now().Format("Mon Jan _2 15:04:05 2006")}
-		if err := p.CreatePost(a.DB); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+func (a *App) createPostForm(w http.ResponseWriter, r *http.Request) {
+	p := model.Post{ContentType: model.ContentArticle}
+	if name := r.FormValue("snippet"); name != "" {
+		var err error
+		if p, err = a.snippetPost(r, name); err != nil {
+			a.renderError(w, r, http.StatusInternalServerError, err)
 			return
 		}
-		http.Redirect(w, r, "/", http.StatusSeeOther)
-
-	default:
-		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+	snippets, err := model.GetSnippets(a.DB)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to fetch snippets: %v", err))
 		return
 	}
-}
 
-func (a *App) updatePost(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		id, err := strconv.Atoi(r.FormValue("id"))
-		if err != nil {
-			http.Error(w, "Invalid ID", http.StatusBadRequest)
-			return
-		}
+	data := struct {
+		PageData
+		Post     model.Post
+		Snippets []model.Snippet
+		Snippet  string
+		Series   string
+	}{
+		a.pageData(r),
+		p,
+		snippets,
+		r.FormValue("snippet"),
+		r.FormValue("series"),
+	}
+	a.renderTemplate(w, r, "create.gohtml", data)
+}
 
-		p := model.Post{ID: id}
-		if err := p.GetPost(a.DB); err != nil {
-			switch err {
-			case sql.ErrNoRows:
-				http.Error(w, "Post not found", http.StatusNotFound)
-			default:
-				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			}
-			return
-		}
+func (a *App) createPost(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		a.renderError(w, r, http.StatusBadRequest, errors.New("Invalid payload"))
+		return
+	}
 
-		data := struct {
-			Post       model.Post
-			LogAsAdmin bool
-		}{
-			p,
-			a.Sessions.IsAdmin(r),
-		}
-		err = a.Temp.ExecuteTemplate(w, "update.gohtml", data)
-		log.Println(err)
+	title := r.FormValue("title")
+	body := r.FormValue("body")
+	if title == "" || body == "" {
+		a.renderError(w, r, http.StatusBadRequest, nil)
+		return
+	}
 
-	case http.MethodPost:
-		if err := r.ParseForm(); err != nil {
-			http.Error(w, "Bad Request", http.StatusBadRequest)
-			return
-		}
+	p := model.Post{Title: title, Body: body, Date: a.now().Format("Mon Jan _2 15:04:05 2006"), NoIndex: r.FormValue("noindex") != "", NoFollow: r.FormValue("nofollow") != ""}
+	p.CoverImage = strings.TrimSpace(r.FormValue("cover_image"))
+	p.Visibility = r.FormValue("visibility")
+	p.Pinned = r.FormValue("pinned") != ""
+	p.Featured = r.FormValue("featured") != ""
+	p.CanonicalURL = strings.TrimSpace(r.FormValue("canonical_url"))
+	if !validCanonicalURL(p.CanonicalURL) {
+		a.renderError(w, r, http.StatusBadRequest, errors.New("Invalid canonical URL"))
+		return
+	}
+	if !readContentType(r, &p) {
+		a.renderError(w, r, http.StatusBadRequest, errors.New("Invalid content type"))
+		return
+	}
+	if err := a.readExpiry(r, &p); err != nil {
+		a.renderError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err := readAgeBanner(r, &p); err != nil {
+		a.renderError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	p.Status = r.FormValue("status")
+	if p.Status != "" && !model.IsStatus(p.Status) {
+		a.renderError(w, r, http.StatusBadRequest, errors.New("Invalid status"))
+		return
+	}
+	if a.Config.Sanitize.Posts {
+		p.Body = a.sanitizer.SanitizeSource(p.Body)
+	}
+	if !a.checkDuplicate(w, r, p) {
+		return
+	}
+	if err := p.CreatePost(a.DB); err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	a.audit(r, "post create", fmt.Sprintf("title %q, %d chars", p.Title, len(p.Body)))
+	a.resolveEmbeds(p.Body)
+	a.announce(r, p)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
 
-		id, err := strconv.Atoi(r.FormValue("id"))
-		if err != nil {
-			http.Error(w, "Invalid id value", http.StatusBadRequest)
-			return
-		}
-		title := r.FormValue("title")
-		body := r.FormValue("body")
-		if title == "" || body == "" {
-			http.Error(w, "Empty Fields", http.StatusBadRequest)
-			return
-		}
+func (a *App) updatePostForm(w http.ResponseWriter, r *http.Request) {
+	p, err := a.loadPost(r.FormValue("id"))
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, err)
+		return
+	}
 
-		p := model.Post{ID: id, Title: title, Body: body, Date: time.Now().Format("Mon Jan _2 15:04:05 2006")}
-		if err := p.UpdatePost(a.DB); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+	data := struct {
+		PageData
+		Post model.Post
+	}{
+		a.pageData(r),
+		p,
+	}
+	a.renderTemplate(w, r, "update.gohtml", data)
+}
 
-	default:
-		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+func (a *App) updatePost(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		a.renderError(w, r, http.StatusBadRequest, nil)
 		return
 	}
-}
 
-func (a *App) deletePost(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		if err := r.ParseForm(); err != nil {
-			http.Error(w, "Bad Request", http.StatusBadRequest)
-			return
-		}
-		id, err := strconv.Atoi(r.FormValue("id"))
-		if err != nil {
-			http.Error(w, "Invalid Id", http.StatusBadRequest)
-			return
-		}
+	title := r.FormValue("title")
+	body := r.FormValue("body")
+	if title == "" || body == "" {
+		a.renderError(w, r, http.StatusBadRequest, errors.New("Empty Fields"))
+		return
+	}
 
-		p := model.Post{ID: id}
-		if err = p.GetPost(a.DB); err != nil {
-			switch err {
-			case sql.ErrNoRows:
-				http.Error(w, "Not Found", http.StatusNotFound)
-			default:
-				http.Error(w, "Internal error", http.StatusInternalServerError)
-			}
-			return
-		}
-		if err := p.DeletePost(a.DB); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		http.Redirect(w, r, "/", http.StatusSeeOther)
-	default:
-		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	old, err := a.loadPost(r.FormValue("id"))
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, err)
 		return
 	}
+	id := old.ID
+
+	//published date is kept, the time of the change is tracked in Updated
+	p := model.Post{ID: id, Title: title, Body: body, Date: old.Date, NoIndex: r.FormValue("noindex") != "", NoFollow: r.FormValue("nofollow") != ""}
+	p.CoverImage = strings.TrimSpace(r.FormValue("cover_image"))
+	p.Visibility = r.FormValue("visibility")
+	p.Pinned = r.FormValue("pinned") != ""
+	p.Featured = r.FormValue("featured") != ""
+	p.CanonicalURL = strings.TrimSpace(r.FormValue("canonical_url"))
+	if !validCanonicalURL(p.CanonicalURL) {
+		a.renderError(w, r, http.StatusBadRequest, errors.New("Invalid canonical URL"))
+		return
+	}
+	if !readContentType(r, &p) {
+		a.renderError(w, r, http.StatusBadRequest, errors.New("Invalid content type"))
+		return
+	}
+	if err := a.readExpiry(r, &p); err != nil {
+		a.renderError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err := readAgeBanner(r, &p); err != nil {
+		a.renderError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if a.Config.Sanitize.Posts {
+		p.Body = a.sanitizer.SanitizeSource(p.Body)
+	}
+	if err := p.UpdatePost(a.DB); err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	a.audit(r, "post update", postDiffSummary(old, p))
+	a.resolveEmbeds(p.Body)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
-func (a *App) about(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		a.Temp.ExecuteTemplate(w, "about.gohtml", a.Sessions.IsAdmin(r))
+//resolveEmbeds fetches embeds of the saved post in the background, so readers don't wait for the providers
+func (a *App) resolveEmbeds(body string) {
+	if a.render.Embedder != nil {
+		go a.render.Embedder.Resolve(body)
+	}
+}
+
+func (a *App) deletePost(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		a.renderError(w, r, http.StatusBadRequest, nil)
 		return
-	case http.MethodHead:
-		w.WriteHeader(http.StatusOK)
+	}
+	p, err := a.loadPost(r.FormValue("id"))
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, err)
 		return
-	default:
-		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+	comments, err := p.DeletePost(a.DB)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, err)
 		return
 	}
+	a.audit(r, "post delete", fmt.Sprintf("post %d %q with %d comments", p.ID, p.Title, comments))
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (a *App) about(w http.ResponseWriter, r *http.Request) {
+	a.renderTemplate(w, r, "about.gohtml", a.pageData(r))
 }
 
 func (a *App) links(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		data := struct {
-			LogAsAdmin bool
-			Links      []model.Info
-		}{
-			a.Sessions.IsAdmin(r),
-			a.Links.List,
-		}
-		a.Temp.ExecuteTemplate(w, "links.gohtml", data)
-	case http.MethodHead:
-		w.WriteHeader(http.StatusOK)
-		return
-	default:
-		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
-		return
+	data := struct {
+		PageData
+		Links []model.Info
+	}{
+		a.pageData(r),
+		a.Links.List,
 	}
+	a.renderTemplate(w, r, "links.gohtml", data)
 }
 
 func (a *App) courses(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		data := struct {
-			LogAsAdmin bool
-			Courses    []model.Info
-		}{
-			a.Sessions.IsAdmin(r),
-			a.Courses.List,
-		}
-		a.Temp.ExecuteTemplate(w, "courses.gohtml", data)
-	case http.MethodHead:
-		w.WriteHeader(http.StatusOK)
-		return
-	default:
-		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
-		return
+	data := struct {
+		PageData
+		Courses []model.Info
+	}{
+		a.pageData(r),
+		a.Courses.List,
 	}
+	a.renderTemplate(w, r, "courses.gohtml", data)
 }
 
-func (a *App) login(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		a.Temp.ExecuteTemplate(w, "login.gohtml", a.Sessions.IsAdmin(r))
+func (a *App) loginPage(w http.ResponseWriter, r *http.Request) {
+	a.renderTemplate(w, r, "login.gohtml", a.pageData(r))
+}
 
-	case http.MethodPost:
-		if err := r.ParseForm(); err != nil {
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			return
-		}
+func (a *App) login(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, err)
+		return
+	}
 
-		login := r.FormValue("login")
-		pass := r.FormValue("password")
+	login := r.FormValue("login")
+	pass := r.FormValue("password")
 
-		if login == "" || pass == "" {
-			http.Error(w, "Invalid Input data", http.StatusBadRequest)
-			return
+	if login == "" || pass == "" {
+		a.renderError(w, r, http.StatusBadRequest, errors.New("Invalid Input data"))
+		return
+	}
+	if a.loginLocked(login, a.storedIP(r)) {
+		a.auditAs("anonymous", r, "login blocked", fmt.Sprintf("attempt for %q while locked from %s", login, a.storedIP(r)))
+		a.banLog.Write(BanAuthLocked, login, r)
+		a.loginTooManyAttempts(w, r)
+		return
+	}
+	u := &model.User{Name: login}
+
+	if u.CheckCredentials(a.DB, pass) && u.IsAdmin(a.DB) {
+		a.loginSucceeded(login, r)
+		admin := model.User{Type: session.ADMIN, Name: "admin"}
+		remember := ""
+		if r.FormValue("remember") == "on" && a.Config.Login.RememberFor > 0 {
+			selector, rc, err := a.issueRememberToken(admin)
+			if err != nil {
+				log.Println("Unable to issue remember token: ", err)
+			} else {
+				remember = selector
+				http.SetCookie(w, rc)
+			}
 		}
-		u := &model.User{Name: login}
-
-		if u.CheckCredentials(a.DB, pass) && u.IsAdmin(a.DB) {
-			c := a.Sessions.CreateSession(model.User{Type: session.ADMIN, Name: "admin"})
-			http.SetCookie(w, c)
-			http.Redirect(w, r, "/", http.StatusSeeOther)
+		c := a.Sessions.CreateRememberedSession(admin, r, remember)
+		http.SetCookie(w, c)
+		a.auditAs("admin", r, "login", "successful admin login")
+		if a.Config.Headless.Enabled {
+			w.WriteHeader(http.StatusNoContent)
 			return
 		}
-		http.Error(w, "Invalid login credentials", http.StatusUnauthorized)
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	a.loginFailed(login, r)
+	a.renderError(w, r, http.StatusUnauthorized, errors.New("Invalid login credentials"))
+}
+
+func (a *App) logout(w http.ResponseWriter, r *http.Request) {
+	if a.Sessions.IsAdmin(r) {
+		s, _ := a.Sessions.GetSession(r)
+		a.revokeSession(s)
+		http.SetCookie(w, a.Sessions.DelSession(s.ID))
+		http.SetCookie(w, a.cookies.Expire("remember"))
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	} else {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
 		return
+	}
+}
 
-	case http.MethodHead:
-		w.WriteHeader(http.StatusOK)
+func (a *App) oauth(w http.ResponseWriter, r *http.Request) {
+	token, err := a.OAuth.Exchange(oauth2.NoContext, r.URL.Query().Get("code"))
+	if err != nil {
+		log.Println(w, "there was an issue getting your token: ", err.Error())
+		return
+	}
+	if !token.Valid() {
+		log.Println(w, "retreived invalid token")
 		return
+	}
 
-	default:
-		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	client := github.NewClient(a.OAuth.Client(oauth2.NoContext, token))
+	user, _, err := client.Users.Get(context.Background(), "")
+	if err != nil {
+		log.Println(w, "error getting name")
 		return
 	}
+
+	c := a.Sessions.CreateSession(model.User{Type: session.GITHUB, Name: *(user.Login)}, r)
+	http.SetCookie(w, c)
+	//http.Redirect(w, r, "/", http.StatusSeeOther)
+	http.Redirect(w, r, r.Header.Get("Referer"), http.StatusSeeOther)
+	log.Println("You have logged in as github user :", *(user.Login))
 }
 
-func (a *App) logout(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		if a.Sessions.IsAdmin(r) {
-			c, _ := r.Cookie("session")
-			a.Sessions.DelSession(c.Value)
-			http.SetCookie(w, c)
-			http.Redirect(w, r, "/", http.StatusSeeOther)
-		} else {
-			http.Error(w, "Not Authorized", http.StatusUnauthorized)
-			return
-		}
-	case http.MethodHead:
-		w.WriteHeader(http.StatusOK)
+func (a *App) createComment(w http.ResponseWriter, r *http.Request) {
+	if !(a.Sessions.IsLoggedin(r)) {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
 		return
-	default:
-		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+	if !a.settings.Get().CommentsOpen() {
+		a.renderError(w, r, http.StatusForbidden, errors.New("Comments are closed"))
 		return
 	}
-}
 
-func (a *App) oauth(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		token, err := a.OAuth.Exchange(oauth2.NoContext, r.URL.Query().Get("code"))
-		if err != nil {
-			log.Println(w, "there was an issue getting your token: ", err.Error())
-			return
-		}
-		if !token.Valid() {
-			log.Println(w, "retreived invalid token")
-			return
-		}
+	if err := r.ParseForm(); err != nil {
+		a.renderError(w, r, http.StatusBadRequest, errors.New("Invalid payload"))
+		return
+	}
 
-		client := github.NewClient(a.OAuth.Client(oauth2.NoContext, token))
-		user, _, err := client.Users.Get(context.Background(), "")
-		if err != nil {
-			log.Println(w, "error getting name")
-			return
-		}
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		a.renderError(w, r, http.StatusBadRequest, errors.New("Invalid Id"))
+		return
+	}
 
-		c := a.Sessions.CreateSession(model.User{Type: session.GITHUB, Name: *(user.Login)})
-		http.SetCookie(w, c)
-		//http.Redirect(w, r, "/", http.StatusSeeOther)
-		http.Redirect(w, r, r.Header.Get("Referer"), http.StatusSeeOther)
-		log.Println("You have logged in as github user :", *(user.Login))
+	u, _ := a.Sessions.GetUser(r)
+	name := u.Name
+	comment := r.FormValue("comment")
+	if name == "" || comment == "" {
+		a.renderError(w, r, http.StatusBadRequest, nil)
 		return
+	}
 
-	case http.MethodHead:
-		w.WriteHeader(http.StatusOK)
+	if a.commentBlocked(w, r) {
 		return
-	default:
-		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+
+	sub, err := a.replySubscription(r, id, name)
+	if err != nil {
+		a.renderError(w, r, http.StatusBadRequest, err)
 		return
 	}
-}
 
-func (a *App) createComment(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodPost:
-		if !(a.Sessions.IsLoggedin(r)) {
-			http.Error(w, "Not Authorized", http.StatusUnauthorized)
-			return
+	p := model.Comment{PostID: id, Name: name, Date: a.now().Format("Mon Jan _2 15:04:05 2006"), Data: comment}
+	if err := p.CreateComment(a.DB); err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	a.events.Publish(p)
+	if sub != nil {
+		if err := sub.Subscribe(a.DB); err != nil {
+			log.Printf("Unable to subscribe %s to post %d: %v", name, id, err)
 		}
+	}
+	if u.Type != session.ADMIN {
+		notify(a.DB, NotifyComment, "New comment by "+name, "/post?id="+strconv.Itoa(id))
+	}
+	http.Redirect(w, r, r.Header.Get("Referer"), http.StatusSeeOther)
+}
 
-		if err := r.ParseForm(); err != nil {
-			http.Error(w, "Invalid payload", http.StatusBadRequest)
-			return
-		}
+func (a *App) deleteComment(w http.ResponseWriter, r *http.Request) {
+	if !a.Sessions.IsAdmin(r) {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
 
-		id, err := strconv.Atoi(r.FormValue("id"))
-		if err != nil {
-			http.Error(w, "Invalid Id", http.StatusBadRequest)
-			return
-		}
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		a.renderError(w, r, http.StatusBadRequest, errors.New("Invalid Id"))
+		return
+	}
 
-		name := r.FormValue("name")
-		comment := r.FormValue("comment")
-		if name == "" || comment == "" {
-			http.Error(w, "Bad Request", 400)
-			return
-		}
+	c := model.Comment{CommentID: id}
+	if err := c.DeleteComment(a.DB); err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	a.audit(r, "comment delete", fmt.Sprintf("comment %d", id))
+	http.Redirect(w, r, r.Header.Get("Referer"), http.StatusSeeOther)
+}
 
-		p := model.Comment{PostID: id, Name: name, Date: time.Now().Format("Mon Jan _2 15:04:05 2006"), Data: comment}
-		if err := p.CreateComment(a.DB); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		http.Redirect(w, r, r.Header.Get("Referer"), http.StatusSeeOther)
+func (a *App) likeComment(w http.ResponseWriter, r *http.Request) {
+	u, ok := a.Sessions.GetUser(r)
+	if !ok {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
 
-	default:
-		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		a.renderError(w, r, http.StatusBadRequest, errors.New("Invalid Id"))
 		return
 	}
-}
 
-func (a *App) deleteComment(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		if !a.Sessions.IsAdmin(r) {
-			http.Error(w, "Not Authorized", http.StatusUnauthorized)
-			return
-		}
+	c := model.Comment{CommentID: id}
+	if !c.IsCommentExist(a.DB) {
+		a.renderError(w, r, http.StatusNotFound, nil)
+		return
+	}
 
-		id, err := strconv.Atoi(r.FormValue("id"))
-		if err != nil {
-			http.Error(w, "Invalid Id", http.StatusBadRequest)
-			return
-		}
+	if !a.reacts.Allow(u.Name) {
+		a.banLog.Write(BanRateLimited, u.Name, r)
+		a.renderError(w, r, http.StatusTooManyRequests, nil)
+		return
+	}
 
-		c := model.Comment{CommentID: id}
-		if err := c.DeleteComment(a.DB); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		http.Redirect(w, r, r.Header.Get("Referer"), http.StatusSeeOther)
-	default:
-		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	if _, err := model.ToggleCommentLike(a.DB, id, u.Name); err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, err)
 		return
 	}
+	http.Redirect(w, r, r.Header.Get("Referer"), http.StatusSeeOther)
+}
+
+//templateFuncs returns the helper functions available in all templates
+func (a *App) templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"comment":  render.Comment,
+		"post":     a.render.Post,
+		"settings": a.settings.Get,
+		"readonly": a.readOnly.Enabled,
+		"consent":  func() bool { return a.Config.Privacy.Consent },
+		"expiry":   a.expiryInput,
+	}
 }
 
 func absolute(i int) int {
@@ -660,8 +1078,32 @@ func absolute(i int) int {
 	return i
 }
 
-func isNextPage(nextPage, totalPosts int) bool {
-	return (totalPosts / PostsPerPage) > nextPage
+//pageCount returns number of pages holding total items, there is always at least one page
+func pageCount(total, perPage int) int {
+	if total <= perPage {
+		return 1
+	}
+	return (total + perPage - 1) / perPage
+}
+
+//isNextPage reports whether there are posts after the page
+func isNextPage(page, totalPosts, perPage int) bool {
+	return (page+1)*perPage < totalPosts
+}
+
+//postDiffSummary describes what has been changed in the post
+func postDiffSummary(old, new model.Post) string {
+	summary := fmt.Sprintf("post %d", new.ID)
+	if old.Title != new.Title {
+		summary += fmt.Sprintf(", title %q -> %q", old.Title, new.Title)
+	}
+	if old.Body != new.Body {
+		summary += fmt.Sprintf(", body %d -> %d chars", len(old.Body), len(new.Body))
+	}
+	if old.Title == new.Title && old.Body == new.Body {
+		summary += ", no changes"
+	}
+	return summary
 }
 
 func HashPassword(password string) (bool, string) {
@@ -675,16 +1117,28 @@ func HashPassword(password string) (bool, string) {
 	return true, string(hashedPassword)
 }
 
+var (
+	commentRouteRe = regexp.MustCompile(`/(create|delete|like)-comment`)
+	adminRouteRe   = regexp.MustCompile(`/(delete|update|create|admin)`)
+	//banAdminRouteRe matches the admin routes only, links with the words elsewhere in the path aren't attacks
+	banAdminRouteRe = regexp.MustCompile(`^/((delete|update|create)$|admin(/|$))`)
+)
+
+//securityMiddleware requires login for comment actions and admin for admin routes, the path is matched
+//without the query string
 func (app *App) securityMiddleware(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if match, _ := regexp.MatchString("/(create|delete)-comment", r.URL.RequestURI()); match {
+		if commentRouteRe.MatchString(r.URL.Path) {
 			if !app.Sessions.IsLoggedin(r) {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				app.renderError(w, r, http.StatusUnauthorized, nil)
 				return
 			}
-		} else if match, _ := regexp.MatchString("/(delete|update|create)", r.URL.RequestURI()); match {
+		} else if adminRouteRe.MatchString(r.URL.Path) {
 			if !app.Sessions.IsAdmin(r) {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				if banAdminRouteRe.MatchString(r.URL.Path) {
+					app.banLog.Write(BanUnauthorized, "", r)
+				}
+				app.renderError(w, r, http.StatusUnauthorized, nil)
 				return
 			}
 		}
@@ -1,12 +1,37 @@
 package app
 
 import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io/fs"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/textproto"
 	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"text/template"
+	"time"
+
+	"github.com/ultramozg/golang-blog-engine/fixtures"
+	"github.com/ultramozg/golang-blog-engine/integrations"
+	"github.com/ultramozg/golang-blog-engine/middleware"
+	"github.com/ultramozg/golang-blog-engine/model"
+	"github.com/ultramozg/golang-blog-engine/router"
+	"github.com/ultramozg/golang-blog-engine/session"
+	"github.com/ultramozg/golang-blog-engine/systemd"
+	"github.com/ultramozg/golang-blog-engine/tracing"
 )
 
 func TestMain(m *testing.M) {
@@ -77,6 +102,56 @@ func TestFailedLogin(t *testing.T) {
 	}
 }
 
+func TestLoginLockout(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+	a.Config.Login.MaxFailures = 3
+	//failures of the earlier runs would lock the addresses out
+	if _, err := a.DB.Exec(`delete from login_attempts where ip in ('198.51.100.7', '198.51.100.8')`); err != nil {
+		t.Fatal(err)
+	}
+
+	name := "lockout-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	try := func(ip string) int {
+		payload := url.Values{}
+		payload.Set("login", name)
+		payload.Set("password", "blabla")
+
+		req, err := http.NewRequest(http.MethodPost, "/login", strings.NewReader(payload.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = ip + ":1234"
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(a.login).ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	for i := 0; i < 3; i++ {
+		if status := try("198.51.100.7"); status != http.StatusUnauthorized {
+			t.Errorf("attempt %v returned wrong status code: got %v want %v", i, status, http.StatusUnauthorized)
+		}
+	}
+	if status := try("198.51.100.8"); status != http.StatusTooManyRequests {
+		t.Errorf("locked account returned wrong status code: got %v want %v", status, http.StatusTooManyRequests)
+	}
+
+	entries, err := model.GetAuditEntries(a.DB, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	locked := false
+	for _, e := range entries {
+		if e.Actor == "anonymous" && e.Action == "account locked" && strings.Contains(e.Summary, strconv.Quote(name)) {
+			locked = true
+		}
+	}
+	if !locked {
+		t.Errorf("account lock wasn't written to the audit log")
+	}
+}
+
 func TestSuccesfullLogin(t *testing.T) {
 	a := NewApp()
 	a.Initialize()
@@ -107,9 +182,115 @@ func TestSuccesfullLogin(t *testing.T) {
 	}
 }
 
+func TestRememberMe(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	payload := url.Values{}
+	payload.Set("login", "admin")
+	payload.Set("password", "12345")
+	payload.Set("remember", "on")
+
+	req, err := http.NewRequest(http.MethodPost, "/login", strings.NewReader(payload.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(a.login).ServeHTTP(rr, req)
+
+	var remember *http.Cookie
+	for _, c := range rr.Result().Cookies() {
+		if c.Name == "remember" {
+			remember = c
+		}
+	}
+	if remember == nil {
+		t.Fatal("login handler hasn't set 'remember' cookie")
+	}
+
+	sessions := func(c *http.Cookie) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, "/admin/sessions", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.AddCookie(c)
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr = sessions(remember)
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("sessions handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	rotated := false
+	for _, c := range rr.Result().Cookies() {
+		if c.Name == "remember" && c.Value != remember.Value && c.MaxAge > 0 {
+			rotated = true
+		}
+	}
+	if !rotated {
+		t.Errorf("remember token hasn't been rotated")
+	}
+
+	//parallel requests carrying the old token are served within the grace period and leave the new cookie alone
+	rr = sessions(remember)
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("rotated remember token within grace period returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	for _, c := range rr.Result().Cookies() {
+		if c.Name == "remember" {
+			t.Errorf("rotated remember token within grace period has set 'remember' cookie: %+v", c)
+		}
+	}
+
+	if _, err := a.DB.Exec(`update remember_tokens set expires = ? where rotated = 1`, time.Now().Add(-time.Second).Unix()); err != nil {
+		t.Fatal(err)
+	}
+	if status := sessions(remember).Code; status != http.StatusUnauthorized {
+		t.Errorf("reused remember token returned wrong status code: got %v want %v", status, http.StatusUnauthorized)
+	}
+}
+
+func TestSessionCookie(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	c := a.Sessions.CreateSession(model.User{Type: session.GITHUB, Name: "tester"}, nil)
+	if !c.HttpOnly || c.SameSite != http.SameSiteLaxMode || c.Path != "/" {
+		t.Errorf("session cookie has wrong attributes: got %+v", c)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(c)
+	if !a.Sessions.IsLoggedin(req) {
+		t.Errorf("session cookie hasn't been accepted")
+	}
+
+	//the middle character is flipped, trailing bits of the last one may be ignored by the decoder
+	forged := *c
+	value := []byte(c.Value)
+	if value[len(value)/2] == 'A' {
+		value[len(value)/2] = 'B'
+	} else {
+		value[len(value)/2] = 'A'
+	}
+	forged.Value = string(value)
+	req, _ = http.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&forged)
+	if a.Sessions.IsLoggedin(req) {
+		t.Errorf("tampered session cookie has been accepted")
+	}
+}
+
 func TestCreatePost(t *testing.T) {
 	a := NewApp()
 	a.Initialize()
+	//the post created by the earlier run would be reported as duplicate
+	if _, err := a.DB.Exec(`delete from posts where title = 'New Post'`); err != nil {
+		t.Fatal(err)
+	}
 
 	payload := url.Values{}
 	payload.Set("login", "admin")
@@ -126,7 +307,7 @@ func TestCreatePost(t *testing.T) {
 
 	req.AddCookie(rr.Result().Cookies()[0])
 
-	// create test post with cookie set
+	//create test post with cookie set
 	payload = url.Values{}
 	payload.Set("title", "New Post")
 	payload.Set("body", "test body")
@@ -196,3 +377,3724 @@ func TestDeletePost(t *testing.T) {
 		t.Errorf("GetPage handler returned wrong status code: got %v want %v", status, http.StatusSeeOther)
 	}
 }
+
+func TestDeletePostRemovesComments(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	p := model.Post{Title: "Post with comments", Body: "body", Date: "date"}
+	if err := p.CreatePost(a.DB); err != nil {
+		t.Fatal(err)
+	}
+	posts, err := model.GetPosts(a.DB, 1, 0)
+	if err != nil || len(posts) == 0 {
+		t.Fatal("Unable to fetch created post", err)
+	}
+	id := posts[0].ID
+
+	c := model.Comment{PostID: id, Name: "tester", Date: "date", Data: "comment"}
+	if err := c.CreateComment(a.DB); err != nil {
+		t.Fatal(err)
+	}
+
+	p = model.Post{ID: id}
+	if _, err := p.DeletePost(a.DB); err != nil {
+		t.Fatal(err)
+	}
+
+	comms, err := model.GetComments(a.DB, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comms) != 0 {
+		t.Errorf("Comments of deleted post still exist: got %v want %v", len(comms), 0)
+	}
+}
+
+func TestCommentsCount(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	p := model.Post{Title: "Counted comments", Body: "body", Date: "date"}
+	if err := p.CreatePost(a.DB); err != nil {
+		t.Fatal(err)
+	}
+	name := "counter-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	for i := 0; i < 2; i++ {
+		c := model.Comment{PostID: p.ID, Name: name, Date: "date", Data: "comment"}
+		if err := c.CreateComment(a.DB); err != nil {
+			t.Fatal(err)
+		}
+	}
+	count := func() int {
+		posts, err := model.GetPosts(a.DB, 1, 0)
+		if err != nil || len(posts) == 0 || posts[0].ID != p.ID {
+			t.Fatal("Unable to fetch created post", err)
+		}
+		return posts[0].Comments
+	}
+	if got := count(); got != 2 {
+		t.Errorf("comments count after create is wrong: got %d want 2", got)
+	}
+
+	comms, err := model.GetComments(a.DB, p.ID)
+	if err != nil || len(comms) != 2 {
+		t.Fatal("Unable to fetch comments", err)
+	}
+	c := model.Comment{CommentID: comms[0].CommentID}
+	if err := c.DeleteComment(a.DB); err != nil {
+		t.Fatal(err)
+	}
+	if got := count(); got != 1 {
+		t.Errorf("comments count after delete is wrong: got %d want 1", got)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "/page?p=0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(a.getPage).ServeHTTP(rr, req)
+	if want := `<a href="/post?id=` + strconv.Itoa(p.ID) + `#comments">&#128172; 1</a>`; !strings.Contains(rr.Body.String(), want) {
+		t.Errorf("posts list doesn't show comments count %s", want)
+	}
+
+	if _, err := model.DeleteUserData(a.DB, name); err != nil {
+		t.Fatal(err)
+	}
+	if got := count(); got != 0 {
+		t.Errorf("comments count after erasing user data is wrong: got %d want 0", got)
+	}
+
+	if _, err := a.DB.Exec(`update posts set comments_count = 5 where id = ?`, p.ID); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := model.RecountComments(a.DB); err != nil || n == 0 {
+		t.Errorf("recount didn't correct drifted count: %d, %v", n, err)
+	}
+	if got := count(); got != 0 {
+		t.Errorf("comments count after recount is wrong: got %d want 0", got)
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	payload := url.Values{}
+	payload.Set("login", "admin")
+	payload.Set("password", "12345")
+
+	req, err := http.NewRequest(http.MethodPost, "/login", strings.NewReader(payload.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	handlerLogin := http.HandlerFunc(a.login)
+	handlerLogin.ServeHTTP(rr, req)
+	cookie := rr.Result().Cookies()[0]
+
+	req, err = http.NewRequest(http.MethodGet, "/admin/audit", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	handlerAudit := http.HandlerFunc(a.auditLog)
+	handlerAudit.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusUnauthorized {
+		t.Errorf("audit handler returned wrong status code without session: got %v want %v", status, http.StatusUnauthorized)
+	}
+
+	req.AddCookie(cookie)
+	rr = httptest.NewRecorder()
+	handlerAudit.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("audit handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	expected := "successful admin login"
+	if !strings.Contains(rr.Body.String(), expected) {
+		t.Errorf("audit handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
+	}
+
+	//names of failed logins come from anonymous clients and must not inject markup into the log
+	hostile := `<script>alert("audit")</script>`
+	payload.Set("login", hostile)
+	req, _ = http.NewRequest(http.MethodPost, "/login", strings.NewReader(payload.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.RemoteAddr = "203.0.113." + strconv.FormatInt(time.Now().UnixNano()%250+1, 10) + ":1234"
+	handlerLogin.ServeHTTP(httptest.NewRecorder(), req)
+
+	req, _ = http.NewRequest(http.MethodGet, "/admin/audit", nil)
+	req.AddCookie(cookie)
+	rr = httptest.NewRecorder()
+	handlerAudit.ServeHTTP(rr, req)
+	if strings.Contains(rr.Body.String(), "<script>alert") {
+		t.Errorf("audit log doesn't escape the login name: %v", rr.Body.String())
+	}
+	if !strings.Contains(rr.Body.String(), "&lt;script&gt;") {
+		t.Errorf("failed login with the name isn't in the audit log: %v", rr.Body.String())
+	}
+}
+
+func TestLikeComment(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	p := model.Post{Title: "Post to like", Body: "body", Date: "date"}
+	if err := p.CreatePost(a.DB); err != nil {
+		t.Fatal(err)
+	}
+	posts, err := model.GetPosts(a.DB, 1, 0)
+	if err != nil || len(posts) == 0 {
+		t.Fatal("Unable to fetch created post", err)
+	}
+	c := model.Comment{PostID: posts[0].ID, Name: "tester", Date: "date", Data: "comment"}
+	if err := c.CreateComment(a.DB); err != nil {
+		t.Fatal(err)
+	}
+	comms, err := model.GetComments(a.DB, posts[0].ID)
+	if err != nil || len(comms) == 0 {
+		t.Fatal("Unable to fetch created comment", err)
+	}
+	id := strconv.Itoa(comms[0].CommentID)
+
+	cookie := a.Sessions.CreateSession(model.User{Type: session.GITHUB, Name: "tester"}, nil)
+	handler := http.HandlerFunc(a.likeComment)
+
+	expected := []int{http.StatusSeeOther, http.StatusTooManyRequests}
+	for _, want := range expected {
+		req, err := http.NewRequest(http.MethodPost, "/like-comment?id="+id, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.AddCookie(cookie)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if status := rr.Code; status != want {
+			t.Errorf("like handler returned wrong status code: got %v want %v", status, want)
+		}
+	}
+
+	comms, err = model.GetComments(a.DB, posts[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if comms[0].Likes != 1 {
+		t.Errorf("comment has wrong likes count: got %v want %v", comms[0].Likes, 1)
+	}
+}
+
+func TestPostLike(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	p := model.Post{Title: "Post to like", Body: "body", Date: "date"}
+	if err := p.CreatePost(a.DB); err != nil {
+		t.Fatal(err)
+	}
+	posts, err := model.GetPosts(a.DB, 1, 0)
+	if err != nil || len(posts) == 0 {
+		t.Fatal("Unable to fetch created post", err)
+	}
+
+	like := func(c *http.Cookie) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodPost, "/api/posts/"+strconv.Itoa(posts[0].ID)+"/like", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.RemoteAddr = "198.51.100.20:1234"
+		if c != nil {
+			req.AddCookie(c)
+		}
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	//the like of a client without cookie isn't counted, it only gets the visitor cookie
+	rr := like(nil)
+	if status := rr.Code; status != http.StatusPreconditionRequired {
+		t.Errorf("post like handler returned wrong status code without cookie: got %v want %v", status, http.StatusPreconditionRequired)
+	}
+	cookies := rr.Result().Cookies()
+	if len(cookies) == 0 || cookies[0].Name != "visitor" {
+		t.Fatalf("post like handler hasn't set visitor cookie: got %v", cookies)
+	}
+
+	rr = like(cookies[0])
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("post like handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	expected := `"likes":1,"liked":true`
+	if !strings.Contains(rr.Body.String(), expected) {
+		t.Errorf("post like handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
+	}
+
+	//reading the likes doesn't issue the visitor cookie
+	get := func(c *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/posts/"+strconv.Itoa(posts[0].ID)+"/like", nil)
+		if c != nil {
+			req.AddCookie(c)
+		}
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, req)
+		return rr
+	}
+	if rr := get(nil); rr.Code != http.StatusOK || len(rr.Result().Cookies()) != 0 || !strings.Contains(rr.Body.String(), `"liked":false`) {
+		t.Errorf("likes of the post read without cookie returned unexpected response: got %v %v %v", rr.Code, rr.Result().Cookies(), rr.Body.String())
+	}
+	if body := get(cookies[0]).Body.String(); !strings.Contains(body, expected) {
+		t.Errorf("likes of the post returned unexpected body: got %v want %v", body, expected)
+	}
+
+	//another visitor cookie from the same ip is limited too
+	other := a.cookies.New("visitor", "other-visitor", 60)
+	if status := like(other).Code; status != http.StatusTooManyRequests {
+		t.Errorf("post like handler returned wrong status code for the same ip: got %v want %v", status, http.StatusTooManyRequests)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	p := model.Post{Title: "Searchable gopher post", Body: "body", Date: "date"}
+	if err := p.CreatePost(a.DB); err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "/search?q=gopher", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(a.search)
+	handler.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("search handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if !strings.Contains(rr.Body.String(), p.Title) {
+		t.Errorf("search handler returned unexpected body: got %v want %v", rr.Body.String(), p.Title)
+	}
+
+	req, err = http.NewRequest(http.MethodGet, "/opensearch.xml", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	handler = http.HandlerFunc(a.openSearch)
+	handler.ServeHTTP(rr, req)
+	expected := "/search?q={searchTerms}"
+	if !strings.Contains(rr.Body.String(), expected) {
+		t.Errorf("opensearch handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
+	}
+}
+
+func TestPostRobots(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	p := model.Post{Title: "Hidden post", Body: "body", Date: "date", NoIndex: true}
+	if err := p.CreatePost(a.DB); err != nil {
+		t.Fatal(err)
+	}
+	posts, err := model.GetPosts(a.DB, 1, 0)
+	if err != nil || len(posts) == 0 {
+		t.Fatal("Unable to fetch created post", err)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "/post?id="+strconv.Itoa(posts[0].ID), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(a.getPost)
+	handler.ServeHTTP(rr, req)
+	if tag := rr.Header().Get("X-Robots-Tag"); tag != "noindex" {
+		t.Errorf("post handler returned wrong X-Robots-Tag: got %v want %v", tag, "noindex")
+	}
+	expected := `<meta name="robots" content="noindex">`
+	if !strings.Contains(rr.Body.String(), expected) {
+		t.Errorf("post handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
+	}
+
+	req, err = http.NewRequest(http.MethodGet, "/robots.txt", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	handler = http.HandlerFunc(a.robotsTxt)
+	handler.ServeHTTP(rr, req)
+	expected = "Disallow: /admin/"
+	if !strings.Contains(rr.Body.String(), expected) {
+		t.Errorf("robots handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
+	}
+}
+
+func TestMembersOnlyPost(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	p := model.Post{Title: "Members post", Body: "teaser <!--more--> members secret", Date: "date", Visibility: model.VisibilityMembers}
+	if err := p.CreatePost(a.DB); err != nil {
+		t.Fatal(err)
+	}
+	posts, err := model.GetPosts(a.DB, 1, 0)
+	if err != nil || len(posts) == 0 {
+		t.Fatal("Unable to fetch created post", err)
+	}
+
+	get := func(c *http.Cookie) string {
+		req, err := http.NewRequest(http.MethodGet, "/post?id="+strconv.Itoa(posts[0].ID), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c != nil {
+			req.AddCookie(c)
+		}
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(a.getPost).ServeHTTP(rr, req)
+		return rr.Body.String()
+	}
+
+	if body := get(nil); strings.Contains(body, "members secret") || !strings.Contains(body, "teaser") {
+		t.Errorf("post handler leaked members content to anonymous reader: got %v", body)
+	}
+	c := a.Sessions.CreateSession(model.User{Type: session.GITHUB, Name: "member"}, nil)
+	if body := get(c); !strings.Contains(body, "members secret") {
+		t.Errorf("post handler returned unexpected body for member: got %v want %v", body, "members secret")
+	}
+
+	//a unique word of the gated part can't be confirmed through the search
+	word := "gated" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	gated := fixtures.Post(t, a.DB, model.Post{Title: "Gated search post", Body: "<p>public teaser</p>\n<p>" + word + "</p>", Visibility: model.VisibilityMembers})
+	search := func(q string, c *http.Cookie) string {
+		req := httptest.NewRequest(http.MethodGet, "/search?q="+url.QueryEscape(q), nil)
+		if c != nil {
+			req.AddCookie(c)
+		}
+		rr := httptest.NewRecorder()
+		a.search(rr, req)
+		return rr.Body.String()
+	}
+	link := "/post?id=" + strconv.Itoa(gated.ID) + `"`
+	if body := search(word, nil); strings.Contains(body, link) {
+		t.Errorf("search matched members content for anonymous reader: %v", body)
+	}
+	if body := search("public teaser", nil); !strings.Contains(body, link) || strings.Contains(body, word) {
+		t.Errorf("search doesn't match only the excerpt for anonymous reader: %v", body)
+	}
+	if body := search(word, c); !strings.Contains(body, link) {
+		t.Errorf("search doesn't match members content for member: %v", body)
+	}
+}
+
+func TestMembersOnlyStructuredData(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	howTo := fixtures.Post(t, a.DB, model.Post{Title: "Members how-to", ContentType: model.ContentHowTo, Visibility: model.VisibilityMembers,
+		Body: "<p>intro</p><!--more--><ol><li>gated step</li></ol>"})
+	faq := fixtures.Post(t, a.DB, model.Post{Title: "Members FAQ", ContentType: model.ContentFAQ, Visibility: model.VisibilityMembers,
+		Body: `<p>intro</p><!--more-->[faq question="Gated question?"]gated answer[/faq]`})
+
+	structuredData := func(id int, c *http.Cookie) string {
+		req := httptest.NewRequest(http.MethodGet, "/post?id="+strconv.Itoa(id), nil)
+		if c != nil {
+			req.AddCookie(c)
+		}
+		rr := httptest.NewRecorder()
+		a.getPost(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("post handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+		var ld []string
+		for _, part := range strings.Split(rr.Body.String(), `<script type="application/ld+json">`)[1:] {
+			ld = append(ld, part[:strings.Index(part, "</script>")])
+		}
+		return strings.Join(ld, "\n")
+	}
+
+	member := a.Sessions.CreateSession(model.User{Type: session.GITHUB, Name: "member"}, nil)
+	for _, tt := range []struct {
+		id   int
+		want string
+	}{
+		{howTo.ID, "gated step"},
+		{faq.ID, "gated answer"},
+	} {
+		if ld := structuredData(tt.id, nil); strings.Contains(ld, "Gated") || strings.Contains(ld, "gated") {
+			t.Errorf("structured data leaked members content to anonymous reader: %v", ld)
+		}
+		if ld := structuredData(tt.id, member); !strings.Contains(ld, tt.want) {
+			t.Errorf("structured data for member doesn't contain %q: %v", tt.want, ld)
+		}
+	}
+}
+
+func TestWorkflow(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	p := model.Post{Title: "Draft post", Body: "draft body", Date: "date", Status: model.StatusDraft}
+	if err := p.CreatePost(a.DB); err != nil {
+		t.Fatal(err)
+	}
+	posts, err := model.GetUnpublishedPosts(a.DB)
+	if err != nil || len(posts) == 0 {
+		t.Fatal("Unable to fetch created draft", err)
+	}
+	p = posts[0]
+
+	get := func(c *http.Cookie) int {
+		req, err := http.NewRequest(http.MethodGet, "/post?id="+strconv.Itoa(p.ID), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c != nil {
+			req.AddCookie(c)
+		}
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(a.getPost).ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if status := get(nil); status != http.StatusNotFound {
+		t.Errorf("post handler returned wrong status code for draft: got %v want %v", status, http.StatusNotFound)
+	}
+	admin := a.Sessions.CreateSession(model.User{Type: session.ADMIN, Name: "admin"}, nil)
+	if status := get(admin); status != http.StatusOK {
+		t.Errorf("post handler returned wrong status code for admin preview: got %v want %v", status, http.StatusOK)
+	}
+
+	if err := p.SetStatus(a.DB, model.StatusApproved, ""); err != model.ErrInvalidStatus {
+		t.Errorf("draft was approved without review: got %v want %v", err, model.ErrInvalidStatus)
+	}
+	for _, status := range []string{model.StatusReview, model.StatusApproved, model.StatusPublished} {
+		if err := p.SetStatus(a.DB, status, "editor"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if status := get(nil); status != http.StatusOK {
+		t.Errorf("post handler returned wrong status code for published post: got %v want %v", status, http.StatusOK)
+	}
+}
+
+func TestNotifications(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	notify(a.DB, NotifyBrokenLinks, "Link check found 1 broken links", "/admin/broken-links")
+
+	call := func(method string, c *http.Cookie) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, "/api/notifications", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c != nil {
+			req.AddCookie(c)
+		}
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if status := call(http.MethodGet, nil).Code; status != http.StatusUnauthorized {
+		t.Errorf("notifications handler returned wrong status code: got %v want %v", status, http.StatusUnauthorized)
+	}
+
+	admin := a.Sessions.CreateSession(model.User{Type: session.ADMIN, Name: "admin"}, nil)
+	var data struct {
+		Unread int `json:"unread"`
+	}
+	if err := json.Unmarshal(call(http.MethodGet, admin).Body.Bytes(), &data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Unread == 0 {
+		t.Errorf("notifications handler returned no unread notifications")
+	}
+
+	if err := json.Unmarshal(call(http.MethodPost, admin).Body.Bytes(), &data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Unread != 0 {
+		t.Errorf("notifications weren't marked as read: got %v want %v", data.Unread, 0)
+	}
+}
+
+func TestRecentPosts(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	for i := 0; i < 3; i++ {
+		p := model.Post{Title: "Polled post", Body: "body", Date: time.Now().Format("Mon Jan _2 15:04:05 2006")}
+		if err := p.CreatePost(a.DB); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var page struct {
+		Items []struct {
+			ID        int    `json:"id"`
+			Published string `json:"published"`
+		} `json:"items"`
+		NextCursor string `json:"next_cursor"`
+	}
+	get := func(query string) {
+		req, err := http.NewRequest(http.MethodGet, "/api/v1/posts/recent?"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(a.recentPosts).ServeHTTP(rr, req)
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("recent posts handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		page.NextCursor = ""
+		if err := json.Unmarshal(rr.Body.Bytes(), &page); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	get("limit=2")
+	if len(page.Items) != 2 || page.NextCursor == "" {
+		t.Fatalf("recent posts handler returned unexpected page: got %+v", page)
+	}
+	if _, err := time.Parse(time.RFC3339, page.Items[0].Published); err != nil {
+		t.Errorf("recent posts handler returned non ISO date: got %v", page.Items[0].Published)
+	}
+	last := page.Items[1].ID
+
+	get("limit=2&cursor=" + page.NextCursor)
+	if len(page.Items) == 0 || page.Items[0].ID >= last {
+		t.Errorf("recent posts cursor returned overlapping page: got %+v after id %v", page.Items, last)
+	}
+}
+
+func TestHeadless(t *testing.T) {
+	os.Setenv("HEADLESS", "true")
+	defer os.Unsetenv("HEADLESS")
+	a := NewApp()
+	a.Initialize()
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/page?p=0", http.StatusNotFound},
+		{"/robots.txt", http.StatusNotFound},
+		{"/api/emoji", http.StatusOK},
+		{"/api/v1/posts/recent", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(http.MethodGet, tt.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, req)
+		if status := rr.Code; status != tt.status {
+			t.Errorf("headless router returned wrong status code for %v: got %v want %v", tt.path, status, tt.status)
+		}
+	}
+}
+
+func TestCORS(t *testing.T) {
+	os.Setenv("CORS_ALLOWED_ORIGINS", "https://example.com")
+	defer os.Unsetenv("CORS_ALLOWED_ORIGINS")
+	a := NewApp()
+	a.Initialize()
+
+	req, err := http.NewRequest(http.MethodOptions, "/api/emoji", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Origin", "https://example.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	rr := httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusNoContent {
+		t.Errorf("preflight returned wrong status code: got %v want %v", status, http.StatusNoContent)
+	}
+	if origin := rr.Header().Get("Access-Control-Allow-Origin"); origin != "https://example.com" {
+		t.Errorf("preflight returned wrong allowed origin: got %v want %v", origin, "https://example.com")
+	}
+
+	req, err = http.NewRequest(http.MethodGet, "/api/emoji", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Origin", "https://evil.example")
+	rr = httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, req)
+	if origin := rr.Header().Get("Access-Control-Allow-Origin"); origin != "" {
+		t.Errorf("disallowed origin got CORS headers: got %v", origin)
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	req, err := http.NewRequest(http.MethodGet, "/api/openapi.json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("openapi handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			Responses map[string]struct {
+				Content map[string]struct {
+					Schema struct {
+						Properties map[string]struct {
+							Type  string `json:"type"`
+							Items struct {
+								Properties map[string]interface{} `json:"properties"`
+							} `json:"items"`
+						} `json:"properties"`
+					} `json:"schema"`
+				} `json:"content"`
+			} `json:"responses"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI != "3.1.0" {
+		t.Errorf("openapi handler returned wrong version: got %v want %v", doc.OpenAPI, "3.1.0")
+	}
+	items := doc.Paths["/api/v1/posts/recent"]["get"].Responses["200"].Content["application/json"].Schema.Properties["items"]
+	if items.Type != "array" || items.Items.Properties["id"] == nil {
+		t.Errorf("openapi document has wrong schema of recent posts: got %+v", items)
+	}
+	for _, op := range a.apiRoutes() {
+		if _, ok := doc.Paths[op.Path][strings.ToLower(op.Method)]; !ok {
+			t.Errorf("openapi document lacks %v %v", op.Method, op.Path)
+		}
+	}
+}
+
+func TestSettings(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+	defer a.settings.Save(a.settings.defaults)
+
+	admin := a.Sessions.CreateSession(model.User{Type: session.ADMIN, Name: "admin"}, nil)
+	payload := url.Values{}
+	payload.Set("site_title", "Test Blog")
+	payload.Set("posts_per_page", "3")
+	payload.Set("comment_policy", CommentsClosed)
+	payload.Set("author_name", "Jane Doe")
+	payload.Set("author_same_as", "https://github.com/jane\nhttps://twitter.com/jane")
+	payload.Set("publisher_name", "Doe Media")
+	payload.Set("publisher_logo", "/public/img/logo.png")
+
+	req, err := http.NewRequest(http.MethodPost, "/admin/settings", strings.NewReader(payload.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(admin)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(a.saveSiteSettings).ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusSeeOther {
+		t.Errorf("settings handler returned wrong status code: got %v want %v", status, http.StatusSeeOther)
+	}
+
+	if s := a.settings.Get(); s.SiteTitle != "Test Blog" || s.PostsPerPage != 3 || s.CommentsOpen() {
+		t.Errorf("settings weren't saved: got %+v", s)
+	}
+
+	data := a.postStructuredData(req, model.Post{ID: 1, Title: "Post"})
+	for _, want := range []string{
+		`"author":{"@type":"Person","name":"Jane Doe","sameAs":["https://github.com/jane","https://twitter.com/jane"]}`,
+		`"publisher":{"@type":"Organization","logo":{"@type":"ImageObject","url":"http:///public/img/logo.png"},"name":"Doe Media"}`,
+	} {
+		if !strings.Contains(data, want) {
+			t.Errorf("structured data doesn't contain %s: got %s", want, data)
+		}
+	}
+
+	req, err = http.NewRequest(http.MethodGet, "/page?p=0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(a.getPage).ServeHTTP(rr, req)
+	expected := "<title>Test Blog</title>"
+	if !strings.Contains(rr.Body.String(), expected) {
+		t.Errorf("page handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
+	}
+}
+
+func TestPinnedPosts(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	pinned := model.Post{Title: "Pinned post", Body: "body", Date: "date", Pinned: true}
+	if err := pinned.CreatePost(a.DB); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		p := model.Post{Title: "Newer post", Body: "body", Date: "date"}
+		if err := p.CreatePost(a.DB); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, sort := range []string{model.SortPublished, model.SortUpdated} {
+		posts, _, err := model.GetPostSummaries(a.DB, 0, 2, sort)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(posts) == 0 || posts[0].Title != "Pinned post" {
+			t.Errorf("pinned post isn't on top of the first page sorted by %v: got %+v", sort, posts)
+		}
+	}
+
+	//unpin so other tests see the newest post first
+	posts, _, err := model.GetPostSummaries(a.DB, 0, 1, model.SortPublished)
+	if err != nil || len(posts) == 0 {
+		t.Fatal("Unable to fetch pinned post", err)
+	}
+	pinned = model.Post{ID: posts[0].ID}
+	if err := pinned.GetPost(a.DB); err != nil {
+		t.Fatal(err)
+	}
+	pinned.Pinned = false
+	if err := pinned.UpdatePost(a.DB); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPostSummaries(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	p := model.Post{Title: "Summarized post", Body: "<p>Intro</p><!--more--><p>" + strings.Repeat("long ", 300) + "</p>", Date: "date"}
+	if err := p.CreatePost(a.DB); err != nil {
+		t.Fatal(err)
+	}
+	c := model.Comment{PostID: p.ID, Name: "tester", Date: "date", Data: "comment"}
+	if err := c.CreateComment(a.DB); err != nil {
+		t.Fatal(err)
+	}
+
+	published, err := model.CountPosts(a.DB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	posts, total, err := model.GetPostSummaries(a.DB, 0, published, model.SortPublished)
+	if err != nil || len(posts) != published {
+		t.Fatal("Unable to fetch post summaries", err)
+	}
+	//posts left by the earlier runs may be listed first
+	var summary *model.Post
+	for i := range posts {
+		if posts[i].ID == p.ID {
+			summary = &posts[i]
+		}
+	}
+	if summary == nil || summary.Body != "<p>Intro</p>" || summary.Comments != 1 {
+		t.Errorf("wrong summary of the post: got %+v", summary)
+	}
+	if total != published {
+		t.Errorf("wrong total of post summaries: got %d want %d", total, published)
+	}
+
+	if posts, total, err := model.GetPostSummaries(a.DB, total, 1, model.SortPublished); err != nil || len(posts) != 0 || total != 0 {
+		t.Errorf("page past the last one isn't empty: got %d posts of %d, %v", len(posts), total, err)
+	}
+}
+
+func TestPostList(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	for i := 0; i <= a.settings.Get().PostsPerPage; i++ {
+		p := model.Post{Title: "Scroll post", Body: "body", Date: "date"}
+		if err := p.CreatePost(a.DB); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var cards struct {
+		Items      []pollPost `json:"items"`
+		HTML       string     `json:"html"`
+		NextCursor string     `json:"next_cursor"`
+	}
+	get := func(query string) int {
+		req, err := http.NewRequest(http.MethodGet, "/api/posts?"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(a.postList).ServeHTTP(rr, req)
+		if rr.Code == http.StatusOK {
+			cards.NextCursor = ""
+			if err := json.Unmarshal(rr.Body.Bytes(), &cards); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rr.Code
+	}
+
+	if status := get("format=html"); status != http.StatusOK {
+		t.Fatalf("post list handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if !strings.Contains(cards.HTML, "Scroll post") || cards.NextCursor == "" {
+		t.Errorf("post list handler returned unexpected html page: got %+v", cards)
+	}
+
+	if status := get("cursor=" + cards.NextCursor); status != http.StatusOK {
+		t.Fatalf("post list handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if len(cards.Items) == 0 {
+		t.Errorf("post list handler returned empty second page")
+	}
+
+	if status := get("cursor=%25"); status != http.StatusBadRequest {
+		t.Errorf("post list handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+}
+
+func TestFeaturedPosts(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	//featured posts left by the earlier runs may be listed too, only the created ones are checked
+	var ids []int
+	for _, title := range []string{"First featured", "Second featured"} {
+		p := model.Post{Title: title, Body: "body", Date: "date", Featured: true}
+		if err := p.CreatePost(a.DB); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, p.ID)
+	}
+	defer func() {
+		for _, id := range ids {
+			p := model.Post{ID: id}
+			p.DeletePost(a.DB)
+		}
+	}()
+
+	call := func(method, body string, c *http.Cookie) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, "/api/featured", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c != nil {
+			req.AddCookie(c)
+		}
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	order := `{"ids":[` + strconv.Itoa(ids[1]) + `,` + strconv.Itoa(ids[0]) + `]}`
+	if status := call(http.MethodPost, order, nil).Code; status != http.StatusUnauthorized {
+		t.Errorf("featured handler returned wrong status code: got %v want %v", status, http.StatusUnauthorized)
+	}
+
+	admin := a.Sessions.CreateSession(model.User{Type: session.ADMIN, Name: "admin"}, nil)
+	if status := call(http.MethodPost, order, admin).Code; status != http.StatusOK {
+		t.Fatalf("featured handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var featured []featuredPost
+	if err := json.Unmarshal(call(http.MethodGet, "", nil).Body.Bytes(), &featured); err != nil {
+		t.Fatal(err)
+	}
+	var got []int
+	for _, f := range featured {
+		if f.ID == ids[0] || f.ID == ids[1] {
+			got = append(got, f.ID)
+		}
+	}
+	if len(got) != 2 || got[0] != ids[1] || got[1] != ids[0] {
+		t.Errorf("featured posts are in wrong order: got %v want ids %v, %v", got, ids[1], ids[0])
+	}
+}
+
+func TestSEOPreview(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	form := url.Values{
+		"title": {"Embedding templates in Go"},
+		"body":  {"<p>Templates can be embedded into the binary, embedded templates make deploys simple.</p>"},
+	}
+	call := func(c *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/seo-preview", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if c != nil {
+			req.AddCookie(c)
+		}
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(a.seoPreviewHandler).ServeHTTP(rr, req)
+		return rr
+	}
+
+	if status := call(nil).Code; status != http.StatusUnauthorized {
+		t.Errorf("SEO preview handler returned wrong status code: got %v want %v", status, http.StatusUnauthorized)
+	}
+
+	admin := a.Sessions.CreateSession(model.User{Type: session.ADMIN, Name: "admin"}, nil)
+	rr := call(admin)
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("SEO preview handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	var p seoPreview
+	if err := json.Unmarshal(rr.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if !p.Unsaved || p.URL != "http://example.com/post?id=" || !strings.HasPrefix(p.Description, "Templates can be embedded") ||
+		len(p.Keywords) == 0 || !strings.Contains(p.HTML, "serp-preview") {
+		t.Errorf("SEO preview handler returned unexpected preview: got %+v", p)
+	}
+
+	//preview of the saved post matches the head of its page
+	post := fixtures.Post(t, a.DB, model.Post{Title: form.Get("title"), Body: form.Get("body"), CoverImage: "/public/img/cover.png"})
+	form.Set("id", strconv.Itoa(post.ID))
+	form.Set("cover_image", post.CoverImage)
+	p = seoPreview{}
+	if err := json.Unmarshal(call(admin).Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/post?id="+strconv.Itoa(post.ID), nil)
+	page := httptest.NewRecorder()
+	a.getPost(page, req)
+	for _, want := range []string{
+		"<title>" + p.PageTitle + "</title>",
+		`<meta property="og:title" content="` + p.Title + `">`,
+		`<link rel="canonical" href="` + p.URL + `" />`,
+		`<meta name="description" content="` + p.Description + `">`,
+		`<meta property="og:description" content="` + p.Description + `">`,
+		`<meta property="og:image" content="` + p.Image + `">`,
+	} {
+		if !strings.Contains(page.Body.String(), want) {
+			t.Errorf("post page head doesn't match the SEO preview, missing %s", want)
+		}
+	}
+	if p.Unsaved || p.URL != "http://example.com/post?id="+strconv.Itoa(post.ID) {
+		t.Errorf("SEO preview of the saved post has wrong url: got %+v", p)
+	}
+}
+
+func TestCanonicalURL(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	canonical := "https://example.com/original-post"
+	p := model.Post{Title: "Republished post", Body: "body", Date: "date", CanonicalURL: canonical}
+	if err := p.CreatePost(a.DB); err != nil {
+		t.Fatal(err)
+	}
+	posts, err := model.GetRecentPosts(a.DB, 0, 1)
+	if err != nil || len(posts) == 0 {
+		t.Fatal("Unable to fetch republished post", err)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "/post?id="+strconv.Itoa(posts[0].ID), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(a.getPost).ServeHTTP(rr, req)
+
+	if link, want := rr.Header().Get("Link"), "<"+canonical+`>; rel="canonical"`; link != want {
+		t.Errorf("post handler returned wrong Link header: got %q want %q", link, want)
+	}
+	if !strings.Contains(rr.Body.String(), `<link rel="canonical" href="`+canonical+`" />`) {
+		t.Errorf("post page doesn't contain canonical link")
+	}
+	if !strings.Contains(rr.Body.String(), `<li aria-current="page">Republished post</li>`) {
+		t.Errorf("post page doesn't contain breadcrumbs")
+	}
+
+	if validCanonicalURL("/relative") || validCanonicalURL("javascript:alert(1)") || !validCanonicalURL("") {
+		t.Errorf("canonical url validation accepts invalid urls or rejects empty override")
+	}
+}
+
+func TestPaginationSEO(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	perPage := a.settings.Get().PostsPerPage
+	for i := 0; i < perPage; i++ {
+		p := model.Post{Title: "Paginated post", Body: "body", Date: "date"}
+		if err := p.CreatePost(a.DB); err != nil {
+			t.Fatal(err)
+		}
+	}
+	total, err := model.CountPosts(a.DB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := pageCount(total, perPage) - 1
+
+	get := func(p string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, "/page?p="+p, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Host = "example.com"
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(a.getPage).ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("0")
+	body := rr.Body.String()
+	if !strings.Contains(body, `<link rel="canonical" href="http://example.com/page?p=0" />`) {
+		t.Errorf("first page doesn't contain canonical link")
+	}
+	if strings.Contains(body, `rel="prev"`) || !strings.Contains(body, `<link rel="next" href="http://example.com/page?p=1" />`) {
+		t.Errorf("first page has wrong prev or next links")
+	}
+
+	rr = get(strconv.Itoa(last))
+	body = rr.Body.String()
+	if rr.Code != http.StatusOK || !strings.Contains(body, `<link rel="prev" href="http://example.com/page?p=`+strconv.Itoa(last-1)+`" />`) {
+		t.Errorf("last page returned %d or has no prev link", rr.Code)
+	}
+	if strings.Contains(body, `rel="next"`) {
+		t.Errorf("last page has next link")
+	}
+
+	for _, p := range []string{"-1", strconv.Itoa(last + 1)} {
+		if rr := get(p); rr.Code != http.StatusNotFound {
+			t.Errorf("page %s returned wrong status code: got %v want %v", p, rr.Code, http.StatusNotFound)
+		}
+	}
+
+	if isNextPage(0, perPage, perPage) || !isNextPage(0, perPage+1, perPage) || pageCount(0, perPage) != 1 {
+		t.Errorf("page arithmetic is off by one")
+	}
+}
+
+func TestStructuredDataTypes(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+	req, err := http.NewRequest(http.MethodGet, "/post?id=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		post model.Post
+		want []string
+	}{
+		{model.Post{ID: 1, Title: "Post"}, []string{`"@type":"BlogPosting"`}},
+		{model.Post{ID: 1, Title: "Pasta", ContentType: model.ContentRecipe, Body: "<ol><li>Boil water</li></ol>",
+			Schema: map[string]string{"recipeIngredient": "pasta\nwater", "cookTime": "PT10M"}},
+			[]string{`"@type":"Recipe"`, `"recipeIngredient":["pasta","water"]`, `"cookTime":"PT10M"`, `"text":"Boil water"`}},
+		{model.Post{ID: 1, Title: "FAQ", ContentType: model.ContentFAQ, Body: `[faq question="Why?"]Because.[/faq]`},
+			[]string{`"@type":"FAQPage"`, `"name":"Why?"`, `"text":"Because."`}},
+		{model.Post{ID: 1, Title: "Tool", ContentType: model.ContentSoftware, Schema: map[string]string{"programmingLanguage": "Go"}},
+			[]string{`"@type":"SoftwareSourceCode"`, `"programmingLanguage":"Go"`}},
+	}
+	for _, tt := range tests {
+		got := a.postStructuredData(req, tt.post)
+		for _, want := range tt.want {
+			if !strings.Contains(got, want) {
+				t.Errorf("structured data of %v post doesn't contain %s: got %s", tt.post.ContentType, want, got)
+			}
+		}
+	}
+}
+
+func TestBreadcrumbs(t *testing.T) {
+	crumbs := []breadcrumb{{"Home", "https://example.com/"}, {"Post", ""}}
+	got := breadcrumbData(crumbs, "https://example.com/post?id=1")
+	for _, want := range []string{
+		`"@type":"BreadcrumbList"`,
+		`{"@type":"ListItem","item":"https://example.com/","name":"Home","position":1}`,
+		`{"@type":"ListItem","item":"https://example.com/post?id=1","name":"Post","position":2}`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("breadcrumb data doesn't contain %s: got %s", want, got)
+		}
+	}
+}
+
+func TestMultiDomain(t *testing.T) {
+	os.Setenv("DOMAINS", "example.com=en, example.de=de")
+	defer os.Unsetenv("DOMAINS")
+	a := NewApp()
+	a.Initialize()
+
+	if hosts := a.Config.Hosts(); len(hosts) != 2 || hosts[1] != "example.de" {
+		t.Errorf("configured hosts are wrong: got %v", hosts)
+	}
+
+	p := model.Post{Title: "Localized post", Body: "body", Date: "date"}
+	if err := p.CreatePost(a.DB); err != nil {
+		t.Fatal(err)
+	}
+	posts, err := model.GetRecentPosts(a.DB, 0, 1)
+	if err != nil || len(posts) == 0 {
+		t.Fatal("Unable to fetch localized post", err)
+	}
+	path := "/post?id=" + strconv.Itoa(posts[0].ID)
+
+	req, err := http.NewRequest(http.MethodGet, path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Host = "example.de:443"
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(a.getPost).ServeHTTP(rr, req)
+
+	for _, want := range []string{
+		`<link rel="canonical" href="https://example.de` + path + `" />`,
+		`<link rel="alternate" hreflang="en" href="https://example.com` + path + `" />`,
+		`<link rel="alternate" hreflang="de" href="https://example.de` + path + `" />`,
+		`<link rel="alternate" hreflang="x-default" href="https://example.com` + path + `" />`,
+	} {
+		if !strings.Contains(rr.Body.String(), want) {
+			t.Errorf("post page doesn't contain %s", want)
+		}
+	}
+}
+
+func TestNormalizeURL(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	tests := []struct {
+		method, url, location string
+	}{
+		{http.MethodGet, "/About/", "/about"},
+		{http.MethodGet, "//post?id=1", "/post?id=1"},
+		{http.MethodGet, "/index.html", "/"},
+		{http.MethodHead, "/links/index.html", "/links"},
+		{http.MethodGet, "/public/css/Custom.css", ""},
+		{http.MethodGet, "/api/posts/", ""},
+		{http.MethodPost, "/Search/", ""},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.url, nil))
+
+		if tt.location == "" {
+			if location := rr.Header().Get("Location"); location != "" {
+				t.Errorf("%s %s was redirected to %s", tt.method, tt.url, location)
+			}
+			continue
+		}
+		if rr.Code != http.StatusMovedPermanently || rr.Header().Get("Location") != tt.location {
+			t.Errorf("%s %s got %v %q want %v %q", tt.method, tt.url, rr.Code, rr.Header().Get("Location"), http.StatusMovedPermanently, tt.location)
+		}
+	}
+}
+
+func TestNotFoundPage(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	p := model.Post{Title: "Understanding Go interfaces", Body: "body", Date: "date"}
+	if err := p.CreatePost(a.DB); err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "/understandng-go-interfaces", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("missing page returned wrong status code: got %v want %v", status, http.StatusNotFound)
+	}
+	if !strings.Contains(rr.Body.String(), ">Understanding Go interfaces</a>") {
+		t.Errorf("not found page doesn't suggest similar post")
+	}
+
+	list, err := model.GetNotFound(a.DB, NotFoundListLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, n := range list {
+		found = found || n.Path == "/understandng-go-interfaces"
+	}
+	if !found {
+		t.Errorf("missing page hit wasn't recorded: got %+v", list)
+	}
+
+	if s := similarity("go-interfaces", "understanding-go-interfaces"); s < MinSuggestionScore {
+		t.Errorf("partial slug similarity is too low: got %v", s)
+	}
+	if s := similarity("contact", "understanding-go-interfaces"); s >= MinSuggestionScore {
+		t.Errorf("unrelated slug similarity is too high: got %v", s)
+	}
+}
+
+func TestRedirects(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	admin := a.Sessions.CreateSession(model.User{Type: session.ADMIN, Name: "admin"}, nil)
+	save := func(form url.Values) int {
+		req, err := http.NewRequest(http.MethodPost, "/admin/redirects", strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(admin)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(a.saveRedirect).ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if status := save(url.Values{"source": {"/Old/Blog.aspx"}, "destination": {"/post?id=1"}, "status": {"301"}}); status != http.StatusSeeOther {
+		t.Fatalf("redirects handler returned wrong status code: got %v want %v", status, http.StatusSeeOther)
+	}
+	defer model.DeleteRedirect(a.DB, "/Old/Blog.aspx")
+	if status := save(url.Values{"source": {"old"}, "destination": {"/"}, "status": {"301"}}); status != http.StatusBadRequest {
+		t.Errorf("redirects handler accepted relative source: got %v want %v", status, http.StatusBadRequest)
+	}
+
+	rr := httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/Old/Blog.aspx?ref=feed", nil))
+	if rr.Code != http.StatusMovedPermanently || rr.Header().Get("Location") != "/post?id=1" {
+		t.Errorf("redirect wasn't applied: got %v %q", rr.Code, rr.Header().Get("Location"))
+	}
+
+	rd, err := model.FindRedirect(a.DB, "/Old/Blog.aspx")
+	if err != nil || rd.Hits != 1 {
+		t.Errorf("redirect hit wasn't counted: got %+v, %v", rd, err)
+	}
+}
+
+func TestReadOnlyMode(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	admin := a.Sessions.CreateSession(model.User{Type: session.ADMIN, Name: "admin"}, nil)
+	set := func(enabled string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/read-only", strings.NewReader("enabled="+enabled))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(admin)
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if status := set("true"); status != http.StatusOK {
+		t.Fatalf("read-only handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	defer a.readOnly.Set(false)
+
+	tests := []struct {
+		method, url string
+		status      int
+	}{
+		{http.MethodPost, "/create-comment", http.StatusServiceUnavailable},
+		{http.MethodGet, "/delete?id=1", http.StatusServiceUnavailable},
+		{http.MethodGet, "/page?p=0", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.url, nil)
+		req.AddCookie(admin)
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, req)
+		if rr.Code != tt.status {
+			t.Errorf("%s %s in read-only mode returned wrong status code: got %v want %v", tt.method, tt.url, rr.Code, tt.status)
+		}
+	}
+
+	if status := set("false"); status != http.StatusOK || a.readOnly.Enabled() {
+		t.Errorf("read-only mode wasn't turned off: got %v", status)
+	}
+}
+
+func TestAuditPostsSEO(t *testing.T) {
+	long := "This post has enough text in the body to build a reasonable meta description. "
+	posts := []model.Post{
+		{ID: 1, Title: "Same title", Body: long + `<a href="/post?id=42">gone</a>`},
+		{ID: 2, Title: "Same title", Body: long + `<img src="/public/pic.png">`},
+		{ID: 3, Title: strings.Repeat("very long title ", 5), Body: "short"},
+	}
+
+	kinds := map[string]int{}
+	for _, issue := range auditPostsSEO(posts) {
+		kinds[issue.Kind]++
+	}
+
+	expected := map[string]int{
+		"duplicate title":          2,
+		"broken internal link":     1,
+		"image without alt":        1,
+		"long title":               1,
+		"missing meta description": 1,
+	}
+	for kind, want := range expected {
+		if got := kinds[kind]; got != want {
+			t.Errorf("SEO audit found wrong number of %q issues: got %v want %v", kind, got, want)
+		}
+	}
+}
+
+func TestLinkChecker(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ok" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	p := model.Post{Title: "Post with links", Body: `<a href="` + srv.URL + `/ok">ok</a> <a href="` + srv.URL + `/missing">missing</a>`, Date: "date"}
+	if err := p.CreatePost(a.DB); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := newLinkChecker(a.DB, 0, time.Hour).Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	broken, err := model.GetBrokenLinks(a.DB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//links of the posts left by the earlier runs point to closed servers
+	var links []model.LinkCheck
+	for _, l := range broken {
+		if l.PostID == p.ID {
+			links = append(links, l)
+		}
+	}
+	if len(links) != 1 || links[0].URL != srv.URL+"/missing" || links[0].Status != http.StatusNotFound {
+		t.Errorf("link checker found unexpected broken links: got %v want %v", links, srv.URL+"/missing")
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	done := make(chan struct{}, 1)
+	s := newScheduler(a.DB, 0, time.Local)
+	s.Register("test-job", time.Hour, true, func() error {
+		done <- struct{}{}
+		return nil
+	})
+	s.Register("disabled-job", time.Hour, false, func() error {
+		t.Error("disabled job has been executed")
+		return nil
+	})
+	s.Start()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("scheduled job hasn't been executed")
+	}
+	s.Stop()
+
+	runs, err := model.GetJobRuns(a.DB, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].Name != "test-job" || runs[0].Error != "" {
+		t.Errorf("scheduler saved unexpected job run: got %v want %v", runs, "test-job")
+	}
+	if s.Context().Err() == nil {
+		t.Error("context of the scheduler isn't done after Stop")
+	}
+
+	//housekeeping jobs are turned off by their JOB_ settings
+	t.Setenv("JOB_POST_EXPIRY", "false")
+	t.Setenv("JOB_COMMENTS_RECOUNT", "false")
+	b := NewApp()
+	b.Initialize()
+	registered := map[string]bool{}
+	for _, j := range b.jobs.jobs {
+		registered[j.Name] = true
+	}
+	if registered["post-expiry"] || registered["comments-recount"] || !registered["login-cleanup"] {
+		t.Errorf("jobs aren't registered by their settings: %v", registered)
+	}
+
+	//link check stops mid-run once the context is done
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer slow.Close()
+	slowPost := fixtures.Post(t, a.DB, model.Post{Title: "Slow links", Body: `<a href="` + slow.URL + `/one">one</a> <a href="` + slow.URL + `/two">two</a>`})
+	defer slowPost.DeletePost(a.DB)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	started := time.Now()
+	if err := newLinkChecker(a.DB, time.Minute, time.Hour).Run(ctx); err != context.Canceled {
+		t.Errorf("link check didn't stop with the context: %v", err)
+	}
+	if d := time.Since(started); d > 3*time.Second {
+		t.Errorf("link check took %s to stop", d)
+	}
+}
+
+func TestRouter(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	tests := []struct {
+		method, path string
+		status       int
+		allow        string
+	}{
+		{http.MethodGet, "/about", http.StatusOK, ""},
+		{http.MethodHead, "/about", http.StatusOK, ""},
+		{http.MethodPost, "/about", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS"},
+		{http.MethodOptions, "/api/read-only", http.StatusNoContent, "GET, HEAD, OPTIONS, POST"},
+		{http.MethodDelete, "/api/featured", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS, POST"},
+		{http.MethodGet, "/api/posts/abc/like", http.StatusBadRequest, ""},
+		{http.MethodGet, "/api/posts/999999/like", http.StatusNotFound, ""},
+		{http.MethodGet, "/api/posts/1/unknown", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, req)
+		if rr.Code != tt.status {
+			t.Errorf("router returned wrong status code for %v %v: got %v want %v", tt.method, tt.path, rr.Code, tt.status)
+		}
+		if allow := rr.Header().Get("Allow"); allow != tt.allow {
+			t.Errorf("router returned wrong Allow header for %v %v: got %q want %q", tt.method, tt.path, allow, tt.allow)
+		}
+	}
+
+	rt := router.New()
+	rt.Get("/files/{uuid}/thumbnail", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("thumbnail " + router.Param(r, "uuid")))
+	})
+	rt.Get("/files/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("file " + router.Param(r, "path")))
+	})
+	rt.Get("/files/new", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("new"))
+	})
+	for path, expected := range map[string]string{
+		"/files/42/thumbnail": "thumbnail 42",
+		"/files/42/a/b.png":   "file 42/a/b.png",
+		"/files/new":          "new",
+	} {
+		rr := httptest.NewRecorder()
+		rt.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		if rr.Body.String() != expected {
+			t.Errorf("router served wrong handler for %v: got %q want %q", path, rr.Body.String(), expected)
+		}
+	}
+}
+
+func TestRenderError(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/posts/abc/like", nil)
+	req.Header.Set("X-Request-Id", "test-request-1")
+	rr := httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("api error returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+	if id := rr.Header().Get("X-Request-Id"); id != "test-request-1" {
+		t.Errorf("request id of the proxy wasn't kept: got %q want %q", id, "test-request-1")
+	}
+	var body errorResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	expected := errorResponse{http.StatusBadRequest, "Invalid post id", "test-request-1"}
+	if body != expected {
+		t.Errorf("api error returned unexpected body: got %+v want %+v", body, expected)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/jobs", nil)
+	rr = httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("page error returned wrong status code: got %v want %v", rr.Code, http.StatusUnauthorized)
+	}
+	id := rr.Header().Get("X-Request-Id")
+	if id == "" {
+		t.Error("request id wasn't generated")
+	}
+	for _, want := range []string{"<h4>401 Unauthorized</h4>", "<code>" + id + "</code>", "</html>"} {
+		if !strings.Contains(rr.Body.String(), want) {
+			t.Errorf("error page doesn't contain %q: got %v", want, rr.Body.String())
+		}
+	}
+
+	rr = httptest.NewRecorder()
+	a.renderError(rr, httptest.NewRequest(http.MethodGet, "/api/featured", nil), http.StatusInternalServerError, errors.New("database is locked"))
+	if strings.Contains(rr.Body.String(), "database is locked") {
+		t.Errorf("server error details leaked to the client: got %v", rr.Body.String())
+	}
+
+	//405 of the router is rendered like other errors
+	rr = httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/api/featured", nil))
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || body.Status != http.StatusMethodNotAllowed || rr.Header().Get("Allow") == "" {
+		t.Errorf("method not allowed isn't rendered as api error: %v %v", rr.Body.String(), err)
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	if err := a.CheckTemplates(); err != nil {
+		t.Errorf("templates check failed: %v", err)
+	}
+
+	temp, err := a.Temp.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.Must(temp.New("broken.gohtml").Parse(`half written page {{.Missing}}`))
+	template.Must(temp.New("include.gohtml").Parse(`{{if .}}{{template "missing"}}{{end}}`))
+	a.Temp = temp
+
+	rr := httptest.NewRecorder()
+	a.renderTemplate(rr, httptest.NewRequest(http.MethodGet, "/broken", nil), "broken.gohtml", struct{}{})
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("broken template returned wrong status code: got %v want %v", rr.Code, http.StatusInternalServerError)
+	}
+	if strings.Contains(rr.Body.String(), "half written page") {
+		t.Errorf("broken template was partially written: got %v", rr.Body.String())
+	}
+
+	if name := undefinedTemplate(temp, temp.Lookup("include.gohtml").Tree.Root); name != "missing" {
+		t.Errorf("undefined template wasn't found: got %q want %q", name, "missing")
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	c := newConfig()
+	if err := c.Validate(); err != nil {
+		t.Errorf("default test configuration is invalid: %v", err)
+	}
+
+	f, err := ioutil.TempFile("", "cert")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	c.Production = "true"
+	c.Domain = ""
+	c.Sites = nil
+	c.CertDir = f.Name()
+	c.OAuth.ClientID = "client"
+	c.Templates = "../templates/*.missing"
+	c.Cookie.SameSite = "none"
+
+	err = c.Validate()
+	problems, ok := err.(ConfigError)
+	if !ok {
+		t.Fatalf("invalid configuration wasn't reported: got %v", err)
+	}
+	for _, want := range []string{"DOMAIN", "CERT_DIR", "CLIENT_SECRET", "TEMPLATES", "COOKIE_SAMESITE"} {
+		if !strings.Contains(problems.Error(), want) {
+			t.Errorf("configuration problems don't mention %v: got %v", want, problems)
+		}
+	}
+	if len(problems) != 5 {
+		t.Errorf("wrong number of configuration problems: got %v want %v", len(problems), 5)
+	}
+}
+
+func TestAssets(t *testing.T) {
+	a := NewApp()
+	a.Config = newConfig()
+	a.Config.Templates = ""
+	a.Assets = os.DirFS("..")
+
+	if err := a.CheckTemplates(); err != nil {
+		t.Errorf("templates from assets failed the check: %v", err)
+	}
+
+	a.public = a.publicFiles()
+	if w, h := a.localImageSize("/public/../../go.mod"); w != 0 || h != 0 {
+		t.Errorf("image size was read outside of public files: got %vx%v", w, h)
+	}
+	if _, err := fs.Stat(a.public, "css/custom.css"); err != nil {
+		t.Errorf("public file wasn't found in assets: %v", err)
+	}
+
+	a.Assets = nil
+	if err := a.CheckTemplates(); err == nil {
+		t.Errorf("templates were parsed without assets and TEMPLATES")
+	}
+}
+
+func TestConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"blog.yaml": `
+DOMAIN: file.example.com
+POSTS_PER_PAGE: 7
+CORS_ALLOWED_ORIGINS: [https://a.example.com, https://b.example.com]
+PROFILE: dev
+profiles:
+  dev:
+    DOMAIN: dev.example.com
+  prod:
+    DOMAIN: prod.example.com
+    POSTS_PER_PAGE: 9
+`,
+		"blog.toml": `
+# comment
+DOMAIN = "file.example.com"
+POSTS_PER_PAGE = 7
+CORS_ALLOWED_ORIGINS = ["https://a.example.com", 'https://b.example.com'] # origins
+PROFILE = "dev"
+
+[profiles.dev]
+DOMAIN = "dev.example.com"
+
+[profiles.prod]
+DOMAIN = "prod.example.com"
+POSTS_PER_PAGE = 9
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		c, err := loadConfig(ConfigSource{File: path})
+		if err != nil {
+			t.Fatalf("%s: unable to load config: %v", name, err)
+		}
+		if c.Domain != "dev.example.com" || c.Posts.PerPage != 7 {
+			t.Errorf("%s: profile of the file wasn't applied: got %q %d", name, c.Domain, c.Posts.PerPage)
+		}
+		if !reflect.DeepEqual(c.CORS.Origins, []string{"https://a.example.com", "https://b.example.com"}) {
+			t.Errorf("%s: list wasn't read: got %v", name, c.CORS.Origins)
+		}
+		if c.DBURI != "file:../database/database.sqlite" {
+			t.Errorf("%s: environment didn't take precedence over the file: got %q", name, c.DBURI)
+		}
+
+		c, err = loadConfig(ConfigSource{File: path, Profile: "prod", Set: map[string]string{"POSTS_PER_PAGE": "3"}})
+		if err != nil {
+			t.Fatalf("%s: unable to load config: %v", name, err)
+		}
+		if c.Domain != "prod.example.com" || c.Posts.PerPage != 3 {
+			t.Errorf("%s: selected profile and flags weren't applied: got %q %d", name, c.Domain, c.Posts.PerPage)
+		}
+
+		if _, err := loadConfig(ConfigSource{File: path, Profile: "staging"}); err == nil {
+			t.Errorf("%s: undefined profile was accepted", name)
+		}
+	}
+
+	path := filepath.Join(dir, "typo.yaml")
+	if err := ioutil.WriteFile(path, []byte("DOMIAN: example.com\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfig(ConfigSource{File: path}); err == nil || !strings.Contains(err.Error(), "DOMIAN") {
+		t.Errorf("unknown key wasn't reported: got %v", err)
+	}
+}
+
+func TestSystemd(t *testing.T) {
+	dir, err := ioutil.TempDir("", "systemd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if ok, err := systemd.Notify("READY=1"); ok || err != nil {
+		t.Errorf("notified without NOTIFY_SOCKET: got %v %v", ok, err)
+	}
+
+	socket := filepath.Join(dir, "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	os.Setenv("NOTIFY_SOCKET", socket)
+	defer os.Unsetenv("NOTIFY_SOCKET")
+
+	if ok, err := systemd.Notify("READY=1"); !ok || err != nil {
+		t.Fatalf("unable to notify: got %v %v", ok, err)
+	}
+	buf := make([]byte, 64)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:n]) != "READY=1" {
+		t.Errorf("wrong state was sent: got %q want %q", buf[:n], "READY=1")
+	}
+
+	os.Setenv("WATCHDOG_USEC", "30000000")
+	defer os.Unsetenv("WATCHDOG_USEC")
+	if d, err := systemd.WatchdogInterval(); d != 30*time.Second || err != nil {
+		t.Errorf("wrong watchdog interval: got %v %v want %v", d, err, 30*time.Second)
+	}
+	os.Setenv("WATCHDOG_PID", "1")
+	defer os.Unsetenv("WATCHDOG_PID")
+	if d, _ := systemd.WatchdogInterval(); d != 0 {
+		t.Errorf("watchdog of another process was enabled: got %v", d)
+	}
+
+	pidFile := filepath.Join(dir, "blog.pid")
+	if err := systemd.WritePIDFile(pidFile); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadFile(pidFile); string(b) != strconv.Itoa(os.Getpid())+"\n" {
+		t.Errorf("wrong PID file content: got %q", b)
+	}
+}
+
+func TestPrivacy(t *testing.T) {
+	ips := []struct {
+		ip, mode, want string
+	}{
+		{"192.0.2.17", IPFull, "192.0.2.17"},
+		{"192.0.2.17", IPTruncate, "192.0.2.0"},
+		{"2001:db8:1:2::1", IPTruncate, "2001:db8:1::"},
+	}
+	for _, c := range ips {
+		if got := anonymizeIP(c.ip, c.mode, ""); got != c.want {
+			t.Errorf("wrong anonymized ip of %s in %s mode: got %q want %q", c.ip, c.mode, got, c.want)
+		}
+	}
+	if h := anonymizeIP("192.0.2.17", IPHash, "secret"); h == "192.0.2.17" || h != anonymizeIP("192.0.2.17", IPHash, "secret") {
+		t.Errorf("hashed ip isn't stable or leaks the ip: got %q", h)
+	}
+
+	a := NewApp()
+	a.Initialize()
+
+	c := model.Comment{PostID: 1, Name: "gdpr-reader", Date: "date", Data: "comment to forget"}
+	if err := c.CreateComment(a.DB); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := model.TogglePostLike(a.DB, 1, "user:gdpr-reader"); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/me/data", nil)
+	rr := httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("data was exported without login: got %v want %v", rr.Code, http.StatusUnauthorized)
+	}
+
+	cookie := a.Sessions.CreateSession(model.User{Type: session.GITHUB, Name: "gdpr-reader"}, req)
+	req = httptest.NewRequest(http.MethodGet, "/api/me/data", nil)
+	req.AddCookie(cookie)
+	rr = httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, req)
+	var data model.UserData
+	if err := json.Unmarshal(rr.Body.Bytes(), &data); err != nil {
+		t.Fatalf("invalid export %q: %v", rr.Body.String(), err)
+	}
+	if len(data.Comments) != 1 || data.Comments[0].Data != "comment to forget" || !reflect.DeepEqual(data.LikedPosts, []int{1}) {
+		t.Errorf("export is incomplete: got %+v", data)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/me/data", nil)
+	req.AddCookie(cookie)
+	rr = httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("delete returned wrong status code: got %v want %v", rr.Code, http.StatusNoContent)
+	}
+	if data, _ := model.GetUserData(a.DB, "gdpr-reader"); len(data.Comments) != 0 || len(data.LikedPosts) != 0 {
+		t.Errorf("data wasn't deleted: got %+v", data)
+	}
+	if a.Sessions.IsLoggedin(req) {
+		t.Errorf("session wasn't revoked after deleting the data")
+	}
+}
+
+func TestConsent(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+	a.Config.Privacy.Consent = true
+
+	recorded := func(path string) bool {
+		misses, err := model.GetNotFound(a.DB, NotFoundListLimit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range misses {
+			if m.Path == path {
+				return true
+			}
+		}
+		return false
+	}
+	//paths recorded by the earlier runs stay in the database
+	suffix := strconv.FormatInt(time.Now().UnixNano(), 36)
+	missing, granted := "/consent-missing-"+suffix, "/consent-granted-"+suffix
+
+	rr := httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/consent", nil))
+	if !strings.Contains(rr.Body.String(), `"banner":true`) {
+		t.Errorf("consent banner isn't requested: got %v", rr.Body.String())
+	}
+	a.notFound(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, missing, nil))
+	if recorded(missing) {
+		t.Errorf("not found path was recorded without consent")
+	}
+
+	rr = httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/consent", strings.NewReader("consent=maybe")))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("invalid consent returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/consent", strings.NewReader("consent=granted"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr = httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, req)
+	if !strings.Contains(rr.Body.String(), `"tracking":true`) {
+		t.Errorf("consent wasn't granted: got %v", rr.Body.String())
+	}
+	cookies := rr.Result().Cookies()
+	if len(cookies) == 0 || cookies[0].Name != "consent" {
+		t.Fatalf("consent cookie wasn't set: got %v", cookies)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, granted, nil)
+	req.AddCookie(cookies[0])
+	req.Header.Set("DNT", "1")
+	a.notFound(httptest.NewRecorder(), req)
+	if recorded(granted) {
+		t.Errorf("not found path was recorded despite Do-Not-Track")
+	}
+	req.Header.Del("DNT")
+	a.notFound(httptest.NewRecorder(), req)
+	if !recorded(granted) {
+		t.Errorf("not found path wasn't recorded with consent")
+	}
+}
+
+func TestCommentEvents(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+	a.events = newCommentStreams(10, 1)
+
+	p := model.Post{Title: "Live post", Body: "body", Date: "date"}
+	if err := p.CreatePost(a.DB); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(a.Router)
+	defer srv.Close()
+	stream := srv.URL + "/events/post/" + strconv.Itoa(p.ID)
+
+	resp, err := http.Get(stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" || resp.Header.Get("Content-Encoding") != "" {
+		t.Fatalf("events handler returned %d %v", resp.StatusCode, resp.Header)
+	}
+	if second, err := http.Get(stream); err != nil || second.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("second stream of the client isn't refused: %v %v", second, err)
+	} else {
+		second.Body.Close()
+	}
+	if missing, err := http.Get(srv.URL + "/events/post/999999999"); err != nil || missing.StatusCode != http.StatusNotFound {
+		t.Errorf("stream of missing post isn't refused: %v %v", missing, err)
+	} else {
+		missing.Body.Close()
+	}
+
+	user := a.Sessions.CreateSession(model.User{Type: session.GITHUB, Name: "live-reader"}, nil)
+	payload := url.Values{"id": {strconv.Itoa(p.ID)}, "comment": {"**live** <script>"}}
+	req := httptest.NewRequest(http.MethodPost, "/create-comment", strings.NewReader(payload.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(user)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(a.createComment).ServeHTTP(rr, req)
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("create comment handler returned wrong status code: got %v want %v", rr.Code, http.StatusSeeOther)
+	}
+
+	var data string
+	lines := bufio.NewScanner(resp.Body)
+	for data == "" && lines.Scan() {
+		data = strings.TrimPrefix(lines.Text(), "data: ")
+		if data == lines.Text() {
+			data = ""
+		}
+	}
+	var e commentEvent
+	if err := json.Unmarshal([]byte(data), &e); err != nil {
+		t.Fatalf("unable to read comment event %q: %v", data, err)
+	}
+	if e.ID == 0 || e.Name != "live-reader" || !strings.Contains(e.HTML, "<strong>live</strong>") || strings.Contains(e.HTML, "<script>") {
+		t.Errorf("wrong comment event: %+v", e)
+	}
+
+	a.events.Close()
+	for lines.Scan() {
+	}
+	if _, err := a.events.Subscribe(p.ID, "192.0.2.1"); err != errStreamsClosed {
+		t.Errorf("closed streams accept subscribers: %v", err)
+	}
+}
+
+func TestLivePreview(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	d := diffLines([]string{"a", "b", "c"}, []string{"a", "x", "y", "c"})
+	if d.At != 1 || d.Delete != 1 || !reflect.DeepEqual(d.Insert, []string{"x", "y"}) {
+		t.Errorf("wrong diff of lines: %+v", d)
+	}
+	if d := diffLines([]string{"a", "a"}, []string{"a"}); d.At != 1 || d.Delete != 1 || len(d.Insert) != 0 {
+		t.Errorf("wrong diff of repeated lines: %+v", d)
+	}
+
+	srv := httptest.NewServer(a.Router)
+	defer srv.Close()
+	if resp, err := http.Get(srv.URL + "/api/preview/stream"); err != nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("preview stream is open to anonymous readers: %v %v", resp, err)
+	} else {
+		resp.Body.Close()
+	}
+
+	admin := a.Sessions.CreateSession(model.User{Type: session.ADMIN, Name: "admin"}, nil)
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/api/preview/stream", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.AddCookie(admin)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("preview stream returned wrong status code: got %v want %v", resp.StatusCode, http.StatusOK)
+	}
+
+	lines := bufio.NewScanner(resp.Body)
+	next := func(event string, v interface{}) {
+		t.Helper()
+		for lines.Scan() {
+			if lines.Text() != "event: "+event || !lines.Scan() {
+				continue
+			}
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(lines.Text(), "data: ")), v); err != nil {
+				t.Fatal(err)
+			}
+			return
+		}
+		t.Fatalf("stream ended before %s event", event)
+	}
+	update := func(channel, body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/preview", strings.NewReader(url.Values{"channel": {channel}, "body": {body}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(admin)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(a.updatePreview).ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	var channel string
+	next("channel", &channel)
+	if status := update(channel, "<p>Hello</p>\n<p>World</p>"); status != http.StatusNoContent {
+		t.Fatalf("preview update returned wrong status code: got %v want %v", status, http.StatusNoContent)
+	}
+	var diff previewDiff
+	next("diff", &diff)
+	if diff.At != 0 || diff.Delete != 0 || !strings.Contains(strings.Join(diff.Insert, "\n"), "World") {
+		t.Errorf("wrong first preview: %+v", diff)
+	}
+	preview := diff.Insert
+
+	update(channel, "<p>Hello</p>\n<p>Editor</p>")
+	next("diff", &diff)
+	if diff.At == 0 || diff.Delete == 0 || !strings.Contains(strings.Join(diff.Insert, "\n"), "Editor") || strings.Contains(strings.Join(diff.Insert, "\n"), "Hello") {
+		t.Errorf("preview diff isn't limited to the changed line: %+v of %q", diff, preview)
+	}
+
+	if status := update("unknown", "body"); status != http.StatusNotFound {
+		t.Errorf("update of unknown channel returned wrong status code: got %v want %v", status, http.StatusNotFound)
+	}
+}
+
+func TestCommentFeeds(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	p := model.Post{Title: "Discussed post", Body: "body", Date: "date"}
+	if err := p.CreatePost(a.DB); err != nil {
+		t.Fatal(err)
+	}
+	posts, err := model.GetPosts(a.DB, 1, 0)
+	if err != nil || len(posts) == 0 {
+		t.Fatal("Unable to fetch created post", err)
+	}
+	id := posts[0].ID
+	c := model.Comment{PostID: id, Name: "feed-reader", Date: "Mon Jan  2 15:04:05 2006", Data: "subscribed <b>comment</b>"}
+	if err := c.CreateComment(a.DB); err != nil {
+		t.Fatal(err)
+	}
+	draft := model.Post{Title: "Draft", Body: "body", Date: "date", Status: model.StatusDraft}
+	if err := draft.CreatePost(a.DB); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"/comments.rss", "/post/comments.rss?id=" + strconv.Itoa(id)} {
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s returned wrong status code: got %v want %v", path, rr.Code, http.StatusOK)
+		}
+		var feed rssFeed
+		if err := xml.Unmarshal(rr.Body.Bytes(), &feed); err != nil {
+			t.Fatalf("%s is invalid: %v", path, err)
+		}
+		found := false
+		for _, item := range feed.Channel.Items {
+			if item.Title == "feed-reader on Discussed post" {
+				found = true
+				if !strings.Contains(item.Description, "&lt;b&gt;") || item.PubDate != "Mon, 02 Jan 2006 15:04:05 +0000" {
+					t.Errorf("%s has wrong item: got %+v", path, item)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("%s doesn't have the comment: got %v", path, rr.Body.String())
+		}
+	}
+
+	drafts, err := model.GetUnpublishedPosts(a.DB)
+	if err != nil || len(drafts) == 0 {
+		t.Fatal("Unable to fetch draft", err)
+	}
+	rr := httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/post/comments.rss?id="+strconv.Itoa(drafts[0].ID), nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("comments of draft returned wrong status code: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+}
+
+//fakeSMTP accepts mail on a local port and passes message data to the channel
+func fakeSMTP(t *testing.T) (string, chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				tp := textproto.NewConn(conn)
+				tp.PrintfLine("220 fake")
+				for {
+					line, err := tp.ReadLine()
+					if err != nil {
+						return
+					}
+					switch strings.ToUpper(strings.SplitN(line, " ", 2)[0]) {
+					case "DATA":
+						tp.PrintfLine("354 go ahead")
+						data, _ := tp.ReadDotBytes()
+						messages <- string(data)
+						tp.PrintfLine("250 ok")
+					case "QUIT":
+						tp.PrintfLine("221 bye")
+						return
+					default:
+						tp.PrintfLine("250 ok")
+					}
+				}
+			}(conn)
+		}
+	}()
+	t.Cleanup(func() { l.Close() })
+	return l.Addr().String(), messages
+}
+
+func TestReplyNotifications(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+	addr, messages := fakeSMTP(t)
+	a.mail = newMailer(Mail{SMTPAddr: addr, From: "blog@example.com"})
+
+	p := model.Post{Title: "Thread post", Body: "body", Date: "date"}
+	if err := p.CreatePost(a.DB); err != nil {
+		t.Fatal(err)
+	}
+	posts, err := model.GetPosts(a.DB, 1, 0)
+	if err != nil || len(posts) == 0 {
+		t.Fatal("Unable to fetch created post", err)
+	}
+	id := strconv.Itoa(posts[0].ID)
+
+	comment := func(name, form string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/create-comment", strings.NewReader("id="+id+"&"+form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(a.Sessions.CreateSession(model.User{Type: session.GITHUB, Name: name}, req))
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := comment("subscriber", "comment=first&notify=on&email=nope"); rr.Code != http.StatusBadRequest {
+		t.Errorf("invalid email returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+	comment("subscriber", "comment=first&notify=on&email=subscriber@example.com")
+	comment("subscriber", "comment=own follow up")
+	comment("replier", "comment=the reply")
+
+	if err := a.sendReplyNotifications(); err != nil {
+		t.Fatal(err)
+	}
+	var msg string
+	select {
+	case msg = <-messages:
+	case <-time.After(5 * time.Second):
+		t.Fatal("reply notification wasn't sent")
+	}
+	if !strings.Contains(msg, "replier wrote") || strings.Contains(msg, "own follow up") || strings.Contains(msg, "first") {
+		t.Errorf("notification has wrong comments: got %v", msg)
+	}
+
+	if err := a.sendReplyNotifications(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-messages:
+		t.Errorf("comments were sent twice: got %v", msg)
+	default:
+	}
+
+	i := strings.Index(msg, "token=")
+	if i < 0 {
+		t.Fatalf("notification has no unsubscribe link: got %v", msg)
+	}
+	token := strings.Fields(msg[i+len("token="):])[0]
+	rr := httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/comments/unsubscribe?token="+token, nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("unsubscribe returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if subs, _ := model.GetCommentSubscriptions(a.DB); len(subs) != 0 {
+		t.Errorf("subscription wasn't removed: got %v", subs)
+	}
+}
+
+func TestBulkPosts(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	admin := a.Sessions.CreateSession(model.User{Type: session.ADMIN, Name: "admin"}, nil)
+	bulk := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/admin/posts/bulk", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(admin)
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	ids := []string{}
+	for _, status := range []string{model.StatusDraft, model.StatusReview} {
+		p := model.Post{Title: "Bulk " + status, Body: "body", Date: "date", Status: status}
+		if err := p.CreatePost(a.DB); err != nil {
+			t.Fatal(err)
+		}
+	}
+	posts, err := model.GetUnpublishedPosts(a.DB)
+	if err != nil || len(posts) < 2 {
+		t.Fatal("Unable to fetch created posts", err)
+	}
+	for _, p := range posts[:2] {
+		ids = append(ids, strconv.Itoa(p.ID))
+	}
+
+	if rr := bulk(url.Values{"action": {"explode"}, "id": ids}); rr.Code != http.StatusBadRequest {
+		t.Errorf("unknown action returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+
+	rr := bulk(url.Values{"action": {"status:" + model.StatusApproved}, "id": ids})
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Skipped, can't move from draft to approved") {
+		t.Errorf("confirmation doesn't show skipped post: got %v %v", rr.Code, rr.Body.String())
+	}
+	if p, _ := model.GetPostsByIDs(a.DB, []int{posts[0].ID}); p[0].Status != model.StatusReview {
+		t.Errorf("action was applied before confirmation")
+	}
+
+	if rr := bulk(url.Values{"action": {"status:" + model.StatusApproved}, "id": ids, "confirm": {"yes"}}); rr.Code != http.StatusSeeOther {
+		t.Errorf("bulk action returned wrong status code: got %v want %v", rr.Code, http.StatusSeeOther)
+	}
+	got, err := model.GetPostsByIDs(a.DB, []int{posts[0].ID, posts[1].ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0].Status != model.StatusApproved || got[1].Status != model.StatusDraft {
+		t.Errorf("wrong statuses after bulk action: got %s and %s", got[0].Status, got[1].Status)
+	}
+
+	bulk(url.Values{"action": {"delete"}, "id": ids, "confirm": {"yes"}})
+	if got, _ := model.GetPostsByIDs(a.DB, []int{posts[0].ID, posts[1].ID}); len(got) != 0 {
+		t.Errorf("posts weren't deleted: got %v", got)
+	}
+}
+
+func TestRegenerateSEO(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	p := model.Post{Title: "Regenerate SEO", Body: `<p>text</p><img alt="first" src="/images/first.png"><img src="/images/second.png">`, Date: "date"}
+	if err := p.CreatePost(a.DB); err != nil {
+		t.Fatal(err)
+	}
+	posts, err := model.GetAllPosts(a.DB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p = posts[len(posts)-1]
+
+	admin := a.Sessions.CreateSession(model.User{Type: session.ADMIN, Name: "admin"}, nil)
+	regenerate := func(dryRun string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/seo-regenerate", strings.NewReader(url.Values{"dry_run": {dryRun}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, req)
+		return rr
+	}
+	changed := func(rr *httptest.ResponseRecorder) bool {
+		var changes []SEOChange
+		if err := json.Unmarshal(rr.Body.Bytes(), &changes); err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range changes {
+			if c.PostID == p.ID {
+				return c.Field == "cover_image" && c.Value == "/images/first.png"
+			}
+		}
+		return false
+	}
+	cover := func() string {
+		got, err := model.GetPostsByIDs(a.DB, []int{p.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got[0].CoverImage
+	}
+
+	if rr := regenerate("true", nil); rr.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous regeneration returned wrong status code: got %v want %v", rr.Code, http.StatusUnauthorized)
+	}
+
+	if rr := regenerate("true", admin); rr.Code != http.StatusOK || !changed(rr) {
+		t.Errorf("dry run doesn't report the cover image: got %v %v", rr.Code, rr.Body.String())
+	}
+	if got := cover(); got != "" {
+		t.Errorf("dry run stored the cover image %q", got)
+	}
+
+	if rr := regenerate("false", admin); rr.Code != http.StatusOK || !changed(rr) {
+		t.Errorf("regeneration doesn't report the cover image: got %v %v", rr.Code, rr.Body.String())
+	}
+	if got := cover(); got != "/images/first.png" {
+		t.Errorf("wrong cover image after regeneration: got %q want %q", got, "/images/first.png")
+	}
+
+	if rr := regenerate("true", admin); changed(rr) {
+		t.Errorf("post with cover image is regenerated again: %v", rr.Body.String())
+	}
+}
+
+func TestDuplicatePosts(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	unique := strconv.FormatInt(time.Now().UnixNano(), 36)
+	title := "Duplicate " + unique
+	body := "<p>Body of the post " + unique + " which is published twice by accident</p>"
+	p := model.Post{Title: title, Body: body, Date: "date"}
+	if err := p.CreatePost(a.DB); err != nil {
+		t.Fatal(err)
+	}
+
+	admin := a.Sessions.CreateSession(model.User{Type: session.ADMIN, Name: "admin"}, nil)
+	create := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/create", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(admin)
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, req)
+		return rr
+	}
+	count := func() int {
+		similar, err := model.FindSimilarPosts(a.DB, title, body, 0.999)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(similar)
+	}
+
+	if s := model.Similarity(title, " "+strings.ToUpper(title)); s < 0.999 {
+		t.Errorf("case and whitespace change similarity: got %v want 1", s)
+	}
+	if s := model.Similarity(body, "<p>Something different</p>"); s > 0.5 {
+		t.Errorf("unrelated texts are too similar: got %v", s)
+	}
+
+	rr := create(url.Values{"title": {title + "!"}, "body": {body}})
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Possible duplicate") || !strings.Contains(rr.Body.String(), `name="duplicate" value="yes"`) {
+		t.Errorf("duplicate post isn't confirmed: got %v %v", rr.Code, rr.Body.String())
+	}
+	if n := count(); n != 1 {
+		t.Errorf("duplicate post is created before confirmation, %d copies", n)
+	}
+
+	if rr := create(url.Values{"title": {title}, "body": {body}, "duplicate": {"yes"}}); rr.Code != http.StatusSeeOther {
+		t.Errorf("confirmed duplicate returned wrong status code: got %v want %v", rr.Code, http.StatusSeeOther)
+	}
+	if n := count(); n != 2 {
+		t.Errorf("confirmed duplicate isn't created, %d copies", n)
+	}
+
+	a.Config.Posts.Duplicates = DuplicatesBlock
+	if rr := create(url.Values{"title": {title}, "body": {body}, "duplicate": {"yes"}}); rr.Code != http.StatusConflict {
+		t.Errorf("blocked duplicate returned wrong status code: got %v want %v", rr.Code, http.StatusConflict)
+	}
+	if n := count(); n != 2 {
+		t.Errorf("blocked duplicate is created, %d copies", n)
+	}
+}
+
+func TestClonePost(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	p := model.Post{Title: "Weekly links", Body: `<p>Links of the week <img src="/public/img/week.png"></p>`, Date: "date", CoverImage: "/public/img/cover.png",
+		Pinned: true, CanonicalURL: "https://example.com/weekly", ContentType: model.ContentArticle}
+	if err := p.CreatePost(a.DB); err != nil {
+		t.Fatal(err)
+	}
+	posts, err := model.GetAllPosts(a.DB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p = posts[len(posts)-1]
+
+	clone := func(cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/admin/posts/clone", strings.NewReader(url.Values{"clone": {strconv.Itoa(p.ID)}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := clone(nil); rr.Code == http.StatusSeeOther {
+		t.Errorf("anonymous user cloned the post")
+	}
+
+	rr := clone(a.Sessions.CreateSession(model.User{Type: session.ADMIN, Name: "admin"}, nil))
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("clone returned wrong status code: got %v want %v", rr.Code, http.StatusSeeOther)
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(rr.Header().Get("Location"), "/update?id="))
+	if err != nil || id == p.ID {
+		t.Fatalf("clone redirects to wrong page: %q", rr.Header().Get("Location"))
+	}
+
+	c := model.Post{ID: id}
+	if err := c.GetPost(a.DB); err != nil {
+		t.Fatal(err)
+	}
+	if c.Title != "Weekly links (copy)" || c.Body != p.Body || c.CoverImage != p.CoverImage || c.Status != model.StatusDraft {
+		t.Errorf("wrong copy of the post: got %+v", c)
+	}
+	if c.Pinned || c.CanonicalURL != "" {
+		t.Errorf("pin or canonical url is copied: got %+v", c)
+	}
+}
+
+func TestSnippets(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	admin := a.Sessions.CreateSession(model.User{Type: session.ADMIN, Name: "admin"}, nil)
+	do := func(method, target string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(admin)
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := do(http.MethodPost, "/admin/snippets", url.Values{"name": {"empty"}}); rr.Code != http.StatusBadRequest {
+		t.Errorf("snippet without body returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+
+	form := url.Values{"name": {"weekly"}, "title": {"{{series}} of {{date}}"}, "body": {"<p>Links of {{series}}, {{unknown}}</p>"}}
+	if rr := do(http.MethodPost, "/admin/snippets", form); rr.Code != http.StatusSeeOther {
+		t.Errorf("saving snippet returned wrong status code: got %v want %v", rr.Code, http.StatusSeeOther)
+	}
+	if rr := do(http.MethodGet, "/admin/snippets", nil); !strings.Contains(rr.Body.String(), `href="/create?snippet=weekly"`) {
+		t.Errorf("snippet isn't listed: %v", rr.Body.String())
+	}
+
+	rr := do(http.MethodGet, "/create?snippet=weekly&series=Weekly+links", nil)
+	title := "Weekly links of " + time.Now().Format("January 2, 2006")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `value="`+title+`"`) ||
+		!strings.Contains(rr.Body.String(), "&lt;p&gt;Links of Weekly links, {{unknown}}&lt;/p&gt;") {
+		t.Errorf("create form isn't filled from the snippet: got %v %v", rr.Code, rr.Body.String())
+	}
+	if rr := do(http.MethodGet, "/create?snippet=missing", nil); rr.Code != http.StatusNotFound {
+		t.Errorf("unknown snippet returned wrong status code: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+
+	do(http.MethodPost, "/admin/snippets", url.Values{"action": {"delete"}, "name": {"weekly"}})
+	if _, err := model.GetSnippet(a.DB, "weekly"); err == nil {
+		t.Errorf("snippet isn't deleted")
+	}
+}
+
+func TestAnnouncements(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	received := map[string]string{}
+	telegramDown := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/statuses":
+			if r.Header.Get("Authorization") != "Bearer mastodon-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			received["mastodon"] = r.FormValue("status")
+		case "/xrpc/com.atproto.server.createSession":
+			w.Write([]byte(`{"accessJwt": "jwt", "did": "did:plc:blog"}`))
+		case "/xrpc/com.atproto.repo.createRecord":
+			var body struct {
+				Repo   string            `json:"repo"`
+				Record map[string]string `json:"record"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Repo != "did:plc:blog" || r.Header.Get("Authorization") != "Bearer jwt" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			received["bluesky"] = body.Record["text"]
+		case "/botbot-token/sendMessage":
+			if telegramDown {
+				http.Error(w, `{"ok": false}`, http.StatusBadGateway)
+				return
+			}
+			received["telegram"] = r.FormValue("chat_id") + ": " + r.FormValue("text")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	a.Config.Announce = Announce{
+		MastodonURL: srv.URL, MastodonToken: "mastodon-token", MastodonTemplate: "New post: {{title}} {{url}}",
+		BlueskyPDS: srv.URL, BlueskyHandle: "blog.example.com", BlueskyPassword: "app-password", BlueskyTemplate: DefaultAnnounceTemplate,
+		TelegramAPI: srv.URL, TelegramToken: "bot-token", TelegramChat: "@blog", TelegramTemplate: DefaultAnnounceTemplate,
+	}
+	a.channels = announceChannels(a.Config.Announce)
+
+	admin := a.Sessions.CreateSession(model.User{Type: session.ADMIN, Name: "admin"}, nil)
+	post := func(target string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(admin)
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, req)
+		return rr
+	}
+	queued := func(postID int) []model.Announcement {
+		list, err := model.GetAnnouncements(a.DB, AnnouncementsLimit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := []model.Announcement{}
+		for _, an := range list {
+			if an.PostID == postID {
+				found = append(found, an)
+			}
+		}
+		return found
+	}
+	//posts and announcements of the earlier runs stay in the database, so the posts are created with
+	//unique titles and bodies and looked up by the title
+	create := func(form url.Values) int {
+		if rr := post("/create", form); rr.Code != http.StatusSeeOther {
+			t.Fatalf("creating post returned wrong status code: got %v want %v", rr.Code, http.StatusSeeOther)
+		}
+		var id int
+		if err := a.DB.QueryRow(`select id from posts where title = ?`, form.Get("title")).Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+
+	title := "Announced " + strconv.FormatInt(time.Now().UnixNano(), 36)
+	id := create(url.Values{"title": {title}, "body": {"<p>" + title + "</p>"}})
+	if got := queued(id); len(got) != 3 {
+		t.Fatalf("announcements aren't queued for every channel: got %+v", got)
+	}
+
+	if err := a.sendAnnouncements(); err == nil {
+		t.Errorf("failed delivery isn't reported")
+	}
+	link := "http://example.com/post?id=" + strconv.Itoa(id)
+	if received["mastodon"] != "New post: "+title+" "+link || received["bluesky"] != title+" "+link {
+		t.Errorf("wrong announcements received: %v", received)
+	}
+	for _, an := range queued(id) {
+		if an.Channel == "telegram" && (an.Status != model.AnnouncementQueued || an.Attempts != 1 || !strings.Contains(an.Error, "502")) {
+			t.Errorf("failed announcement isn't queued for retry: %+v", an)
+		}
+		if an.Channel != "telegram" && an.Status != model.AnnouncementSent {
+			t.Errorf("delivered announcement isn't marked sent: %+v", an)
+		}
+	}
+
+	telegramDown = false
+	if err := a.sendAnnouncements(); err != nil {
+		t.Errorf("retry failed: %v", err)
+	}
+	if received["telegram"] != "@blog: "+title+" "+link {
+		t.Errorf("wrong telegram message: %q", received["telegram"])
+	}
+
+	p := model.Post{ID: id}
+	if err := p.GetPost(a.DB); err != nil {
+		t.Fatal(err)
+	}
+	a.announce(httptest.NewRequest(http.MethodGet, "/", nil), p)
+	if got := queued(id); len(got) != 3 {
+		t.Errorf("post is announced twice: %+v", got)
+	}
+
+	a.Config.Announce.Delay = time.Hour
+	members := create(url.Values{"title": {"Members " + title}, "body": {"members only " + title}, "visibility": {model.VisibilityMembers}})
+	if got := queued(members); len(got) != 0 {
+		t.Errorf("members only post is announced: %+v", got)
+	}
+	id = create(url.Values{"title": {"Cancelled " + title}, "body": {"to be cancelled " + title}})
+	got := queued(id)
+	if len(got) != 3 {
+		t.Fatalf("announcements aren't queued: %+v", got)
+	}
+	if rr := post("/admin/announcements/cancel", url.Values{"id": {strconv.Itoa(got[0].ID)}}); rr.Code != http.StatusSeeOther {
+		t.Errorf("cancel returned wrong status code: got %v want %v", rr.Code, http.StatusSeeOther)
+	}
+	if rr := post("/admin/announcements/cancel", url.Values{"id": {strconv.Itoa(got[0].ID)}}); rr.Code != http.StatusConflict {
+		t.Errorf("second cancel returned wrong status code: got %v want %v", rr.Code, http.StatusConflict)
+	}
+	if err := a.sendAnnouncements(); err != nil {
+		t.Errorf("announcements are sent before the delay: %v", err)
+	}
+	for _, an := range queued(id) {
+		if an.Status == model.AnnouncementSent {
+			t.Errorf("announcement is sent before the delay: %+v", an)
+		}
+	}
+}
+
+func TestSitemap(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	p := model.Post{Title: "Sitemap post", Body: "body", Date: "date"}
+	hidden := model.Post{Title: "Sitemap noindex", Body: "body", Date: "date", NoIndex: true}
+	for _, post := range []*model.Post{&p, &hidden} {
+		if err := post.CreatePost(a.DB); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := a.DB.Exec(`update posts set updated_at = 1000 where id = ?`, p.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	sitemap := func() string {
+		req := httptest.NewRequest(http.MethodGet, "/sitemap.xml", nil)
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("sitemap returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+		return rr.Body.String()
+	}
+	entry := func(body string, id int) string {
+		loc := "<loc>http://example.com/post?id=" + strconv.Itoa(id) + "</loc>"
+		i := strings.Index(body, loc)
+		if i < 0 {
+			return ""
+		}
+		return body[i : i+strings.Index(body[i:], "</url>")]
+	}
+
+	body := sitemap()
+	if !strings.Contains(entry(body, p.ID), "<lastmod>1970-01-01T00:16:40Z</lastmod>") {
+		t.Errorf("wrong sitemap entry of the post: %v", entry(body, p.ID))
+	}
+	if entry(body, hidden.ID) != "" {
+		t.Errorf("noindex post is in the sitemap")
+	}
+
+	c := model.Comment{PostID: p.ID, Name: "reader", Date: "date", Data: "comment"}
+	if err := c.CreateComment(a.DB); err != nil {
+		t.Fatal(err)
+	}
+	if e := entry(sitemap(), p.ID); !strings.Contains(e, "<lastmod>"+time.Now().UTC().Format("2006-01-02")) {
+		t.Errorf("lastmod isn't updated by the comment: %v", e)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/robots.txt", nil)
+	rr := httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, req)
+	if !strings.Contains(rr.Body.String(), "Sitemap: http://example.com/sitemap.xml") {
+		t.Errorf("robots.txt doesn't point to the sitemap: %v", rr.Body.String())
+	}
+
+	total, err := model.CountSitemapPosts(a.DB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	published, err := model.CountPosts(a.DB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listPages := pageCount(published, a.settings.Get().PostsPerPage)
+	defer func(size int) { sitemapPageSize = size }(sitemapPageSize)
+	sitemapPageSize = 2
+	pages := (listPages + total + 1) / 2
+	index := sitemap()
+	if !strings.Contains(index, "<sitemapindex") || !strings.Contains(index, "<loc>http://example.com/sitemap.xml?page="+strconv.Itoa(pages)+"</loc>") {
+		t.Errorf("sitemap index doesn't list all %d pages: %v", pages, index)
+	}
+
+	var set struct {
+		URLs []sitemapURL `xml:"url"`
+	}
+	seen := map[string]bool{}
+	for i := 1; i <= pages; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/sitemap.xml?page="+strconv.Itoa(i), nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, req)
+		if rr.Header().Get("Content-Encoding") != "gzip" {
+			t.Fatalf("sitemap page %d isn't compressed", i)
+		}
+		gz, err := gzip.NewReader(rr.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		set.URLs = nil
+		if err := xml.NewDecoder(gz).Decode(&set); err != nil {
+			t.Fatal(err)
+		}
+		if len(set.URLs) == 0 || len(set.URLs) > 2 {
+			t.Errorf("sitemap page %d has wrong number of urls: %d", i, len(set.URLs))
+		}
+		for _, u := range set.URLs {
+			if seen[u.Loc] {
+				t.Errorf("%s is listed twice", u.Loc)
+			}
+			seen[u.Loc] = true
+			if strings.Contains(u.Loc, "/page?p=") && u.Priority != ListPagePriority {
+				t.Errorf("list page %s has wrong priority: %q", u.Loc, u.Priority)
+			}
+		}
+	}
+	if len(seen) != listPages+total || !seen["http://example.com/page?p=0"] || seen["http://example.com/"] {
+		t.Errorf("sitemap pages list wrong urls: got %d want %d", len(seen), listPages+total)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/sitemap.xml?page="+strconv.Itoa(pages+1), nil)
+	rr = httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("sitemap page past the end returned wrong status code: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+}
+
+func TestTracing(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	type span struct {
+		TraceID      string `json:"traceId"`
+		SpanID       string `json:"spanId"`
+		ParentSpanID string `json:"parentSpanId"`
+		Name         string `json:"name"`
+		Kind         int    `json:"kind"`
+	}
+	spans := map[string]span{}
+	service := ""
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			ResourceSpans []struct {
+				Resource struct {
+					Attributes []struct {
+						Key   string            `json:"key"`
+						Value map[string]string `json:"value"`
+					} `json:"attributes"`
+				} `json:"resource"`
+				ScopeSpans []struct {
+					Spans []span `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		if r.URL.Path != "/v1/traces" || json.NewDecoder(r.Body).Decode(&body) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for _, rs := range body.ResourceSpans {
+			service = rs.Resource.Attributes[0].Value["stringValue"]
+			for _, ss := range rs.ScopeSpans {
+				for _, s := range ss.Spans {
+					spans[s.Name] = s
+				}
+			}
+		}
+	}))
+	defer srv.Close()
+
+	a.tracer = tracing.New(tracing.Config{Endpoint: srv.URL, Service: "blog", SampleRatio: 0, Interval: time.Hour})
+	a.initializeRoutes()
+
+	req := httptest.NewRequest(http.MethodGet, "/page?p=0", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	a.Router.ServeHTTP(httptest.NewRecorder(), req)
+	a.Router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/about", nil))
+	a.tracer.Shutdown()
+
+	server, render := spans["GET /page"], spans["render posts.gohtml"]
+	if service != "blog" || server.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || server.ParentSpanID != "00f067aa0ba902b7" || server.Kind != tracing.KindServer {
+		t.Errorf("wrong server span %+v of service %q", server, service)
+	}
+	if render.TraceID != server.TraceID || render.ParentSpanID != server.SpanID {
+		t.Errorf("render span isn't child of the server span: %+v", render)
+	}
+	if _, ok := spans["GET /about"]; ok {
+		t.Errorf("trace is recorded with zero sample ratio")
+	}
+}
+
+func TestPprof(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	get := func(path string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, req)
+		return rr
+	}
+	admin := a.Sessions.CreateSession(model.User{Type: session.ADMIN, Name: "admin"}, nil)
+
+	if rr := get("/debug/pprof/", admin); rr.Code != http.StatusNotFound {
+		t.Errorf("pprof is served while disabled: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+
+	a.Config.Pprof.Enabled = true
+	a.initializeRoutes()
+	if rr := get("/debug/pprof/", nil); rr.Code != http.StatusUnauthorized {
+		t.Errorf("pprof is served to anonymous user: got %v want %v", rr.Code, http.StatusUnauthorized)
+	}
+	if rr := get("/debug/pprof/", admin); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "goroutine") {
+		t.Errorf("pprof index isn't served to admin: got %v %v", rr.Code, rr.Body.String())
+	}
+	if rr := get("/debug/pprof/goroutine?debug=1", admin); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "goroutine profile") {
+		t.Errorf("goroutine profile isn't served to admin: got %v %v", rr.Code, rr.Body.String())
+	}
+
+	for addr, want := range map[string]bool{"127.0.0.1:6060": true, "[::1]:6060": true, "localhost:6060": true, ":6060": false, "0.0.0.0:6060": false, "example.com:6060": false} {
+		if got := isLoopback(addr); got != want {
+			t.Errorf("isLoopback(%q) = %v want %v", addr, got, want)
+		}
+	}
+}
+
+func TestCache(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	p := model.Post{Title: "Cached post", Body: "first body", Date: "date"}
+	if err := p.CreatePost(a.DB); err != nil {
+		t.Fatal(err)
+	}
+	page := func() string {
+		req := httptest.NewRequest(http.MethodGet, "/post?id="+strconv.Itoa(p.ID), nil)
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("post handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+		return rr.Body.String()
+	}
+
+	page()
+	//writes bypassing the model aren't seen until the entry expires
+	if _, err := a.DB.Exec(`update posts set body = 'second body' where id = ?`, p.ID); err != nil {
+		t.Fatal(err)
+	}
+	if body := page(); !strings.Contains(body, "first body") {
+		t.Errorf("post isn't served from the cache")
+	}
+
+	p.Body = "third body"
+	if err := p.UpdatePost(a.DB); err != nil {
+		t.Fatal(err)
+	}
+	if body := page(); !strings.Contains(body, "third body") {
+		t.Errorf("cached post isn't invalidated by the update")
+	}
+
+	c := model.Comment{PostID: p.ID, Name: "reader", Date: "date", Data: "cached comment"}
+	if err := c.CreateComment(a.DB); err != nil {
+		t.Fatal(err)
+	}
+	if body := page(); !strings.Contains(body, "cached comment") {
+		t.Errorf("cached comments aren't invalidated by the new comment")
+	}
+
+	a.cache = newCaches(a.DB, 0)
+	page()
+	if _, err := a.DB.Exec(`update posts set body = 'fourth body' where id = ?`, p.ID); err != nil {
+		t.Fatal(err)
+	}
+	if body := page(); !strings.Contains(body, "fourth body") {
+		t.Errorf("post is cached with zero TTL")
+	}
+}
+
+func TestDatabaseMaintenance(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	s := newScheduler(a.DB, 0, time.Local)
+	s.RegisterDaily("db-maintenance", 4, true, a.maintainDatabase)
+	s.RegisterDaily("invalid-hour", 24, true, a.maintainDatabase)
+	if len(s.jobs) != 1 {
+		t.Fatalf("scheduler registered unexpected jobs: got %v want 1", len(s.jobs))
+	}
+
+	j := s.jobs[0]
+	now := time.Date(2024, 5, 1, 3, 30, 0, 0, time.UTC)
+	if d := j.delay(now, true); d != 30*time.Minute {
+		t.Errorf("daily job is delayed wrongly before its hour: got %v want %v", d, 30*time.Minute)
+	}
+	if d := j.delay(now.Add(time.Hour), false); d != 23*time.Hour+30*time.Minute {
+		t.Errorf("daily job is delayed wrongly after its hour: got %v want %v", d, 23*time.Hour+30*time.Minute)
+	}
+	if schedule := j.Schedule(); schedule != "daily at 04:00" {
+		t.Errorf("daily job has wrong schedule: got %q", schedule)
+	}
+
+	s.execute(j)
+	runs, err := model.GetJobRuns(a.DB, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].Error != "" || !strings.Contains(runs[0].Summary, "freed") {
+		t.Errorf("maintenance saved unexpected job run: got %+v", runs)
+	}
+
+	var mode int
+	if err := a.DB.QueryRow(`pragma auto_vacuum`).Scan(&mode); err != nil {
+		t.Fatal(err)
+	}
+	if mode != 2 {
+		t.Errorf("incremental vacuum isn't enabled after maintenance: got auto_vacuum %v want 2", mode)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/diagnostics", nil)
+	rr := httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("diagnostics are shown to anonymous user: got %v want %v", rr.Code, http.StatusUnauthorized)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/diagnostics", nil)
+	req.AddCookie(a.Sessions.CreateSession(model.User{Type: session.ADMIN, Name: "admin"}, nil))
+	rr = httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("diagnostics handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if body := rr.Body.String(); !strings.Contains(body, "comments_postid") || !strings.Contains(body, "Comments of the post") {
+		t.Errorf("diagnostics page misses indexes or plans: %v", body)
+	}
+
+	//plans depend on the statistics collected by ANALYZE, so only the indexes are checked
+	indexes, err := model.GetIndexes(a.DB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := map[string]bool{}
+	for _, i := range indexes {
+		names[i.Name] = true
+	}
+	for _, name := range []string{"posts_status_pinned", "comments_postid", "comments_name", "login_attempts_name", "announcements_due"} {
+		if !names[name] {
+			t.Errorf("index %s isn't created", name)
+		}
+	}
+}
+
+func TestCompression(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	get := func(h http.Handler, path, accept string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Encoding", accept)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for accept, want := range map[string]string{
+		"gzip":                 "gzip",
+		"deflate, gzip;q=0.5":  "gzip",
+		"br;q=1.0, *;q=0.1":    "gzip",
+		"gzip;q=0":             "",
+		"*;q=0":                "",
+		"identity":             "",
+		"GZIP; Q=0.8, deflate": "gzip",
+	} {
+		rr := get(a.Router, "/about", accept, nil)
+		if got := rr.Header().Get("Content-Encoding"); got != want {
+			t.Errorf("wrong content coding for Accept-Encoding %q: got %q want %q", accept, got, want)
+		}
+		if !strings.Contains(rr.Header().Get("Vary"), "Accept-Encoding") {
+			t.Errorf("response to Accept-Encoding %q doesn't vary by it", accept)
+		}
+	}
+
+	rr := get(a.Router, "/about", "gzip", nil)
+	gz, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, err := ioutil.ReadAll(gz); err != nil || !strings.Contains(string(body), "</html>") {
+		t.Errorf("compressed page can't be read: %v %v", err, string(body))
+	}
+
+	admin := a.Sessions.CreateSession(model.User{Type: session.ADMIN, Name: "admin"}, nil)
+	if rr := get(a.Router, "/admin/sessions", "gzip", admin); rr.Code != http.StatusOK || rr.Header().Get("Content-Encoding") != "" {
+		t.Errorf("sessions page reflecting session refs is compressed: %v %q", rr.Code, rr.Header().Get("Content-Encoding"))
+	}
+
+	image := middleware.GzipMiddleware(middleware.SetHeaderMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("\x89PNG\r\n\x1a\n"))
+	})))
+	if rr := get(image, "/public/logo.png", "gzip", nil); rr.Header().Get("Content-Encoding") != "" || rr.Header().Get("Content-Type") != "image/png" {
+		t.Errorf("image is compressed: %q %q", rr.Header().Get("Content-Encoding"), rr.Header().Get("Content-Type"))
+	}
+}
+
+func TestReadAsVisitor(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	draft := model.Post{Title: "Visitor draft", Body: "body", Date: "date", Status: model.StatusDraft}
+	members := model.Post{Title: "Visitor members post", Body: "<p>Intro</p>\n\n<p>Secret part</p>", Date: "date", Visibility: model.VisibilityMembers}
+	for _, p := range []*model.Post{&draft, &members} {
+		if err := p.CreatePost(a.DB); err != nil {
+			t.Fatal(err)
+		}
+	}
+	admin := a.Sessions.CreateSession(model.User{Type: session.ADMIN, Name: "admin"}, nil)
+	do := func(method, path string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(admin)
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, req)
+		return rr
+	}
+	membersPage := "/post?id=" + strconv.Itoa(members.ID)
+	draftPage := "/post?id=" + strconv.Itoa(draft.ID)
+
+	if rr := do(http.MethodGet, membersPage, nil); !strings.Contains(rr.Body.String(), `href="/create"`) || !strings.Contains(rr.Body.String(), "Secret part") {
+		t.Errorf("admin doesn't see admin controls or the whole members only post")
+	}
+
+	if rr := do(http.MethodPost, "/admin/visitor", url.Values{"on": {"true"}}); rr.Code != http.StatusSeeOther {
+		t.Fatalf("visitor toggle returned wrong status code: got %v want %v", rr.Code, http.StatusSeeOther)
+	}
+	rr := do(http.MethodGet, membersPage, nil)
+	body := rr.Body.String()
+	if strings.Contains(body, `href="/create"`) || strings.Contains(body, "Secret part") || !strings.Contains(body, "visitor-banner") {
+		t.Errorf("admin reading as visitor sees admin controls or members only content")
+	}
+	if rr.Header().Get("Cache-Control") != "private, no-cache" {
+		t.Errorf("page of admin reading as visitor is cacheable: %q", rr.Header().Get("Cache-Control"))
+	}
+	if rr := do(http.MethodGet, draftPage, nil); rr.Code != http.StatusNotFound {
+		t.Errorf("draft is shown to admin reading as visitor: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+	if rr := do(http.MethodGet, "/admin/jobs", nil); rr.Code != http.StatusOK {
+		t.Errorf("admin pages are closed to admin reading as visitor: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	do(http.MethodPost, "/admin/visitor", url.Values{"on": {"false"}})
+	if rr := do(http.MethodGet, draftPage, nil); rr.Code != http.StatusOK || strings.Contains(rr.Body.String(), "visitor-banner") {
+		t.Errorf("admin view isn't restored: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	anonymous := httptest.NewRequest(http.MethodPost, "/admin/visitor", nil)
+	rr = httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, anonymous)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("visitor toggle is open to anonymous readers: got %v want %v", rr.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestCachePolicy(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	p := model.Post{Title: "Cache policy post", Body: "body", Date: "date"}
+	if err := p.CreatePost(a.DB); err != nil {
+		t.Fatal(err)
+	}
+	get := func(path string, cookie *http.Cookie) http.Header {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, req)
+		return rr.Header()
+	}
+	post := "/post?id=" + strconv.Itoa(p.ID)
+
+	if h := get(post, nil); h.Get("Cache-Control") != "no-cache" {
+		t.Errorf("post without lifetime is cacheable: %q", h.Get("Cache-Control"))
+	}
+	if h := get("/public/css/code.css", nil); h.Get("Cache-Control") != "public, max-age=2592000" {
+		t.Errorf("wrong Cache-Control of static file: %q", h.Get("Cache-Control"))
+	}
+
+	a.caching = newCachePolicy(HTTPCache{Posts: 5 * time.Minute, Feeds: time.Hour, Static: time.Hour})
+	if h := get(post, nil); h.Get("Cache-Control") != "public, max-age=300" || h.Get("Surrogate-Control") != "" {
+		t.Errorf("wrong caching headers of post: %v", h)
+	}
+	if h := get("/comments.rss", nil); h.Get("Cache-Control") != "public, max-age=3600" {
+		t.Errorf("wrong Cache-Control of feed: %q", h.Get("Cache-Control"))
+	}
+	user := a.Sessions.CreateSession(model.User{Type: session.GITHUB, Name: "reader"}, nil)
+	if h := get(post, user); h.Get("Cache-Control") != "private, no-cache" {
+		t.Errorf("post of logged in user is cacheable: %q", h.Get("Cache-Control"))
+	}
+	if h := get("/post?id=999999999", nil); h.Get("Cache-Control") != "no-cache" {
+		t.Errorf("missing post is cacheable: %q", h.Get("Cache-Control"))
+	}
+
+	a.caching = newCachePolicy(HTTPCache{Posts: 5 * time.Minute, Static: time.Hour, CDN: true})
+	h := get(post, nil)
+	if h.Get("Cache-Control") != "public, max-age=0, must-revalidate" || h.Get("Surrogate-Control") != "max-age=300" || h.Get("CDN-Cache-Control") != "max-age=300" {
+		t.Errorf("wrong caching headers of post behind CDN: %v", h)
+	}
+	if h := get("/post?id=999999999", nil); h.Get("Surrogate-Control") != "" {
+		t.Errorf("missing post is kept by CDN: %v", h)
+	}
+	if h := get("/public/css/code.css", nil); h.Get("Cache-Control") != "public, max-age=3600" || h.Get("Surrogate-Control") != "max-age=3600" {
+		t.Errorf("wrong caching headers of static file behind CDN: %v", h)
+	}
+}
+
+func TestPostETag(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	p := model.Post{Title: "Validated post", Body: "body", Date: "date"}
+	if err := p.CreatePost(a.DB); err != nil {
+		t.Fatal(err)
+	}
+	get := func(etag string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/post?id="+strconv.Itoa(p.ID), nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("", nil)
+	etag := rr.Header().Get("ETag")
+	if rr.Code != http.StatusOK || !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("post page returned %d without weak ETag: %q", rr.Code, etag)
+	}
+	if rr := get(`"other", `+strings.TrimPrefix(etag, "W/"), nil); rr.Code != http.StatusNotModified || rr.Body.Len() != 0 {
+		t.Errorf("unchanged post returned wrong status code: got %v want %v", rr.Code, http.StatusNotModified)
+	}
+
+	c := model.Comment{PostID: p.ID, Name: "tester", Date: "date", Data: "comment"}
+	if err := c.CreateComment(a.DB); err != nil {
+		t.Fatal(err)
+	}
+	rr = get(etag, nil)
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") == etag {
+		t.Errorf("commented post returned %d with the old ETag", rr.Code)
+	}
+	etag = rr.Header().Get("ETag")
+
+	p.Body = "updated body"
+	if err := p.UpdatePost(a.DB); err != nil {
+		t.Fatal(err)
+	}
+	if rr := get(etag, nil); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "updated body") {
+		t.Errorf("updated post returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	user := a.Sessions.CreateSession(model.User{Type: session.GITHUB, Name: "reader"}, nil)
+	if rr := get("*", user); rr.Code != http.StatusOK || rr.Header().Get("ETag") != "" {
+		t.Errorf("post page of logged in user is validated: %d %q", rr.Code, rr.Header().Get("ETag"))
+	}
+}
+
+func TestCDNPurge(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	var mu sync.Mutex
+	purged := map[string][]string{}
+	failures := 2
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.URL.Path == "/zones/zone/purge_cache" && r.Header.Get("Authorization") == "Bearer cf-token":
+			if failures > 0 {
+				failures--
+				http.Error(w, `{"success": false}`, http.StatusInternalServerError)
+				return
+			}
+			var body struct {
+				Files []string `json:"files"`
+				All   bool     `json:"purge_everything"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			if body.All {
+				body.Files = []string{"*"}
+			}
+			purged["cloudflare"] = append(purged["cloudflare"], body.Files...)
+		case strings.HasPrefix(r.URL.Path, "/purge/") && r.Header.Get("Fastly-Key") == "fastly-token":
+			purged["fastly"] = append(purged["fastly"], strings.TrimPrefix(r.URL.Path, "/purge/"))
+		case r.URL.Path == "/purge" && r.Header.Get("AccessKey") == "bunny-key":
+			purged["bunny"] = append(purged["bunny"], r.URL.Query().Get("url"))
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer srv.Close()
+
+	a.Config.Domain = "blog.example.com"
+	a.purges = newPurgeQueue(a.DB, []integrations.Purger{
+		integrations.Cloudflare{API: srv.URL, Zone: "zone", Token: "cf-token"},
+		integrations.Fastly{API: srv.URL, Service: "service", Token: "fastly-token"},
+		integrations.Bunny{API: srv.URL, PullZone: "zone", Key: "bunny-key"},
+	}, time.Millisecond)
+	model.OnChange(a.DB, a.purgeChanged)
+
+	contains := func(urls []string, u string) bool {
+		for _, v := range urls {
+			if v == u {
+				return true
+			}
+		}
+		return false
+	}
+
+	//a new post shifts the posts of every list page
+	p := model.Post{Title: "Purged post", Body: "body", Date: "date"}
+	if err := p.CreatePost(a.DB); err != nil {
+		t.Fatal(err)
+	}
+	a.purges.flush()
+	total, err := model.CountPosts(a.DB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := "https://blog.example.com/page?p=" + strconv.Itoa((total-1)/a.settings.Get().PostsPerPage)
+	post := "https://blog.example.com/post?id=" + strconv.Itoa(p.ID)
+	for _, u := range []string{post, "https://blog.example.com/", "https://blog.example.com/sitemap.xml", "https://blog.example.com/page?p=0", last} {
+		if !contains(purged["cloudflare"], u) {
+			t.Errorf("purge after create misses %v: %v", u, purged["cloudflare"])
+		}
+	}
+
+	cloneID, err := p.ClonePost(a.DB, "Purged clone", "date")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.purges.flush()
+	if clone := "https://blog.example.com/post?id=" + strconv.Itoa(cloneID); !contains(purged["cloudflare"], clone) {
+		t.Errorf("purge after clone misses the clone page: %v", purged["cloudflare"])
+	}
+
+	purged = map[string][]string{}
+	p.Body = "changed body"
+	if err := p.UpdatePost(a.DB); err != nil {
+		t.Fatal(err)
+	}
+	a.purges.flush()
+
+	if urls := purged["cloudflare"]; !contains(urls, post) || !contains(urls, "https://blog.example.com/sitemap.xml") || !contains(urls, "https://blog.example.com/") {
+		t.Errorf("cloudflare purge after retries misses pages of the post: %v", urls)
+	}
+	if urls := purged["fastly"]; !contains(urls, "blog.example.com/post?id="+strconv.Itoa(p.ID)) {
+		t.Errorf("fastly purge misses the post page: %v", urls)
+	}
+	if urls := purged["bunny"]; !contains(urls, post) {
+		t.Errorf("bunny purge misses the post page: %v", urls)
+	}
+
+	purged = map[string][]string{}
+	a.purges.Add()
+	a.purges.flush()
+	if urls := purged["cloudflare"]; !contains(urls, "*") {
+		t.Errorf("whole site isn't purged: %v", urls)
+	}
+
+	failures = PurgeAttempts
+	a.purges.Add(post)
+	a.purges.flush()
+	notes, err := model.GetNotifications(a.DB, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) != 1 || notes[0].Kind != NotifyPurgeFailed {
+		t.Errorf("admin isn't notified about failed purge: %v", notes)
+	}
+}
+
+//fakeCountries maps ips to countries in place of a GeoIP database
+type fakeCountries map[string]string
+
+func (c fakeCountries) Country(ip net.IP) (string, error) {
+	return c[ip.String()], nil
+}
+
+func TestBanLogUnauthorized(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+	path := filepath.Join(t.TempDir(), "ban.log")
+	a.banLog = newBanLog(path, DefaultBanLogFormat)
+
+	get := func(target string) int {
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, target, nil))
+		return rr.Code
+	}
+	//the words in the query string aren't admin routes
+	if status := get("/search?q=/admin/create"); status == http.StatusUnauthorized {
+		t.Errorf("search with admin route in the query returned wrong status code: got %v", status)
+	}
+	if status := get("/admin/audit?x=1"); status != http.StatusUnauthorized {
+		t.Errorf("admin route returned wrong status code: got %v want %v", status, http.StatusUnauthorized)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 || !strings.Contains(lines[0], BanUnauthorized) || !strings.Contains(lines[0], `"/admin/audit"`) {
+		t.Errorf("ban log has unexpected entries: %q", lines)
+	}
+}
+
+func TestFirewall(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	var err error
+	if a.firewall, err = newFirewall(Firewall{AdminAllow: []string{"10.0.0.0/8", "192.0.2.1"}, AdminDeny: []string{"10.1.0.0/16"}}); err != nil {
+		t.Fatal(err)
+	}
+	a.firewall.countries["XX"] = true
+	a.firewall.geo = fakeCountries{"198.51.100.7": "XX"}
+	a.initializeRoutes()
+
+	admin := a.Sessions.CreateSession(model.User{Type: session.ADMIN, Name: "admin"}, nil)
+	for path, ips := range map[string]map[string]int{
+		"/admin/jobs":    {"10.2.3.4": http.StatusOK, "192.0.2.1": http.StatusOK, "10.1.2.3": http.StatusForbidden, "203.0.113.5": http.StatusForbidden},
+		"/api/read-only": {"10.2.3.4": http.StatusOK, "203.0.113.5": http.StatusForbidden},
+		"/about":         {"203.0.113.5": http.StatusOK},
+	} {
+		for ip, want := range ips {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.RemoteAddr = ip + ":1234"
+			req.AddCookie(admin)
+			rr := httptest.NewRecorder()
+			a.Router.ServeHTTP(rr, req)
+			if rr.Code != want {
+				t.Errorf("%s from %s returned wrong status code: got %v want %v", path, ip, rr.Code, want)
+			}
+		}
+	}
+
+	p := model.Post{Title: "Firewall post", Body: "body", Date: "date"}
+	if err := p.CreatePost(a.DB); err != nil {
+		t.Fatal(err)
+	}
+	reader := a.Sessions.CreateSession(model.User{Type: session.GITHUB, Name: "firewall-reader"}, nil)
+	for ip, want := range map[string]int{"198.51.100.7": http.StatusForbidden, "198.51.100.8": http.StatusSeeOther} {
+		form := url.Values{"id": {strconv.Itoa(p.ID)}, "comment": {"from " + ip}}
+		req := httptest.NewRequest(http.MethodPost, "/create-comment", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = ip + ":1234"
+		req.AddCookie(reader)
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, req)
+		if rr.Code != want {
+			t.Errorf("comment from %s returned wrong status code: got %v want %v", ip, rr.Code, want)
+		}
+	}
+
+	entries, err := model.GetAuditEntries(a.DB, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := map[string]bool{}
+	for _, e := range entries {
+		found[e.Action] = true
+	}
+	if !found["firewall block"] || !found["comment blocked"] {
+		t.Errorf("blocked requests aren't in the audit log: %v", entries)
+	}
+
+	if _, err := newFirewall(Firewall{AdminAllow: []string{"not-a-network"}}); err == nil {
+		t.Errorf("invalid network is accepted")
+	}
+}
+
+func TestDiscourageIndexing(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+	a.Config.Robots.Discourage = true
+	a.initializeRoutes()
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		return rr
+	}
+
+	rr := get("/robots.txt")
+	if body := rr.Body.String(); body != "User-agent: *\nDisallow: /\n" {
+		t.Errorf("robots.txt doesn't disallow everything: %q", body)
+	}
+	for _, path := range []string{"/robots.txt", "/page?p=0", "/about", "/missing-page"} {
+		if tags := get(path).Header().Values("X-Robots-Tag"); len(tags) == 0 || tags[0] != "noindex" {
+			t.Errorf("%s isn't noindex: %q", path, tags)
+		}
+	}
+	if rr := get("/sitemap.xml"); rr.Code != http.StatusNotFound {
+		t.Errorf("sitemap is served while indexing is discouraged: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+}
+
+func TestSeedDemo(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	if !reflect.DeepEqual(demoContent(7, 10, now), demoContent(7, 10, now)) {
+		t.Errorf("demo content differs for the same seed")
+	}
+	if reflect.DeepEqual(demoContent(7, 10, now), demoContent(8, 10, now)) {
+		t.Errorf("demo content doesn't depend on the seed")
+	}
+
+	a := NewApp()
+	a.Initialize()
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "links.yml"), []byte("infos: []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sum, err := a.SeedDemo(DemoOptions{Seed: 7, Posts: 5, DataDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.Posts != 5 || !reflect.DeepEqual(sum.Files, []string{filepath.Join(dir, "courses.yml")}) {
+		t.Errorf("wrong seed summary: %+v", sum)
+	}
+	if courses, err := model.ConverYamlToStruct(filepath.Join(dir, "courses.yml")); err != nil || len(courses.List) == 0 {
+		t.Errorf("demo courses aren't readable: %v %v", courses, err)
+	}
+
+	posts, err := model.GetPosts(a.DB, 1, 0)
+	if err != nil || len(posts) == 0 {
+		t.Fatal("Unable to fetch seeded post", err)
+	}
+	rr := httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/post?id="+strconv.Itoa(posts[0].ID), nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), posts[0].Title) {
+		t.Errorf("seeded post isn't rendered: got %v", rr.Code)
+	}
+}
+
+func TestGoldenPages(t *testing.T) {
+	//a database of its own keeps ids and lists the same on every run
+	t.Setenv("DBURI", "file:"+filepath.Join(t.TempDir(), "golden.sqlite"))
+	t.Setenv("TIMEZONE", "UTC")
+	a := NewApp()
+	a.Initialize()
+	defer a.DB.Close()
+
+	p := fixtures.Post(t, a.DB, model.Post{Title: "Golden post", Body: "<p>Intro of the golden post</p>\n<!--more-->\n<p>The rest</p>\n```go\nfmt.Println(\"golden\")\n```"})
+	fixtures.Post(t, a.DB, model.Post{Title: "Second golden post"})
+	fixtures.Comment(t, a.DB, p.ID, "gopher", "First comment")
+	fixtures.Comment(t, a.DB, p.ID, "octocat", "Second comment with <b>markup</b>")
+
+	for name, path := range map[string]string{
+		"post":          "/post?id=" + strconv.Itoa(p.ID),
+		"page":          "/page?p=0",
+		"comments-feed": "/comments.rss",
+		"post-feed":     "/post/comments.rss?id=" + strconv.Itoa(p.ID),
+	} {
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		if rr.Code != http.StatusOK {
+			t.Errorf("%s returned wrong status code: got %v want %v", path, rr.Code, http.StatusOK)
+			continue
+		}
+		//posts are modified when they are stored
+		fixtures.Golden(t, name, rr.Body.Bytes(), fixtures.Replace(`"dateModified":"[^"]*"`, `"dateModified":"MODIFIED"`))
+	}
+}
+
+func TestTimezone(t *testing.T) {
+	t.Setenv("TIMEZONE", "Asia/Tokyo")
+	a := NewApp()
+	a.Initialize()
+
+	if got := a.isoDate("Mon Jan  1 12:00:00 2024"); got != "2024-01-01T12:00:00+09:00" {
+		t.Errorf("date isn't read in the site timezone: got %v", got)
+	}
+	if _, offset := a.now().Zone(); offset != 9*60*60 {
+		t.Errorf("current time isn't in the site timezone: offset %d", offset)
+	}
+
+	p := fixtures.Post(t, a.DB, model.Post{Title: "Tokyo post"})
+	rr := httptest.NewRecorder()
+	a.sitemap(rr, httptest.NewRequest(http.MethodGet, "/sitemap.xml", nil))
+	if !strings.Contains(rr.Body.String(), "+09:00</lastmod>") {
+		t.Errorf("sitemap lastmod of post %d isn't in the site timezone: %v", p.ID, rr.Body.String())
+	}
+
+	a.Config.Timezone = "Mars/Olympus_Mons"
+	if err := a.Config.Validate(); err == nil || !strings.Contains(err.Error(), "TIMEZONE") {
+		t.Errorf("unknown timezone is accepted: %v", err)
+	}
+}
+
+func TestPostExpiry(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+
+	past, future := time.Now().Add(-time.Hour).Unix(), time.Now().Add(time.Hour).Unix()
+	gone := fixtures.Post(t, a.DB, model.Post{Title: "Sale ends", ExpiresAt: past})
+	old := fixtures.Post(t, a.DB, model.Post{Title: "Old release", ExpiresAt: past, ExpiryAction: model.ExpiryOutdated})
+	kept := fixtures.Post(t, a.DB, model.Post{Title: "Upcoming meetup", ExpiresAt: future})
+
+	if err := a.expirePosts(); err != nil {
+		t.Fatal(err)
+	}
+	get := func(id int) model.Post {
+		p := model.Post{ID: id}
+		if err := p.GetPost(a.DB); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	if p := get(gone.ID); p.Published() || p.ExpiresAt != 0 {
+		t.Errorf("expired post isn't unpublished: %+v", p)
+	}
+	if p := get(old.ID); !p.Published() || !p.Outdated || p.Updated == 0 {
+		t.Errorf("expired post isn't marked outdated: %+v", p)
+	}
+	if p := get(kept.ID); !p.Published() || p.Outdated {
+		t.Errorf("post expiring later is changed: %+v", p)
+	}
+
+	rr := httptest.NewRecorder()
+	a.sitemap(rr, httptest.NewRequest(http.MethodGet, "/sitemap.xml", nil))
+	if strings.Contains(rr.Body.String(), "id="+strconv.Itoa(gone.ID)+"<") {
+		t.Errorf("unpublished post is in the sitemap: %v", rr.Body.String())
+	}
+	rr = httptest.NewRecorder()
+	a.recentPosts(rr, httptest.NewRequest(http.MethodGet, "/api/v1/posts/recent", nil))
+	if !strings.Contains(rr.Body.String(), `"outdated":true`) || !strings.Contains(rr.Body.String(), `"expires":"`) {
+		t.Errorf("feed doesn't reflect the expiry: %v", rr.Body.String())
+	}
+
+	//moving the expiry clears the outdated mark
+	p := get(old.ID)
+	form := url.Values{"id": {strconv.Itoa(p.ID)}, "title": {p.Title}, "body": {p.Body},
+		"expires_at": {a.expiryInput(future)}, "expiry_action": {model.ExpiryOutdated}}
+	req := httptest.NewRequest(http.MethodPost, "/update", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr = httptest.NewRecorder()
+	a.updatePost(rr, req)
+	if p = get(old.ID); p.Outdated || p.ExpiresAt != future-future%60 {
+		t.Errorf("expiry isn't updated: %+v", p)
+	}
+
+	form.Set("expiry_action", "explode")
+	req = httptest.NewRequest(http.MethodPost, "/update", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr = httptest.NewRecorder()
+	a.updatePost(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("unknown expiry action is accepted: %d", rr.Code)
+	}
+}
+
+func TestAgeBanner(t *testing.T) {
+	a := NewApp()
+	a.Initialize()
+	a.Config.Posts.AgeBanner = 2
+
+	date := func(years int) string {
+		return a.now().AddDate(-years, 0, -1).Format("Mon Jan _2 15:04:05 2006")
+	}
+	old := fixtures.Post(t, a.DB, model.Post{Title: "Old post", Date: date(3)})
+	recent := fixtures.Post(t, a.DB, model.Post{Title: "Recent post", Date: date(1)})
+	never := fixtures.Post(t, a.DB, model.Post{Title: "Evergreen post", Date: date(3), AgeBanner: model.AgeBannerNever})
+	own := fixtures.Post(t, a.DB, model.Post{Title: "Fast moving post", Date: date(1), AgeBanner: 1})
+
+	page := func(id int) string {
+		rr := httptest.NewRecorder()
+		a.getPost(rr, httptest.NewRequest(http.MethodGet, "/post?id="+strconv.Itoa(id), nil))
+		return rr.Body.String()
+	}
+	if body := page(old.ID); !strings.Contains(body, "This post is 3 years old") {
+		t.Errorf("old post has no age banner: %v", body)
+	}
+	if body := page(old.ID); !strings.Contains(body, `"dateModified":"`) || !strings.Contains(body, `"datePublished":"`) {
+		t.Errorf("structured data has no dates: %v", body)
+	}
+	if body := page(recent.ID); strings.Contains(body, "age-banner") {
+		t.Errorf("recent post has age banner: %v", body)
+	}
+	if body := page(never.ID); strings.Contains(body, "age-banner") {
+		t.Errorf("post hiding the banner has it: %v", body)
+	}
+	if body := page(own.ID); !strings.Contains(body, "This post is a year old") {
+		t.Errorf("threshold of the post is ignored: %v", body)
+	}
+
+	for v, want := range map[string]int{"": model.AgeBannerDefault, "never": model.AgeBannerNever, "5": 5} {
+		var p model.Post
+		if err := readAgeBanner(httptest.NewRequest(http.MethodGet, "/?age_banner="+v, nil), &p); err != nil || p.AgeBanner != want {
+			t.Errorf("age banner %q is read as %d: %v", v, p.AgeBanner, err)
+		}
+	}
+	if err := readAgeBanner(httptest.NewRequest(http.MethodGet, "/?age_banner=-3", nil), &model.Post{}); err == nil {
+		t.Error("negative age banner is accepted")
+	}
+}
+
+func TestWellKnown(t *testing.T) {
+	t.Setenv("SECURITY_CONTACT", "mailto:security@example.com,https://example.com/report")
+	t.Setenv("SECURITY_POLICY", "https://example.com/disclosure")
+	a := NewApp()
+	a.Initialize()
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		a.Router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		return rr
+	}
+
+	body := get("/.well-known/security.txt").Body.String()
+	for _, want := range []string{"Contact: mailto:security@example.com\n", "Contact: https://example.com/report\n",
+		"Policy: https://example.com/disclosure\n", "Expires: ", "Canonical: http://example.com/.well-known/security.txt\n"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("security.txt has no %q: %v", want, body)
+		}
+	}
+
+	var links nodeInfoLinks
+	if err := json.Unmarshal(get("/.well-known/nodeinfo").Body.Bytes(), &links); err != nil || len(links.Links) != 1 || links.Links[0].Rel != NodeInfoSchema {
+		t.Fatalf("nodeinfo doesn't link the schema: %+v %v", links, err)
+	}
+	rr := get(strings.TrimPrefix(links.Links[0].Href, "http://example.com"))
+	var info nodeInfo
+	if err := json.Unmarshal(rr.Body.Bytes(), &info); err != nil || info.Software.Name != NodeInfoSoftware || info.Usage.Users["total"] != 1 {
+		t.Errorf("unexpected nodeinfo: %v %v", rr.Body.String(), err)
+	}
+
+	if err := a.settings.Save(Settings{SiteTitle: "Blog", SocialLinks: []string{"https://mastodon.social/@jane"},
+		Author: Entity{Type: EntityPerson, SameAs: []string{"https://github.com/jane", "https://mastodon.social/@jane"}}}); err != nil {
+		t.Fatal(err)
+	}
+	body = get("/about").Body.String()
+	if strings.Count(body, `<link rel="me" href="https://mastodon.social/@jane" />`) != 1 || !strings.Contains(body, `<link rel="me" href="https://github.com/jane" />`) {
+		t.Errorf("profiles aren't linked with rel=me: %v", body)
+	}
+
+	a.Config.WellKnown.SecurityContacts = []string{"security@example.com"}
+	if err := a.Config.Validate(); err == nil || !strings.Contains(err.Error(), "SECURITY_CONTACT") {
+		t.Errorf("contact without scheme is accepted: %v", err)
+	}
+}
@@ -0,0 +1,25 @@
+package app
+
+import (
+	"io/fs"
+	"log"
+	"os"
+)
+
+//publicFiles returns static files served under /public/, PUBLIC_DIR overrides the files
+//embedded in the binary, e.g. to customize the styles without rebuilding
+func (a *App) publicFiles() fs.FS {
+	if a.Config.Public != "" || a.Assets == nil {
+		dir := a.Config.Public
+		if dir == "" {
+			dir = "public"
+		}
+		return os.DirFS(dir)
+	}
+
+	public, err := fs.Sub(a.Assets, "public")
+	if err != nil {
+		log.Fatal("Unable to open embedded public files: ", err)
+	}
+	return public
+}
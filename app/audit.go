@@ -0,0 +1,87 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/ultramozg/golang-blog-engine/model"
+)
+
+const (
+	AuditEntriesPerPage = 20
+)
+
+//audit records the admin action performed within the request into the audit log
+func (a *App) audit(r *http.Request, action, summary string) {
+	actor := "anonymous"
+	if u, ok := a.Sessions.GetUser(r); ok {
+		actor = u.Name
+	}
+	a.auditAs(actor, r, action, summary)
+}
+
+//auditAs is the same as audit but with explicitly set actor, used when the session is not created yet
+func (a *App) auditAs(actor string, r *http.Request, action, summary string) {
+	e := model.AuditEntry{
+		Actor:   actor,
+		IP:      a.storedIP(r),
+		Action:  action,
+		Summary: summary,
+		Date:    a.now().Format("Mon Jan _2 15:04:05 2006"),
+	}
+	if err := e.CreateAuditEntry(a.DB); err != nil {
+		log.Println("Unable to write audit log entry: ", err)
+	}
+}
+
+func (a *App) auditLog(w http.ResponseWriter, r *http.Request) {
+	if !a.Sessions.IsAdmin(r) {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
+
+	page, err := strconv.Atoi(r.FormValue("p"))
+	if err != nil {
+		page = 0
+	}
+
+	entries, err := model.GetAuditEntries(a.DB, AuditEntriesPerPage, page*AuditEntriesPerPage)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to fetch audit log: %v", err))
+		return
+	}
+	total, err := model.CountAuditEntries(a.DB)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to count audit log: %v", err))
+		return
+	}
+
+	data := struct {
+		PageData
+		Entries    []model.AuditEntry
+		IsPrevPage bool
+		IsNextPage bool
+		PrevPage   int
+		NextPage   int
+	}{
+		a.pageData(r),
+		entries,
+		page > 0,
+		(page+1)*AuditEntriesPerPage < total,
+		absolute(page - 1),
+		page + 1,
+	}
+	a.renderTemplate(w, r, "audit.gohtml", data)
+}
+
+//clientIP returns ip address of the remote side without port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,72 @@
+package app
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	//DefaultBanLogFormat puts the ip before the values sent by the client, so they can't fake a match
+	//of the fail2ban filter, e.g. failregex = ^\S+ \S+ (auth-failure|auth-locked) ip=<HOST>
+	DefaultBanLogFormat = "{{time}} {{event}} ip={{ip}} {{method}} {{path}} user={{user}}"
+	BanLogTimeFormat    = "2006-01-02 15:04:05"
+)
+
+//Events written to the ban log
+const (
+	BanAuthFailure  = "auth-failure"
+	BanAuthLocked   = "auth-locked"
+	BanUnauthorized = "unauthorized"
+	BanFirewall     = "firewall-block"
+	BanRateLimited  = "rate-limited"
+)
+
+//banLog appends failed logins and abusive requests to a file watched by fail2ban. The file is reopened
+//for every line, so it can be rotated by logrotate without signaling the blog
+type banLog struct {
+	mu     sync.Mutex
+	path   string
+	format string
+}
+
+//newBanLog returns nil if the path is empty, writing to nil ban log does nothing
+func newBanLog(path, format string) *banLog {
+	if path == "" {
+		return nil
+	}
+	return &banLog{path: path, format: format}
+}
+
+//Write appends the event of the request, the client's ip is written as is even if PRIVACY_IP_MODE
+//hides it elsewhere since fail2ban can't ban hashed ips
+func (l *banLog) Write(event, user string, r *http.Request) {
+	if l == nil {
+		return
+	}
+	values := map[string]string{
+		"time":   time.Now().Format(BanLogTimeFormat),
+		"event":  event,
+		"ip":     clientIP(r),
+		"method": r.Method,
+		"path":   strconv.Quote(r.URL.Path),
+		"user":   strconv.Quote(user),
+	}
+	line := strings.NewReplacer("\r", " ", "\n", " ").Replace(expandPlaceholders(l.format, values)) + "\n"
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		log.Println("Unable to open ban log: ", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.WriteString(line); err != nil {
+		log.Println("Unable to write ban log: ", err)
+	}
+}
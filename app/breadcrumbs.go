@@ -0,0 +1,52 @@
+package app
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/ultramozg/golang-blog-engine/model"
+)
+
+//breadcrumb is one level of the page location, the current page has no URL
+type breadcrumb struct {
+	Name string
+	URL  string
+}
+
+//postBreadcrumbs returns location of the post starting from the front page
+func (a *App) postBreadcrumbs(r *http.Request, p model.Post) []breadcrumb {
+	return []breadcrumb{
+		{"Home", a.baseURL(r) + "/"},
+		{p.Title, ""},
+	}
+}
+
+//breadcrumbData returns schema.org BreadcrumbList JSON-LD of the breadcrumbs,
+//the last item is the current page which is identified by url
+func breadcrumbData(crumbs []breadcrumb, current string) string {
+	items := make([]interface{}, 0, len(crumbs))
+	for i, c := range crumbs {
+		u := c.URL
+		if u == "" {
+			u = current
+		}
+		items = append(items, map[string]interface{}{
+			"@type":    "ListItem",
+			"position": i + 1,
+			"name":     c.Name,
+			"item":     u,
+		})
+	}
+
+	b, err := json.Marshal(map[string]interface{}{
+		"@context":        "https://schema.org",
+		"@type":           "BreadcrumbList",
+		"itemListElement": items,
+	})
+	if err != nil {
+		log.Println("Unable to marshal breadcrumbs: ", err)
+		return ""
+	}
+	return string(b)
+}
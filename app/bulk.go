@@ -0,0 +1,177 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ultramozg/golang-blog-engine/model"
+)
+
+//bulkActions are the operations which can be applied to many posts at once,
+//status changes are given as "status:<status>"
+var bulkActions = map[string]string{
+	"delete":    "Delete",
+	"pin":       "Pin",
+	"unpin":     "Unpin",
+	"feature":   "Feature",
+	"unfeature": "Unfeature",
+}
+
+//bulkItem is the post selected for the bulk action, Skip tells why the action doesn't apply to it
+type bulkItem struct {
+	Post model.Post
+	Skip string
+}
+
+//adminPosts lists posts in every status with checkboxes for bulk actions
+func (a *App) adminPosts(w http.ResponseWriter, r *http.Request) {
+	if !a.Sessions.IsAdmin(r) {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
+
+	posts, err := model.GetAllPosts(a.DB)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to fetch posts: %v", err))
+		return
+	}
+	sort.Slice(posts, func(i, j int) bool { return posts[i].ID > posts[j].ID })
+
+	data := struct {
+		PageData
+		Posts    []model.Post
+		Actions  map[string]string
+		Statuses []string
+	}{
+		a.pageData(r).WithRobots("noindex"),
+		posts,
+		bulkActions,
+		[]string{model.StatusDraft, model.StatusReview, model.StatusApproved, model.StatusPublished},
+	}
+	a.renderTemplate(w, r, "adminposts.gohtml", data)
+}
+
+//bulkPosts applies the action to the selected posts, the first request shows what is going to change
+//and the action is applied only after it's confirmed
+func (a *App) bulkPosts(w http.ResponseWriter, r *http.Request) {
+	if !a.Sessions.IsAdmin(r) {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		a.renderError(w, r, http.StatusBadRequest, errors.New("Invalid payload"))
+		return
+	}
+
+	action := r.FormValue("action")
+	status := strings.TrimPrefix(action, "status:")
+	if _, ok := bulkActions[action]; !ok && !(status != action && model.IsStatus(status)) {
+		a.renderError(w, r, http.StatusBadRequest, errors.New("Unknown bulk action"))
+		return
+	}
+
+	ids := []int{}
+	for _, v := range r.Form["id"] {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			a.renderError(w, r, http.StatusBadRequest, errors.New("Invalid post id"))
+			return
+		}
+		ids = append(ids, id)
+	}
+	posts, err := model.GetPostsByIDs(a.DB, ids)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to fetch posts: %v", err))
+		return
+	}
+	if len(posts) == 0 {
+		a.renderError(w, r, http.StatusBadRequest, errors.New("No posts selected"))
+		return
+	}
+
+	items := make([]bulkItem, len(posts))
+	for i, p := range posts {
+		items[i].Post = p
+		if status != action && p.Status != status && !model.CanTransition(p.Status, status) {
+			items[i].Skip = "can't move from " + p.Status + " to " + status
+		}
+	}
+
+	if r.FormValue("confirm") != "yes" {
+		label := bulkActions[action]
+		if label == "" {
+			label = "Move to " + status
+		}
+		data := struct {
+			PageData
+			Action string
+			Label  string
+			Items  []bulkItem
+		}{
+			a.pageData(r).WithRobots("noindex"),
+			action,
+			label,
+			items,
+		}
+		a.renderTemplate(w, r, "bulkconfirm.gohtml", data)
+		return
+	}
+
+	applied := []string{}
+	for _, it := range items {
+		if it.Skip != "" {
+			continue
+		}
+		if err := a.bulkApply(it.Post, action, status); err != nil {
+			a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("bulk %s failed on post %d after %d posts: %v", action, it.Post.ID, len(applied), err))
+			return
+		}
+		applied = append(applied, strconv.Itoa(it.Post.ID))
+		if status != action {
+			it.Post.Status = status
+			a.announce(r, it.Post)
+		}
+	}
+	a.audit(r, "posts bulk "+action, fmt.Sprintf("posts %s", strings.Join(applied, ", ")))
+	http.Redirect(w, r, "/admin/posts", http.StatusSeeOther)
+}
+
+//bulkApply applies the bulk action to one post
+func (a *App) bulkApply(p model.Post, action, status string) error {
+	switch action {
+	case "delete":
+		_, err := p.DeletePost(a.DB)
+		return err
+	case "pin", "unpin":
+		return p.SetPinned(a.DB, action == "pin")
+	case "feature", "unfeature":
+		return p.SetFeatured(a.DB, action == "feature")
+	}
+	return p.SetStatus(a.DB, status, p.Reviewer)
+}
+
+//clonePost copies the post into a new draft to be used as a template of recurring posts
+//and opens the copy in the editor
+func (a *App) clonePost(w http.ResponseWriter, r *http.Request) {
+	if !a.Sessions.IsAdmin(r) {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
+
+	p, err := a.loadPost(r.FormValue("clone"))
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	id, err := p.ClonePost(a.DB, p.Title+" (copy)", a.now().Format("Mon Jan _2 15:04:05 2006"))
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to clone post %d: %v", p.ID, err))
+		return
+	}
+	a.audit(r, "post clone", fmt.Sprintf("post %d %q copied to post %d", p.ID, p.Title, id))
+	http.Redirect(w, r, "/update?id="+strconv.Itoa(id), http.StatusSeeOther)
+}
@@ -0,0 +1,67 @@
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ultramozg/golang-blog-engine/cache"
+	"github.com/ultramozg/golang-blog-engine/model"
+)
+
+//caches hold data of the hottest read handlers, writes of the model are published on the bus
+//and invalidate it, topics are the model ones and keys are post ids
+type caches struct {
+	bus      *cache.Bus[int]
+	posts    *cache.Cache[int, model.Post]
+	comments *cache.Cache[int, []model.Comment]
+}
+
+func newCaches(db *sql.DB, ttl time.Duration) *caches {
+	c := &caches{
+		bus:      cache.NewBus[int](),
+		posts:    cache.New[int, model.Post](ttl),
+		comments: cache.New[int, []model.Comment](ttl),
+	}
+	cache.Attach(c.bus, model.TopicPosts, c.posts)
+	cache.Attach(c.bus, model.TopicComments, c.comments)
+	model.OnChange(db, func(topic string, ids ...int) {
+		if len(ids) == 0 {
+			c.bus.InvalidateAll(topic)
+			return
+		}
+		c.bus.Invalidate(topic, ids...)
+	})
+	return c
+}
+
+//prune drops expired entries
+func (c *caches) prune() error {
+	c.posts.Prune()
+	c.comments.Prune()
+	return nil
+}
+
+//cachedPost is loadPost served from the cache, the post must not be modified
+func (a *App) cachedPost(id string) (model.Post, error) {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return model.Post{}, newError(http.StatusBadRequest, "Invalid post id")
+	}
+	return a.cache.posts.Load(n, func() (model.Post, error) {
+		return a.loadPost(id)
+	})
+}
+
+//cachedComments returns comments of the post from the cache, the slice must not be modified
+func (a *App) cachedComments(postID int) ([]model.Comment, error) {
+	return a.cache.comments.Load(postID, func() ([]model.Comment, error) {
+		comments, err := model.GetComments(a.DB, postID)
+		if err != nil {
+			return nil, fmt.Errorf("unable to fetch comments of post %d: %v", postID, err)
+		}
+		return comments, nil
+	})
+}
@@ -0,0 +1,106 @@
+package app
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ultramozg/golang-blog-engine/model"
+)
+
+//Classes of the responses, each has its own lifetime in caches
+const (
+	CachePosts  = "posts"
+	CacheLists  = "lists"
+	CacheFeeds  = "feeds"
+	CacheStatic = "static"
+)
+
+//cachePolicy sets caching headers of the responses from the configured lifetimes of their classes
+type cachePolicy struct {
+	ttls map[string]time.Duration
+	cdn  bool
+	//seed changes the validators on every start, templates could have changed
+	seed string
+}
+
+func newCachePolicy(cfg HTTPCache) cachePolicy {
+	return cachePolicy{
+		ttls: map[string]time.Duration{CachePosts: cfg.Posts, CacheLists: cfg.Lists, CacheFeeds: cfg.Feeds, CacheStatic: cfg.Static},
+		cdn:  cfg.CDN,
+		seed: strconv.FormatInt(time.Now().UnixNano(), 36),
+	}
+}
+
+//apply sets Cache-Control of the class, behind a CDN it's accompanied by Surrogate-Control and
+//CDN-Cache-Control understood by the CDNs. Pages are kept only by the CDN, so purging them is enough
+//to publish changes, browsers revalidate them. Private responses are never kept by shared caches
+func (p cachePolicy) apply(h http.Header, class string, private bool) {
+	ttl := p.ttls[class]
+	if private || ttl <= 0 {
+		noCache(h, private)
+		return
+	}
+
+	maxAge := "max-age=" + strconv.Itoa(int(ttl.Seconds()))
+	if !p.cdn {
+		h.Set("Cache-Control", "public, "+maxAge)
+		return
+	}
+	if class == CacheStatic {
+		h.Set("Cache-Control", "public, "+maxAge)
+	} else {
+		h.Set("Cache-Control", "public, max-age=0, must-revalidate")
+	}
+	h.Set("Surrogate-Control", maxAge)
+	h.Set("CDN-Cache-Control", maxAge)
+}
+
+//noCache makes clients revalidate the response on every use, e.g. errors and pages of logged in users
+func noCache(h http.Header, private bool) {
+	h.Del("Surrogate-Control")
+	h.Del("CDN-Cache-Control")
+	if private {
+		h.Set("Cache-Control", "private, no-cache")
+		return
+	}
+	h.Set("Cache-Control", "no-cache")
+}
+
+//cacheable applies the cache policy of the class to the responses of the handler,
+//pages rendered for a logged in user are private
+func (a *App) cacheable(class string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, loggedIn := a.Sessions.GetUser(r)
+		a.caching.apply(w.Header(), class, loggedIn && class != CacheStatic)
+		h(w, r)
+	}
+}
+
+//postETag returns weak validator of the post page. It's keyed on the post with its update time and on its
+//comments, likes of both are shown on the page too, as well as the site and its settings. The post itself
+//is hashed since some changes don't touch the update time and it has only a resolution of a second
+func (a *App) postETag(r *http.Request, p model.Post, comms []model.Comment) string {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s|%s|%+v|%+v|%d", a.caching.seed, a.baseURL(r), a.settings.Get(), p, a.postAge(p))
+	for _, c := range comms {
+		fmt.Fprintf(h, "|%d:%s:%d", c.CommentID, c.Date, c.Likes)
+	}
+	return `W/"` + strconv.FormatUint(h.Sum64(), 36) + `"`
+}
+
+//notModified sets ETag of the response and reports whether the client has the same version of it,
+//If-None-Match is compared weakly as required for GET and HEAD
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	for _, tag := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -1,7 +1,17 @@
 package app
 
 import (
+	"fmt"
+	"io/ioutil"
+	"log"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ultramozg/golang-blog-engine/model"
 )
 
 type Server struct {
@@ -18,46 +28,751 @@ type OAuth struct {
 	ClientSecret       string
 }
 
+//Database holds connection pool and SQLite tuning parameters
+type Database struct {
+	MaxOpenConns int
+	MaxIdleConns int
+	JournalMode  string
+	//MaintenanceHour is hour of the site timezone the database is optimized and vacuumed at, -1 disables it
+	MaintenanceHour int
+}
+
+//Robots holds robots.txt rules applied to all user agents. Discourage keeps staging deployments out of
+//search engines: robots.txt disallows everything, every response is noindex and there is no sitemap
+type Robots struct {
+	Allow      []string
+	Disallow   []string
+	Discourage bool
+}
+
+//Cookie holds attributes of the cookies issued by the blog, values are encrypted with key derived from Secret.
+//Secure defaults to true in production, SameSite is either "lax" or "strict"
+type Cookie struct {
+	Secret   string
+	Domain   string
+	Path     string
+	Secure   bool
+	SameSite string
+}
+
+//Headless turns the blog into API only backend, HTML pages are disabled
+//and robots.txt is served only if Robots is set
+type Headless struct {
+	Enabled bool
+	Robots  bool
+}
+
+//CORS holds cross origin policy of the API routes, empty Origins disables CORS
+type CORS struct {
+	Origins     []string
+	Methods     []string
+	Headers     []string
+	Credentials bool
+	MaxAge      time.Duration
+}
+
+//Posts holds defaults of the post lists, the admin can override them in site settings.
+//Duplicates is what happens when a new post is nearly identical to an existing one:
+//"warn" asks the admin to confirm, "block" rejects the post and "off" skips the check
+type Posts struct {
+	PerPage int
+	//Sort is "published" or "updated"
+	Sort       string
+	Duplicates string
+	//DuplicateSimilarity is the title or body similarity in percent from which posts are duplicates
+	DuplicateSimilarity int
+	//AgeBanner is the age in years from which posts show "this post is N years old" banner, zero disables it.
+	//Posts can override it with their own threshold
+	AgeBanner int
+}
+
+//Mail holds SMTP settings used to notify the admin, mail is disabled if SMTPAddr is empty.
+//Comments subscribed readers are notified about are batched and sent every ReplyInterval
+type Mail struct {
+	SMTPAddr      string
+	User          string
+	Password      string
+	From          string
+	AdminEmail    string
+	ReplyInterval time.Duration
+}
+
+//Login holds brute-force protection settings of the admin login,
+//MaxFailures failed attempts within Lockout period lock the account and the ip until they expire
+type Login struct {
+	MaxFailures int
+	Lockout     time.Duration
+	Notify      bool
+	//RememberFor is lifetime of the "remember me" token, zero disables it
+	RememberFor time.Duration
+}
+
+//Sanitize holds settings of the html sanitizer applied to post bodies
+type Sanitize struct {
+	Posts  bool
+	Strict bool
+	Tags   []string
+	Attrs  []string
+}
+
+//Embed holds settings of converting links in posts into embeds
+type Embed struct {
+	Enabled bool
+	TTL     time.Duration
+	//CacheSize is the number of links whose markup is cached
+	CacheSize int
+}
+
+//Scheduler holds settings of the periodic jobs runner
+type Scheduler struct {
+	Jitter time.Duration
+}
+
+//Jobs enables the housekeeping jobs, each one is set by JOB_<NAME> like JOB_LINK_CHECK
+type Jobs struct {
+	LoginCleanup         bool
+	NotificationsCleanup bool
+	PostExpiry           bool
+	CommentsRecount      bool
+}
+
+//LinkCheck holds settings of the background broken link checker
+type LinkCheck struct {
+	Enabled  bool
+	Interval time.Duration
+	Delay    time.Duration
+	TTL      time.Duration
+}
+
+//Privacy holds personal data settings, IPMode is how client ips are stored in the login attempts
+//and the audit log: "full", "truncate" or "hash", Retention is how long statistics are kept.
+//Consent asks readers before collecting statistics about them
+type Privacy struct {
+	IPMode    string
+	Retention time.Duration
+	Consent   bool
+}
+
+//Announce holds social networks published posts are shared on, a channel is enabled when its credentials are set.
+//Templates are the texts of the announcements with {{title}} and {{url}} placeholders. Announcements are sent
+//Delay after publishing so they can be cancelled, failed ones are retried every Interval
+type Announce struct {
+	MastodonURL      string
+	MastodonToken    string
+	MastodonTemplate string
+	BlueskyPDS       string
+	BlueskyHandle    string
+	BlueskyPassword  string
+	BlueskyTemplate  string
+	TelegramAPI      string
+	TelegramToken    string
+	TelegramChat     string
+	TelegramTemplate string
+	Delay            time.Duration
+	Interval         time.Duration
+}
+
+//Purge holds credentials of the CDN APIs, pages are purged from every configured CDN when they change
+type Purge struct {
+	CloudflareZone  string
+	CloudflareToken string
+	FastlyService   string
+	FastlyToken     string
+	BunnyPullZone   string
+	BunnyKey        string
+}
+
+//Tracing holds OpenTelemetry settings, spans are exported with OTLP/HTTP to Endpoint if it's set.
+//SampleRatio is the share of traces recorded, from 0 to 1
+type Tracing struct {
+	Endpoint    string
+	Service     string
+	SampleRatio float64
+}
+
+//Pprof exposes runtime profiles: under /debug/pprof/ to the admin if Enabled and without authentication
+//on Addr, which must be a loopback address
+type Pprof struct {
+	Enabled bool
+	Addr    string
+}
+
+//Cache holds settings of the in-memory cache of posts and comments, zero TTL disables it
+type Cache struct {
+	TTL time.Duration
+}
+
+//HTTPCache holds lifetimes of the responses in browser and proxy caches, zero lifetime makes clients
+//revalidate on every visit. Behind a CDN pages are kept by the CDN and revalidated by browsers
+type HTTPCache struct {
+	Posts  time.Duration
+	Lists  time.Duration
+	Feeds  time.Duration
+	Static time.Duration
+	CDN    bool
+}
+
+//Events limits live comment streams of the post pages, zero MaxConnections disables them
+type Events struct {
+	MaxConnections int
+	MaxPerClient   int
+}
+
+//Firewall restricts admin routes to AdminAllow networks except AdminDeny ones, both are CIDR lists and
+//empty AdminAllow allows all networks. Comments from BlockCountries (ISO 3166-1 codes) are rejected,
+//countries are looked up in GeoIPDB MaxMind database, e.g. GeoLite2-Country.mmdb
+type Firewall struct {
+	AdminAllow     []string
+	AdminDeny      []string
+	GeoIPDB        string
+	BlockCountries []string
+}
+
+//BanLog is the file failed logins and abusive requests are written to for fail2ban, empty Path disables it.
+//Format is a line of the log with {{time}}, {{event}}, {{ip}}, {{method}}, {{path}} and {{user}} placeholders
+type BanLog struct {
+	Path   string
+	Format string
+}
+
+//WellKnown holds /.well-known documents generated from the configuration. security.txt is served only
+//if SecurityContacts are set, its expiry is SecurityExpires after the request. Contacts are mailto:, tel:
+//or https: addresses, SecurityPolicy and SecurityEncryption are https: addresses of the disclosure policy
+//and the public key
+type WellKnown struct {
+	NodeInfo           bool
+	SecurityContacts   []string
+	SecurityPolicy     string
+	SecurityEncryption string
+	SecurityExpires    time.Duration
+}
+
+//Site is a domain the blog is served on together with the language of its visitors
+type Site struct {
+	Host string
+	Lang string
+}
+
 //Config is strcuct which holds necesary data such as server conf
 //database, log, cert, oauth
 type Config struct {
 	Server     Server
 	OAuth      OAuth
+	Database   Database
+	Robots     Robots
+	Cookie     Cookie
+	Headless   Headless
+	Posts      Posts
+	CORS       CORS
+	Mail       Mail
+	Login      Login
+	Sanitize   Sanitize
+	Embed      Embed
+	Scheduler  Scheduler
+	LinkCheck  LinkCheck
+	Jobs       Jobs
+	Privacy    Privacy
+	Announce   Announce
+	Tracing    Tracing
+	Pprof      Pprof
+	Cache      Cache
+	HTTPCache  HTTPCache
+	Purge      Purge
+	Events     Events
+	Firewall   Firewall
+	BanLog     BanLog
+	WellKnown  WellKnown
 	Production string
 	DBURI      string
 	Domain     string
-	AdminPass  string
-	Templates  string
+	//ReadOnly starts the blog in read-only mode
+	ReadOnly bool
+	//Timezone is IANA name of the timezone dates of posts and comments are written in and hours of
+	//daily jobs are counted in, e.g. Europe/Berlin. The server local time is used if it's empty
+	Timezone string
+	//Sites are all domains of the blog, the first one is the default for hreflang
+	Sites     []Site
+	AdminPass string
+	Templates string
+	//CertDir caches TLS certificates issued by Let's Encrypt
+	CertDir string
+	//PIDFile is written on start and removed on stop when set, e.g. for PIDFile= of systemd unit
+	PIDFile string
+	//Templates and Public override templates and static files embedded in the binary with the files on disk
+	Public string
 }
 
-//NewConfig create config structure
+//lookupFunc returns configuration value by the name of its environment variable
+type lookupFunc func(key string) (string, bool)
+
+//NewConfig create config structure from the environment
 func newConfig() *Config {
+	return newConfigFrom(os.LookupEnv)
+}
+
+//newConfigFrom create config structure, values are looked up by the names of environment variables
+func newConfigFrom(env lookupFunc) *Config {
 	return &Config{
 		Server: Server{
-			Addr:  getEnv("IP_ADDR", "0.0.0.0"),
-			Http:  getEnv("HTTP_PORT", ":8080"),
-			Https: getEnv("HTTPS_PORT", ":8443"),
+			Addr:  env.getEnv("IP_ADDR", "0.0.0.0"),
+			Http:  env.getEnv("HTTP_PORT", ":8080"),
+			Https: env.getEnv("HTTPS_PORT", ":8443"),
 		},
 		OAuth: OAuth{
-			GithubAuthorizeURL: getEnv("GITHUB_AUTHORIZE_URL", ""),
-			GithubTokenURL:     getEnv("GITHUB_TOKEN_URL", ""),
-			RedirectURL:        getEnv("REDIRECT_URL", ""),
-			ClientID:           getEnv("CLIENT_ID", ""),
-			ClientSecret:       getEnv("CLIENT_SECRET", ""),
+			GithubAuthorizeURL: env.getEnv("GITHUB_AUTHORIZE_URL", ""),
+			GithubTokenURL:     env.getEnv("GITHUB_TOKEN_URL", ""),
+			RedirectURL:        env.getEnv("REDIRECT_URL", ""),
+			ClientID:           env.getEnv("CLIENT_ID", ""),
+			ClientSecret:       env.getEnv("CLIENT_SECRET", ""),
+		},
+		Database: Database{
+			MaxOpenConns:    env.getEnvInt("DB_MAX_OPEN_CONNS", 8),
+			MaxIdleConns:    env.getEnvInt("DB_MAX_IDLE_CONNS", 4),
+			JournalMode:     env.getEnv("DB_JOURNAL_MODE", "WAL"),
+			MaintenanceHour: env.getEnvInt("DB_MAINTENANCE_HOUR", 4),
+		},
+		Robots: Robots{
+			Allow:      env.getEnvList("ROBOTS_ALLOW", nil),
+			Disallow:   env.getEnvList("ROBOTS_DISALLOW", []string{"/login", "/logout", "/create", "/update", "/delete", "/admin/", "/api/", "/auth-callback"}),
+			Discourage: env.getEnv("DISCOURAGE_INDEXING", "false") == "true",
+		},
+		Cookie: Cookie{
+			Secret:   env.getEnv("COOKIE_SECRET", ""),
+			Domain:   env.getEnv("COOKIE_DOMAIN", ""),
+			Path:     env.getEnv("COOKIE_PATH", "/"),
+			Secure:   env.getEnv("COOKIE_SECURE", env.getEnv("PRODUCTION", "false")) == "true",
+			SameSite: env.getEnv("COOKIE_SAMESITE", "lax"),
+		},
+		Headless: Headless{
+			Enabled: env.getEnv("HEADLESS", "false") == "true",
+			Robots:  env.getEnv("HEADLESS_ROBOTS", "false") == "true",
+		},
+		CORS: CORS{
+			Origins:     env.getEnvList("CORS_ALLOWED_ORIGINS", []string{}),
+			Methods:     env.getEnvList("CORS_ALLOWED_METHODS", []string{"GET", "POST", "OPTIONS"}),
+			Headers:     env.getEnvList("CORS_ALLOWED_HEADERS", []string{"Content-Type"}),
+			Credentials: env.getEnv("CORS_ALLOW_CREDENTIALS", "false") == "true",
+			MaxAge:      env.getEnvDuration("CORS_MAX_AGE", 10*time.Minute),
+		},
+		Posts: Posts{
+			PerPage:             env.getEnvInt("POSTS_PER_PAGE", PostsPerPage),
+			Sort:                env.getEnv("POSTS_SORT", "published"),
+			Duplicates:          env.getEnv("DUPLICATE_POSTS", DuplicatesWarn),
+			DuplicateSimilarity: env.getEnvInt("DUPLICATE_SIMILARITY", 90),
+			AgeBanner:           env.getEnvInt("AGE_BANNER_YEARS", 0),
+		},
+		Mail: Mail{
+			SMTPAddr:      env.getEnv("SMTP_ADDR", ""),
+			User:          env.getEnv("SMTP_USER", ""),
+			Password:      env.getEnv("SMTP_PASSWORD", ""),
+			From:          env.getEnv("MAIL_FROM", ""),
+			AdminEmail:    env.getEnv("ADMIN_EMAIL", ""),
+			ReplyInterval: env.getEnvDuration("REPLY_NOTIFY_INTERVAL", 15*time.Minute),
+		},
+		Login: Login{
+			MaxFailures: env.getEnvInt("LOGIN_MAX_FAILURES", 5),
+			Lockout:     env.getEnvDuration("LOGIN_LOCKOUT", 15*time.Minute),
+			Notify:      env.getEnv("LOGIN_LOCKOUT_NOTIFY", "false") == "true",
+			RememberFor: env.getEnvDuration("LOGIN_REMEMBER_FOR", 30*24*time.Hour),
+		},
+		Sanitize: Sanitize{
+			Posts:  env.getEnv("SANITIZE_POSTS", "false") == "true",
+			Strict: env.getEnv("SANITIZE_STRICT", "false") == "true",
+			Tags:   env.getEnvList("SANITIZE_ALLOW_TAGS", nil),
+			Attrs:  env.getEnvList("SANITIZE_ALLOW_ATTRS", nil),
 		},
-		Templates:  getEnv("TEMPLATES", "templates/*.gohtml"),
-		Production: getEnv("PRODUCTION", "false"),
-		DBURI:      getEnv("DBURI", "file:database/database.sqlite"),
-		Domain:     getEnv("DOMAIN", ""),
-		AdminPass:  getEnv("ADMIN_PASSWORD", "12345"),
+		Embed: Embed{
+			Enabled:   env.getEnv("EMBEDS", "true") == "true",
+			TTL:       env.getEnvDuration("EMBED_CACHE_TTL", 24*time.Hour),
+			CacheSize: env.getEnvInt("EMBED_CACHE_SIZE", 512),
+		},
+		Announce: Announce{
+			MastodonURL:      env.getEnv("MASTODON_URL", ""),
+			MastodonToken:    env.getEnv("MASTODON_TOKEN", ""),
+			MastodonTemplate: env.getEnv("MASTODON_TEMPLATE", DefaultAnnounceTemplate),
+			BlueskyPDS:       env.getEnv("BLUESKY_PDS", "https://bsky.social"),
+			BlueskyHandle:    env.getEnv("BLUESKY_HANDLE", ""),
+			BlueskyPassword:  env.getEnv("BLUESKY_APP_PASSWORD", ""),
+			BlueskyTemplate:  env.getEnv("BLUESKY_TEMPLATE", DefaultAnnounceTemplate),
+			TelegramAPI:      env.getEnv("TELEGRAM_API", "https://api.telegram.org"),
+			TelegramToken:    env.getEnv("TELEGRAM_BOT_TOKEN", ""),
+			TelegramChat:     env.getEnv("TELEGRAM_CHAT_ID", ""),
+			TelegramTemplate: env.getEnv("TELEGRAM_TEMPLATE", DefaultAnnounceTemplate),
+			Delay:            env.getEnvDuration("ANNOUNCE_DELAY", 10*time.Minute),
+			Interval:         env.getEnvDuration("ANNOUNCE_INTERVAL", time.Minute),
+		},
+		Tracing: Tracing{
+			Endpoint:    env.getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
+			Service:     env.getEnv("OTEL_SERVICE_NAME", "golang-blog-engine"),
+			SampleRatio: env.getEnvFloat("OTEL_TRACES_SAMPLER_ARG", 1),
+		},
+		Purge: Purge{
+			CloudflareZone:  env.getEnv("CLOUDFLARE_ZONE_ID", ""),
+			CloudflareToken: env.getEnv("CLOUDFLARE_API_TOKEN", ""),
+			FastlyService:   env.getEnv("FASTLY_SERVICE_ID", ""),
+			FastlyToken:     env.getEnv("FASTLY_API_TOKEN", ""),
+			BunnyPullZone:   env.getEnv("BUNNY_PULL_ZONE_ID", ""),
+			BunnyKey:        env.getEnv("BUNNY_ACCESS_KEY", ""),
+		},
+		Pprof: Pprof{
+			Enabled: env.getEnv("PPROF", "false") == "true",
+			Addr:    env.getEnv("PPROF_ADDR", ""),
+		},
+		Cache: Cache{
+			TTL: env.getEnvDuration("CACHE_TTL", time.Minute),
+		},
+		HTTPCache: HTTPCache{
+			Posts:  env.getEnvDuration("HTTP_CACHE_POSTS", 0),
+			Lists:  env.getEnvDuration("HTTP_CACHE_LISTS", 0),
+			Feeds:  env.getEnvDuration("HTTP_CACHE_FEEDS", 0),
+			Static: env.getEnvDuration("HTTP_CACHE_STATIC", 30*24*time.Hour),
+			CDN:    env.getEnv("HTTP_CACHE_CDN", "false") == "true",
+		},
+		Events: Events{
+			MaxConnections: env.getEnvInt("EVENTS_MAX_CONNECTIONS", 1000),
+			MaxPerClient:   env.getEnvInt("EVENTS_MAX_PER_CLIENT", 4),
+		},
+		Firewall: Firewall{
+			AdminAllow:     env.getEnvList("ADMIN_ALLOW", nil),
+			AdminDeny:      env.getEnvList("ADMIN_DENY", nil),
+			GeoIPDB:        env.getEnv("GEOIP_DB", ""),
+			BlockCountries: env.getEnvList("COMMENT_BLOCK_COUNTRIES", nil),
+		},
+		BanLog: BanLog{
+			Path:   env.getEnv("BANLOG_PATH", ""),
+			Format: env.getEnv("BANLOG_FORMAT", DefaultBanLogFormat),
+		},
+		WellKnown: WellKnown{
+			NodeInfo:           env.getEnv("NODEINFO", "true") == "true",
+			SecurityContacts:   env.getEnvList("SECURITY_CONTACT", nil),
+			SecurityPolicy:     env.getEnv("SECURITY_POLICY", ""),
+			SecurityEncryption: env.getEnv("SECURITY_ENCRYPTION", ""),
+			SecurityExpires:    env.getEnvDuration("SECURITY_TXT_EXPIRES", 30*24*time.Hour),
+		},
+		Scheduler: Scheduler{
+			Jitter: env.getEnvDuration("JOBS_JITTER", time.Minute),
+		},
+		LinkCheck: LinkCheck{
+			Enabled:  env.getEnv("JOB_LINK_CHECK", "true") == "true",
+			Interval: env.getEnvDuration("LINK_CHECK_INTERVAL", 24*time.Hour),
+			Delay:    env.getEnvDuration("LINK_CHECK_DELAY", time.Second),
+			TTL:      env.getEnvDuration("LINK_CHECK_TTL", 72*time.Hour),
+		},
+		Jobs: Jobs{
+			LoginCleanup:         env.getEnv("JOB_LOGIN_CLEANUP", "true") == "true",
+			NotificationsCleanup: env.getEnv("JOB_NOTIFICATIONS_CLEANUP", "true") == "true",
+			PostExpiry:           env.getEnv("JOB_POST_EXPIRY", "true") == "true",
+			CommentsRecount:      env.getEnv("JOB_COMMENTS_RECOUNT", "true") == "true",
+		},
+		Privacy: Privacy{
+			IPMode:    env.getEnv("PRIVACY_IP_MODE", IPFull),
+			Retention: env.getEnvDuration("DATA_RETENTION", 90*24*time.Hour),
+			Consent:   env.getEnv("PRIVACY_CONSENT", "false") == "true",
+		},
+		Templates:  env.getEnv("TEMPLATES", ""),
+		Public:     env.getEnv("PUBLIC_DIR", ""),
+		CertDir:    env.getEnv("CERT_DIR", "cert"),
+		PIDFile:    env.getEnv("PID_FILE", ""),
+		Production: env.getEnv("PRODUCTION", "false"),
+		DBURI:      env.getEnv("DBURI", "file:database/database.sqlite"),
+		Domain:     env.getEnv("DOMAIN", ""),
+		ReadOnly:   env.getEnv("READ_ONLY", "false") == "true",
+		Timezone:   env.getEnv("TIMEZONE", ""),
+		Sites:      env.getEnvSites("DOMAINS", env.getEnv("DOMAIN", ""), env.getEnv("SITE_LANG", "en")),
+		AdminPass:  env.getEnv("ADMIN_PASSWORD", "12345"),
 	}
 }
 
-// Simple helper function to read an environment or return a default value
-func getEnv(key string, defaultVal string) string {
-	if value, exists := os.LookupEnv(key); exists {
+//Simple helper function to read an environment or return a default value
+func (env lookupFunc) getEnv(key string, defaultVal string) string {
+	if value, exists := env(key); exists {
 		return value
 	}
 
 	return defaultVal
 }
+
+//Simple helper function to read an integer environment or return a default value
+func (env lookupFunc) getEnvInt(key string, defaultVal int) int {
+	value, exists := env(key)
+	if !exists {
+		return defaultVal
+	}
+
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid value %q for %s, using default %d", value, key, defaultVal)
+		return defaultVal
+	}
+	return i
+}
+
+//Simple helper function to read a decimal environment or return a default value
+func (env lookupFunc) getEnvFloat(key string, defaultVal float64) float64 {
+	value, exists := env(key)
+	if !exists {
+		return defaultVal
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("Invalid value %q for %s, using default %v", value, key, defaultVal)
+		return defaultVal
+	}
+	return f
+}
+
+//Simple helper function to read a comma separated environment or return a default value
+func (env lookupFunc) getEnvList(key string, defaultVal []string) []string {
+	value, exists := env(key)
+	if !exists {
+		return defaultVal
+	}
+
+	list := []string{}
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+//Simple helper function to read a duration environment (e.g. "1h30m") or return a default value
+func (env lookupFunc) getEnvDuration(key string, defaultVal time.Duration) time.Duration {
+	value, exists := env(key)
+	if !exists {
+		return defaultVal
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid value %q for %s, using default %s", value, key, defaultVal)
+		return defaultVal
+	}
+	return d
+}
+
+//getEnvSites reads comma separated host=lang pairs, e.g. "example.com=en,example.de=de",
+//without the environment the blog is served on the single domain
+func (env lookupFunc) getEnvSites(key, domain, lang string) []Site {
+	sites := []Site{}
+	for _, v := range env.getEnvList(key, nil) {
+		host, l := v, lang
+		if i := strings.Index(v, "="); i >= 0 {
+			host, l = strings.TrimSpace(v[:i]), strings.TrimSpace(v[i+1:])
+		}
+		sites = append(sites, Site{Host: host, Lang: l})
+	}
+	if len(sites) == 0 && domain != "" {
+		sites = append(sites, Site{Host: domain, Lang: lang})
+	}
+	return sites
+}
+
+//Hosts returns all domains of the blog
+func (c *Config) Hosts() []string {
+	hosts := []string{}
+	if c.Domain != "" {
+		hosts = append(hosts, c.Domain)
+	}
+	for _, s := range c.Sites {
+		if s.Host != c.Domain {
+			hosts = append(hosts, s.Host)
+		}
+	}
+	return hosts
+}
+
+//ConfigError lists all problems of the configuration found by Validate
+type ConfigError []string
+
+func (e ConfigError) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e, "\n  ")
+}
+
+//Validate checks the configuration before the servers start and reports all problems at once
+func (c *Config) Validate() error {
+	var problems ConfigError
+	addf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if c.Production == "true" {
+		if c.Domain == "" && len(c.Sites) == 0 {
+			addf("DOMAIN or DOMAINS is required in production to issue TLS certificates")
+		}
+		if err := writableDir(c.CertDir); err != nil {
+			addf("CERT_DIR %q is not writable: %v", c.CertDir, err)
+		}
+	}
+	for _, s := range c.Sites {
+		if s.Host == "" || s.Lang == "" {
+			addf("DOMAINS entry %q must be host=lang", s.Host+"="+s.Lang)
+		}
+	}
+
+	oauth := map[string]string{
+		"GITHUB_AUTHORIZE_URL": c.OAuth.GithubAuthorizeURL,
+		"GITHUB_TOKEN_URL":     c.OAuth.GithubTokenURL,
+		"REDIRECT_URL":         c.OAuth.RedirectURL,
+		"CLIENT_ID":            c.OAuth.ClientID,
+		"CLIENT_SECRET":        c.OAuth.ClientSecret,
+	}
+	var set, missing []string
+	for key, value := range oauth {
+		if value == "" {
+			missing = append(missing, key)
+		} else {
+			set = append(set, key)
+		}
+	}
+	if len(set) > 0 && len(missing) > 0 {
+		sort.Strings(missing)
+		addf("GitHub OAuth is partially configured, missing %s", strings.Join(missing, ", "))
+	}
+
+	if c.Mail.SMTPAddr != "" && (c.Mail.From == "" || c.Mail.AdminEmail == "") {
+		addf("MAIL_FROM and ADMIN_EMAIL are required when SMTP_ADDR is set")
+	}
+
+	if c.Templates != "" {
+		if files, err := filepath.Glob(c.Templates); err != nil {
+			addf("TEMPLATES %q is not a valid pattern: %v", c.Templates, err)
+		} else if len(files) == 0 {
+			addf("TEMPLATES %q matches no files", c.Templates)
+		}
+	}
+	if c.Public != "" {
+		if info, err := os.Stat(c.Public); err != nil || !info.IsDir() {
+			addf("PUBLIC_DIR %q is not a directory", c.Public)
+		}
+	}
+
+	switch c.Posts.Duplicates {
+	case DuplicatesOff, DuplicatesWarn, DuplicatesBlock:
+	default:
+		addf("DUPLICATE_POSTS must be off, warn or block, got %q", c.Posts.Duplicates)
+	}
+	if c.Posts.DuplicateSimilarity < 1 || c.Posts.DuplicateSimilarity > 100 {
+		addf("DUPLICATE_SIMILARITY must be between 1 and 100, got %d", c.Posts.DuplicateSimilarity)
+	}
+	if c.Posts.AgeBanner < 0 {
+		addf("AGE_BANNER_YEARS must not be negative, got %d", c.Posts.AgeBanner)
+	}
+	if c.Embed.Enabled && c.Embed.CacheSize < 1 {
+		addf("EMBED_CACHE_SIZE must be positive, got %d", c.Embed.CacheSize)
+	}
+
+	for name, ttl := range map[string]time.Duration{"HTTP_CACHE_POSTS": c.HTTPCache.Posts, "HTTP_CACHE_LISTS": c.HTTPCache.Lists,
+		"HTTP_CACHE_FEEDS": c.HTTPCache.Feeds, "HTTP_CACHE_STATIC": c.HTTPCache.Static} {
+		if ttl < 0 {
+			addf("%s must not be negative, got %s", name, ttl)
+		}
+	}
+
+	if len(cdnPurgers(c.Purge)) > 0 && c.Domain == "" && len(c.Sites) == 0 {
+		addf("DOMAIN or DOMAINS is required to purge pages from the CDN")
+	}
+
+	if _, err := loadLocation(c.Timezone); err != nil {
+		addf("TIMEZONE must be IANA timezone name like Europe/Berlin: %v", err)
+	}
+
+	if c.Database.MaintenanceHour < -1 || c.Database.MaintenanceHour > 23 {
+		addf("DB_MAINTENANCE_HOUR must be between 0 and 23 or -1 to disable it, got %d", c.Database.MaintenanceHour)
+	}
+
+	if c.Events.MaxConnections < 0 {
+		addf("EVENTS_MAX_CONNECTIONS must not be negative, got %d", c.Events.MaxConnections)
+	}
+	if c.Events.MaxPerClient < 1 {
+		addf("EVENTS_MAX_PER_CLIENT must be positive, got %d", c.Events.MaxPerClient)
+	}
+
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		addf("OTEL_TRACES_SAMPLER_ARG must be between 0 and 1, got %v", c.Tracing.SampleRatio)
+	}
+
+	if c.Pprof.Addr != "" && !isLoopback(c.Pprof.Addr) {
+		addf("PPROF_ADDR must be a loopback address like 127.0.0.1:6060, got %q", c.Pprof.Addr)
+	}
+
+	switch c.Privacy.IPMode {
+	case IPFull, IPTruncate:
+	case IPHash:
+		if c.Cookie.Secret == "" {
+			addf("COOKIE_SECRET is required to hash ips with PRIVACY_IP_MODE=hash")
+		}
+	default:
+		addf("PRIVACY_IP_MODE must be full, truncate or hash, got %q", c.Privacy.IPMode)
+	}
+
+	for name, list := range map[string][]string{"ADMIN_ALLOW": c.Firewall.AdminAllow, "ADMIN_DENY": c.Firewall.AdminDeny} {
+		if _, err := parseCIDRs(list); err != nil {
+			addf("%s must list networks like 10.0.0.0/8: %v", name, err)
+		}
+	}
+	if len(c.Firewall.BlockCountries) > 0 {
+		if info, err := os.Stat(c.Firewall.GeoIPDB); err != nil || info.IsDir() {
+			addf("GEOIP_DB %q must be a MaxMind database file when COMMENT_BLOCK_COUNTRIES is set", c.Firewall.GeoIPDB)
+		}
+	}
+
+	if c.BanLog.Path != "" {
+		if err := writableDir(filepath.Dir(c.BanLog.Path)); err != nil {
+			addf("BANLOG_PATH directory %q is not writable: %v", filepath.Dir(c.BanLog.Path), err)
+		}
+		if !strings.Contains(c.BanLog.Format, "{{ip}}") {
+			addf("BANLOG_FORMAT must contain {{ip}} placeholder, got %q", c.BanLog.Format)
+		}
+	}
+
+	for _, contact := range c.WellKnown.SecurityContacts {
+		if !strings.HasPrefix(contact, "mailto:") && !strings.HasPrefix(contact, "tel:") && !strings.HasPrefix(contact, "https://") {
+			addf("SECURITY_CONTACT must list mailto:, tel: or https:// addresses, got %q", contact)
+		}
+	}
+	for name, v := range map[string]string{"SECURITY_POLICY": c.WellKnown.SecurityPolicy, "SECURITY_ENCRYPTION": c.WellKnown.SecurityEncryption} {
+		if v != "" && !strings.HasPrefix(v, "https://") {
+			addf("%s must be https:// address, got %q", name, v)
+		}
+	}
+	if c.WellKnown.SecurityExpires <= 0 || c.WellKnown.SecurityExpires > 365*24*time.Hour {
+		addf("SECURITY_TXT_EXPIRES must be positive and at most a year, got %s", c.WellKnown.SecurityExpires)
+	}
+
+	if c.PIDFile != "" {
+		if err := writableDir(filepath.Dir(c.PIDFile)); err != nil {
+			addf("PID_FILE directory %q is not writable: %v", filepath.Dir(c.PIDFile), err)
+		}
+	}
+
+	if c.Posts.Sort != model.SortPublished && c.Posts.Sort != model.SortUpdated {
+		addf("POSTS_SORT must be published or updated, got %q", c.Posts.Sort)
+	}
+	if c.Posts.PerPage < 1 {
+		addf("POSTS_PER_PAGE must be positive, got %d", c.Posts.PerPage)
+	}
+	if mode := strings.ToLower(c.Cookie.SameSite); mode != "lax" && mode != "strict" {
+		addf("COOKIE_SAMESITE must be lax or strict, got %q", c.Cookie.SameSite)
+	}
+
+	if len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+//writableDir creates the directory if needed and checks that files can be written there
+func writableDir(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(dir, ".write-check")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
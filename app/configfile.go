@@ -0,0 +1,298 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+//ConfigSource tells where the configuration comes from besides the environment, values are layered
+//as defaults < config file < profile of the config file < environment < Set (command line flags)
+type ConfigSource struct {
+	//File is YAML or TOML config file, CONFIG_FILE environment variable is used if empty
+	File string
+	//Profile selects profiles.<name> section of the file, e.g. dev, staging or prod,
+	//PROFILE from the environment or the file is used if empty
+	Profile string
+	//Set overrides single values, the keys are the names of environment variables
+	Set map[string]string
+}
+
+//configLayers looks up configuration values by the names of their environment variables
+type configLayers struct {
+	set     map[string]string
+	profile map[string]string
+	file    map[string]string
+	used    map[string]bool
+}
+
+func (l *configLayers) lookup(key string) (string, bool) {
+	l.used[key] = true
+	if v, ok := l.set[key]; ok {
+		return v, true
+	}
+	if v, ok := os.LookupEnv(key); ok {
+		return v, true
+	}
+	if v, ok := l.profile[key]; ok {
+		return v, true
+	}
+	v, ok := l.file[key]
+	return v, ok
+}
+
+//loadConfig reads the configuration from all layers of the source, unknown keys of the file are reported
+//so a typo doesn't silently fall back to the default
+func loadConfig(src ConfigSource) (*Config, error) {
+	l := &configLayers{set: map[string]string{}, used: map[string]bool{}}
+	for k, v := range src.Set {
+		l.set[strings.ToUpper(k)] = v
+	}
+
+	file := src.File
+	if file == "" {
+		file = os.Getenv("CONFIG_FILE")
+	}
+	profiles := map[string]map[string]string{}
+	if file != "" {
+		var err error
+		if l.file, profiles, err = readConfigFile(file); err != nil {
+			return nil, err
+		}
+	}
+
+	profile, _ := l.lookup("PROFILE")
+	if src.Profile != "" {
+		profile = src.Profile
+	}
+	if profile != "" {
+		p, ok := profiles[profile]
+		if !ok {
+			return nil, fmt.Errorf("profile %q is not defined in config file %q", profile, file)
+		}
+		l.profile = p
+	}
+
+	c := newConfigFrom(l.lookup)
+
+	var unknown []string
+	for _, values := range append([]map[string]string{l.file}, valuesOf(profiles)...) {
+		for k := range values {
+			if !l.used[k] {
+				unknown = append(unknown, k)
+			}
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("config file %q has unknown keys: %s", file, strings.Join(unknown, ", "))
+	}
+	return c, nil
+}
+
+func valuesOf(profiles map[string]map[string]string) []map[string]string {
+	list := []map[string]string{}
+	for _, p := range profiles {
+		list = append(list, p)
+	}
+	return list
+}
+
+//readConfigFile reads flat key-value config file, the format is chosen by the extension. Keys are the names
+//of environment variables, lists are joined with commas and profiles.<name> sections hold the profiles, e.g.
+//
+//	DOMAIN: example.com
+//	CORS_ALLOWED_ORIGINS: [https://example.com]
+//	profiles:
+//	  prod:
+//	    PRODUCTION: true
+func readConfigFile(name string) (map[string]string, map[string]map[string]string, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	raw := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		raw, err = parseTOML(data)
+	default:
+		err = errors.New("unsupported format, use .yaml, .yml or .toml")
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read config file %q: %v", name, err)
+	}
+
+	values := map[string]string{}
+	profiles := map[string]map[string]string{}
+	for k, v := range raw {
+		key := strings.ToUpper(k)
+		if key != "PROFILES" {
+			if values[key], err = configValue(key, v); err != nil {
+				return nil, nil, fmt.Errorf("config file %q: %v", name, err)
+			}
+			continue
+		}
+
+		for p, section := range configMap(v) {
+			profiles[p] = map[string]string{}
+			for k, v := range configMap(section) {
+				key := strings.ToUpper(k)
+				if profiles[p][key], err = configValue(key, v); err != nil {
+					return nil, nil, fmt.Errorf("config file %q, profile %s: %v", name, p, err)
+				}
+			}
+		}
+	}
+	return values, profiles, nil
+}
+
+//configMap converts mapping of YAML or TOML into map with string keys, nil if it isn't a mapping
+func configMap(v interface{}) map[string]interface{} {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m
+	case map[interface{}]interface{}:
+		converted := map[string]interface{}{}
+		for k, v := range m {
+			converted[fmt.Sprint(k)] = v
+		}
+		return converted
+	}
+	return nil
+}
+
+//configValue converts value of the file into the format of environment variable
+func configValue(key string, v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool, int, int64, float64:
+		return fmt.Sprint(v), nil
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			s, err := configValue(key, item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = s
+		}
+		return strings.Join(items, ","), nil
+	}
+	return "", fmt.Errorf("%s has unsupported value %v", key, v)
+}
+
+//parseTOML parses the subset of TOML the config file needs: key = value pairs of strings, numbers,
+//booleans and single line arrays of them, and [profiles.<name>] tables
+func parseTOML(data []byte) (map[string]interface{}, error) {
+	root := map[string]interface{}{}
+	table := root
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			name := strings.TrimSpace(strings.Trim(stripTOMLComment(line), "[]"))
+			parts := strings.Split(name, ".")
+			if len(parts) != 2 || parts[0] != "profiles" || parts[1] == "" {
+				return nil, fmt.Errorf("line %d: only [profiles.<name>] tables are supported", n+1)
+			}
+			profiles, _ := root["profiles"].(map[string]interface{})
+			if profiles == nil {
+				profiles = map[string]interface{}{}
+				root["profiles"] = profiles
+			}
+			table = map[string]interface{}{}
+			profiles[parts[1]] = table
+			continue
+		}
+
+		i := strings.Index(line, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", n+1)
+		}
+		key := strings.Trim(strings.TrimSpace(line[:i]), `"`)
+		v, rest, err := parseTOMLValue(strings.TrimSpace(line[i+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n+1, err)
+		}
+		if rest = strings.TrimSpace(rest); rest != "" && !strings.HasPrefix(rest, "#") {
+			return nil, fmt.Errorf("line %d: unexpected %q", n+1, rest)
+		}
+		table[key] = v
+	}
+	return root, nil
+}
+
+//parseTOMLValue parses the value at the beginning of s and returns the rest of s
+func parseTOMLValue(s string) (interface{}, string, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		end := 1
+		for ; end < len(s) && s[end] != '"'; end++ {
+			if s[end] == '\\' {
+				end++
+			}
+		}
+		if end >= len(s) {
+			return nil, "", errors.New("unterminated string")
+		}
+		v, err := strconv.Unquote(s[:end+1])
+		return v, s[end+1:], err
+	case strings.HasPrefix(s, "'"):
+		end := strings.Index(s[1:], "'")
+		if end < 0 {
+			return nil, "", errors.New("unterminated string")
+		}
+		return s[1 : end+1], s[end+2:], nil
+	case strings.HasPrefix(s, "["):
+		items := []interface{}{}
+		s = strings.TrimSpace(s[1:])
+		for !strings.HasPrefix(s, "]") {
+			v, rest, err := parseTOMLValue(s)
+			if err != nil {
+				return nil, "", err
+			}
+			items = append(items, v)
+			s = strings.TrimSpace(rest)
+			if strings.HasPrefix(s, ",") {
+				s = strings.TrimSpace(s[1:])
+			} else if !strings.HasPrefix(s, "]") {
+				return nil, "", errors.New("unterminated array")
+			}
+		}
+		return items, s[1:], nil
+	}
+
+	end := strings.IndexAny(s, ",]#")
+	if end < 0 {
+		end = len(s)
+	}
+	v := strings.TrimSpace(s[:end])
+	if v == "" {
+		return nil, "", errors.New("missing value")
+	}
+	if v == "true" || v == "false" {
+		return v == "true", s[end:], nil
+	}
+	return v, s[end:], nil
+}
+
+func stripTOMLComment(line string) string {
+	if i := strings.Index(line, "#"); i >= 0 {
+		return strings.TrimSpace(line[:i])
+	}
+	return line
+}
@@ -0,0 +1,38 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/ultramozg/golang-blog-engine/model"
+)
+
+//diagnostics shows indexes of the database and plans of the hot queries, so missing indexes are easy to spot
+func (a *App) diagnostics(w http.ResponseWriter, r *http.Request) {
+	if !a.Sessions.IsAdmin(r) {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
+
+	plans, err := model.ExplainHotQueries(a.DB)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to explain queries: %v", err))
+		return
+	}
+	indexes, err := model.GetIndexes(a.DB)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to fetch indexes: %v", err))
+		return
+	}
+
+	data := struct {
+		PageData
+		Plans   []model.QueryPlan
+		Indexes []model.Index
+	}{
+		a.pageData(r),
+		plans,
+		indexes,
+	}
+	a.renderTemplate(w, r, "diagnostics.gohtml", data)
+}
@@ -0,0 +1,66 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/ultramozg/golang-blog-engine/model"
+)
+
+//Modes of the duplicate post check
+const (
+	DuplicatesOff   = "off"
+	DuplicatesWarn  = "warn"
+	DuplicatesBlock = "block"
+)
+
+type formField struct {
+	Name  string
+	Value string
+}
+
+//checkDuplicate looks for existing posts nearly identical to the new one. It reports false if the
+//response is already written: the post is rejected or the admin is asked to confirm it, the confirmation
+//form resubmits the post with duplicate=yes
+func (a *App) checkDuplicate(w http.ResponseWriter, r *http.Request, p model.Post) bool {
+	mode := a.Config.Posts.Duplicates
+	if mode == DuplicatesOff || (mode == DuplicatesWarn && r.FormValue("duplicate") == "yes") {
+		return true
+	}
+
+	similar, err := model.FindSimilarPosts(a.DB, p.Title, p.Body, float64(a.Config.Posts.DuplicateSimilarity)/100)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to check for duplicate posts: %v", err))
+		return false
+	}
+	if len(similar) == 0 {
+		return true
+	}
+	if mode == DuplicatesBlock {
+		a.renderError(w, r, http.StatusConflict, fmt.Errorf("Post is nearly identical to post %d %q", similar[0].ID, similar[0].Title))
+		return false
+	}
+
+	fields := []formField{}
+	for name, values := range r.PostForm {
+		for _, v := range values {
+			fields = append(fields, formField{name, v})
+		}
+	}
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+
+	data := struct {
+		PageData
+		Title   string
+		Similar []model.SimilarPost
+		Fields  []formField
+	}{
+		a.pageData(r).WithRobots("noindex"),
+		p.Title,
+		similar,
+		fields,
+	}
+	a.renderTemplate(w, r, "duplicate.gohtml", data)
+	return false
+}
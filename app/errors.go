@@ -0,0 +1,112 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/ultramozg/golang-blog-engine/middleware"
+)
+
+//Error is an error which carries status of the response, its message is shown to the client
+//while the wrapped error is only logged
+type Error struct {
+	Status  int
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+	return e.Message + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+//newError returns error with the status and message shown to the client
+func newError(status int, message string) *Error {
+	return &Error{Status: status, Message: message}
+}
+
+//errorResponse is the body of JSON errors
+type errorResponse struct {
+	Status    int    `json:"status"`
+	Error     string `json:"error"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+//renderError responds with the error, API routes get JSON and pages get the error template
+//or the not found page. Status and message of Error take precedence, other errors of server
+//are replaced by the status text and logged with the request id
+func (a *App) renderError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	message := ""
+	var e *Error
+	if errors.As(err, &e) {
+		status = e.Status
+		message = e.Message
+		err = e.Err
+	} else if err != nil && status < http.StatusInternalServerError {
+		message = err.Error()
+		err = nil
+	}
+	id := middleware.RequestID(r)
+
+	if err != nil {
+		log.Printf("%s %s %s: %d %v", id, r.Method, r.URL.Path, status, err)
+	}
+	if message == "" {
+		message = http.StatusText(status)
+	}
+
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	//errors aren't kept with the lifetime of the page
+	noCache(w.Header(), false)
+	if a.wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if err := json.NewEncoder(w).Encode(errorResponse{status, message, id}); err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	if status == http.StatusNotFound {
+		a.notFound(w, r)
+		return
+	}
+
+	data := struct {
+		PageData
+		Status    int
+		Title     string
+		Message   string
+		RequestID string
+	}{
+		a.pageData(r).WithRobots("noindex"),
+		status,
+		http.StatusText(status),
+		message,
+		id,
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := a.Temp.ExecuteTemplate(w, "error.gohtml", data); err != nil {
+		log.Println("Unable to render error page: ", err)
+	}
+}
+
+//wantsJSON reports whether the error response should be JSON, that is for API routes,
+//headless mode and clients which ask for it
+func (a *App) wantsJSON(r *http.Request) bool {
+	if strings.HasPrefix(r.URL.Path, "/api/") || a.Config.Headless.Enabled {
+		return true
+	}
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "application/json") && !strings.Contains(accept, "text/html")
+}
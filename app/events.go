@@ -0,0 +1,191 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ultramozg/golang-blog-engine/middleware"
+	"github.com/ultramozg/golang-blog-engine/model"
+	"github.com/ultramozg/golang-blog-engine/render"
+)
+
+//EventsKeepalive is interval of the comments sent to idle streams so proxies don't close them,
+//EventsBuffer is how many comments a slow reader can lag behind before they are dropped for it
+const (
+	EventsKeepalive = 30 * time.Second
+	EventsBuffer    = 16
+)
+
+var (
+	errTooManyStreams = errors.New("Too many live streams")
+	errStreamsClosed  = errors.New("Live streams are closed")
+)
+
+//commentEvent is a new comment as sent to the post pages, HTML is the rendered comment text
+type commentEvent struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Date string `json:"date"`
+	HTML string `json:"html"`
+}
+
+//commentStreams is an in-process pub/sub of new comments, post pages subscribe to their post.
+//Number of streams is limited in total and per client ip
+type commentStreams struct {
+	mu           sync.Mutex
+	subs         map[int]map[chan commentEvent]bool
+	clients      map[string]int
+	total        int
+	max          int
+	maxPerClient int
+	closed       bool
+}
+
+func newCommentStreams(max, maxPerClient int) *commentStreams {
+	return &commentStreams{
+		subs:         map[int]map[chan commentEvent]bool{},
+		clients:      map[string]int{},
+		max:          max,
+		maxPerClient: maxPerClient,
+	}
+}
+
+//Subscribe returns channel receiving new comments of the post, it's closed by Close
+func (s *commentStreams) Subscribe(postID int, client string) (chan commentEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil, errStreamsClosed
+	}
+	if s.total >= s.max || s.clients[client] >= s.maxPerClient {
+		return nil, errTooManyStreams
+	}
+	ch := make(chan commentEvent, EventsBuffer)
+	if s.subs[postID] == nil {
+		s.subs[postID] = map[chan commentEvent]bool{}
+	}
+	s.subs[postID][ch] = true
+	s.clients[client]++
+	s.total++
+	return ch, nil
+}
+
+//Unsubscribe releases the stream of the client
+func (s *commentStreams) Unsubscribe(postID int, client string, ch chan commentEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.subs[postID][ch] {
+		return
+	}
+	delete(s.subs[postID], ch)
+	if len(s.subs[postID]) == 0 {
+		delete(s.subs, postID)
+	}
+	if s.clients[client]--; s.clients[client] == 0 {
+		delete(s.clients, client)
+	}
+	s.total--
+}
+
+//Publish sends the comment to the pages of its post, readers which don't keep up miss it
+func (s *commentStreams) Publish(c model.Comment) {
+	e := commentEvent{ID: c.CommentID, Name: c.Name, Date: c.Date, HTML: render.Comment(c.Data)}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subs[c.PostID] {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+//Close ends all streams, they never become idle so the server would wait for them on shutdown
+func (s *commentStreams) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	for _, chans := range s.subs {
+		for ch := range chans {
+			close(ch)
+		}
+	}
+	s.subs = map[int]map[chan commentEvent]bool{}
+}
+
+//postEvents serves /events/post/{id}, a stream of Server-Sent Events with new comments of the post
+func (a *App) postEvents(w http.ResponseWriter, r *http.Request, p model.Post) {
+	client := clientIP(r)
+	ch, err := a.events.Subscribe(p.ID, client)
+	if err != nil {
+		w.Header().Set("Retry-After", "60")
+		a.renderError(w, r, http.StatusServiceUnavailable, err)
+		return
+	}
+	defer a.events.Unsubscribe(p.ID, client, ch)
+
+	rc := startEventStream(w)
+	keepalive := time.NewTicker(EventsKeepalive)
+	defer keepalive.Stop()
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			err = sendKeepalive(w, rc)
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			err = sendEvent(w, rc, "comment", e)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+//startEventStream sends headers of Server-Sent Events stream. The stream outlives the write timeout
+//of the server and compressing it would hold the events back
+func startEventStream(w http.ResponseWriter) *http.ResponseController {
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+	middleware.DisableCompression(w)
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("X-Accel-Buffering", "no")
+	noCache(h, false)
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", (5 * time.Second).Milliseconds())
+	rc.Flush()
+	return rc
+}
+
+//sendEvent writes the event with v encoded as json and sends it to the client right away
+func sendEvent(w http.ResponseWriter, rc *http.ResponseController, name string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data); err != nil {
+		return err
+	}
+	return rc.Flush()
+}
+
+//sendKeepalive writes a comment to the idle stream so proxies don't close it
+func sendKeepalive(w http.ResponseWriter, rc *http.ResponseController) error {
+	if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+		return err
+	}
+	return rc.Flush()
+}
@@ -0,0 +1,71 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ultramozg/golang-blog-engine/model"
+)
+
+//ExpiryInputFormat is the format of datetime-local input of the post forms
+const ExpiryInputFormat = "2006-01-02T15:04"
+
+//readExpiry reads the expiry of the post form, the date is in the blog's timezone
+//and empty date means the post never expires
+func (a *App) readExpiry(r *http.Request, p *model.Post) error {
+	p.ExpiryAction = r.FormValue("expiry_action")
+	if p.ExpiryAction == "" {
+		p.ExpiryAction = model.ExpiryUnpublish
+	}
+	if !model.IsExpiryAction(p.ExpiryAction) {
+		return errors.New("Invalid expiry action")
+	}
+	p.ExpiresAt = 0
+	v := strings.TrimSpace(r.FormValue("expires_at"))
+	if v == "" {
+		return nil
+	}
+	t, err := time.ParseInLocation(ExpiryInputFormat, v, a.location)
+	if err != nil {
+		return errors.New("Invalid expiry date")
+	}
+	p.ExpiresAt = t.Unix()
+	return nil
+}
+
+//expiryInput formats unix time for the datetime-local input, zero is empty
+func (a *App) expiryInput(unix int64) string {
+	if unix <= 0 {
+		return ""
+	}
+	return time.Unix(unix, 0).In(a.location).Format(ExpiryInputFormat)
+}
+
+//expiryDate formats unix time for feeds, zero is empty
+func (a *App) expiryDate(unix int64) string {
+	if unix <= 0 {
+		return ""
+	}
+	return time.Unix(unix, 0).In(a.location).Format(time.RFC3339)
+}
+
+//expirePosts unpublishes or marks outdated the posts whose expiry date has passed
+func (a *App) expirePosts() error {
+	posts, err := model.ExpirePosts(a.DB, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	for _, p := range posts {
+		what := "unpublished"
+		if p.Outdated {
+			what = "marked outdated"
+		}
+		log.Printf("Post %d %q expired and was %s", p.ID, p.Title, what)
+		notify(a.DB, NotifyPostExpired, fmt.Sprintf("Post %q expired and was %s", p.Title, what), fmt.Sprintf("/post?id=%d", p.ID))
+	}
+	return nil
+}
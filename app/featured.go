@@ -0,0 +1,76 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ultramozg/golang-blog-engine/model"
+)
+
+type featuredPost struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+}
+
+//featuredOrderRequest is the body of the featured posts reorder request
+type featuredOrderRequest struct {
+	IDs []int `json:"ids"`
+}
+
+//featured serves GET /api/featured with featured posts in display order
+func (a *App) featured(w http.ResponseWriter, r *http.Request) {
+	posts, err := model.GetFeaturedPosts(a.DB)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to fetch featured posts: %v", err))
+		return
+	}
+	list := make([]featuredPost, 0, len(posts))
+	for _, p := range posts {
+		list = append(list, featuredPost{p.ID, p.Title})
+	}
+	writeJSON(w, list)
+}
+
+//reorderFeatured serves POST /api/featured which stores the new order sent by the drag and drop list of the admin
+func (a *App) reorderFeatured(w http.ResponseWriter, r *http.Request) {
+	if !a.Sessions.IsAdmin(r) {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
+	var req featuredOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.renderError(w, r, http.StatusBadRequest, errors.New("Invalid payload"))
+		return
+	}
+	if err := model.SetFeaturedOrder(a.DB, req.IDs); err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to reorder featured posts: %v", err))
+		return
+	}
+	a.audit(r, "featured order", fmt.Sprintf("featured posts reordered: %v", req.IDs))
+	a.featured(w, r)
+}
+
+//featuredCuration renders drag and drop list of featured posts
+func (a *App) featuredCuration(w http.ResponseWriter, r *http.Request) {
+	if !a.Sessions.IsAdmin(r) {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
+
+	posts, err := model.GetFeaturedPosts(a.DB)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to fetch featured posts: %v", err))
+		return
+	}
+
+	data := struct {
+		PageData
+		Posts []model.Post
+	}{
+		a.pageData(r),
+		posts,
+	}
+	a.renderTemplate(w, r, "featured.gohtml", data)
+}
@@ -0,0 +1,130 @@
+package app
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ultramozg/golang-blog-engine/model"
+	"github.com/ultramozg/golang-blog-engine/render"
+)
+
+const (
+	CommentFeedSize = 50
+)
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	DC      string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	Items       []rssItem `xml:"item"`
+}
+
+type rssGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Description string  `xml:"description"`
+	Author      string  `xml:"dc:creator,omitempty"`
+	PubDate     string  `xml:"pubDate,omitempty"`
+	GUID        rssGUID `xml:"guid"`
+}
+
+//commentsFeed serves /comments.rss with the latest comments of all published posts
+func (a *App) commentsFeed(w http.ResponseWriter, r *http.Request) {
+	comments, err := model.GetRecentComments(a.DB, 0, CommentFeedSize)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to fetch comments: %v", err))
+		return
+	}
+	posts, err := model.GetPublishedTitles(a.DB)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to fetch posts: %v", err))
+		return
+	}
+	titles := map[int]string{}
+	for _, p := range posts {
+		titles[p.ID] = p.Title
+	}
+
+	site := a.settings.Get().SiteTitle
+	a.writeCommentFeed(w, r, rssChannel{
+		Title:       "Comments of " + site,
+		Link:        a.baseURL(r) + "/",
+		Description: "Latest comments of " + site,
+	}, comments, titles)
+}
+
+//postCommentsFeed serves /post/comments.rss?id= with comments of the published post, newest first
+func (a *App) postCommentsFeed(w http.ResponseWriter, r *http.Request) {
+	p, err := a.loadPost(r.FormValue("id"))
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if !p.Published() {
+		a.renderError(w, r, http.StatusNotFound, nil)
+		return
+	}
+
+	comments, err := model.GetComments(a.DB, p.ID)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to fetch comments of post %d: %v", p.ID, err))
+		return
+	}
+	sort.Slice(comments, func(i, j int) bool { return comments[i].CommentID > comments[j].CommentID })
+	if len(comments) > CommentFeedSize {
+		comments = comments[:CommentFeedSize]
+	}
+
+	a.writeCommentFeed(w, r, rssChannel{
+		Title:       "Comments on " + p.Title,
+		Link:        a.postURL(r, p.ID),
+		Description: "Discussion of " + p.Title,
+	}, comments, map[int]string{p.ID: p.Title})
+}
+
+//writeCommentFeed writes RSS 2.0 feed of the comments, titles are the titles of their posts
+func (a *App) writeCommentFeed(w http.ResponseWriter, r *http.Request, channel rssChannel, comments []model.Comment, titles map[int]string) {
+	channel.Items = []rssItem{}
+	for _, c := range comments {
+		item := rssItem{
+			Title:       c.Name + " on " + titles[c.PostID],
+			Link:        a.postURL(r, c.PostID) + "#comment-" + strconv.Itoa(c.CommentID),
+			Description: render.Comment(c.Data),
+			Author:      c.Name,
+		}
+		item.GUID = rssGUID{Value: item.Link}
+		if t, err := a.parseDate(c.Date); err == nil {
+			item.PubDate = t.Format(time.RFC1123Z)
+		}
+		channel.Items = append(channel.Items, item)
+	}
+
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	feed := rssFeed{Version: "2.0", DC: "http://purl.org/dc/elements/1.1/", Channel: channel}
+	if err := xml.NewEncoder(w).Encode(feed); err != nil {
+		log.Println(err)
+	}
+}
+
+//postURL returns absolute address of the post page
+func (a *App) postURL(r *http.Request, id int) string {
+	return a.baseURL(r) + "/post?id=" + strconv.Itoa(id)
+}
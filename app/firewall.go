@@ -0,0 +1,157 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/ultramozg/golang-blog-engine/geoip"
+)
+
+//adminPrefixes and adminRoutes are the admin pages the CIDR lists apply to,
+//admin API routes are added from apiRoutes
+var adminPrefixes = []string{"/admin/", "/debug/pprof/"}
+
+var adminRoutes = []string{"/create", "/update", "/delete", "/delete-comment", "/login", "/logout"}
+
+//countryLookup returns ISO 3166-1 code of the country of the ip, *geoip.Reader implements it
+type countryLookup interface {
+	Country(ip net.IP) (string, error)
+}
+
+//firewall restricts admin routes to the allowed networks and rejects comments from the blocked countries
+type firewall struct {
+	allow []*net.IPNet
+	deny  []*net.IPNet
+	//adminPaths are the paths which require admin besides adminPrefixes
+	adminPaths map[string]bool
+	countries  map[string]bool
+	geo        countryLookup
+}
+
+//newFirewall parses the CIDR lists and opens the GeoIP database if countries are blocked
+func newFirewall(conf Firewall) (*firewall, error) {
+	f := &firewall{adminPaths: map[string]bool{}, countries: map[string]bool{}}
+	for _, p := range adminRoutes {
+		f.adminPaths[p] = true
+	}
+	var err error
+	if f.allow, err = parseCIDRs(conf.AdminAllow); err != nil {
+		return nil, fmt.Errorf("ADMIN_ALLOW: %v", err)
+	}
+	if f.deny, err = parseCIDRs(conf.AdminDeny); err != nil {
+		return nil, fmt.Errorf("ADMIN_DENY: %v", err)
+	}
+	for _, c := range conf.BlockCountries {
+		f.countries[strings.ToUpper(c)] = true
+	}
+	if len(f.countries) > 0 {
+		if f.geo, err = geoip.Open(conf.GeoIPDB); err != nil {
+			return nil, fmt.Errorf("GEOIP_DB: %v", err)
+		}
+	}
+	return f, nil
+}
+
+//parseCIDRs parses networks like 10.0.0.0/8, single addresses are networks of one address
+func parseCIDRs(list []string) ([]*net.IPNet, error) {
+	nets := []*net.IPNet{}
+	for _, s := range list {
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q", s)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+//isAdminRoute reports whether the CIDR lists apply to the path
+func (f *firewall) isAdminRoute(path string) bool {
+	if f.adminPaths[path] {
+		return true
+	}
+	for _, p := range adminPrefixes {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+//AllowAdmin reports whether the ip may reach admin routes, denied networks win over allowed ones
+//and an empty allow list allows everyone
+func (f *firewall) AllowAdmin(ip net.IP) bool {
+	if ip == nil {
+		return len(f.allow) == 0 && len(f.deny) == 0
+	}
+	if containsIP(f.deny, ip) {
+		return false
+	}
+	return len(f.allow) == 0 || containsIP(f.allow, ip)
+}
+
+//BlockedCountry returns the country of the ip if comments from it are blocked, addresses the database
+//doesn't know are let through
+func (f *firewall) BlockedCountry(ip net.IP) string {
+	if f.geo == nil || ip == nil {
+		return ""
+	}
+	country, err := f.geo.Country(ip)
+	if err != nil {
+		log.Printf("Unable to look up country of %v: %v", ip, err)
+		return ""
+	}
+	if f.countries[country] {
+		return country
+	}
+	return ""
+}
+
+//firewallMiddleware rejects requests to admin routes from the networks which aren't allowed
+func (a *App) firewallMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.firewall.isAdminRoute(r.URL.Path) && !a.firewall.AllowAdmin(net.ParseIP(clientIP(r))) {
+			a.audit(r, "firewall block", r.Method+" "+r.URL.Path)
+			a.banLog.Write(BanFirewall, "", r)
+			a.renderError(w, r, http.StatusForbidden, errors.New("Access from your network is not allowed"))
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+//commentBlocked rejects the comment with 403 if it comes from a blocked country
+func (a *App) commentBlocked(w http.ResponseWriter, r *http.Request) bool {
+	country := a.firewall.BlockedCountry(net.ParseIP(clientIP(r)))
+	if country == "" {
+		return false
+	}
+	a.audit(r, "comment blocked", "comment from "+country)
+	a.renderError(w, r, http.StatusForbidden, errors.New("Comments are not accepted from your country"))
+	return true
+}
@@ -0,0 +1,15 @@
+package app
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+//writeJSON encodes v as json response
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,103 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/ultramozg/golang-blog-engine/model"
+)
+
+const (
+	MostLikedPostsCount = 20
+)
+
+//knownLiker returns identity of the reader who likes posts: session user if logged in,
+//otherwise anonymous visitor cookie. It's false for readers without the cookie
+func (a *App) knownLiker(r *http.Request) (string, bool) {
+	if u, ok := a.Sessions.GetUser(r); ok {
+		return "user:" + u.Name, true
+	}
+	if id, err := a.cookies.Read(r, "visitor"); err == nil && id != "" {
+		return "visitor:" + id, true
+	}
+	return "", false
+}
+
+//liker is knownLiker which issues the visitor cookie to unknown readers, minted reports that
+func (a *App) liker(w http.ResponseWriter, r *http.Request) (liker string, minted bool) {
+	if liker, ok := a.knownLiker(r); ok {
+		return liker, false
+	}
+
+	id := uuid.NewV4().String()
+	http.SetCookie(w, a.cookies.New("visitor", id, 365*24*60*60))
+	return "visitor:" + id, true
+}
+
+//postLikes is response of the post like endpoint
+type postLikes struct {
+	ID    int  `json:"id"`
+	Likes int  `json:"likes"`
+	Liked bool `json:"liked"`
+}
+
+//getPostLikes serves GET /api/posts/{id}/like with likes count of the post, the read doesn't
+//issue the visitor cookie so readers without it haven't liked the post
+func (a *App) getPostLikes(w http.ResponseWriter, r *http.Request, p model.Post) {
+	liker, ok := a.knownLiker(r)
+	liked := ok && model.IsPostLiked(a.DB, p.ID, liker)
+
+	writeJSON(w, postLikes{p.ID, p.Likes, liked})
+}
+
+//postLike serves POST /api/posts/{id}/like which toggles the like of the reader. Clients without
+//the visitor cookie get it with 428 and have to repeat the request, otherwise every request would be
+//a new visitor. The ip is limited as well since the cookie is in the hands of the client
+func (a *App) postLike(w http.ResponseWriter, r *http.Request, p model.Post) {
+	liker, minted := a.liker(w, r)
+	if minted {
+		a.renderError(w, r, http.StatusPreconditionRequired, errors.New("Cookies are required to like posts, try again"))
+		return
+	}
+	if !a.reacts.Allow("ip:"+clientIP(r)+"/post") || !a.reacts.Allow(liker+"/post") {
+		a.banLog.Write(BanRateLimited, liker, r)
+		a.renderError(w, r, http.StatusTooManyRequests, nil)
+		return
+	}
+	liked, err := model.TogglePostLike(a.DB, p.ID, liker)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to toggle post like: %v", err))
+		return
+	}
+	if liked {
+		p.Likes++
+	} else {
+		p.Likes--
+	}
+
+	writeJSON(w, postLikes{p.ID, p.Likes, liked})
+}
+
+func (a *App) mostLiked(w http.ResponseWriter, r *http.Request) {
+	if !a.Sessions.IsAdmin(r) {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
+
+	posts, err := model.GetMostLikedPosts(a.DB, MostLikedPostsCount)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to fetch most liked posts: %v", err))
+		return
+	}
+
+	data := struct {
+		PageData
+		Posts []model.Post
+	}{
+		a.pageData(r),
+		posts,
+	}
+	a.renderTemplate(w, r, "likes.gohtml", data)
+}
@@ -0,0 +1,149 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ultramozg/golang-blog-engine/model"
+)
+
+//linkChecker checks outbound links of all posts with HEAD requests
+type linkChecker struct {
+	db     *sql.DB
+	client *http.Client
+	//delay between two consecutive requests
+	delay time.Duration
+	//results younger than ttl are reused instead of checking the link again
+	ttl time.Duration
+}
+
+func newLinkChecker(db *sql.DB, delay, ttl time.Duration) *linkChecker {
+	return &linkChecker{
+		db:     db,
+		client: &http.Client{Timeout: 10 * time.Second},
+		delay:  delay,
+		ttl:    ttl,
+	}
+}
+
+//Run checks links of all posts once, it returns the error of the context if it's done mid-run
+func (c *linkChecker) Run(ctx context.Context) error {
+	posts, err := model.GetAllPosts(c.db)
+	if err != nil {
+		return err
+	}
+
+	checked, broken := 0, 0
+	for _, p := range posts {
+		links := outboundLinks(p.Body)
+		if err := model.DeleteStaleLinkChecks(c.db, p.ID, links); err != nil {
+			return err
+		}
+
+		for _, link := range links {
+			l, err := model.GetRecentLinkCheck(c.db, link, time.Now().Add(-c.ttl).Unix())
+			switch err {
+			case nil:
+			case sql.ErrNoRows:
+				l = c.check(ctx, link)
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				checked++
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(c.delay):
+				}
+			default:
+				return err
+			}
+
+			l.PostID = p.ID
+			if err := l.SaveLinkCheck(c.db); err != nil {
+				return err
+			}
+			if l.IsBroken() {
+				broken++
+			}
+		}
+	}
+	log.Printf("Link check finished: %d links requested, %d broken links found", checked, broken)
+	if broken > 0 {
+		notify(c.db, NotifyBrokenLinks, fmt.Sprintf("Link check found %d broken links", broken), "/admin/broken-links")
+	}
+	return nil
+}
+
+//check requests the link, falling back to GET for servers which don't support HEAD
+func (c *linkChecker) check(ctx context.Context, link string) model.LinkCheck {
+	l := model.LinkCheck{URL: link, CheckedAt: time.Now().Unix()}
+
+	resp, err := c.request(ctx, http.MethodHead, link)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		resp.Body.Close()
+		resp, err = c.request(ctx, http.MethodGet, link)
+	}
+	if err != nil {
+		l.Error = err.Error()
+		return l
+	}
+	resp.Body.Close()
+
+	l.Status = resp.StatusCode
+	return l
+}
+
+func (c *linkChecker) request(ctx context.Context, method, link string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, link, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.client.Do(req)
+}
+
+//outboundLinks returns unique absolute http(s) links of the post body
+func outboundLinks(body string) []string {
+	_, links, _ := inspectBody(body)
+
+	seen := make(map[string]bool)
+	outbound := []string{}
+	for _, link := range links {
+		u, err := url.Parse(link)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			continue
+		}
+		if !seen[link] {
+			seen[link] = true
+			outbound = append(outbound, link)
+		}
+	}
+	return outbound
+}
+
+func (a *App) brokenLinks(w http.ResponseWriter, r *http.Request) {
+	if !a.Sessions.IsAdmin(r) {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
+
+	links, err := model.GetBrokenLinks(a.DB)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to fetch broken links: %v", err))
+		return
+	}
+
+	data := struct {
+		PageData
+		Links []model.LinkCheck
+	}{
+		a.pageData(r),
+		links,
+	}
+	a.renderTemplate(w, r, "brokenlinks.gohtml", data)
+}
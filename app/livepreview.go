@@ -0,0 +1,175 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+//PreviewStreams is how many editors can watch the live preview at once
+const PreviewStreams = 8
+
+var errPreviewChannel = errors.New("Unknown preview channel")
+
+//previewDiff turns the previous preview into the current one: Delete lines starting at At
+//are replaced with Insert. Lines are the lines of the rendered HTML
+type previewDiff struct {
+	At     int      `json:"at"`
+	Delete int      `json:"delete"`
+	Insert []string `json:"insert"`
+}
+
+//diffLines returns the change between old and new lines, lines before and after it are the same in both
+func diffLines(old, new []string) previewDiff {
+	start := 0
+	for start < len(old) && start < len(new) && old[start] == new[start] {
+		start++
+	}
+	end := 0
+	for end < len(old)-start && end < len(new)-start && old[len(old)-1-end] == new[len(new)-1-end] {
+		end++
+	}
+	return previewDiff{At: start, Delete: len(old) - start - end, Insert: new[start : len(new)-end]}
+}
+
+//previewChannel holds the latest body sent by the editor, wake tells the stream to render it
+type previewChannel struct {
+	body string
+	wake chan struct{}
+}
+
+//previewStreams connects the edits posted by the editor to its preview stream by the channel id
+type previewStreams struct {
+	mu       sync.Mutex
+	channels map[string]*previewChannel
+	closed   bool
+}
+
+func newPreviewStreams() *previewStreams {
+	return &previewStreams{channels: map[string]*previewChannel{}}
+}
+
+//Open creates channel for a new stream
+func (s *previewStreams) Open() (string, *previewChannel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return "", nil, errStreamsClosed
+	}
+	if len(s.channels) >= PreviewStreams {
+		return "", nil, errTooManyStreams
+	}
+	id := randomHex(16)
+	c := &previewChannel{wake: make(chan struct{}, 1)}
+	s.channels[id] = c
+	return id, c, nil
+}
+
+//Update replaces the body of the channel, edits made while the previous one renders are coalesced
+func (s *previewStreams) Update(id, body string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.channels[id]
+	if !ok {
+		return errPreviewChannel
+	}
+	c.body = body
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+//Body returns the latest body of the channel
+func (s *previewStreams) Body(c *previewChannel) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return c.body
+}
+
+//Remove drops the channel of the finished stream
+func (s *previewStreams) Remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c, ok := s.channels[id]; ok {
+		delete(s.channels, id)
+		close(c.wake)
+	}
+}
+
+//Close ends all streams before shutdown
+func (s *previewStreams) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	for id, c := range s.channels {
+		delete(s.channels, id)
+		close(c.wake)
+	}
+}
+
+//previewStream serves /api/preview/stream, a stream of Server-Sent Events for the post editor.
+//The first event carries id of the channel to post the body to, every posted body is rendered
+//by the post pipeline and sent as the diff against the previously sent HTML
+func (a *App) previewStream(w http.ResponseWriter, r *http.Request) {
+	if !a.Sessions.IsAdmin(r) {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
+	id, c, err := a.previews.Open()
+	if err != nil {
+		w.Header().Set("Retry-After", "60")
+		a.renderError(w, r, http.StatusServiceUnavailable, err)
+		return
+	}
+	defer a.previews.Remove(id)
+
+	rc := startEventStream(w)
+	if err := sendEvent(w, rc, "channel", id); err != nil {
+		return
+	}
+	var sent []string
+	keepalive := time.NewTicker(EventsKeepalive)
+	defer keepalive.Stop()
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			err = sendKeepalive(w, rc)
+		case _, ok := <-c.wake:
+			if !ok {
+				return
+			}
+			lines := strings.Split(a.render.Post(a.previews.Body(c)), "\n")
+			if d := diffLines(sent, lines); d.Delete > 0 || len(d.Insert) > 0 {
+				err = sendEvent(w, rc, "diff", d)
+			}
+			sent = lines
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+//updatePreview serves /api/preview, it takes the body being edited for the preview stream of the channel
+func (a *App) updatePreview(w http.ResponseWriter, r *http.Request) {
+	if !a.Sessions.IsAdmin(r) {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
+	if err := a.previews.Update(r.FormValue("channel"), r.FormValue("body")); err != nil {
+		a.renderError(w, r, http.StatusNotFound, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
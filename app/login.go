@@ -0,0 +1,66 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ultramozg/golang-blog-engine/model"
+)
+
+//loginLocked reports whether login attempts for the account or from the client ip are temporarily blocked
+func (a *App) loginLocked(name, ip string) bool {
+	if a.Config.Login.MaxFailures <= 0 {
+		return false
+	}
+	since := time.Now().Add(-a.Config.Login.Lockout).Unix()
+	byName, byIP, err := model.CountFailedLogins(a.DB, name, ip, since)
+	if err != nil {
+		log.Println("Unable to count failed logins: ", err)
+		return false
+	}
+	return byName >= a.Config.Login.MaxFailures || byIP >= a.Config.Login.MaxFailures
+}
+
+//loginFailed records failed attempt and locks the account once the limit is reached
+func (a *App) loginFailed(name string, r *http.Request) {
+	ip := a.storedIP(r)
+	if err := model.RecordLoginAttempt(a.DB, name, ip, false, time.Now().Unix()); err != nil {
+		log.Println("Unable to record login attempt: ", err)
+	}
+	//the name is typed by an anonymous client, it's quoted in the summary and never stored as the actor
+	a.auditAs("anonymous", r, "login failed", fmt.Sprintf("invalid credentials for %q from %s", name, ip))
+	a.banLog.Write(BanAuthFailure, name, r)
+
+	if !a.loginLocked(name, ip) {
+		return
+	}
+	summary := fmt.Sprintf("login locked for %s after %d failed attempts from %s", a.Config.Login.Lockout, a.Config.Login.MaxFailures, ip)
+	a.auditAs("anonymous", r, "account locked", fmt.Sprintf("%q %s", name, summary))
+	notify(a.DB, NotifyLoginLocked, summary, "/admin/audit")
+
+	if a.Config.Login.Notify && a.Config.Mail.AdminEmail != "" {
+		go func() {
+			err := a.mail.Send([]string{a.Config.Mail.AdminEmail}, "Login locked for "+name, summary+"\n")
+			if err != nil {
+				log.Println("Unable to send lockout notification: ", err)
+			}
+		}()
+	}
+}
+
+//loginSucceeded resets failures counter of the account and the ip
+func (a *App) loginSucceeded(name string, r *http.Request) {
+	if err := model.RecordLoginAttempt(a.DB, name, a.storedIP(r), true, time.Now().Unix()); err != nil {
+		log.Println("Unable to record login attempt: ", err)
+	}
+}
+
+//loginTooManyAttempts replies with 429 and hints when to retry
+func (a *App) loginTooManyAttempts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(a.Config.Login.Lockout.Seconds())))
+	a.renderError(w, r, http.StatusTooManyRequests, errors.New("Too many failed login attempts, try again later"))
+}
@@ -0,0 +1,51 @@
+package app
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+//mailer sends plain text emails through the configured SMTP server
+type mailer struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+//newMailer returns nil if SMTP isn't configured, sending through nil mailer is a no-op
+func newMailer(c Mail) *mailer {
+	if c.SMTPAddr == "" || c.From == "" {
+		return nil
+	}
+
+	m := &mailer{addr: c.SMTPAddr, from: c.From}
+	if c.User != "" {
+		host, _, err := net.SplitHostPort(c.SMTPAddr)
+		if err != nil {
+			host = c.SMTPAddr
+		}
+		m.auth = smtp.PlainAuth("", c.User, c.Password, host)
+	}
+	return m
+}
+
+//Send delivers the message to the recipients
+func (m *mailer) Send(to []string, subject, body string) error {
+	if m == nil || len(to) == 0 {
+		return nil
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", m.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", strings.NewReplacer("\r", "", "\n", " ").Replace(subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	return smtp.SendMail(m.addr, m.auth, m.from, to, []byte(msg.String()))
+}
@@ -0,0 +1,18 @@
+package app
+
+import (
+	"github.com/ultramozg/golang-blog-engine/model"
+)
+
+//maintainDatabase optimizes and vacuums the database, checkpoint blocked by long readers
+//is reported to the admin since the WAL keeps growing until it succeeds
+func (a *App) maintainDatabase() (string, error) {
+	m, err := model.Maintain(a.DB)
+	if err != nil {
+		return "", err
+	}
+	if m.Busy {
+		notify(a.DB, NotifyMaintenance, "Database checkpoint was blocked: "+m.String(), "/admin/jobs")
+	}
+	return m.String(), nil
+}
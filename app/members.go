@@ -0,0 +1,25 @@
+package app
+
+import (
+	"net/http"
+
+	"github.com/ultramozg/golang-blog-engine/model"
+	"github.com/ultramozg/golang-blog-engine/render"
+)
+
+//restrict cuts body of members only post down to the excerpt for anonymous readers,
+//it's done before rendering so the rest of the content never reaches the page
+func (a *App) restrict(r *http.Request, p *model.Post) bool {
+	if _, loggedIn, _ := a.viewer(r); !p.MembersOnly() || loggedIn {
+		return false
+	}
+	p.Body = render.Excerpt(p.Body)
+	return true
+}
+
+//restrictAll applies restrict to every post of the list
+func (a *App) restrictAll(r *http.Request, posts []model.Post) {
+	for i := range posts {
+		a.restrict(r, &posts[i])
+	}
+}
@@ -0,0 +1,39 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ultramozg/golang-blog-engine/model"
+	"github.com/ultramozg/golang-blog-engine/render"
+)
+
+const (
+	MentionsLimit = 10
+)
+
+//mentions serves /api/mentions?q= with recent commenter names for autocomplete
+func (a *App) mentions(w http.ResponseWriter, r *http.Request) {
+	names, err := model.GetCommenterNames(a.DB, strings.TrimPrefix(r.FormValue("q"), "@"), MentionsLimit)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to fetch commenter names: %v", err))
+		return
+	}
+	writeJSON(w, names)
+}
+
+//emojiShortcode is one entry of the emoji autocomplete
+type emojiShortcode struct {
+	Code  string `json:"code"`
+	Emoji string `json:"emoji"`
+}
+
+//emoji serves /api/emoji?q= with matching shortcodes and their characters
+func (a *App) emoji(w http.ResponseWriter, r *http.Request) {
+	codes := []emojiShortcode{}
+	for _, code := range render.EmojiWithPrefix(strings.Trim(r.FormValue("q"), ":")) {
+		codes = append(codes, emojiShortcode{code, render.Emoji[code]})
+	}
+	writeJSON(w, codes)
+}
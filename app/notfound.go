@@ -0,0 +1,166 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/ultramozg/golang-blog-engine/model"
+	"github.com/ultramozg/golang-blog-engine/render"
+)
+
+const (
+	NotFoundSuggestions = 5
+	NotFoundListLimit   = 100
+	MaxNotFoundPath     = 512
+	//MinSuggestionScore is the lowest similarity of the post to the missing path to suggest it
+	MinSuggestionScore = 0.5
+)
+
+//notFound renders not found page with posts similar to the requested path
+//and records the hit so the admin can see common misses unless the reader opted out of tracking
+func (a *App) notFound(w http.ResponseWriter, r *http.Request) {
+	if (r.Method == http.MethodGet || r.Method == http.MethodHead) && a.trackingAllowed(r) {
+		missed := r.URL.RequestURI()
+		if len(missed) > MaxNotFoundPath {
+			missed = missed[:MaxNotFoundPath]
+		}
+		if err := model.RecordNotFound(a.DB, missed); err != nil {
+			log.Println("Unable to record not found path: ", err)
+		}
+	}
+
+	data := struct {
+		PageData
+		Path        string
+		Suggestions []model.Post
+	}{
+		a.pageData(r).WithRobots("noindex"),
+		r.URL.Path,
+		a.suggestPosts(r.URL.Path),
+	}
+	w.WriteHeader(http.StatusNotFound)
+	if err := a.Temp.ExecuteTemplate(w, "notfound.gohtml", data); err != nil {
+		log.Println(err)
+	}
+}
+
+//suggestPosts returns published posts which titles look like the last segment of the path
+func (a *App) suggestPosts(p string) []model.Post {
+	base := path.Base(p)
+	query := render.Slug(strings.TrimSuffix(base, path.Ext(base)))
+	if query == "" {
+		return nil
+	}
+
+	posts, err := model.GetPublishedTitles(a.DB)
+	if err != nil {
+		log.Println("Unable to fetch post titles: ", err)
+		return nil
+	}
+
+	type scored struct {
+		post  model.Post
+		score float64
+	}
+	matches := []scored{}
+	for _, post := range posts {
+		if s := similarity(query, render.Slug(post.Title)); s >= MinSuggestionScore {
+			matches = append(matches, scored{post, s})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+
+	suggestions := []model.Post{}
+	for i := 0; i < len(matches) && i < NotFoundSuggestions; i++ {
+		suggestions = append(suggestions, matches[i].post)
+	}
+	return suggestions
+}
+
+//similarity scores slugs from 0 to 1, it's the better of edit distance similarity
+//and share of the query words found in the slug, so both typos and partial slugs match
+func similarity(query, slug string) float64 {
+	if slug == "" {
+		return 0
+	}
+
+	a, b := []rune(query), []rune(slug)
+	longest := len(a)
+	if len(b) > longest {
+		longest = len(b)
+	}
+	score := 1 - float64(levenshtein(a, b))/float64(longest)
+
+	words := map[string]bool{}
+	for _, w := range strings.Split(slug, "-") {
+		words[w] = true
+	}
+	queryWords := strings.Split(query, "-")
+	shared := 0
+	for _, w := range queryWords {
+		if words[w] {
+			shared++
+		}
+	}
+	if s := float64(shared) / float64(len(queryWords)); s > score {
+		score = s
+	}
+	return score
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+//notFoundLog renders the most requested missing paths
+func (a *App) notFoundLog(w http.ResponseWriter, r *http.Request) {
+	if !a.Sessions.IsAdmin(r) {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
+
+	list, err := model.GetNotFound(a.DB, NotFoundListLimit)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to fetch not found paths: %v", err))
+		return
+	}
+
+	data := struct {
+		PageData
+		NotFound []model.NotFound
+	}{
+		a.pageData(r),
+		list,
+	}
+	a.renderTemplate(w, r, "notfoundlog.gohtml", data)
+}
@@ -0,0 +1,113 @@
+package app
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/ultramozg/golang-blog-engine/model"
+)
+
+const (
+	NotificationsLimit  = 50
+	NotificationsToKeep = 500
+)
+
+//Kinds of notifications
+const (
+	NotifyComment        = "comment"
+	NotifyBrokenLinks    = "broken_links"
+	NotifyJobFailed      = "job_failed"
+	NotifyLoginLocked    = "login_locked"
+	NotifyAnnounceFailed = "announce_failed"
+	NotifyMaintenance    = "maintenance"
+	NotifyPurgeFailed    = "purge_failed"
+	NotifyPostExpired    = "post_expired"
+)
+
+//notificationList is response of the notifications endpoint
+type notificationList struct {
+	Unread        int                  `json:"unread"`
+	Notifications []model.Notification `json:"notifications"`
+}
+
+//notify records notification for the admin, failures are only logged
+func notify(db *sql.DB, kind, message, link string) {
+	if err := model.CreateNotification(db, kind, message, link); err != nil {
+		log.Println("Unable to create notification: ", err)
+	}
+}
+
+//notifications serves GET /api/notifications with latest notifications and unread count
+func (a *App) notifications(w http.ResponseWriter, r *http.Request) {
+	if !a.Sessions.IsAdmin(r) {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
+	a.writeNotifications(w, r)
+}
+
+//markNotificationsRead serves POST /api/notifications which marks notification given by id as read
+//or all of them if id is omitted
+func (a *App) markNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	if !a.Sessions.IsAdmin(r) {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
+
+	id := 0
+	if v := r.FormValue("id"); v != "" {
+		var err error
+		if id, err = strconv.Atoi(v); err != nil {
+			a.renderError(w, r, http.StatusBadRequest, errors.New("Invalid Id"))
+			return
+		}
+	}
+	if err := model.MarkNotificationsRead(a.DB, id); err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to mark notifications read: %v", err))
+		return
+	}
+	a.writeNotifications(w, r)
+}
+
+//writeNotifications responds with latest notifications and unread count
+func (a *App) writeNotifications(w http.ResponseWriter, r *http.Request) {
+	list, err := model.GetNotifications(a.DB, NotificationsLimit)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to fetch notifications: %v", err))
+		return
+	}
+	unread, err := model.CountUnreadNotifications(a.DB)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to count notifications: %v", err))
+		return
+	}
+
+	writeJSON(w, notificationList{unread, list})
+}
+
+//notificationCenter renders notifications page of the admin
+func (a *App) notificationCenter(w http.ResponseWriter, r *http.Request) {
+	if !a.Sessions.IsAdmin(r) {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
+
+	list, err := model.GetNotifications(a.DB, NotificationsLimit)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to fetch notifications: %v", err))
+		return
+	}
+
+	data := struct {
+		PageData
+		Notifications []model.Notification
+	}{
+		a.pageData(r),
+		list,
+	}
+	a.renderTemplate(w, r, "notifications.gohtml", data)
+}
@@ -0,0 +1,478 @@
+package app
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ultramozg/golang-blog-engine/model"
+	"github.com/ultramozg/golang-blog-engine/render"
+)
+
+//apiParam is a parameter of API operation, In is "query", "path" or "form"
+type apiParam struct {
+	Name        string
+	In          string
+	Type        string
+	Required    bool
+	Description string
+}
+
+//apiOperation describes one method of the API route and the handler serving it, Response is an example value
+//which json schema of the successful response is generated from, nil means no content,
+//Body is an example of json request body in the same way
+type apiOperation struct {
+	Method   string
+	Path     string
+	Handler  http.HandlerFunc
+	Summary  string
+	Params   []apiParam
+	Body     interface{}
+	Status   int
+	Response interface{}
+	Errors   []int
+	//Auth is "admin" or "user" if the operation requires session of such user
+	Auth string
+}
+
+//apiRoutes is the registry of authentication and JSON API routes,
+//they are registered on the router and documented in /api/openapi.json from here
+func (a *App) apiRoutes() []apiOperation {
+	id := apiParam{Name: "id", In: "path", Type: "integer", Required: true, Description: "Post id"}
+	cursor := []apiParam{
+		{Name: "cursor", In: "query", Type: "string", Description: "Opaque cursor from next_cursor of the previous page"},
+		{Name: "limit", In: "query", Type: "integer", Description: "Page size, up to " + strconv.Itoa(PollMaxLimit)},
+	}
+
+	return []apiOperation{
+		{
+			Method:  http.MethodPost,
+			Path:    "/login",
+			Handler: a.login,
+			Summary: "Log in as admin, session cookie is set on success",
+			Params: []apiParam{
+				{Name: "login", In: "form", Type: "string", Required: true},
+				{Name: "password", In: "form", Type: "string", Required: true},
+				{Name: "remember", In: "form", Type: "string", Description: `"on" issues long-lived remember me cookie`},
+			},
+			Status: http.StatusSeeOther,
+			Errors: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusTooManyRequests},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/logout",
+			Handler: a.logout,
+			Summary: "Log out and revoke the session",
+			Status:  http.StatusSeeOther,
+			Errors:  []int{http.StatusUnauthorized},
+			Auth:    "admin",
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/auth-callback",
+			Handler: a.oauth,
+			Summary: "GitHub OAuth callback, logs the reader in",
+			Params:  []apiParam{{Name: "code", In: "query", Type: "string", Required: true}},
+			Status:  http.StatusSeeOther,
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/openapi.json",
+			Handler:  a.openAPI,
+			Summary:  "This document",
+			Response: map[string]interface{}{},
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/api/posts",
+			Handler: a.postList,
+			Summary: "Front page posts for infinite scroll",
+			Params: []apiParam{
+				{Name: "cursor", In: "query", Type: "string", Description: "Opaque cursor from next_cursor of the previous page"},
+				{Name: "format", In: "query", Type: "string", Description: "html returns rendered post cards instead of items"},
+			},
+			Response: postCards{Items: []pollPost{}},
+			Errors:   []int{http.StatusBadRequest},
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/posts/{id}/like",
+			Handler:  a.withPost(a.getPostLikes),
+			Summary:  "Likes of the post",
+			Params:   []apiParam{id},
+			Response: postLikes{},
+			Errors:   []int{http.StatusNotFound},
+		},
+		{
+			Method:   http.MethodPost,
+			Path:     "/api/posts/{id}/like",
+			Handler:  a.withPost(a.postLike),
+			Summary:  "Toggle like of the post, the first request of a client without the visitor cookie is answered with 428 setting it",
+			Params:   []apiParam{id},
+			Response: postLikes{},
+			Errors:   []int{http.StatusNotFound, http.StatusPreconditionRequired, http.StatusTooManyRequests},
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/posts/{id}/gallery",
+			Handler:  a.withPost(a.postGallery),
+			Summary:  "Images of the post galleries",
+			Params:   []apiParam{id},
+			Response: []render.GalleryImage{},
+			Errors:   []int{http.StatusNotFound},
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/v1/posts/recent",
+			Handler:  a.recentPosts,
+			Summary:  "Published posts, newest first",
+			Params:   cursor,
+			Response: pollPage{Items: []pollPost{}},
+			Errors:   []int{http.StatusBadRequest},
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/v1/comments/recent",
+			Handler:  a.recentComments,
+			Summary:  "Comments of published posts, newest first",
+			Params:   cursor,
+			Response: pollPage{Items: []pollComment{}},
+			Errors:   []int{http.StatusBadRequest},
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/mentions",
+			Handler:  a.mentions,
+			Summary:  "Commenter names for @mention autocomplete",
+			Params:   []apiParam{{Name: "q", In: "query", Type: "string", Description: "Name prefix"}},
+			Response: []string{},
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/emoji",
+			Handler:  a.emoji,
+			Summary:  "Emoji shortcodes for autocomplete",
+			Params:   []apiParam{{Name: "q", In: "query", Type: "string", Description: "Shortcode prefix"}},
+			Response: []emojiShortcode{},
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/featured",
+			Handler:  a.featured,
+			Summary:  "Featured posts in display order",
+			Response: []featuredPost{},
+		},
+		{
+			Method:   http.MethodPost,
+			Path:     "/api/featured",
+			Handler:  a.reorderFeatured,
+			Summary:  "Reorder featured posts, ids are given in the display order",
+			Body:     featuredOrderRequest{},
+			Response: []featuredPost{},
+			Errors:   []int{http.StatusBadRequest, http.StatusUnauthorized},
+			Auth:     "admin",
+		},
+		{
+			Method:  http.MethodPost,
+			Path:    "/api/seo-preview",
+			Handler: a.seoPreviewHandler,
+			Summary: "Canonical url, meta description, keywords and search result preview of the post being edited",
+			Params: []apiParam{
+				{Name: "id", In: "form", Type: "integer"},
+				{Name: "title", In: "form", Type: "string", Required: true},
+				{Name: "body", In: "form", Type: "string"},
+				{Name: "cover_image", In: "form", Type: "string"},
+				{Name: "canonical_url", In: "form", Type: "string"},
+			},
+			Response: seoPreview{Keywords: []string{}},
+			Errors:   []int{http.StatusUnauthorized},
+			Auth:     "admin",
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/api/preview/stream",
+			Handler: a.previewStream,
+			Summary: "Server-Sent Events stream of the live preview, channel event carries id of the channel and diff events the changed lines of the rendered body",
+			Errors:  []int{http.StatusUnauthorized, http.StatusServiceUnavailable},
+			Auth:    "admin",
+		},
+		{
+			Method:  http.MethodPost,
+			Path:    "/api/preview",
+			Handler: a.updatePreview,
+			Summary: "Render the body being edited on the live preview stream of the channel",
+			Params: []apiParam{
+				{Name: "channel", In: "form", Type: "string", Required: true},
+				{Name: "body", In: "form", Type: "string"},
+			},
+			Status: http.StatusNoContent,
+			Errors: []int{http.StatusNotFound, http.StatusUnauthorized},
+			Auth:   "admin",
+		},
+		{
+			Method:   http.MethodPost,
+			Path:     "/api/seo-regenerate",
+			Handler:  a.regenerateSEO,
+			Summary:  "Fill missing SEO fields of existing posts from their bodies, dry run only reports the changes",
+			Params:   []apiParam{{Name: "dry_run", In: "form", Type: "boolean"}},
+			Response: []SEOChange{},
+			Errors:   []int{http.StatusUnauthorized},
+			Auth:     "admin",
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/read-only",
+			Handler:  a.readOnlyMode,
+			Summary:  "Read-only mode state",
+			Response: readOnlyState{},
+			Errors:   []int{http.StatusUnauthorized},
+			Auth:     "admin",
+		},
+		{
+			Method:   http.MethodPost,
+			Path:     "/api/read-only",
+			Handler:  a.setReadOnlyMode,
+			Summary:  "Turn read-only mode on or off, writes return 503 while it's on",
+			Params:   []apiParam{{Name: "enabled", In: "form", Type: "boolean", Required: true}},
+			Response: readOnlyState{},
+			Errors:   []int{http.StatusBadRequest, http.StatusUnauthorized},
+			Auth:     "admin",
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/consent",
+			Handler:  a.getConsent,
+			Summary:  "Consent to statistics of the reader, Do-Not-Track and Global Privacy Control headers are respected",
+			Response: consentState{},
+		},
+		{
+			Method:   http.MethodPost,
+			Path:     "/api/consent",
+			Handler:  a.setConsent,
+			Summary:  "Store choice of the reader in the consent cookie",
+			Params:   []apiParam{{Name: "consent", In: "form", Type: "string", Required: true, Description: "granted or denied"}},
+			Response: consentState{},
+			Errors:   []int{http.StatusBadRequest},
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/me/data",
+			Handler:  a.myData,
+			Summary:  "Export comments and likes of the logged in reader",
+			Response: model.UserData{Comments: []model.Comment{}, LikedComments: []int{}, LikedPosts: []int{}},
+			Errors:   []int{http.StatusUnauthorized},
+			Auth:     "user",
+		},
+		{
+			Method:  http.MethodDelete,
+			Path:    "/api/me/data",
+			Handler: a.deleteMyData,
+			Summary: "Delete comments and likes of the logged in reader and log them out",
+			Status:  http.StatusNoContent,
+			Errors:  []int{http.StatusUnauthorized},
+			Auth:    "user",
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/notifications",
+			Handler:  a.notifications,
+			Summary:  "Latest admin notifications",
+			Response: notificationList{Notifications: []model.Notification{}},
+			Errors:   []int{http.StatusUnauthorized},
+			Auth:     "admin",
+		},
+		{
+			Method:   http.MethodPost,
+			Path:     "/api/notifications",
+			Handler:  a.markNotificationsRead,
+			Summary:  "Mark notification as read, all of them if id is omitted",
+			Params:   []apiParam{{Name: "id", In: "form", Type: "integer"}},
+			Response: notificationList{Notifications: []model.Notification{}},
+			Errors:   []int{http.StatusBadRequest, http.StatusUnauthorized},
+			Auth:     "admin",
+		},
+	}
+}
+
+//openAPIDocument builds OpenAPI 3.1 document of the routes
+func openAPIDocument(routes []apiOperation, title, server string) map[string]interface{} {
+	paths := map[string]map[string]interface{}{}
+	for _, op := range routes {
+		if paths[op.Path] == nil {
+			paths[op.Path] = map[string]interface{}{}
+		}
+		paths[op.Path][strings.ToLower(op.Method)] = op.document()
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.1.0",
+		"info": map[string]interface{}{
+			"title":   title + " API",
+			"version": "1.0.0",
+		},
+		"servers": []map[string]string{{"url": server}},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"securitySchemes": map[string]interface{}{
+				"session": map[string]string{"type": "apiKey", "in": "cookie", "name": "session"},
+			},
+		},
+	}
+}
+
+func (op apiOperation) document() map[string]interface{} {
+	doc := map[string]interface{}{
+		"summary":     op.Summary,
+		"operationId": operationID(op.Method, op.Path),
+	}
+
+	params := []map[string]interface{}{}
+	form := map[string]interface{}{}
+	required := []string{}
+	for _, p := range op.Params {
+		if p.In == "form" {
+			form[p.Name] = map[string]interface{}{"type": p.Type, "description": p.Description}
+			if p.Required {
+				required = append(required, p.Name)
+			}
+			continue
+		}
+		params = append(params, map[string]interface{}{
+			"name":        p.Name,
+			"in":          p.In,
+			"required":    p.Required,
+			"description": p.Description,
+			"schema":      map[string]string{"type": p.Type},
+		})
+	}
+	if len(params) > 0 {
+		doc["parameters"] = params
+	}
+	if op.Body != nil {
+		doc["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": jsonSchema(reflect.ValueOf(op.Body))},
+			},
+		}
+	} else if len(form) > 0 {
+		schema := map[string]interface{}{"type": "object", "properties": form}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		doc["requestBody"] = map[string]interface{}{
+			"content": map[string]interface{}{
+				"application/x-www-form-urlencoded": map[string]interface{}{"schema": schema},
+			},
+		}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := map[string]interface{}{"description": http.StatusText(status)}
+	if op.Response != nil {
+		success["content"] = map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": jsonSchema(reflect.ValueOf(op.Response))},
+		}
+	}
+	responses := map[string]interface{}{strconv.Itoa(status): success}
+	for _, code := range op.Errors {
+		responses[strconv.Itoa(code)] = map[string]string{"description": http.StatusText(code)}
+	}
+	doc["responses"] = responses
+
+	if op.Auth != "" {
+		doc["security"] = []map[string][]string{{"session": {}}}
+		doc["description"] = "Requires " + op.Auth + " session"
+	}
+	return doc
+}
+
+//operationID makes identifier like getApiPostsIdLike from method and path
+func operationID(method, path string) string {
+	id := strings.ToLower(method)
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool { return !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9') }) {
+		id += strings.ToUpper(part[:1]) + part[1:]
+	}
+	return id
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+//jsonSchema generates json schema of the value from its type and json tags,
+//interface fields are described by the value they hold
+func jsonSchema(v reflect.Value) map[string]interface{} {
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			if v.Kind() == reflect.Ptr {
+				return jsonSchema(reflect.Zero(v.Type().Elem()))
+			}
+			return map[string]interface{}{}
+		}
+		v = v.Elem()
+	}
+
+	t := v.Type()
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		props := map[string]interface{}{}
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			name, opts := f.Name, ""
+			if tag := f.Tag.Get("json"); tag != "" {
+				if tag == "-" {
+					continue
+				}
+				parts := strings.SplitN(tag, ",", 2)
+				if parts[0] != "" {
+					name = parts[0]
+				}
+				if len(parts) == 2 {
+					opts = parts[1]
+				}
+			}
+			props[name] = jsonSchema(v.Field(i))
+			if !strings.Contains(opts, "omitempty") {
+				required = append(required, name)
+			}
+		}
+		sort.Strings(required)
+		schema := map[string]interface{}{"type": "object", "properties": props}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": jsonSchema(reflect.Zero(t.Elem()))}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": jsonSchema(reflect.Zero(t.Elem()))}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	}
+	return map[string]interface{}{}
+}
+
+//openAPI serves /api/openapi.json
+func (a *App) openAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, openAPIDocument(a.apiRoutes(), a.settings.Get().SiteTitle, a.baseURL(r)))
+}
@@ -0,0 +1,66 @@
+package app
+
+import (
+	"net/http"
+	"strconv"
+)
+
+//PageData holds template data shared by all pages, handlers embed it into
+//their page specific data so templates see both as fields of the same value
+type PageData struct {
+	Head        head
+	LogAsAdmin  bool
+	LogAsUser   bool
+	AuthURL     string
+	ClientID    string
+	RedirectURL string
+	BaseURL     string
+}
+
+//pageData builds common template data of the request, it's the data of an anonymous visitor
+//when the admin reads the blog as one
+func (a *App) pageData(r *http.Request) PageData {
+	admin, loggedIn, visitor := a.viewer(r)
+	h := newHead(admin)
+	h.Visitor = visitor
+	return PageData{
+		Head:        h,
+		LogAsAdmin:  admin,
+		LogAsUser:   loggedIn,
+		AuthURL:     a.Config.OAuth.GithubAuthorizeURL,
+		ClientID:    a.Config.OAuth.ClientID,
+		RedirectURL: a.Config.OAuth.RedirectURL,
+		BaseURL:     a.baseURL(r),
+	}
+}
+
+//WithHead replaces header data, e.g. with SEO data of the post
+func (d PageData) WithHead(h head) PageData {
+	h.Visitor = d.Head.Visitor
+	d.Head = h
+	return d
+}
+
+//WithRobots sets robots directives of the page
+func (d PageData) WithRobots(robots ...string) PageData {
+	return d.WithHead(newHead(d.LogAsAdmin, robots...))
+}
+
+//listPageURL returns absolute address of the page of the posts list, it's the canonical one for all pages
+//including the first page which "/" redirects to
+func (a *App) listPageURL(r *http.Request, page int) string {
+	return a.baseURL(r) + "/page?p=" + strconv.Itoa(page)
+}
+
+//listPageData returns page data of the posts list page with its canonical address and its neighbours
+func (a *App) listPageData(r *http.Request, page int, next bool) PageData {
+	d := a.pageData(r)
+	d.Head.Canonical = a.listPageURL(r, page)
+	if page > 0 {
+		d.Head.Prev = a.listPageURL(r, page-1)
+	}
+	if next {
+		d.Head.Next = a.listPageURL(r, page+1)
+	}
+	return d
+}
@@ -0,0 +1,144 @@
+package app
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/ultramozg/golang-blog-engine/model"
+	"github.com/ultramozg/golang-blog-engine/render"
+)
+
+const (
+	PollDefaultLimit = 20
+	PollMaxLimit     = 100
+)
+
+//pollPost is a post as seen by integration platforms, the id never changes
+type pollPost struct {
+	ID         int    `json:"id"`
+	Title      string `json:"title"`
+	URL        string `json:"url"`
+	Excerpt    string `json:"excerpt"`
+	CoverImage string `json:"cover_image,omitempty"`
+	Published  string `json:"published"`
+	Expires    string `json:"expires,omitempty"`
+	Outdated   bool   `json:"outdated,omitempty"`
+}
+
+type pollComment struct {
+	ID        int    `json:"id"`
+	PostID    int    `json:"post_id"`
+	Author    string `json:"author"`
+	Text      string `json:"text"`
+	URL       string `json:"url"`
+	Published string `json:"published"`
+}
+
+//pollPage is a page of polling endpoint, items are ordered newest first
+//and NextCursor points to the older items
+type pollPage struct {
+	Items      interface{} `json:"items"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+func encodeCursor(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(id)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(string(b))
+}
+
+//pollParams reads cursor and limit of the polling request
+func pollParams(r *http.Request) (before, limit int, err error) {
+	if before, err = decodeCursor(r.FormValue("cursor")); err != nil {
+		return
+	}
+	limit = PollDefaultLimit
+	if v := r.FormValue("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil {
+			return
+		}
+	}
+	if limit < 1 {
+		limit = 1
+	}
+	if limit > PollMaxLimit {
+		limit = PollMaxLimit
+	}
+	return
+}
+
+//recentPosts serves /api/v1/posts/recent for integration platforms like Zapier or IFTTT
+func (a *App) recentPosts(w http.ResponseWriter, r *http.Request) {
+	before, limit, err := pollParams(r)
+	if err != nil {
+		a.renderError(w, r, http.StatusBadRequest, errors.New("Invalid cursor or limit"))
+		return
+	}
+	posts, err := model.GetRecentPosts(a.DB, before, limit)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to fetch recent posts: %v", err))
+		return
+	}
+
+	items := make([]pollPost, 0, len(posts))
+	for _, p := range posts {
+		items = append(items, pollPost{
+			ID:         p.ID,
+			Title:      p.Title,
+			URL:        a.baseURL(r) + "/post?id=" + strconv.Itoa(p.ID),
+			Excerpt:    render.Excerpt(p.Body),
+			CoverImage: a.absoluteURL(r, p.CoverImage),
+			Published:  a.isoDate(p.Date),
+			Expires:    a.expiryDate(p.ExpiresAt),
+			Outdated:   p.Outdated,
+		})
+	}
+	page := pollPage{Items: items}
+	if len(posts) == limit {
+		page.NextCursor = encodeCursor(posts[len(posts)-1].ID)
+	}
+	writeJSON(w, page)
+}
+
+//recentComments serves /api/v1/comments/recent for integration platforms like Zapier or IFTTT
+func (a *App) recentComments(w http.ResponseWriter, r *http.Request) {
+	before, limit, err := pollParams(r)
+	if err != nil {
+		a.renderError(w, r, http.StatusBadRequest, errors.New("Invalid cursor or limit"))
+		return
+	}
+	comments, err := model.GetRecentComments(a.DB, before, limit)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to fetch recent comments: %v", err))
+		return
+	}
+
+	items := make([]pollComment, 0, len(comments))
+	for _, c := range comments {
+		items = append(items, pollComment{
+			ID:        c.CommentID,
+			PostID:    c.PostID,
+			Author:    c.Name,
+			Text:      c.Data,
+			URL:       a.baseURL(r) + "/post?id=" + strconv.Itoa(c.PostID),
+			Published: a.isoDate(c.Date),
+		})
+	}
+	page := pollPage{Items: items}
+	if len(comments) == limit {
+		page.NextCursor = encodeCursor(comments[len(comments)-1].CommentID)
+	}
+	writeJSON(w, page)
+}
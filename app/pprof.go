@@ -0,0 +1,41 @@
+package app
+
+import (
+	"net"
+	"net/http"
+	"net/http/pprof"
+)
+
+//pprofMux serves net/http/pprof profiles under /debug/pprof/
+func pprofMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+//debugPprof serves profiles to the admin when PPROF is enabled. CPU profiles and traces are cut
+//by the write timeout of the server, longer ones are taken on the PPROF_ADDR listener
+func (a *App) debugPprof(w http.ResponseWriter, r *http.Request) {
+	if !a.Sessions.IsAdmin(r) {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
+	a.pprof.ServeHTTP(w, r)
+}
+
+//isLoopback reports whether the listen address only accepts local connections
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,36 @@
+package app
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/ultramozg/golang-blog-engine/model"
+)
+
+var unsafeFilenameRe = regexp.MustCompile(`[^\w.-]+`)
+
+//printPost renders print optimized version of the post for readers who want offline copies
+func (a *App) printPost(w http.ResponseWriter, r *http.Request) {
+	p, err := a.loadPost(r.FormValue("id"))
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if a.hidden(r, p) {
+		a.notFound(w, r)
+		return
+	}
+	a.restrict(r, &p)
+
+	data := struct {
+		Post model.Post
+		URL  string
+	}{
+		p,
+		a.canonicalURL(r, p),
+	}
+
+	w.Header().Add("X-Robots-Tag", "noindex")
+	w.Header().Set("Content-Disposition", `inline; filename="`+unsafeFilenameRe.ReplaceAllString(p.Title, "-")+`.html"`)
+	a.renderTemplate(w, r, "print.gohtml", data)
+}
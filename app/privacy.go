@@ -0,0 +1,170 @@
+package app
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/ultramozg/golang-blog-engine/model"
+	"github.com/ultramozg/golang-blog-engine/session"
+)
+
+//Modes of storing client ips
+const (
+	IPFull     = "full"
+	IPTruncate = "truncate"
+	IPHash     = "hash"
+)
+
+//anonymizeIP returns ip in the form it's stored: as is, with host bits zeroed (/24 of IPv4, /48 of IPv6)
+//or as keyed hash, the same ip always gives the same result so login lockout keeps working
+func anonymizeIP(ip, mode, secret string) string {
+	switch mode {
+	case IPTruncate:
+		parsed := net.ParseIP(ip)
+		if parsed == nil {
+			return ""
+		}
+		if v4 := parsed.To4(); v4 != nil {
+			return v4.Mask(net.CIDRMask(24, 32)).String()
+		}
+		return parsed.Mask(net.CIDRMask(48, 128)).String()
+	case IPHash:
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(ip))
+		return hex.EncodeToString(mac.Sum(nil)[:8])
+	}
+	return ip
+}
+
+//storedIP returns ip of the client anonymized according to the privacy settings
+func (a *App) storedIP(r *http.Request) string {
+	return anonymizeIP(clientIP(r), a.Config.Privacy.IPMode, a.Config.Cookie.Secret)
+}
+
+//myData exports everything the blog stores about the logged in reader
+func (a *App) myData(w http.ResponseWriter, r *http.Request) {
+	u, ok := a.Sessions.GetUser(r)
+	if !ok || u.Type == session.ADMIN {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
+
+	data, err := model.GetUserData(a.DB, u.Name)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to export data of %s: %v", u.Name, err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="my-data.json"`)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		log.Println(err)
+	}
+}
+
+//deleteMyData removes comments and likes of the logged in reader and logs them out everywhere
+func (a *App) deleteMyData(w http.ResponseWriter, r *http.Request) {
+	u, ok := a.Sessions.GetUser(r)
+	if !ok || u.Type == session.ADMIN {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
+
+	comments, err := model.DeleteUserData(a.DB, u.Name)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to delete data of %s: %v", u.Name, err))
+		return
+	}
+	for _, s := range a.Sessions.List() {
+		if s.User.Name == u.Name && s.User.Type == u.Type {
+			a.Sessions.Revoke(s.Ref())
+		}
+	}
+	http.SetCookie(w, a.cookies.Expire("session"))
+	http.SetCookie(w, a.cookies.Expire("remember"))
+	log.Printf("Data of %s deleted on their request, %d comments", u.Name, comments)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+//pruneAnalytics deletes statistics older than the retention period
+func (a *App) pruneAnalytics() error {
+	return model.DeleteNotFoundBefore(a.DB, time.Now().Add(-a.Config.Privacy.Retention).Unix())
+}
+
+//Consent states of the consent cookie
+const (
+	ConsentGranted = "granted"
+	ConsentDenied  = "denied"
+	//ConsentMaxAge is how long the choice of the reader is remembered, in seconds
+	ConsentMaxAge = 365 * 24 * 60 * 60
+)
+
+//consentState is response of the consent endpoint, Banner asks the page to show the consent banner
+type consentState struct {
+	Required bool   `json:"required"`
+	Consent  string `json:"consent"`
+	DNT      bool   `json:"dnt"`
+	Tracking bool   `json:"tracking"`
+	Banner   bool   `json:"banner"`
+}
+
+//doNotTrack reports whether the browser asks not to be tracked with DNT or Global Privacy Control header
+func doNotTrack(r *http.Request) bool {
+	return r.Header.Get("DNT") == "1" || r.Header.Get("Sec-GPC") == "1"
+}
+
+//consent returns choice of the reader stored in the consent cookie, empty if they haven't chosen yet
+func (a *App) consent(r *http.Request) string {
+	value, err := a.cookies.Read(r, "consent")
+	if err != nil || (value != ConsentGranted && value != ConsentDenied) {
+		return ""
+	}
+	return value
+}
+
+//trackingAllowed reports whether statistics may be collected about the request, Do-Not-Track always wins
+//and with PRIVACY_CONSENT nothing is collected until the reader agrees
+func (a *App) trackingAllowed(r *http.Request) bool {
+	if doNotTrack(r) {
+		return false
+	}
+	return !a.Config.Privacy.Consent || a.consent(r) == ConsentGranted
+}
+
+func (a *App) consentState(r *http.Request, consent string) consentState {
+	dnt := doNotTrack(r)
+	required := a.Config.Privacy.Consent
+	return consentState{
+		Required: required,
+		Consent:  consent,
+		DNT:      dnt,
+		Tracking: !dnt && (!required || consent == ConsentGranted),
+		Banner:   required && !dnt && consent == "",
+	}
+}
+
+//getConsent serves GET /api/consent with the consent state of the reader
+func (a *App) getConsent(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, a.consentState(r, a.consent(r)))
+}
+
+//setConsent stores choice of the reader in the consent cookie
+func (a *App) setConsent(w http.ResponseWriter, r *http.Request) {
+	consent := r.FormValue("consent")
+	if consent != ConsentGranted && consent != ConsentDenied {
+		a.renderError(w, r, http.StatusBadRequest, errors.New("consent must be granted or denied"))
+		return
+	}
+
+	http.SetCookie(w, a.cookies.New("consent", consent, ConsentMaxAge))
+	writeJSON(w, a.consentState(r, consent))
+}
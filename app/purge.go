@@ -0,0 +1,196 @@
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ultramozg/golang-blog-engine/integrations"
+	"github.com/ultramozg/golang-blog-engine/model"
+)
+
+//PurgeAttempts is how many times purging is tried before the admin is notified, the delay
+//between the attempts doubles from PurgeBackoff
+const (
+	PurgeAttempts = 5
+	PurgeBackoff  = 2 * time.Second
+)
+
+//cdnPurgers returns the CDNs having credentials configured
+func cdnPurgers(c Purge) []integrations.Purger {
+	purgers := []integrations.Purger{}
+	if c.CloudflareZone != "" && c.CloudflareToken != "" {
+		purgers = append(purgers, integrations.Cloudflare{Zone: c.CloudflareZone, Token: c.CloudflareToken})
+	}
+	if c.FastlyService != "" && c.FastlyToken != "" {
+		purgers = append(purgers, integrations.Fastly{Service: c.FastlyService, Token: c.FastlyToken})
+	}
+	if c.BunnyPullZone != "" && c.BunnyKey != "" {
+		purgers = append(purgers, integrations.Bunny{PullZone: c.BunnyPullZone, Key: c.BunnyKey})
+	}
+	return purgers
+}
+
+//purgeQueue collects urls of the changed pages and purges them from the CDNs in the background,
+//urls added while a purge is running are purged together in the next one
+type purgeQueue struct {
+	db      *sql.DB
+	purgers []integrations.Purger
+	backoff time.Duration
+
+	mu      sync.Mutex
+	pending map[string]bool
+	all     bool
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newPurgeQueue(db *sql.DB, purgers []integrations.Purger, backoff time.Duration) *purgeQueue {
+	return &purgeQueue{
+		db:      db,
+		purgers: purgers,
+		backoff: backoff,
+		pending: map[string]bool{},
+		wake:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+//Add queues the urls, no urls purge the whole site
+func (q *purgeQueue) Add(urls ...string) {
+	q.mu.Lock()
+	if len(urls) == 0 {
+		q.all = true
+	}
+	for _, u := range urls {
+		q.pending[u] = true
+	}
+	q.mu.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+//Start purges queued urls until Stop
+func (q *purgeQueue) Start() {
+	go func() {
+		defer close(q.done)
+		for {
+			select {
+			case <-q.stop:
+				return
+			case <-q.wake:
+				q.flush()
+			}
+		}
+	}()
+}
+
+//Stop waits for the running purge, pending urls are dropped
+func (q *purgeQueue) Stop() {
+	close(q.stop)
+	<-q.done
+}
+
+//flush purges pending urls from every CDN, failed purges are retried with growing delay
+func (q *purgeQueue) flush() {
+	q.mu.Lock()
+	urls := make([]string, 0, len(q.pending))
+	for u := range q.pending {
+		urls = append(urls, u)
+	}
+	all := q.all
+	q.pending, q.all = map[string]bool{}, false
+	q.mu.Unlock()
+
+	if len(urls) == 0 && !all {
+		return
+	}
+	sort.Strings(urls)
+
+	for _, p := range q.purgers {
+		var err error
+		delay := q.backoff
+		for attempt := 1; ; attempt++ {
+			if all {
+				err = p.PurgeAll()
+			} else {
+				err = p.Purge(urls)
+			}
+			if err == nil || attempt == PurgeAttempts {
+				break
+			}
+			select {
+			case <-q.stop:
+				return
+			case <-time.After(delay):
+			}
+			delay *= 2
+		}
+		if err != nil {
+			log.Printf("Unable to purge %d urls from %s: %v", len(urls), p.Name(), err)
+			notify(q.db, NotifyPurgeFailed, fmt.Sprintf("Purging pages from %s failed: %v", p.Name(), err), "")
+		}
+	}
+}
+
+//purgeChanged queues pages showing the changed posts or their comments: the post pages and feeds,
+//the front page, every list page, the sitemap and the comments feed. Changes of unknown posts purge the whole site
+func (a *App) purgeChanged(topic string, ids ...int) {
+	if len(ids) == 0 {
+		a.purges.Add()
+		return
+	}
+
+	paths := append([]string{"/", "/sitemap.xml", "/comments.rss"}, a.listPages()...)
+	for _, id := range ids {
+		n := strconv.Itoa(id)
+		paths = append(paths, "/post?id="+n, "/post/print?id="+n, "/post/comments.rss?id="+n)
+	}
+	urls := []string{}
+	for _, base := range a.siteURLs() {
+		for _, p := range paths {
+			urls = append(urls, base+p)
+		}
+	}
+	a.purges.Add(urls...)
+}
+
+//listPages returns paths of all list pages, a new or removed post shifts the posts of every page.
+//One page past the last is included as it may have just become empty
+func (a *App) listPages() []string {
+	total, err := model.CountPosts(a.DB)
+	if err != nil {
+		log.Println("Unable to count posts to purge list pages: ", err)
+	}
+	perPage := a.settings.Get().PostsPerPage
+	if perPage < 1 {
+		perPage = 1
+	}
+	paths := []string{}
+	for page := 0; page <= total/perPage; page++ {
+		paths = append(paths, "/page?p="+strconv.Itoa(page))
+	}
+	return paths
+}
+
+//siteURLs returns base urls of all domains of the blog
+func (a *App) siteURLs() []string {
+	if len(a.Config.Sites) == 0 {
+		return []string{"https://" + a.Config.Domain}
+	}
+	urls := []string{}
+	for _, s := range a.Config.Sites {
+		urls = append(urls, "https://"+s.Host)
+	}
+	return urls
+}
@@ -0,0 +1,37 @@
+package app
+
+import (
+	"sync"
+	"time"
+)
+
+//rateLimiter allows one action per key within the interval
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	last     map[string]time.Time
+}
+
+func newRateLimiter(interval time.Duration) *rateLimiter {
+	return &rateLimiter{interval: interval, last: make(map[string]time.Time)}
+}
+
+//Allow reports whether the action for key is allowed now and remembers the attempt if so
+func (l *rateLimiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if t, ok := l.last[key]; ok && now.Sub(t) < l.interval {
+		return false
+	}
+	l.last[key] = now
+
+	//drop stale keys so the map doesn't grow forever
+	for k, t := range l.last {
+		if now.Sub(t) >= l.interval {
+			delete(l.last, k)
+		}
+	}
+	return true
+}
@@ -0,0 +1,109 @@
+package app
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+)
+
+//ReadOnlyRetryAfter is the Retry-After value in seconds sent while the blog is read-only
+const ReadOnlyRetryAfter = 60
+
+//readOnlySwitch turns off writes while the database is being backed up or migrated
+type readOnlySwitch struct {
+	enabled int32
+}
+
+//Enabled reports whether the blog is read-only
+func (s *readOnlySwitch) Enabled() bool {
+	return atomic.LoadInt32(&s.enabled) == 1
+}
+
+//Set turns read-only mode on or off
+func (s *readOnlySwitch) Set(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&s.enabled, v)
+}
+
+//Toggle flips read-only mode and returns the new state
+func (s *readOnlySwitch) Toggle() bool {
+	for {
+		old := atomic.LoadInt32(&s.enabled)
+		if atomic.CompareAndSwapInt32(&s.enabled, old, 1-old) {
+			return old == 0
+		}
+	}
+}
+
+//writePaths change data even though they are requested with GET
+var writePaths = map[string]bool{
+	"/delete":         true,
+	"/delete-comment": true,
+}
+
+//readOnlyExempt stay writable so the admin can log in and turn read-only mode off,
+//consent only sets the cookie
+var readOnlyExempt = map[string]bool{
+	"/login":         true,
+	"/api/read-only": true,
+	"/api/consent":   true,
+}
+
+//isWrite reports whether the request changes data
+func isWrite(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return writePaths[r.URL.Path]
+	}
+	return true
+}
+
+//readOnlyMiddleware rejects writes with 503 while the blog is read-only, reads keep working
+func (a *App) readOnlyMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.readOnly.Enabled() && isWrite(r) && !readOnlyExempt[r.URL.Path] {
+			w.Header().Set("Retry-After", strconv.Itoa(ReadOnlyRetryAfter))
+			a.renderError(w, r, http.StatusServiceUnavailable, newError(http.StatusServiceUnavailable, "The blog is in read-only mode, please try again later"))
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+//readOnlyState is response of the read-only endpoint
+type readOnlyState struct {
+	ReadOnly bool `json:"read_only"`
+}
+
+//readOnlyMode serves GET /api/read-only with the read-only mode state
+func (a *App) readOnlyMode(w http.ResponseWriter, r *http.Request) {
+	if !a.Sessions.IsAdmin(r) {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
+
+	writeJSON(w, readOnlyState{a.readOnly.Enabled()})
+}
+
+//setReadOnlyMode serves POST /api/read-only which turns read-only mode on or off from enabled=true|false
+func (a *App) setReadOnlyMode(w http.ResponseWriter, r *http.Request) {
+	if !a.Sessions.IsAdmin(r) {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
+
+	enabled, err := strconv.ParseBool(r.FormValue("enabled"))
+	if err != nil {
+		a.renderError(w, r, http.StatusBadRequest, errors.New("Invalid enabled value"))
+		return
+	}
+	a.readOnly.Set(enabled)
+	log.Printf("Read-only mode set to %v", enabled)
+	a.audit(r, "read-only", "read-only mode set to "+strconv.FormatBool(enabled))
+	writeJSON(w, readOnlyState{a.readOnly.Enabled()})
+}
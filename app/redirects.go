@@ -0,0 +1,104 @@
+package app
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ultramozg/golang-blog-engine/model"
+)
+
+//redirectMiddleware sends requests matching a configured redirect to its destination before routing,
+//the source is matched with the query string first and by the path only then
+func (a *App) redirectMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		rd, err := model.FindRedirect(a.DB, r.URL.RequestURI())
+		if err == sql.ErrNoRows && r.URL.RawQuery != "" {
+			rd, err = model.FindRedirect(a.DB, r.URL.Path)
+			if err == nil && !strings.Contains(rd.Destination, "?") {
+				rd.Destination += "?" + r.URL.RawQuery
+			}
+		}
+		if err != nil {
+			if err != sql.ErrNoRows {
+				log.Println("Unable to look up redirect: ", err)
+			}
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if err := model.CountRedirectHit(a.DB, rd.Source); err != nil {
+			log.Println("Unable to count redirect hit: ", err)
+		}
+		http.Redirect(w, r, rd.Destination, rd.Status)
+	})
+}
+
+//redirects renders redirect manager
+func (a *App) redirects(w http.ResponseWriter, r *http.Request) {
+	if !a.Sessions.IsAdmin(r) {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
+
+	list, err := model.GetRedirects(a.DB)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to fetch redirects: %v", err))
+		return
+	}
+
+	data := struct {
+		PageData
+		Redirects []model.Redirect
+		Source    string
+	}{
+		a.pageData(r),
+		list,
+		r.FormValue("source"),
+	}
+	a.renderTemplate(w, r, "redirects.gohtml", data)
+}
+
+//saveRedirect adds or replaces redirect, or deletes it with action=delete
+func (a *App) saveRedirect(w http.ResponseWriter, r *http.Request) {
+	if !a.Sessions.IsAdmin(r) {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
+
+	source := strings.TrimSpace(r.FormValue("source"))
+	if r.FormValue("action") == "delete" {
+		if err := model.DeleteRedirect(a.DB, source); err != nil {
+			a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to delete redirect: %v", err))
+			return
+		}
+		a.audit(r, "redirect delete", source)
+		http.Redirect(w, r, "/admin/redirects", http.StatusSeeOther)
+		return
+	}
+
+	status, _ := strconv.Atoi(r.FormValue("status"))
+	rd := model.Redirect{Source: source, Destination: strings.TrimSpace(r.FormValue("destination")), Status: status}
+	if err := rd.SaveRedirect(a.DB); err != nil {
+		if err == model.ErrInvalidRedirect {
+			a.renderError(w, r, http.StatusBadRequest, errors.New("Source must be a path, destination a path or url and type 301 or 302"))
+			return
+		}
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to save redirect: %v", err))
+		return
+	}
+	if err := model.DeleteNotFound(a.DB, rd.Source); err != nil {
+		log.Println("Unable to delete not found path: ", err)
+	}
+	a.audit(r, "redirect save", fmt.Sprintf("%s -> %s (%d)", rd.Source, rd.Destination, rd.Status))
+	http.Redirect(w, r, "/admin/redirects", http.StatusSeeOther)
+}
@@ -0,0 +1,95 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/ultramozg/golang-blog-engine/model"
+)
+
+//replySubscription returns subscription to emails about new comments of the post if the comment form asks for it,
+//nil if it doesn't or mail isn't configured
+func (a *App) replySubscription(r *http.Request, postID int, name string) (*model.CommentSubscription, error) {
+	if r.FormValue("notify") != "on" || a.mail == nil {
+		return nil, nil
+	}
+	addr, err := mail.ParseAddress(r.FormValue("email"))
+	if err != nil {
+		return nil, newError(http.StatusBadRequest, "Invalid email for reply notifications")
+	}
+
+	return &model.CommentSubscription{
+		PostID: postID,
+		Name:   name,
+		Email:  addr.Address,
+		Token:  uuid.NewV4().String(),
+		Site:   a.baseURL(r),
+	}, nil
+}
+
+//unsubscribeReplies serves the unsubscribe link of reply notification emails
+func (a *App) unsubscribeReplies(w http.ResponseWriter, r *http.Request) {
+	ok, err := model.Unsubscribe(a.DB, r.FormValue("token"))
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if !ok {
+		a.renderError(w, r, http.StatusNotFound, errors.New("Subscription not found, it may be already cancelled"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "You won't get emails about new comments of this post anymore.")
+}
+
+//sendReplyNotifications emails every subscriber one digest of the comments posted by others since the last run
+func (a *App) sendReplyNotifications() error {
+	subs, err := model.GetCommentSubscriptions(a.DB)
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	for _, s := range subs {
+		comments, err := model.GetCommentsAfter(a.DB, s.PostID, s.NotifiedUntil)
+		if err != nil {
+			return err
+		}
+		if len(comments) == 0 {
+			continue
+		}
+
+		var body strings.Builder
+		for _, c := range comments {
+			if c.Name != s.Name {
+				fmt.Fprintf(&body, "%s wrote on %s:\n%s\n\n", c.Name, c.Date, c.Data)
+			}
+		}
+		if body.Len() > 0 {
+			p := model.Post{ID: s.PostID}
+			if err := p.GetPost(a.DB); err != nil {
+				return err
+			}
+			fmt.Fprintf(&body, "Read the discussion: %s/post?id=%d\n", s.Site, s.PostID)
+			fmt.Fprintf(&body, "Stop these emails: %s/comments/unsubscribe?token=%s\n", s.Site, s.Token)
+			if err := a.mail.Send([]string{s.Email}, "New comments on "+p.Title, body.String()); err != nil {
+				failed = append(failed, s.Email+": "+err.Error())
+				continue
+			}
+		}
+		if err := s.SetNotifiedUntil(a.DB, comments[len(comments)-1].CommentID); err != nil {
+			return err
+		}
+	}
+
+	if len(failed) > 0 {
+		return errors.New("unable to send " + strconv.Itoa(len(failed)) + " reply notifications: " + strings.Join(failed, "; "))
+	}
+	return nil
+}
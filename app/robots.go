@@ -0,0 +1,72 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+//head is the data of the shared header template
+type head struct {
+	Admin  bool
+	Robots string
+
+	//Title, Description, Image, Canonical, StructuredData and Breadcrumbs are set on content pages only
+	Title          string
+	Description    string
+	Image          string
+	Canonical      string
+	StructuredData string
+	Alternates     []alternate
+	Breadcrumbs    []breadcrumb
+	BreadcrumbData string
+
+	//Prev and Next are neighbours of the paginated list page
+	Prev string
+	Next string
+
+	//Visitor is set while the admin reads the blog as an anonymous visitor, the header offers the way back
+	Visitor bool
+}
+
+//newHead builds header template data, robots directives are optional
+func newHead(admin bool, robots ...string) head {
+	return head{Admin: admin, Robots: strings.Join(robots, "")}
+}
+
+//generateRobotsTxt renders robots.txt content from the configured rules, the sitemap is linked under base URL
+func (a *App) generateRobotsTxt(base string) string {
+	var b strings.Builder
+	b.WriteString("User-agent: *\n")
+	if a.Config.Robots.Discourage {
+		b.WriteString("Disallow: /\n")
+		return b.String()
+	}
+	for _, path := range a.Config.Robots.Allow {
+		fmt.Fprintf(&b, "Allow: %s\n", path)
+	}
+	for _, path := range a.Config.Robots.Disallow {
+		fmt.Fprintf(&b, "Disallow: %s\n", path)
+	}
+	if !a.Config.Headless.Enabled {
+		fmt.Fprintf(&b, "\nSitemap: %s/sitemap.xml\n", base)
+	}
+	return b.String()
+}
+
+//noIndexMiddleware marks every response noindex while indexing is discouraged. Robots directives
+//of the handlers are added as separate headers, crawlers apply all of them
+func (a *App) noIndexMiddleware(h http.Handler) http.Handler {
+	if !a.Config.Robots.Discourage {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("X-Robots-Tag", "noindex")
+		h.ServeHTTP(w, r)
+	})
+}
+
+func (a *App) robotsTxt(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprint(w, a.generateRobotsTxt(a.baseURL(r)))
+}
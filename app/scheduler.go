@@ -0,0 +1,198 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ultramozg/golang-blog-engine/model"
+	"github.com/ultramozg/golang-blog-engine/tracing"
+)
+
+const (
+	JobRunsToKeep  = 100
+	JobRunsPerPage = 50
+)
+
+//job is a periodic task registered in the scheduler, jobs with Hour run daily at that hour
+//of the server local time, others every Interval. run returns summary of the run shown on the jobs page
+type job struct {
+	Name     string
+	Interval time.Duration
+	Hour     int
+	run      func() (string, error)
+}
+
+//Schedule describes when the job runs
+func (j job) Schedule() string {
+	if j.Hour >= 0 {
+		return fmt.Sprintf("daily at %02d:00", j.Hour)
+	}
+	return "every " + j.Interval.String()
+}
+
+//delay returns time from now till the next run of the job without jitter,
+//jobs with interval run right after the start
+func (j job) delay(now time.Time, first bool) time.Duration {
+	if j.Hour < 0 {
+		if first {
+			return 0
+		}
+		return j.Interval
+	}
+	next := time.Date(now.Year(), now.Month(), now.Day(), j.Hour, 0, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next.Sub(now)
+}
+
+//scheduler runs registered jobs periodically, each job in its own goroutine,
+//start of every run is shifted by random jitter so jobs don't fire all at once
+type scheduler struct {
+	db     *sql.DB
+	jitter time.Duration
+	jobs   []job
+	//location is the timezone hours of the daily jobs are in
+	location *time.Location
+	//paused skips runs while it returns true, e.g. in read-only mode
+	paused func() bool
+	//tracer records span of every run
+	tracer *tracing.Tracer
+	//ctx is cancelled by Stop, long running jobs watch it to stop mid-run
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newScheduler(db *sql.DB, jitter time.Duration, location *time.Location) *scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &scheduler{db: db, jitter: jitter, location: location, ctx: ctx, cancel: cancel}
+}
+
+//Context returns context of the scheduler which is done once Stop is called
+func (s *scheduler) Context() context.Context {
+	return s.ctx
+}
+
+//Register adds the job to the scheduler, disabled jobs are skipped
+func (s *scheduler) Register(name string, interval time.Duration, enabled bool, run func() error) {
+	if !enabled {
+		log.Printf("Job %s is disabled", name)
+		return
+	}
+	if interval <= 0 {
+		log.Printf("Job %s has invalid interval %s, skipping it", name, interval)
+		return
+	}
+	s.jobs = append(s.jobs, job{name, interval, -1, func() (string, error) { return "", run() }})
+}
+
+//RegisterDaily adds the job running once a day at the hour, e.g. during low traffic at night
+func (s *scheduler) RegisterDaily(name string, hour int, enabled bool, run func() (string, error)) {
+	if !enabled {
+		log.Printf("Job %s is disabled", name)
+		return
+	}
+	if hour < 0 || hour > 23 {
+		log.Printf("Job %s has invalid hour %d, skipping it", name, hour)
+		return
+	}
+	s.jobs = append(s.jobs, job{name, 24 * time.Hour, hour, run})
+}
+
+//Start launches all registered jobs
+func (s *scheduler) Start() {
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(j)
+		log.Printf("Job %s scheduled %s", j.Name, j.Schedule())
+	}
+}
+
+//Stop signals all jobs to stop and waits for running ones to finish
+func (s *scheduler) Stop() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+func (s *scheduler) loop(j job) {
+	defer s.wg.Done()
+
+	timer := time.NewTimer(j.delay(time.Now().In(s.location), true) + s.randJitter())
+	defer timer.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-timer.C:
+			s.execute(j)
+			timer.Reset(j.delay(time.Now().In(s.location), false) + s.randJitter())
+		}
+	}
+}
+
+//execute runs the job once and stores the run log
+func (s *scheduler) execute(j job) {
+	if s.paused != nil && s.paused() {
+		log.Printf("Job %s skipped, jobs are paused", j.Name)
+		return
+	}
+	_, span := s.tracer.Start(s.ctx, "job "+j.Name, tracing.KindInternal)
+	started := time.Now()
+	summary, err := j.run()
+	span.SetError(err)
+	span.End()
+
+	run := model.JobRun{Name: j.Name, Started: started.Unix(), Duration: time.Since(started), Summary: summary}
+	if summary != "" {
+		log.Printf("Job %s: %s", j.Name, summary)
+	}
+	if err != nil {
+		run.Error = err.Error()
+		log.Printf("Job %s failed: %v", j.Name, err)
+		notify(s.db, NotifyJobFailed, "Job "+j.Name+" failed: "+err.Error(), "/admin/jobs")
+	}
+	if err := run.CreateJobRun(s.db); err != nil {
+		log.Println("Unable to save job run: ", err)
+	}
+	if err := model.DeleteOldJobRuns(s.db, j.Name, JobRunsToKeep); err != nil {
+		log.Println("Unable to delete old job runs: ", err)
+	}
+}
+
+func (s *scheduler) randJitter() time.Duration {
+	if s.jitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(s.jitter)))
+}
+
+func (a *App) jobRuns(w http.ResponseWriter, r *http.Request) {
+	if !a.Sessions.IsAdmin(r) {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
+
+	runs, err := model.GetJobRuns(a.DB, JobRunsPerPage)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to fetch job runs: %v", err))
+		return
+	}
+
+	data := struct {
+		PageData
+		Jobs []job
+		Runs []model.JobRun
+	}{
+		a.pageData(r),
+		a.jobs.jobs,
+		runs,
+	}
+	a.renderTemplate(w, r, "jobs.gohtml", data)
+}
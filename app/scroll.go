@@ -0,0 +1,72 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/ultramozg/golang-blog-engine/model"
+	"github.com/ultramozg/golang-blog-engine/render"
+)
+
+//postCards is a page of the front page post list, HTML holds rendered cards
+//when they are requested with format=html, otherwise posts are listed in Items
+type postCards struct {
+	Items      []pollPost `json:"items,omitempty"`
+	HTML       string     `json:"html,omitempty"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+//postList serves /api/posts which is used by infinite scroll of the front page,
+//cursor points to the page of the front page so the order is the same as with the classic pagination
+func (a *App) postList(w http.ResponseWriter, r *http.Request) {
+	page, err := decodeCursor(r.FormValue("cursor"))
+	if err != nil || page < 0 {
+		a.renderError(w, r, http.StatusBadRequest, errors.New("Invalid cursor"))
+		return
+	}
+
+	settings := a.settings.Get()
+	posts, total, err := model.GetPostSummaries(a.DB, page, settings.PostsPerPage, settings.SortOrder)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to fetch posts page: %v", err))
+		return
+	}
+	a.restrictAll(r, posts)
+
+	var cards postCards
+	if isNextPage(page, total, settings.PostsPerPage) {
+		cards.NextCursor = encodeCursor(page + 1)
+	}
+
+	if r.FormValue("format") == "html" {
+		data := struct {
+			PageData
+			Posts []model.Post
+		}{
+			a.pageData(r),
+			posts,
+		}
+		var buf bytes.Buffer
+		if err := a.Temp.ExecuteTemplate(&buf, "cards", data); err != nil {
+			a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to render post cards: %v", err))
+			return
+		}
+		cards.HTML = buf.String()
+	} else {
+		cards.Items = make([]pollPost, 0, len(posts))
+		for _, p := range posts {
+			cards.Items = append(cards.Items, pollPost{
+				ID:         p.ID,
+				Title:      p.Title,
+				URL:        a.baseURL(r) + "/post?id=" + strconv.Itoa(p.ID),
+				Excerpt:    render.Excerpt(p.Body),
+				CoverImage: a.absoluteURL(r, p.CoverImage),
+				Published:  a.isoDate(p.Date),
+			})
+		}
+	}
+	writeJSON(w, cards)
+}
@@ -0,0 +1,100 @@
+package app
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/ultramozg/golang-blog-engine/model"
+)
+
+const (
+	SearchResultsLimit = 50
+	SiteName           = "My Posts"
+)
+
+func (a *App) search(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.FormValue("q"))
+
+	posts := []model.Post{}
+	if query != "" {
+		_, loggedIn, _ := a.viewer(r)
+		var err error
+		posts, err = model.SearchPosts(a.DB, query, SearchResultsLimit, loggedIn)
+		if err != nil {
+			a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to search posts: %v", err))
+			return
+		}
+		a.restrictAll(r, posts)
+	}
+
+	data := struct {
+		PageData
+		Query string
+		Posts []model.Post
+	}{
+		a.pageData(r),
+		query,
+		posts,
+	}
+	a.renderTemplate(w, r, "search.gohtml", data)
+}
+
+//openSearch serves OpenSearch description so browsers can offer searching the site directly
+func (a *App) openSearch(w http.ResponseWriter, r *http.Request) {
+	type url struct {
+		Type     string `xml:"type,attr"`
+		Template string `xml:"template,attr"`
+	}
+	desc := struct {
+		XMLName       xml.Name `xml:"http://a9.com/-/spec/opensearch/1.1/ OpenSearchDescription"`
+		ShortName     string   `xml:"ShortName"`
+		Description   string   `xml:"Description"`
+		InputEncoding string   `xml:"InputEncoding"`
+		URL           url      `xml:"Url"`
+	}{
+		ShortName:     a.settings.Get().SiteTitle,
+		Description:   "Search " + a.settings.Get().SiteTitle,
+		InputEncoding: "UTF-8",
+		URL:           url{"text/html", a.baseURL(r) + "/search?q={searchTerms}"},
+	}
+
+	w.Header().Set("Content-Type", "application/opensearchdescription+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	if err := xml.NewEncoder(w).Encode(desc); err != nil {
+		log.Println(err)
+	}
+}
+
+//baseURL returns scheme and host of the site, the configured domain takes precedence over the request host
+//unless the request came to another configured domain of the blog
+func (a *App) baseURL(r *http.Request) string {
+	if site, ok := a.site(r); ok {
+		return "https://" + site.Host
+	}
+	if a.Config.Domain != "" {
+		return "https://" + a.Config.Domain
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+//site returns configured site the request came to
+func (a *App) site(r *http.Request) (Site, bool) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	for _, s := range a.Config.Sites {
+		if strings.EqualFold(s.Host, host) {
+			return s, true
+		}
+	}
+	return Site{}, false
+}
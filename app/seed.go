@@ -0,0 +1,216 @@
+package app
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ultramozg/golang-blog-engine/model"
+	"github.com/ultramozg/golang-blog-engine/render"
+	"gopkg.in/yaml.v2"
+)
+
+//DefaultDemoPosts is the number of posts seeded when none is given
+const DefaultDemoPosts = 24
+
+//DemoOptions tells what demo content to generate, the same Seed always gives the same content.
+//Links and courses files are written to DataDir unless they exist, empty DataDir skips them
+type DemoOptions struct {
+	Seed    int64
+	Posts   int
+	DataDir string
+}
+
+//DemoSummary counts the stored demo content
+type DemoSummary struct {
+	Posts    int
+	Comments int
+	Likes    int
+	Files    []string
+}
+
+//demoPost is a generated post with its comments, Likers are the commenters who like the comment
+type demoPost struct {
+	Post     model.Post
+	Comments []demoComment
+}
+
+type demoComment struct {
+	Comment model.Comment
+	Likers  []string
+}
+
+var (
+	demoTopics = []string{"Go", "SQLite", "Linux", "Raspberry Pi", "Orange Pi", "Docker", "TLS", "systemd",
+		"HTTP/2", "Kubernetes", "Git", "Vim", "Prometheus", "WireGuard", "ZFS", "Nginx"}
+	demoTitles = []string{"Getting started with %s", "Five things I learned about %s", "%s in production",
+		"Debugging %s at 3 a.m.", "Why I moved my blog to %s", "A practical guide to %s", "%s: notes from a weekend",
+		"Benchmarking %s on a single board computer", "The hidden costs of %s", "Automating %s with a Makefile"}
+	demoSentences = []string{
+		"It started as a small experiment on a spare board under my desk.",
+		"The documentation covers the basics well, but the edge cases are where it gets interesting.",
+		"After a week of running it, the numbers were better than I expected.",
+		"Most of the time was spent reading logs rather than writing code.",
+		"There is no silver bullet, only trade-offs you are willing to live with.",
+		"I kept the configuration in git, so every change can be rolled back.",
+		"The first attempt failed because the defaults assume a much bigger machine.",
+		"A few lines of shell glued everything together.",
+		"Measuring before optimizing saved me from rewriting the wrong part.",
+		"The community forum had the answer, buried in a thread from years ago.",
+		"Memory usage stayed flat even under a synthetic load.",
+		"Backups are boring until the day you need them.",
+	}
+	demoCode = []string{
+		"```go\nfunc main() {\n\thttp.HandleFunc(\"/\", func(w http.ResponseWriter, r *http.Request) {\n\t\tfmt.Fprintln(w, \"hello\")\n\t})\n\tlog.Fatal(http.ListenAndServe(\":8080\", nil))\n}\n```",
+		"```sh\nsudo systemctl enable --now blog.service\njournalctl -u blog.service -f\n```",
+		"```sql\ncreate index if not exists posts_status on posts (status, id);\n```",
+		"```yaml\nserver:\n  addr: 0.0.0.0\n  port: 8080\n```",
+	}
+	demoCommenters = []string{"gopher", "octocat", "linus-fan", "sqlite-lover", "pi-hacker", "rustacean", "vim-user", "night-owl"}
+	demoComments   = []string{
+		"Great write-up, thanks for sharing!",
+		"Did you try it with the latest release? Some of this changed recently.",
+		"I ran into the same issue, the workaround helped.",
+		"How much memory does it use on your board?",
+		"Bookmarked, this is exactly what I was looking for.",
+		"Nice, but I would keep the defaults for a small site.",
+		"Could you share the full config?",
+		"Works on my machine too :)",
+	}
+	demoLinks = model.Infos{List: []model.Info{
+		{Title: "The Go Blog", Link: "https://go.dev/blog/", Description: "News and articles from the Go team"},
+		{Title: "SQLite documentation", Link: "https://www.sqlite.org/docs.html"},
+		{Title: "Let's Encrypt", Link: "https://letsencrypt.org/", Description: "Free TLS certificates"},
+	}}
+	demoCourses = model.Infos{List: []model.Info{
+		{Title: "Programming with Google Go", Link: "https://www.coursera.org/specializations/google-golang", Description: "Coursera specialization"},
+		{Title: "Linux Foundation: Introduction to Linux", Link: "https://training.linuxfoundation.org/training/introduction-to-linux/"},
+	}}
+)
+
+//demoContent generates the posts deterministically from the seed, the newest post is published at now
+//and the older ones go back up to a year
+func demoContent(seed int64, posts int, now time.Time) []demoPost {
+	rnd := rand.New(rand.NewSource(seed))
+	pick := func(list []string) string {
+		return list[rnd.Intn(len(list))]
+	}
+
+	dates := make([]time.Time, posts)
+	for i := range dates {
+		dates[i] = now.Add(-time.Duration(rnd.Int63n(int64(365 * 24 * time.Hour))))
+	}
+	//posts are listed by id, the oldest is stored first
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+	if posts > 0 {
+		dates[posts-1] = now
+	}
+
+	out := make([]demoPost, 0, posts)
+	for i := 0; i < posts; i++ {
+		topic := pick(demoTopics)
+		var body strings.Builder
+		fmt.Fprintf(&body, "<p>%s %s</p>\n%s\n", pick(demoSentences), pick(demoSentences), render.MoreMarker)
+		sections := 2 + rnd.Intn(3)
+		for s := 0; s < sections; s++ {
+			fmt.Fprintf(&body, "<h2>%s, part %d</h2>\n<p>%s %s %s</p>\n", topic, s+1, pick(demoSentences), pick(demoSentences), pick(demoSentences))
+			if rnd.Intn(2) == 0 {
+				body.WriteString(pick(demoCode) + "\n")
+			}
+		}
+
+		p := model.Post{
+			Title:    fmt.Sprintf(pick(demoTitles), topic),
+			Body:     body.String(),
+			Date:     dates[i].Format("Mon Jan _2 15:04:05 2006"),
+			Featured: rnd.Intn(8) == 0,
+			Pinned:   i == posts-2,
+		}
+		if rnd.Intn(10) == 0 {
+			p.Visibility = model.VisibilityMembers
+		}
+
+		dp := demoPost{Post: p}
+		date := dates[i]
+		for c := rnd.Intn(6); c > 0; c-- {
+			date = date.Add(time.Duration(1+rnd.Intn(48)) * time.Hour)
+			dc := demoComment{Comment: model.Comment{Name: pick(demoCommenters), Date: date.Format("Mon Jan _2 15:04:05 2006"), Data: pick(demoComments)}}
+			for _, name := range demoCommenters {
+				if name != dc.Comment.Name && rnd.Intn(4) == 0 {
+					dc.Likers = append(dc.Likers, name)
+				}
+			}
+			dp.Comments = append(dp.Comments, dc)
+		}
+		out = append(out, dp)
+	}
+	return out
+}
+
+//SeedDemo stores generated demo posts, comments and their likes, e.g. for a new deployment or UI development
+func (a *App) SeedDemo(opts DemoOptions) (DemoSummary, error) {
+	var sum DemoSummary
+	if opts.Posts <= 0 {
+		opts.Posts = DefaultDemoPosts
+	}
+	for _, dp := range demoContent(opts.Seed, opts.Posts, time.Now()) {
+		p := dp.Post
+		if err := p.CreatePost(a.DB); err != nil {
+			return sum, fmt.Errorf("unable to create post %q: %v", p.Title, err)
+		}
+		sum.Posts++
+		for _, dc := range dp.Comments {
+			c := dc.Comment
+			c.PostID = p.ID
+			if err := c.CreateComment(a.DB); err != nil {
+				return sum, fmt.Errorf("unable to create comment on %q: %v", p.Title, err)
+			}
+			sum.Comments++
+			for _, name := range dc.Likers {
+				if _, err := model.ToggleCommentLike(a.DB, c.CommentID, name); err != nil {
+					return sum, fmt.Errorf("unable to like comment %d: %v", c.CommentID, err)
+				}
+				sum.Likes++
+			}
+		}
+	}
+
+	if opts.DataDir == "" {
+		return sum, nil
+	}
+	files := []struct {
+		name  string
+		infos model.Infos
+	}{{"links.yml", demoLinks}, {"courses.yml", demoCourses}}
+	for _, f := range files {
+		path := filepath.Join(opts.DataDir, f.name)
+		written, err := writeDemoInfos(path, f.infos)
+		if err != nil {
+			return sum, fmt.Errorf("unable to write %s: %v", path, err)
+		}
+		if written {
+			sum.Files = append(sum.Files, path)
+		}
+	}
+	return sum, nil
+}
+
+//writeDemoInfos writes the list unless the file exists, the admin's own lists are never overwritten
+func writeDemoInfos(path string, infos model.Infos) (bool, error) {
+	if _, err := os.Stat(path); err == nil {
+		return false, nil
+	}
+	b, err := yaml.Marshal(infos)
+	if err != nil {
+		return false, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return false, err
+	}
+	return true, ioutil.WriteFile(path, b, 0644)
+}
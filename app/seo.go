@@ -0,0 +1,224 @@
+package app
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ultramozg/golang-blog-engine/model"
+	"github.com/ultramozg/golang-blog-engine/render"
+)
+
+//absoluteURL makes site relative url absolute
+func (a *App) absoluteURL(r *http.Request, u string) string {
+	if u == "" || strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://") {
+		return u
+	}
+	return a.baseURL(r) + "/" + strings.TrimPrefix(u, "/")
+}
+
+//canonicalURL returns canonical url of the post, the override is used for posts republished from elsewhere
+func (a *App) canonicalURL(r *http.Request, p model.Post) string {
+	if p.CanonicalURL != "" {
+		return p.CanonicalURL
+	}
+	return a.postURL(r, p.ID)
+}
+
+//validCanonicalURL reports whether the canonical url override is empty or absolute http(s) url
+func validCanonicalURL(u string) bool {
+	if u == "" {
+		return true
+	}
+	parsed, err := url.Parse(u)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+//alternate is the same page on another domain of the blog, Lang is a hreflang value
+type alternate struct {
+	Lang string
+	URL  string
+}
+
+//alternates returns the requested page on every configured domain with x-default
+//pointing to the first one, nothing is returned when the blog has a single domain
+func (a *App) alternates(r *http.Request) []alternate {
+	if len(a.Config.Sites) < 2 {
+		return nil
+	}
+	list := []alternate{}
+	for _, s := range a.Config.Sites {
+		list = append(list, alternate{s.Lang, "https://" + s.Host + r.URL.RequestURI()})
+	}
+	return append(list, alternate{"x-default", "https://" + a.Config.Sites[0].Host + r.URL.RequestURI()})
+}
+
+//postHead builds header data of the post page: robots directives, open graph and structured data
+func (a *App) postHead(r *http.Request, p model.Post) head {
+	admin, _, _ := a.viewer(r)
+	h := newHead(admin, p.Robots())
+	h.Title = p.Title
+	h.Description = postDescription(p.Body)
+	h.Image = a.absoluteURL(r, p.CoverImage)
+	h.Canonical = a.canonicalURL(r, p)
+	if p.CanonicalURL == "" {
+		h.Alternates = a.alternates(r)
+	}
+	h.StructuredData = a.postStructuredData(r, p)
+	h.Breadcrumbs = a.postBreadcrumbs(r, p)
+	h.BreadcrumbData = breadcrumbData(h.Breadcrumbs, h.Canonical)
+	return h
+}
+
+//postDescription returns meta description of the post, it's the text of the excerpt
+func postDescription(body string) string {
+	text, _, _ := inspectBody(render.Excerpt(body))
+	return render.Description(text)
+}
+
+//postStructuredData returns schema.org JSON-LD of the post, the type depends on the content type of the post
+func (a *App) postStructuredData(r *http.Request, p model.Post) string {
+	data := map[string]interface{}{
+		"@context":         "https://schema.org",
+		"@type":            "BlogPosting",
+		"headline":         p.Title,
+		"mainEntityOfPage": a.canonicalURL(r, p),
+	}
+	switch p.ContentType {
+	case model.ContentHowTo:
+		data = map[string]interface{}{
+			"@context": "https://schema.org",
+			"@type":    "HowTo",
+			"name":     p.Title,
+			"step":     howToSteps(render.Steps(p.Body)),
+		}
+		if v := p.Schema["totalTime"]; v != "" {
+			data["totalTime"] = v
+		}
+		if v := splitList(p.Schema["supply"]); len(v) > 0 {
+			data["supply"] = namedThings("HowToSupply", v)
+		}
+		if v := splitList(p.Schema["tool"]); len(v) > 0 {
+			data["tool"] = namedThings("HowToTool", v)
+		}
+
+	case model.ContentFAQ:
+		questions := []interface{}{}
+		for _, f := range render.FAQs(p.Body) {
+			questions = append(questions, map[string]interface{}{
+				"@type":          "Question",
+				"name":           f.Question,
+				"acceptedAnswer": map[string]interface{}{"@type": "Answer", "text": f.Answer},
+			})
+		}
+		data = map[string]interface{}{
+			"@context":   "https://schema.org",
+			"@type":      "FAQPage",
+			"mainEntity": questions,
+		}
+
+	case model.ContentRecipe:
+		data = map[string]interface{}{
+			"@context":           "https://schema.org",
+			"@type":              "Recipe",
+			"name":               p.Title,
+			"recipeIngredient":   splitList(p.Schema["recipeIngredient"]),
+			"recipeInstructions": howToSteps(render.Steps(p.Body)),
+		}
+		for _, f := range []string{"recipeYield", "prepTime", "cookTime", "recipeCategory"} {
+			if v := p.Schema[f]; v != "" {
+				data[f] = v
+			}
+		}
+
+	case model.ContentSoftware:
+		data["@type"] = "SoftwareSourceCode"
+		data["name"] = p.Title
+		for _, f := range []string{"codeRepository", "programmingLanguage", "runtimePlatform"} {
+			if v := p.Schema[f]; v != "" {
+				data[f] = v
+			}
+		}
+	}
+	data["url"] = a.canonicalURL(r, p)
+	if published, modified := a.postDates(p); published != "" {
+		data["datePublished"] = published
+		data["dateModified"] = modified
+	}
+	if p.ContentType != model.ContentFAQ {
+		settings := a.settings.Get()
+		if author := settings.Author.schema(a, r); author != nil {
+			data["author"] = author
+		}
+		if publisher := settings.Publisher.schema(a, r); publisher != nil {
+			data["publisher"] = publisher
+		}
+	}
+
+	if p.CoverImage != "" {
+		data["image"] = a.absoluteURL(r, p.CoverImage)
+	}
+	if p.MembersOnly() {
+		data["isAccessibleForFree"] = false
+		data["hasPart"] = map[string]interface{}{
+			"@type":               "WebPageElement",
+			"isAccessibleForFree": false,
+			"cssSelector":         ".members-only",
+		}
+	}
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		log.Println("Unable to marshal structured data: ", err)
+		return ""
+	}
+	return string(b)
+}
+
+func howToSteps(steps []string) []interface{} {
+	list := make([]interface{}, 0, len(steps))
+	for _, s := range steps {
+		list = append(list, map[string]interface{}{"@type": "HowToStep", "text": s})
+	}
+	return list
+}
+
+func namedThings(typ string, names []string) []interface{} {
+	list := make([]interface{}, 0, len(names))
+	for _, n := range names {
+		list = append(list, map[string]interface{}{"@type": typ, "name": n})
+	}
+	return list
+}
+
+//splitList splits extra field value given one item per line or comma separated
+func splitList(s string) []string {
+	items := []string{}
+	for _, item := range strings.FieldsFunc(s, func(r rune) bool { return r == '\n' || r == ',' }) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+//readContentType reads content type of the post and its extra fields from the post form
+func readContentType(r *http.Request, p *model.Post) bool {
+	p.ContentType = r.FormValue("content_type")
+	if p.ContentType == "" {
+		p.ContentType = model.ContentArticle
+	}
+	if !model.IsContentType(p.ContentType) {
+		return false
+	}
+
+	p.Schema = map[string]string{}
+	for _, f := range model.ContentFields[p.ContentType] {
+		if v := strings.TrimSpace(r.FormValue("schema_" + f)); v != "" {
+			p.Schema[f] = v
+		}
+	}
+	return true
+}
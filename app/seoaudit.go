@@ -0,0 +1,145 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ultramozg/golang-blog-engine/model"
+	"golang.org/x/net/html"
+)
+
+const (
+	MaxTitleLength       = 60
+	MinDescriptionLength = 50
+)
+
+//SEOIssue describes one problem found in a post
+type SEOIssue struct {
+	PostID int    `json:"post_id"`
+	Title  string `json:"title"`
+	Kind   string `json:"kind"`
+	Detail string `json:"detail"`
+}
+
+//auditPostsSEO checks all posts for common SEO problems
+func auditPostsSEO(posts []model.Post) []SEOIssue {
+	issues := []SEOIssue{}
+	add := func(p model.Post, kind, detail string) {
+		issues = append(issues, SEOIssue{p.ID, p.Title, kind, detail})
+	}
+
+	exists := make(map[int]bool, len(posts))
+	titles := make(map[string][]int, len(posts))
+	for _, p := range posts {
+		exists[p.ID] = true
+		key := strings.ToLower(strings.TrimSpace(p.Title))
+		titles[key] = append(titles[key], p.ID)
+	}
+
+	for _, p := range posts {
+		if n := utf8.RuneCountInString(p.Title); n > MaxTitleLength {
+			add(p, "long title", strconv.Itoa(n)+" characters, keep it under "+strconv.Itoa(MaxTitleLength))
+		}
+		if ids := titles[strings.ToLower(strings.TrimSpace(p.Title))]; len(ids) > 1 {
+			add(p, "duplicate title", "same title is used by "+strconv.Itoa(len(ids))+" posts")
+		}
+
+		text, links, images := inspectBody(p.Body)
+		if utf8.RuneCountInString(strings.TrimSpace(text)) < MinDescriptionLength {
+			add(p, "missing meta description", "post text is too short to produce a description")
+		}
+		for _, img := range images {
+			add(p, "image without alt", img)
+		}
+		for _, link := range links {
+			if id, ok := internalPostID(link); ok && !exists[id] {
+				add(p, "broken internal link", link)
+			}
+		}
+	}
+	return issues
+}
+
+//inspectBody extracts plain text, links and images lacking alt text from post html
+func inspectBody(body string) (text string, links []string, imagesWithoutAlt []string) {
+	var b strings.Builder
+	z := html.NewTokenizer(strings.NewReader(body))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return b.String(), links, imagesWithoutAlt
+		case html.TextToken:
+			b.Write(z.Text())
+			b.WriteByte(' ')
+		case html.StartTagToken, html.SelfClosingTagToken:
+			t := z.Token()
+			switch t.Data {
+			case "a":
+				if href := attr(t, "href"); href != "" {
+					links = append(links, href)
+				}
+			case "img":
+				if strings.TrimSpace(attr(t, "alt")) == "" {
+					imagesWithoutAlt = append(imagesWithoutAlt, attr(t, "src"))
+				}
+			}
+		}
+	}
+}
+
+func attr(t html.Token, name string) string {
+	for _, a := range t.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+//internalPostID returns post id if the link points to a post of this blog
+func internalPostID(link string) (int, bool) {
+	u, err := url.Parse(link)
+	if err != nil || u.Host != "" || u.Path != "/post" {
+		return 0, false
+	}
+	id, err := strconv.Atoi(u.Query().Get("id"))
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
+
+//seoAudit serves the report as html page, or as json under the .json suffix
+func (a *App) seoAudit(w http.ResponseWriter, r *http.Request) {
+	if !a.Sessions.IsAdmin(r) {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
+
+	posts, err := model.GetAllPosts(a.DB)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to fetch posts for SEO audit: %v", err))
+		return
+	}
+	issues := auditPostsSEO(posts)
+
+	if strings.HasSuffix(r.URL.Path, ".json") {
+		writeJSON(w, issues)
+		return
+	}
+
+	data := struct {
+		PageData
+		Posts  int
+		Issues []SEOIssue
+	}{
+		a.pageData(r),
+		len(posts),
+		issues,
+	}
+	a.renderTemplate(w, r, "seoaudit.gohtml", data)
+}
@@ -0,0 +1,67 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ultramozg/golang-blog-engine/model"
+	"github.com/ultramozg/golang-blog-engine/render"
+)
+
+const SEOPreviewKeywords = 8
+
+//seoPreview is the result of the SEO preview, HTML holds search result and social card snippets.
+//Titles, URL, description and image are the ones the post page head is going to carry
+type seoPreview struct {
+	PageTitle string `json:"page_title"`
+	Title     string `json:"title"`
+	//URL is the canonical url of the post, posts which aren't saved yet get their id on save
+	URL         string   `json:"url"`
+	Unsaved     bool     `json:"unsaved"`
+	Description string   `json:"description"`
+	Keywords    []string `json:"keywords"`
+	Image       string   `json:"image,omitempty"`
+	HTML        string   `json:"html"`
+}
+
+//seoPreviewHandler serves /api/seo-preview, it shows how the post being edited
+//is going to look in search results and when it's shared before the post is saved
+func (a *App) seoPreviewHandler(w http.ResponseWriter, r *http.Request) {
+	if !a.Sessions.IsAdmin(r) {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
+
+	post := model.Post{
+		Title:        strings.TrimSpace(r.FormValue("title")),
+		Body:         r.FormValue("body"),
+		CoverImage:   strings.TrimSpace(r.FormValue("cover_image")),
+		CanonicalURL: strings.TrimSpace(r.FormValue("canonical_url")),
+	}
+	post.ID, _ = strconv.Atoi(r.FormValue("id"))
+	h := a.postHead(r, post)
+	text, _, _ := inspectBody(post.Body)
+	p := seoPreview{
+		PageTitle:   h.Title + " - " + a.settings.Get().SiteTitle,
+		Title:       h.Title,
+		URL:         h.Canonical,
+		Description: h.Description,
+		Keywords:    render.Keywords(post.Title+" "+text, SEOPreviewKeywords),
+		Image:       h.Image,
+	}
+	if post.ID == 0 && post.CanonicalURL == "" {
+		p.URL = a.baseURL(r) + "/post?id="
+		p.Unsaved = true
+	}
+
+	var buf bytes.Buffer
+	if err := a.Temp.ExecuteTemplate(&buf, "seopreview", p); err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to render SEO preview: %v", err))
+		return
+	}
+	p.HTML = buf.String()
+	writeJSON(w, p)
+}
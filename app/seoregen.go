@@ -0,0 +1,94 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ultramozg/golang-blog-engine/model"
+	"golang.org/x/net/html"
+)
+
+//SEOChange is a stored SEO field of the post filled by regeneration
+type SEOChange struct {
+	PostID int    `json:"post_id"`
+	Title  string `json:"title"`
+	Field  string `json:"field"`
+	Value  string `json:"value"`
+}
+
+//seoChanges returns the missing SEO fields of the posts which can be generated from their bodies.
+//Slug, meta description and keywords are computed on every render, so only the cover image is stored
+func seoChanges(posts []model.Post) []SEOChange {
+	changes := []SEOChange{}
+	for _, p := range posts {
+		if strings.TrimSpace(p.CoverImage) != "" {
+			continue
+		}
+		if img := firstImage(p.Body); img != "" {
+			changes = append(changes, SEOChange{p.ID, p.Title, "cover_image", img})
+		}
+	}
+	return changes
+}
+
+//firstImage returns source of the first image of post html
+func firstImage(body string) string {
+	z := html.NewTokenizer(strings.NewReader(body))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if t := z.Token(); t.Data == "img" {
+				if src := strings.TrimSpace(attr(t, "src")); src != "" {
+					return src
+				}
+			}
+		}
+	}
+}
+
+//RegenerateSEO fills the missing SEO fields of all existing posts, in dry run nothing is stored
+//and the changes which would be made are returned
+func (a *App) RegenerateSEO(dryRun bool) ([]SEOChange, error) {
+	posts, err := model.GetAllPosts(a.DB)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch posts: %v", err)
+	}
+	changes := seoChanges(posts)
+	if dryRun {
+		return changes, nil
+	}
+
+	byID := make(map[int]model.Post, len(posts))
+	for _, p := range posts {
+		byID[p.ID] = p
+	}
+	for _, c := range changes {
+		p := byID[c.PostID]
+		if err := p.SetCoverImage(a.DB, c.Value); err != nil {
+			return nil, fmt.Errorf("unable to update post %d: %v", c.PostID, err)
+		}
+	}
+	return changes, nil
+}
+
+//regenerateSEO serves POST /api/seo-regenerate, with dry_run=true the changes are only reported
+func (a *App) regenerateSEO(w http.ResponseWriter, r *http.Request) {
+	if !a.Sessions.IsAdmin(r) {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
+
+	dryRun := r.FormValue("dry_run") == "true"
+	changes, err := a.RegenerateSEO(dryRun)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if !dryRun && len(changes) > 0 {
+		a.audit(r, "seo regenerate", fmt.Sprintf("%d fields of existing posts filled", len(changes)))
+	}
+	writeJSON(w, changes)
+}
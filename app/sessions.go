@@ -0,0 +1,183 @@
+package app
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ultramozg/golang-blog-engine/middleware"
+	"github.com/ultramozg/golang-blog-engine/model"
+	"github.com/ultramozg/golang-blog-engine/session"
+)
+
+//randomHex returns n random bytes encoded as hex
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func hashToken(v string) string {
+	sum := sha256.Sum256([]byte(v))
+	return hex.EncodeToString(sum[:])
+}
+
+//issueRememberToken stores new token for the user and returns its selector and the cookie
+func (a *App) issueRememberToken(u model.User) (string, *http.Cookie, error) {
+	selector, validator := randomHex(12), randomHex(32)
+	t := model.RememberToken{
+		Selector: selector,
+		Hash:     hashToken(validator),
+		Name:     u.Name,
+		Type:     u.Type,
+		Expires:  time.Now().Add(a.Config.Login.RememberFor).Unix(),
+	}
+	if err := t.CreateRememberToken(a.DB); err != nil {
+		return "", nil, err
+	}
+	return selector, a.cookies.New("remember", selector+":"+validator, int(a.Config.Login.RememberFor.Seconds())), nil
+}
+
+//sameSite converts configured SameSite mode, anything but "strict" means lax
+func sameSite(mode string) http.SameSite {
+	if strings.ToLower(mode) == "strict" {
+		return http.SameSiteStrictMode
+	}
+	return http.SameSiteLaxMode
+}
+
+//RememberGrace is how long the replaced remember me token stays valid, requests of the page and its assets
+//sent in parallel still carry it
+const RememberGrace = time.Minute
+
+//rememberMiddleware restores session from the remember me cookie, the token is rotated on every use.
+//Presenting a known selector with wrong validator means the token was stolen, so all tokens of the user are dropped.
+//The cookie is expired only if no token matches it
+func (a *App) rememberMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := r.Cookie("remember"); err != nil || a.Sessions.IsLoggedin(r) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		value, _ := a.cookies.Read(r, "remember")
+		parts := strings.SplitN(value, ":", 2)
+		t, err := model.GetRememberToken(a.DB, parts[0])
+		if err != nil || len(parts) != 2 || t.Expires < time.Now().Unix() {
+			http.SetCookie(w, a.cookies.Expire("remember"))
+			h.ServeHTTP(w, r)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(t.Hash), []byte(hashToken(parts[1]))) != 1 {
+			if err := model.DeleteUserRememberTokens(a.DB, t.Name); err != nil {
+				log.Println("Unable to delete remember tokens: ", err)
+			}
+			a.auditAs(t.Name, r, "remember token reuse", "all remember me tokens were revoked")
+			http.SetCookie(w, a.cookies.Expire("remember"))
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		u := model.User{Type: t.Type, Name: t.Name}
+		rotate := !t.Rotated
+		if rotate {
+			if rotate, err = model.RotateRememberToken(a.DB, t.Selector, time.Now().Add(RememberGrace).Unix()); err != nil {
+				log.Println("Unable to rotate remember token: ", err)
+				h.ServeHTTP(w, r)
+				return
+			}
+		}
+		//within the grace period the session is restored, the cookie of the new token is left alone
+		selector := t.Selector
+		if rotate {
+			var rc *http.Cookie
+			if selector, rc, err = a.issueRememberToken(u); err != nil {
+				log.Println("Unable to rotate remember token: ", err)
+				h.ServeHTTP(w, r)
+				return
+			}
+			http.SetCookie(w, rc)
+		}
+		sc := a.Sessions.CreateRememberedSession(u, r, selector)
+		http.SetCookie(w, sc)
+		cookies := r.Cookies()
+		r.Header.Del("Cookie")
+		for _, old := range cookies {
+			if old.Name != "session" {
+				r.AddCookie(old)
+			}
+		}
+		r.AddCookie(sc)
+		h.ServeHTTP(w, r)
+	})
+}
+
+//revokeSession drops remember me token of the session along with it
+func (a *App) revokeSession(s session.Session) {
+	if s.Remember == "" {
+		return
+	}
+	if err := model.DeleteRememberToken(a.DB, s.Remember); err != nil {
+		log.Println("Unable to delete remember token: ", err)
+	}
+}
+
+//sessions lists active sessions of the admin
+func (a *App) sessions(w http.ResponseWriter, r *http.Request) {
+	if !a.Sessions.IsAdmin(r) {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
+	current, _ := a.Sessions.GetSession(r)
+	//refs revoke sessions and are shown next to user agents anyone can send, see BREACH
+	middleware.DisableCompression(w)
+
+	data := struct {
+		PageData
+		Current  string
+		Sessions []session.Session
+	}{
+		a.pageData(r),
+		current.Ref(),
+		a.Sessions.List(),
+	}
+	a.renderTemplate(w, r, "sessions.gohtml", data)
+}
+
+//sessionAction revokes session given by ref or all except current one
+func (a *App) sessionAction(w http.ResponseWriter, r *http.Request) {
+	if !a.Sessions.IsAdmin(r) {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
+	current, _ := a.Sessions.GetSession(r)
+
+	switch r.FormValue("action") {
+	case "revoke":
+		s, ok := a.Sessions.Revoke(r.FormValue("ref"))
+		if !ok {
+			a.renderError(w, r, http.StatusNotFound, errors.New("Session not found"))
+			return
+		}
+		a.revokeSession(s)
+		a.audit(r, "revoke session", "revoked session of "+s.User.Name+" from "+s.IP)
+	case "revoke-others":
+		removed := a.Sessions.RevokeOthers(current.ID)
+		for _, s := range removed {
+			a.revokeSession(s)
+		}
+		a.audit(r, "revoke session", "revoked all other sessions")
+	default:
+		a.renderError(w, r, http.StatusBadRequest, errors.New("Invalid Input data"))
+		return
+	}
+	http.Redirect(w, r, "/admin/sessions", http.StatusSeeOther)
+}
@@ -0,0 +1,283 @@
+package app
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ultramozg/golang-blog-engine/model"
+)
+
+//Comment policies
+const (
+	CommentsOpen   = "open"
+	CommentsClosed = "closed"
+)
+
+//Settings are site settings which the admin can change at runtime
+type Settings struct {
+	SiteTitle     string
+	Description   string
+	PostsPerPage  int
+	SortOrder     string
+	CommentPolicy string
+	SocialLinks   []string
+	//Author and Publisher are used in meta tags and structured data of the posts
+	Author    Entity
+	Publisher Entity
+}
+
+//Schema.org types of the author
+const (
+	EntityPerson       = "Person"
+	EntityOrganization = "Organization"
+)
+
+//Entity is a person or an organization behind the blog
+type Entity struct {
+	Type   string
+	Name   string
+	URL    string
+	SameAs []string
+	Logo   string
+}
+
+//schema returns schema.org representation of the entity, nil if the name isn't set
+func (e Entity) schema(a *App, r *http.Request) map[string]interface{} {
+	if e.Name == "" {
+		return nil
+	}
+	data := map[string]interface{}{
+		"@type": e.Type,
+		"name":  e.Name,
+	}
+	if e.URL != "" {
+		data["url"] = e.URL
+	}
+	if len(e.SameAs) > 0 {
+		data["sameAs"] = e.SameAs
+	}
+	if e.Logo != "" {
+		key := "logo"
+		if e.Type == EntityPerson {
+			key = "image"
+		}
+		data[key] = map[string]interface{}{"@type": "ImageObject", "url": a.absoluteURL(r, e.Logo)}
+	}
+	return data
+}
+
+//entityType returns the type if it's known, otherwise the fallback
+func entityType(t, fallback string) string {
+	if t == EntityPerson || t == EntityOrganization {
+		return t
+	}
+	return fallback
+}
+
+//loadEntity reads entity from the stored settings, keys are prefixed with the entity name
+func loadEntity(stored map[string]string, prefix, fallbackType string) Entity {
+	return Entity{
+		Type:   entityType(stored[prefix+"_type"], fallbackType),
+		Name:   stored[prefix+"_name"],
+		URL:    stored[prefix+"_url"],
+		SameAs: strings.Fields(stored[prefix+"_same_as"]),
+		Logo:   stored[prefix+"_logo"],
+	}
+}
+
+//storeEntity adds settings of the entity to the stored settings
+func storeEntity(stored map[string]string, prefix string, e Entity) {
+	stored[prefix+"_type"] = e.Type
+	stored[prefix+"_name"] = e.Name
+	stored[prefix+"_url"] = e.URL
+	stored[prefix+"_same_as"] = strings.Join(e.SameAs, "\n")
+	stored[prefix+"_logo"] = e.Logo
+}
+
+//readEntity reads entity from the settings form
+func readEntity(r *http.Request, prefix, fallbackType string) Entity {
+	return Entity{
+		Type:   entityType(r.FormValue(prefix+"_type"), fallbackType),
+		Name:   strings.TrimSpace(r.FormValue(prefix + "_name")),
+		URL:    strings.TrimSpace(r.FormValue(prefix + "_url")),
+		SameAs: strings.Fields(r.FormValue(prefix + "_same_as")),
+		Logo:   strings.TrimSpace(r.FormValue(prefix + "_logo")),
+	}
+}
+
+//CommentsOpen reports whether readers may leave comments
+func (s Settings) CommentsOpen() bool {
+	return s.CommentPolicy != CommentsClosed
+}
+
+//Profiles returns the social links and profiles of the author, pages link them with rel="me"
+//so the profiles can verify they belong to the blog
+func (s Settings) Profiles() []string {
+	profiles := []string{}
+	seen := map[string]bool{}
+	for _, l := range append(append([]string{}, s.SocialLinks...), s.Author.SameAs...) {
+		if !seen[l] {
+			seen[l] = true
+			profiles = append(profiles, l)
+		}
+	}
+	return profiles
+}
+
+//defaultSettings returns settings used until the admin changes them, page size and order come from config
+func defaultSettings(c *Config) Settings {
+	return Settings{
+		SiteTitle:     SiteName,
+		PostsPerPage:  c.Posts.PerPage,
+		SortOrder:     c.Posts.Sort,
+		CommentPolicy: CommentsOpen,
+		SocialLinks:   []string{},
+		Author:        Entity{Type: EntityPerson},
+		Publisher:     Entity{Type: EntityOrganization},
+	}
+}
+
+//settingsStore caches settings table in memory, it's reloaded on save only
+type settingsStore struct {
+	db       *sql.DB
+	defaults Settings
+	mu       sync.RWMutex
+	cached   *Settings
+}
+
+func newSettingsStore(db *sql.DB, defaults Settings) *settingsStore {
+	return &settingsStore{db: db, defaults: defaults}
+}
+
+//Get returns current settings, defaults are used for missing ones
+func (s *settingsStore) Get() Settings {
+	s.mu.RLock()
+	cached := s.cached
+	s.mu.RUnlock()
+	if cached != nil {
+		return *cached
+	}
+
+	v := s.defaults
+	v.SocialLinks = []string{}
+	stored, err := model.GetSettings(s.db)
+	if err != nil {
+		log.Println("Unable to load settings: ", err)
+		return v
+	}
+	if t, ok := stored["site_title"]; ok && t != "" {
+		v.SiteTitle = t
+	}
+	v.Description = stored["site_description"]
+	if n, err := strconv.Atoi(stored["posts_per_page"]); err == nil && n > 0 {
+		v.PostsPerPage = n
+	}
+	if o := stored["sort_order"]; o == model.SortPublished || o == model.SortUpdated {
+		v.SortOrder = o
+	}
+	if p := stored["comment_policy"]; p == CommentsClosed {
+		v.CommentPolicy = p
+	}
+	for _, l := range strings.Fields(stored["social_links"]) {
+		v.SocialLinks = append(v.SocialLinks, l)
+	}
+	v.Author = loadEntity(stored, "author", EntityPerson)
+	v.Publisher = loadEntity(stored, "publisher", EntityOrganization)
+
+	s.mu.Lock()
+	s.cached = &v
+	s.mu.Unlock()
+	return v
+}
+
+//Save stores settings and refreshes the cache
+func (s *settingsStore) Save(v Settings) error {
+	stored := map[string]string{
+		"site_title":       v.SiteTitle,
+		"site_description": v.Description,
+		"posts_per_page":   strconv.Itoa(v.PostsPerPage),
+		"sort_order":       v.SortOrder,
+		"comment_policy":   v.CommentPolicy,
+		"social_links":     strings.Join(v.SocialLinks, "\n"),
+	}
+	storeEntity(stored, "author", v.Author)
+	storeEntity(stored, "publisher", v.Publisher)
+	if err := model.SaveSettings(s.db, stored); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.cached = nil
+	s.mu.Unlock()
+	return nil
+}
+
+//siteSettings renders site settings form
+func (a *App) siteSettings(w http.ResponseWriter, r *http.Request) {
+	if !a.Sessions.IsAdmin(r) {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
+
+	data := struct {
+		PageData
+		Settings Settings
+	}{
+		a.pageData(r),
+		a.settings.Get(),
+	}
+	a.renderTemplate(w, r, "settings.gohtml", data)
+}
+
+//saveSiteSettings validates and saves site settings
+func (a *App) saveSiteSettings(w http.ResponseWriter, r *http.Request) {
+	if !a.Sessions.IsAdmin(r) {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
+
+	perPage, err := strconv.Atoi(r.FormValue("posts_per_page"))
+	if err != nil || perPage < 1 || perPage > 100 {
+		a.renderError(w, r, http.StatusBadRequest, errors.New("Posts per page must be between 1 and 100"))
+		return
+	}
+	v := Settings{
+		SiteTitle:     strings.TrimSpace(r.FormValue("site_title")),
+		Description:   strings.TrimSpace(r.FormValue("site_description")),
+		PostsPerPage:  perPage,
+		SortOrder:     model.SortPublished,
+		CommentPolicy: CommentsOpen,
+		SocialLinks:   strings.Fields(r.FormValue("social_links")),
+		Author:        readEntity(r, "author", EntityPerson),
+		Publisher:     readEntity(r, "publisher", EntityOrganization),
+	}
+	for _, l := range v.Profiles() {
+		if u, err := url.Parse(l); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			a.renderError(w, r, http.StatusBadRequest, fmt.Errorf("Profile %q must be http or https address", l))
+			return
+		}
+	}
+	if r.FormValue("sort_order") == model.SortUpdated {
+		v.SortOrder = model.SortUpdated
+	}
+	if r.FormValue("comment_policy") == CommentsClosed {
+		v.CommentPolicy = CommentsClosed
+	}
+	if v.SiteTitle == "" {
+		v.SiteTitle = SiteName
+	}
+
+	if err := a.settings.Save(v); err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to save settings: %v", err))
+		return
+	}
+	a.audit(r, "settings update", fmt.Sprintf("title %q, %d posts per page sorted by %s, comments %s", v.SiteTitle, v.PostsPerPage, v.SortOrder, v.CommentPolicy))
+	http.Redirect(w, r, "/admin/settings", http.StatusSeeOther)
+}
@@ -0,0 +1,151 @@
+package app
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ultramozg/golang-blog-engine/model"
+)
+
+const sitemapNS = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+//sitemapPageSize is the limit of URLs in one sitemap file set by the protocol, larger blogs get
+//sitemap index at /sitemap.xml pointing to /sitemap.xml?page=N
+var sitemapPageSize = 50000
+
+//ListPagePriority is priority of the posts list pages, posts have the default 0.5
+const ListPagePriority = "0.3"
+
+type sitemapURL struct {
+	Loc      string `xml:"loc"`
+	LastMod  string `xml:"lastmod,omitempty"`
+	Priority string `xml:"priority,omitempty"`
+}
+
+//sitemapWriter encodes sitemap entries one by one, so memory use doesn't depend on the number of URLs
+type sitemapWriter struct {
+	buf  *bufio.Writer
+	enc  *xml.Encoder
+	root string
+}
+
+//newSitemapWriter writes XML header and the opening root element, urlset or sitemapindex
+func newSitemapWriter(w io.Writer, root string) (*sitemapWriter, error) {
+	buf := bufio.NewWriter(w)
+	if _, err := buf.WriteString(xml.Header); err != nil {
+		return nil, err
+	}
+	sw := &sitemapWriter{buf: buf, enc: xml.NewEncoder(buf), root: root}
+	start := xml.StartElement{Name: xml.Name{Local: root}, Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: sitemapNS}}}
+	return sw, sw.enc.EncodeToken(start)
+}
+
+//write encodes one entry, url of urlset or sitemap of sitemapindex
+func (sw *sitemapWriter) write(name string, u sitemapURL) error {
+	return sw.enc.EncodeElement(u, xml.StartElement{Name: xml.Name{Local: name}})
+}
+
+//Close writes the closing root element and flushes the buffer
+func (sw *sitemapWriter) Close() error {
+	if err := sw.enc.EncodeToken(xml.EndElement{Name: xml.Name{Local: sw.root}}); err != nil {
+		return err
+	}
+	if err := sw.enc.Flush(); err != nil {
+		return err
+	}
+	return sw.buf.Flush()
+}
+
+//writeSitemap streams the page of the sitemap. Pages of the posts list come first with lower priority,
+//indexable posts follow, lastmod of the post is the latest of its update and its newest comment
+func (a *App) writeSitemap(w io.Writer, r *http.Request, page, listPages int) error {
+	sw, err := newSitemapWriter(w, "urlset")
+	if err != nil {
+		return err
+	}
+
+	//entries of the page are [start, end) of the list pages followed by the posts
+	start, end := (page-1)*sitemapPageSize, page*sitemapPageSize
+	for i := start; i < end && i < listPages; i++ {
+		if err := sw.write("url", sitemapURL{Loc: a.listPageURL(r, i), Priority: ListPagePriority}); err != nil {
+			return err
+		}
+	}
+	first := start
+	if first < listPages {
+		first = listPages
+	}
+	if first < end {
+		err = model.EachSitemapPost(a.DB, first-listPages, end-first, func(p model.SitemapPost) error {
+			u := sitemapURL{Loc: a.postURL(r, p.ID)}
+			if p.LastMod > 0 {
+				u.LastMod = time.Unix(p.LastMod, 0).In(a.location).Format(time.RFC3339)
+			}
+			return sw.write("url", u)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return sw.Close()
+}
+
+//writeSitemapIndex streams the sitemap index listing all pages of the sitemap
+func (a *App) writeSitemapIndex(w io.Writer, r *http.Request, pages int) error {
+	sw, err := newSitemapWriter(w, "sitemapindex")
+	if err != nil {
+		return err
+	}
+	for i := 1; i <= pages; i++ {
+		if err := sw.write("sitemap", sitemapURL{Loc: a.baseURL(r) + "/sitemap.xml?page=" + strconv.Itoa(i)}); err != nil {
+			return err
+		}
+	}
+	return sw.Close()
+}
+
+//sitemap serves /sitemap.xml, entries are encoded straight into the response which is compressed
+//by the gzip middleware
+func (a *App) sitemap(w http.ResponseWriter, r *http.Request) {
+	total, err := model.CountSitemapPosts(a.DB)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to count posts for sitemap: %v", err))
+		return
+	}
+	published, err := model.CountPosts(a.DB)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to count posts for sitemap: %v", err))
+		return
+	}
+	listPages := pageCount(published, a.settings.Get().PostsPerPage)
+	pages := pageCount(listPages+total, sitemapPageSize)
+
+	page := 0
+	if s := r.FormValue("page"); s != "" {
+		if page, err = strconv.Atoi(s); err != nil || page < 1 || page > pages {
+			a.renderError(w, r, http.StatusNotFound, nil)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+
+	//the response is already being written, failures can only be logged
+	switch {
+	case page == 0 && pages > 1:
+		err = a.writeSitemapIndex(w, r, pages)
+	case page == 0:
+		err = a.writeSitemap(w, r, 1, listPages)
+	default:
+		err = a.writeSitemap(w, r, page, listPages)
+	}
+	if err != nil {
+		log.Println("Unable to write sitemap: ", err)
+	}
+}
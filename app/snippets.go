@@ -0,0 +1,105 @@
+package app
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ultramozg/golang-blog-engine/model"
+)
+
+//expandPlaceholders replaces {{name}} placeholders of the snippet with the values, unknown placeholders are kept
+func expandPlaceholders(s string, values map[string]string) string {
+	pairs := make([]string, 0, 2*len(values))
+	for name, value := range values {
+		pairs = append(pairs, "{{"+name+"}}", value)
+	}
+	return strings.NewReplacer(pairs...).Replace(s)
+}
+
+//snippetPost returns the new post prefilled from the snippet, {{date}} is today and {{series}} is given
+//by the admin when choosing the snippet
+func (a *App) snippetPost(r *http.Request, name string) (model.Post, error) {
+	s, err := model.GetSnippet(a.DB, name)
+	if err == sql.ErrNoRows {
+		return model.Post{}, newError(http.StatusNotFound, "Snippet not found")
+	}
+	if err != nil {
+		return model.Post{}, fmt.Errorf("unable to fetch snippet %q: %v", name, err)
+	}
+
+	values := map[string]string{
+		"date":   a.now().Format("January 2, 2006"),
+		"series": strings.TrimSpace(r.FormValue("series")),
+	}
+	return model.Post{
+		Title:       expandPlaceholders(s.Title, values),
+		Body:        expandPlaceholders(s.Body, values),
+		ContentType: model.ContentArticle,
+	}, nil
+}
+
+//snippets renders snippet library
+func (a *App) snippets(w http.ResponseWriter, r *http.Request) {
+	if !a.Sessions.IsAdmin(r) {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
+
+	list, err := model.GetSnippets(a.DB)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to fetch snippets: %v", err))
+		return
+	}
+
+	edit := model.Snippet{}
+	for _, s := range list {
+		if s.Name == r.FormValue("name") {
+			edit = s
+		}
+	}
+
+	data := struct {
+		PageData
+		Snippets []model.Snippet
+		Edit     model.Snippet
+	}{
+		a.pageData(r).WithRobots("noindex"),
+		list,
+		edit,
+	}
+	a.renderTemplate(w, r, "snippets.gohtml", data)
+}
+
+//saveSnippet adds or replaces snippet, or deletes it with action=delete
+func (a *App) saveSnippet(w http.ResponseWriter, r *http.Request) {
+	if !a.Sessions.IsAdmin(r) {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	if r.FormValue("action") == "delete" {
+		if err := model.DeleteSnippet(a.DB, name); err != nil {
+			a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to delete snippet: %v", err))
+			return
+		}
+		a.audit(r, "snippet delete", name)
+		http.Redirect(w, r, "/admin/snippets", http.StatusSeeOther)
+		return
+	}
+
+	s := model.Snippet{Name: name, Title: r.FormValue("title"), Body: r.FormValue("body")}
+	if err := s.SaveSnippet(a.DB); err != nil {
+		if err == model.ErrInvalidSnippet {
+			a.renderError(w, r, http.StatusBadRequest, errors.New("Snippet name and body are required"))
+			return
+		}
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to save snippet: %v", err))
+		return
+	}
+	a.audit(r, "snippet save", fmt.Sprintf("%s, %d chars", s.Name, len(s.Body)))
+	http.Redirect(w, r, "/admin/snippets", http.StatusSeeOther)
+}
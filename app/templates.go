@@ -0,0 +1,127 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/ultramozg/golang-blog-engine/tracing"
+)
+
+//requiredTemplates are executed by the handlers, the blog doesn't start if any of them is missing
+var requiredTemplates = []string{
+	"cards", "seopreview",
+	"about.gohtml", "adminposts.gohtml", "announcements.gohtml", "audit.gohtml", "brokenlinks.gohtml", "bulkconfirm.gohtml", "courses.gohtml", "create.gohtml",
+	"diagnostics.gohtml", "duplicate.gohtml", "error.gohtml", "featured.gohtml", "jobs.gohtml", "likes.gohtml", "links.gohtml",
+	"login.gohtml", "notfound.gohtml", "notfoundlog.gohtml", "notifications.gohtml",
+	"post.gohtml", "posts.gohtml", "print.gohtml", "redirects.gohtml", "search.gohtml",
+	"seoaudit.gohtml", "sessions.gohtml", "settings.gohtml", "snippets.gohtml", "update.gohtml",
+	"workflow.gohtml", "workflowlist.gohtml",
+}
+
+var renderBuffers = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+//parseTemplates parses templates embedded in the binary or given by TEMPLATES glob and verifies
+//that all templates executed by the handlers or included by other templates are defined
+func (a *App) parseTemplates() (*template.Template, error) {
+	t := template.New("").Funcs(a.templateFuncs())
+	source := a.Config.Templates
+	var err error
+	switch {
+	case source != "":
+		t, err = t.ParseGlob(source)
+	case a.Assets != nil:
+		source = "embedded templates"
+		t, err = t.ParseFS(a.Assets, "templates/*.gohtml")
+	default:
+		return nil, errors.New("templates are not embedded, set TEMPLATES to the templates on disk")
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range requiredTemplates {
+		if t.Lookup(name) == nil {
+			return nil, fmt.Errorf("template %q is not defined in %s", name, source)
+		}
+	}
+	for _, tmpl := range t.Templates() {
+		if tmpl.Tree == nil {
+			continue
+		}
+		if name := undefinedTemplate(t, tmpl.Tree.Root); name != "" {
+			return nil, fmt.Errorf("template %q includes undefined template %q", tmpl.Name(), name)
+		}
+	}
+	return t, nil
+}
+
+//undefinedTemplate returns name of the first template included under the node which isn't defined
+func undefinedTemplate(t *template.Template, node parse.Node) string {
+	switch n := node.(type) {
+	case *parse.TemplateNode:
+		if t.Lookup(n.Name) == nil {
+			return n.Name
+		}
+	case *parse.ListNode:
+		if n == nil {
+			return ""
+		}
+		for _, c := range n.Nodes {
+			if name := undefinedTemplate(t, c); name != "" {
+				return name
+			}
+		}
+	case *parse.IfNode:
+		return undefinedBranch(t, &n.BranchNode)
+	case *parse.RangeNode:
+		return undefinedBranch(t, &n.BranchNode)
+	case *parse.WithNode:
+		return undefinedBranch(t, &n.BranchNode)
+	}
+	return ""
+}
+
+func undefinedBranch(t *template.Template, b *parse.BranchNode) string {
+	if name := undefinedTemplate(t, b.List); name != "" {
+		return name
+	}
+	return undefinedTemplate(t, b.ElseList)
+}
+
+//CheckTemplates parses and verifies templates without starting the blog, see -check-templates flag
+func (a *App) CheckTemplates() error {
+	if a.Config == nil {
+		var err error
+		if a.Config, err = loadConfig(a.ConfigSource); err != nil {
+			return err
+		}
+	}
+	_, err := a.parseTemplates()
+	return err
+}
+
+//renderTemplate executes the template into a buffer before writing it out,
+//so a failed render responds with 500 instead of a half written page
+func (a *App) renderTemplate(w http.ResponseWriter, r *http.Request, name string, data interface{}) {
+	_, span := a.tracer.Start(r.Context(), "render "+name, tracing.KindInternal)
+	defer span.End()
+
+	buf := renderBuffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer renderBuffers.Put(buf)
+
+	if err := a.Temp.ExecuteTemplate(buf, name, data); err != nil {
+		span.SetError(err)
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to render %s: %v", name, err))
+		return
+	}
+	buf.WriteTo(w)
+}
@@ -0,0 +1,32 @@
+package app
+
+import (
+	"time"
+)
+
+//loadLocation returns the timezone of the site, empty name is the local time of the server
+func loadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(name)
+}
+
+//now returns the current time in the timezone of the site, dates of posts and comments are stored in it
+func (a *App) now() time.Time {
+	return time.Now().In(a.location)
+}
+
+//parseDate parses date stored in DB, it has no zone and is read in the timezone of the site
+func (a *App) parseDate(date string) (time.Time, error) {
+	return time.ParseInLocation("Mon Jan _2 15:04:05 2006", date, a.location)
+}
+
+//isoDate converts date stored in DB to RFC 3339, unparsable dates are returned as is
+func (a *App) isoDate(date string) string {
+	t, err := a.parseDate(date)
+	if err != nil {
+		return date
+	}
+	return t.Format(time.RFC3339)
+}
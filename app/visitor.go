@@ -0,0 +1,42 @@
+package app
+
+import (
+	"net/http"
+
+	"github.com/ultramozg/golang-blog-engine/session"
+)
+
+//viewer returns who the pages are rendered for. An admin reading the blog as a visitor gets the pages
+//of an anonymous visitor: no admin controls, no drafts and members only posts cut to the excerpt
+func (a *App) viewer(r *http.Request) (admin, loggedIn, visitor bool) {
+	s, ok := a.Sessions.GetSession(r)
+	if !ok {
+		return false, false, false
+	}
+	if s.AsVisitor {
+		return false, false, true
+	}
+	return s.User.Type == session.ADMIN, true, false
+}
+
+//readAsVisitor serves POST /admin/visitor, on=true switches the admin session to the view
+//of an anonymous visitor and on=false back
+func (a *App) readAsVisitor(w http.ResponseWriter, r *http.Request) {
+	if !a.Sessions.IsAdmin(r) {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
+	on := r.FormValue("on") == "true"
+	a.Sessions.SetAsVisitor(r, on)
+	if on {
+		a.audit(r, "read as visitor", "on")
+	} else {
+		a.audit(r, "read as visitor", "off")
+	}
+
+	back := r.Header.Get("Referer")
+	if back == "" {
+		back = "/"
+	}
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
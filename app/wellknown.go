@@ -0,0 +1,120 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ultramozg/golang-blog-engine/model"
+)
+
+// Version of the blog reported by nodeinfo, main sets it to the commit of the build
+var Version = "dev"
+
+const (
+	NodeInfoSchema   = "http://nodeinfo.diaspora.software/ns/schema/2.1"
+	NodeInfoSoftware = "golang-blog-engine"
+)
+
+// nodeInfoLinks is /.well-known/nodeinfo pointing to the documents of the supported schema versions
+type nodeInfoLinks struct {
+	Links []nodeInfoLink `json:"links"`
+}
+
+type nodeInfoLink struct {
+	Rel  string `json:"rel"`
+	Href string `json:"href"`
+}
+
+// nodeInfo is the NodeInfo 2.1 document, the blog has a single user and no federation protocols
+type nodeInfo struct {
+	Version           string                 `json:"version"`
+	Software          nodeInfoSoftware       `json:"software"`
+	Protocols         []string               `json:"protocols"`
+	Services          nodeInfoServices       `json:"services"`
+	OpenRegistrations bool                   `json:"openRegistrations"`
+	Usage             nodeInfoUsage          `json:"usage"`
+	Metadata          map[string]interface{} `json:"metadata"`
+}
+
+type nodeInfoSoftware struct {
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	Repository string `json:"repository"`
+}
+
+type nodeInfoServices struct {
+	Inbound  []string `json:"inbound"`
+	Outbound []string `json:"outbound"`
+}
+
+type nodeInfoUsage struct {
+	Users      map[string]int `json:"users"`
+	LocalPosts int            `json:"localPosts"`
+}
+
+// nodeInfoWellKnown serves /.well-known/nodeinfo
+func (a *App) nodeInfoWellKnown(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, nodeInfoLinks{Links: []nodeInfoLink{{Rel: NodeInfoSchema, Href: a.baseURL(r) + "/nodeinfo/2.1"}}})
+}
+
+// nodeInfo serves /nodeinfo/2.1 describing the blog software and its published posts
+func (a *App) nodeInfo(w http.ResponseWriter, r *http.Request) {
+	posts, err := model.CountPosts(a.DB)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to count posts: %v", err))
+		return
+	}
+	settings := a.settings.Get()
+	info := nodeInfo{
+		Version:   "2.1",
+		Software:  nodeInfoSoftware{Name: NodeInfoSoftware, Version: Version, Repository: "https://github.com/ultramozg/golang-blog-engine"},
+		Protocols: []string{},
+		Services:  nodeInfoServices{Inbound: []string{}, Outbound: []string{"rss2.0"}},
+		Usage:     nodeInfoUsage{Users: map[string]int{"total": 1}, LocalPosts: posts},
+		Metadata:  map[string]interface{}{"nodeName": settings.SiteTitle, "nodeDescription": settings.Description},
+	}
+
+	w.Header().Set("Content-Type", `application/json; profile="`+NodeInfoSchema+`#"`)
+	if err := json.NewEncoder(w).Encode(info); err != nil {
+		log.Println(err)
+	}
+}
+
+// generateSecurityTxt renders security.txt of RFC 9116 from the configuration, the expiry moves
+// with every request so the file never goes stale while the blog runs
+func (a *App) generateSecurityTxt(r *http.Request) string {
+	conf := a.Config.WellKnown
+	var b strings.Builder
+	for _, c := range conf.SecurityContacts {
+		fmt.Fprintf(&b, "Contact: %s\n", c)
+	}
+	fmt.Fprintf(&b, "Expires: %s\n", time.Now().Add(conf.SecurityExpires).UTC().Truncate(time.Hour).Format(time.RFC3339))
+	if conf.SecurityEncryption != "" {
+		fmt.Fprintf(&b, "Encryption: %s\n", conf.SecurityEncryption)
+	}
+	if conf.SecurityPolicy != "" {
+		fmt.Fprintf(&b, "Policy: %s\n", conf.SecurityPolicy)
+	}
+	langs := []string{}
+	seen := map[string]bool{}
+	for _, s := range a.Config.Sites {
+		if s.Lang != "" && !seen[s.Lang] {
+			seen[s.Lang] = true
+			langs = append(langs, s.Lang)
+		}
+	}
+	if len(langs) > 0 {
+		fmt.Fprintf(&b, "Preferred-Languages: %s\n", strings.Join(langs, ", "))
+	}
+	fmt.Fprintf(&b, "Canonical: %s/.well-known/security.txt\n", a.baseURL(r))
+	return b.String()
+}
+
+func (a *App) securityTxt(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprint(w, a.generateSecurityTxt(r))
+}
@@ -0,0 +1,136 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ultramozg/golang-blog-engine/model"
+)
+
+//hidden reports whether the post isn't published yet and the reader can't preview it
+func (a *App) hidden(r *http.Request, p model.Post) bool {
+	admin, _, _ := a.viewer(r)
+	return !p.Published() && !admin
+}
+
+//workflow lists posts in the editorial workflow, with id it shows the post state,
+//reviewer and editorial notes
+func (a *App) workflow(w http.ResponseWriter, r *http.Request) {
+	if !a.Sessions.IsAdmin(r) {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
+
+	if r.FormValue("id") == "" {
+		a.workflowList(w, r)
+		return
+	}
+	p, err := a.loadPost(r.FormValue("id"))
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	notes, err := model.GetEditorialNotes(a.DB, p.ID)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to fetch editorial notes: %v", err))
+		return
+	}
+
+	data := struct {
+		PageData
+		Post  model.Post
+		Notes []model.EditorialNote
+	}{
+		a.pageData(r),
+		p,
+		notes,
+	}
+	a.renderTemplate(w, r, "workflow.gohtml", data)
+}
+
+//workflowAction changes status or reviewer of the post and adds or resolves editorial notes
+func (a *App) workflowAction(w http.ResponseWriter, r *http.Request) {
+	if !a.Sessions.IsAdmin(r) {
+		a.renderError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
+	p, err := a.loadPost(r.FormValue("id"))
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	actor := "admin"
+	if u, ok := a.Sessions.GetUser(r); ok {
+		actor = u.Name
+	}
+
+	switch r.FormValue("action") {
+	case "status":
+		from := p.Status
+		if err := p.SetStatus(a.DB, r.FormValue("status"), strings.TrimSpace(r.FormValue("reviewer"))); err != nil {
+			if err == model.ErrInvalidStatus {
+				a.renderError(w, r, http.StatusBadRequest, errors.New("Invalid status transition"))
+				return
+			}
+			a.renderError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		a.audit(r, "post status", fmt.Sprintf("post %d %s -> %s, reviewer %q", p.ID, from, p.Status, p.Reviewer))
+		a.announce(r, p)
+
+	case "note":
+		n := model.EditorialNote{
+			PostID: p.ID,
+			Author: actor,
+			Quote:  strings.TrimSpace(r.FormValue("quote")),
+			Note:   strings.TrimSpace(r.FormValue("note")),
+			Date:   a.now().Format("Mon Jan _2 15:04:05 2006"),
+		}
+		if n.Note == "" {
+			a.renderError(w, r, http.StatusBadRequest, errors.New("Invalid Input data"))
+			return
+		}
+		if err := n.CreateEditorialNote(a.DB); err != nil {
+			a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to create editorial note: %v", err))
+			return
+		}
+
+	case "resolve":
+		noteID, err := strconv.Atoi(r.FormValue("note"))
+		if err != nil {
+			a.renderError(w, r, http.StatusBadRequest, errors.New("Invalid Input data"))
+			return
+		}
+		if err := model.ResolveEditorialNote(a.DB, p.ID, noteID); err != nil {
+			a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to resolve editorial note: %v", err))
+			return
+		}
+
+	default:
+		a.renderError(w, r, http.StatusBadRequest, errors.New("Invalid Input data"))
+		return
+	}
+	http.Redirect(w, r, "/admin/workflow?id="+strconv.Itoa(p.ID), http.StatusSeeOther)
+}
+
+func (a *App) workflowList(w http.ResponseWriter, r *http.Request) {
+	posts, err := model.GetUnpublishedPosts(a.DB)
+	if err != nil {
+		a.renderError(w, r, http.StatusInternalServerError, fmt.Errorf("unable to fetch unpublished posts: %v", err))
+		return
+	}
+
+	data := struct {
+		PageData
+		Posts []model.Post
+	}{
+		a.pageData(r),
+		posts,
+	}
+	a.renderTemplate(w, r, "workflowlist.gohtml", data)
+}
@@ -0,0 +1,147 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+type entry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+//Cache is a typed in-memory cache whose entries expire after TTL, zero TTL disables caching.
+//Values are shared between readers and must not be modified
+type Cache[K comparable, V any] struct {
+	ttl   time.Duration
+	mu    sync.RWMutex
+	items map[K]entry[V]
+	//gen changes on every invalidation, values loaded before it aren't cached
+	gen uint64
+}
+
+//New returns empty cache with entries living for ttl
+func New[K comparable, V any](ttl time.Duration) *Cache[K, V] {
+	return &Cache[K, V]{ttl: ttl, items: map[K]entry[V]{}}
+}
+
+//Get returns the value of the key if it's cached and not expired
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	c.mu.RLock()
+	e, ok := c.items[key]
+	c.mu.RUnlock()
+	if !ok || time.Now().After(e.expires) {
+		var zero V
+		return zero, false
+	}
+	return e.value, true
+}
+
+//Set caches the value of the key
+func (c *Cache[K, V]) Set(key K, value V) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	c.items[key] = entry[V]{value, time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+}
+
+//Load returns the cached value of the key, on miss it's loaded and cached unless loading fails
+func (c *Cache[K, V]) Load(key K, load func() (V, error)) (V, error) {
+	if v, ok := c.Get(key); ok {
+		return v, nil
+	}
+	c.mu.RLock()
+	gen := c.gen
+	c.mu.RUnlock()
+
+	v, err := load()
+	if err != nil || c.ttl <= 0 {
+		return v, err
+	}
+	c.mu.Lock()
+	if c.gen == gen {
+		c.items[key] = entry[V]{v, time.Now().Add(c.ttl)}
+	}
+	c.mu.Unlock()
+	return v, nil
+}
+
+//Delete removes the key
+func (c *Cache[K, V]) Delete(key K) {
+	c.mu.Lock()
+	delete(c.items, key)
+	c.gen++
+	c.mu.Unlock()
+}
+
+//Clear removes all keys
+func (c *Cache[K, V]) Clear() {
+	c.mu.Lock()
+	c.items = map[K]entry[V]{}
+	c.gen++
+	c.mu.Unlock()
+}
+
+//Prune removes expired entries, caches with many rarely read keys should be pruned periodically
+func (c *Cache[K, V]) Prune() {
+	now := time.Now()
+	c.mu.Lock()
+	for k, e := range c.items {
+		if now.After(e.expires) {
+			delete(c.items, k)
+		}
+	}
+	c.mu.Unlock()
+}
+
+//Bus delivers invalidations from the write paths to the caches holding the changed data
+type Bus[K comparable] struct {
+	mu   sync.RWMutex
+	subs map[string][]func(key K, all bool)
+}
+
+//NewBus returns bus without subscribers
+func NewBus[K comparable]() *Bus[K] {
+	return &Bus[K]{subs: map[string][]func(K, bool){}}
+}
+
+//Subscribe calls fn on every invalidation of the topic, all is set when the whole topic is invalidated
+func (b *Bus[K]) Subscribe(topic string, fn func(key K, all bool)) {
+	b.mu.Lock()
+	b.subs[topic] = append(b.subs[topic], fn)
+	b.mu.Unlock()
+}
+
+//Invalidate tells subscribers of the topic that the data of the keys has changed
+func (b *Bus[K]) Invalidate(topic string, keys ...K) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, fn := range b.subs[topic] {
+		for _, k := range keys {
+			fn(k, false)
+		}
+	}
+}
+
+//InvalidateAll tells subscribers of the topic that any of its data may have changed
+func (b *Bus[K]) InvalidateAll(topic string) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var zero K
+	for _, fn := range b.subs[topic] {
+		fn(zero, true)
+	}
+}
+
+//Attach subscribes the cache to the topic of the bus
+func Attach[K comparable, V any](b *Bus[K], topic string, c *Cache[K, V]) {
+	b.Subscribe(topic, func(key K, all bool) {
+		if all {
+			c.Clear()
+			return
+		}
+		c.Delete(key)
+	})
+}
@@ -0,0 +1,163 @@
+//Package fixtures helps tests of the blog: it stores posts and comments with sensible defaults
+//and compares rendered pages with golden files.
+//
+//Golden files are kept in testdata/golden/<name>.golden of the package under test, run
+//
+//	go test ./app -run Golden -update
+//
+//to write them after an intended change of the templates and review the difference with git diff.
+package fixtures
+
+import (
+	"bytes"
+	"database/sql"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/ultramozg/golang-blog-engine/model"
+)
+
+//FixedDate is the date of the posts and comments created without one, so pages showing them don't change
+const FixedDate = "Mon Jan  1 12:00:00 2024"
+
+//GoldenDir is where golden files are read from, relative to the directory of the tested package
+var GoldenDir = filepath.Join("testdata", "golden")
+
+var update = flag.Bool("update", false, "Write golden files with the actual output instead of comparing")
+
+//Post stores the post, empty title, body and date get defaults
+func Post(t testing.TB, db *sql.DB, p model.Post) model.Post {
+	t.Helper()
+	if p.Title == "" {
+		p.Title = "Fixture post"
+	}
+	if p.Body == "" {
+		p.Body = "<p>Fixture post body</p>"
+	}
+	if p.Date == "" {
+		p.Date = FixedDate
+	}
+	if err := p.CreatePost(db); err != nil {
+		t.Fatalf("fixtures: unable to create post %q: %v", p.Title, err)
+	}
+	return p
+}
+
+//Comment stores a comment of the user on the post
+func Comment(t testing.TB, db *sql.DB, postID int, name, text string) model.Comment {
+	t.Helper()
+	c := model.Comment{PostID: postID, Name: name, Date: FixedDate, Data: text}
+	if err := c.CreateComment(db); err != nil {
+		t.Fatalf("fixtures: unable to create comment of %s: %v", name, err)
+	}
+	return c
+}
+
+//Normalizer removes parts of the output which change between runs, e.g. times and ids
+type Normalizer func(string) string
+
+//Replace returns normalizer replacing all matches of the regular expression, see regexp.ReplaceAllString
+func Replace(pattern, replacement string) Normalizer {
+	re := regexp.MustCompile(pattern)
+	return func(s string) string {
+		return re.ReplaceAllString(s, replacement)
+	}
+}
+
+//Golden compares the output with the golden file of the name after normalizing it, differences
+//are reported line by line. With -update the golden file is written instead
+func Golden(t testing.TB, name string, got []byte, normalize ...Normalizer) {
+	t.Helper()
+	s := string(got)
+	for _, n := range normalize {
+		s = n(s)
+	}
+
+	path := filepath.Join(GoldenDir, name+".golden")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(s), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("fixtures: unable to read golden file, run the test with -update to create it: %v", err)
+	}
+	if d := Diff(string(want), s); d != "" {
+		t.Errorf("%s differs from the golden file (-want +got), run the test with -update if it's intended:\n%s", name, d)
+	}
+}
+
+//Diff returns the lines removed from want (-) and added in got (+) with a line of context around them,
+//it's empty if both are equal
+func Diff(want, got string) string {
+	if want == got {
+		return ""
+	}
+	a, b := strings.Split(want, "\n"), strings.Split(got, "\n")
+
+	//lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	type line struct {
+		op   byte
+		text string
+		num  int
+	}
+	var lines []line
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, line{' ', a[i], i + 1})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, line{'-', a[i], i + 1})
+			i++
+		default:
+			lines = append(lines, line{'+', b[j], j + 1})
+			j++
+		}
+	}
+
+	var out bytes.Buffer
+	last := -1
+	for k, l := range lines {
+		near := l.op != ' ' || (k > 0 && lines[k-1].op != ' ') || (k+1 < len(lines) && lines[k+1].op != ' ')
+		if !near {
+			continue
+		}
+		if last >= 0 && k > last+1 {
+			out.WriteString("...\n")
+		}
+		fmt.Fprintf(&out, "%c %4d | %s\n", l.op, l.num, l.text)
+		last = k
+	}
+	return out.String()
+}
@@ -0,0 +1,21 @@
+package fixtures
+
+import "testing"
+
+func TestDiff(t *testing.T) {
+	if d := Diff("a\nb\nc", "a\nb\nc"); d != "" {
+		t.Errorf("equal texts differ: %q", d)
+	}
+
+	want := "     1 | a\n-    2 | b\n+    2 | B\n     3 | c\n...\n     6 | f\n+    7 | g\n"
+	if d := Diff("a\nb\nc\nd\ne\nf", "a\nB\nc\nd\ne\nf\ng"); d != want {
+		t.Errorf("wrong diff:\n%s\nwant:\n%s", d, want)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	n := Replace(`id=\d+`, "id=N")
+	if got := n("/post?id=42#comment-7"); got != "/post?id=N#comment-7" {
+		t.Errorf("wrong normalized text: %q", got)
+	}
+}
@@ -0,0 +1,138 @@
+package integrations
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+//Purger removes cached copies of the blog pages from a CDN
+type Purger interface {
+	//Name identifies the CDN in the logs, e.g. "cloudflare"
+	Name() string
+	//Purge removes the pages given by absolute urls
+	Purge(urls []string) error
+	//PurgeAll removes all pages of the blog
+	PurgeAll() error
+}
+
+//CloudflarePurgeLimit is how many urls Cloudflare accepts in one purge request
+const CloudflarePurgeLimit = 30
+
+//Cloudflare purges the zone with an API token having Cache Purge permission
+type Cloudflare struct {
+	//API is address of the API, https://api.cloudflare.com/client/v4 by default
+	API   string
+	Zone  string
+	Token string
+}
+
+func (c Cloudflare) Name() string {
+	return "cloudflare"
+}
+
+func (c Cloudflare) Purge(urls []string) error {
+	for len(urls) > 0 {
+		n := len(urls)
+		if n > CloudflarePurgeLimit {
+			n = CloudflarePurgeLimit
+		}
+		if err := c.purge(map[string]interface{}{"files": urls[:n]}); err != nil {
+			return err
+		}
+		urls = urls[n:]
+	}
+	return nil
+}
+
+func (c Cloudflare) PurgeAll() error {
+	return c.purge(map[string]interface{}{"purge_everything": true})
+}
+
+func (c Cloudflare) purge(body map[string]interface{}) error {
+	req, err := jsonRequest(orDefault(c.API, "https://api.cloudflare.com/client/v4")+"/zones/"+url.PathEscape(c.Zone)+"/purge_cache", body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	return do(req, nil)
+}
+
+//Fastly purges the service with an API token having purge_select scope, purge_all for PurgeAll
+type Fastly struct {
+	//API is address of the API, https://api.fastly.com by default
+	API     string
+	Service string
+	Token   string
+}
+
+func (f Fastly) Name() string {
+	return "fastly"
+}
+
+//Purge purges the urls one by one, Fastly has no batch purge by url
+func (f Fastly) Purge(urls []string) error {
+	for _, u := range urls {
+		//the cached url is given without the scheme, its query must not be taken for query of the API call
+		cached := strings.Replace(strings.TrimPrefix(strings.TrimPrefix(u, "https://"), "http://"), "?", "%3F", 1)
+		if err := f.post("/purge/" + cached); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f Fastly) PurgeAll() error {
+	return f.post("/service/" + url.PathEscape(f.Service) + "/purge_all")
+}
+
+func (f Fastly) post(path string) error {
+	req, err := http.NewRequest(http.MethodPost, orDefault(f.API, "https://api.fastly.com")+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Fastly-Key", f.Token)
+	return do(req, nil)
+}
+
+//Bunny purges the pull zone of BunnyCDN with the account API key
+type Bunny struct {
+	//API is address of the API, https://api.bunny.net by default
+	API      string
+	PullZone string
+	Key      string
+}
+
+func (b Bunny) Name() string {
+	return "bunny"
+}
+
+//Purge purges the urls one by one, BunnyCDN has no batch purge by url
+func (b Bunny) Purge(urls []string) error {
+	for _, u := range urls {
+		if err := b.post("/purge?" + url.Values{"url": {u}}.Encode()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b Bunny) PurgeAll() error {
+	return b.post("/pullzone/" + url.PathEscape(b.PullZone) + "/purgeCache")
+}
+
+func (b Bunny) post(path string) error {
+	req, err := http.NewRequest(http.MethodPost, orDefault(b.API, "https://api.bunny.net")+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("AccessKey", b.Key)
+	return do(req, nil)
+}
+
+func orDefault(api, fallback string) string {
+	if api == "" {
+		return fallback
+	}
+	return strings.TrimSuffix(api, "/")
+}
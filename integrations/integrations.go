@@ -0,0 +1,150 @@
+package integrations
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+//Channel is a social network posts are announced on
+type Channel interface {
+	//Name identifies the channel in the delivery log, e.g. "mastodon"
+	Name() string
+	//Send publishes the text on behalf of the blog
+	Send(text string) error
+}
+
+//client is shared by all channels, slow networks must not hold the announcement job forever
+var client = &http.Client{Timeout: 15 * time.Second}
+
+//Mastodon posts statuses with an access token of the application having write:statuses scope
+type Mastodon struct {
+	//URL is address of the instance, e.g. https://mastodon.social
+	URL   string
+	Token string
+}
+
+func (m Mastodon) Name() string {
+	return "mastodon"
+}
+
+func (m Mastodon) Send(text string) error {
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(m.URL, "/")+"/api/v1/statuses", strings.NewReader(url.Values{"status": {text}}.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+m.Token)
+	return do(req, nil)
+}
+
+//Bluesky creates posts with an app password, a session is created for every post
+type Bluesky struct {
+	//PDS is address of the personal data server, https://bsky.social for most accounts
+	PDS      string
+	Handle   string
+	Password string
+}
+
+func (b Bluesky) Name() string {
+	return "bluesky"
+}
+
+func (b Bluesky) Send(text string) error {
+	base := strings.TrimSuffix(b.PDS, "/") + "/xrpc/"
+
+	var session struct {
+		AccessJwt string `json:"accessJwt"`
+		DID       string `json:"did"`
+	}
+	req, err := jsonRequest(base+"com.atproto.server.createSession", map[string]string{"identifier": b.Handle, "password": b.Password})
+	if err != nil {
+		return err
+	}
+	if err := do(req, &session); err != nil {
+		return fmt.Errorf("unable to log in: %v", err)
+	}
+
+	req, err = jsonRequest(base+"com.atproto.repo.createRecord", map[string]interface{}{
+		"repo":       session.DID,
+		"collection": "app.bsky.feed.post",
+		"record": map[string]string{
+			"$type":     "app.bsky.feed.post",
+			"text":      text,
+			"createdAt": time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+session.AccessJwt)
+	return do(req, nil)
+}
+
+//Telegram sends messages to a chat or a channel with a bot the chat has added
+type Telegram struct {
+	//API is address of the Bot API, https://api.telegram.org by default
+	API   string
+	Token string
+	//Chat is id of the chat or @username of the channel
+	Chat string
+}
+
+func (t Telegram) Name() string {
+	return "telegram"
+}
+
+func (t Telegram) Send(text string) error {
+	api := t.API
+	if api == "" {
+		api = "https://api.telegram.org"
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(api, "/")+"/bot"+t.Token+"/sendMessage", strings.NewReader(url.Values{"chat_id": {t.Chat}, "text": {text}}.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return do(req, nil)
+}
+
+func jsonRequest(target string, body interface{}) (*http.Request, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+//do sends the request and decodes json response into v if it's not nil, non 2xx responses are errors
+//carrying the beginning of the response body, it usually tells what's wrong
+func do(req *http.Request, v interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		//the url is dropped from the error, the telegram one contains the bot token
+		if uerr, ok := err.(*url.Error); ok {
+			return uerr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
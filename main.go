@@ -1,28 +1,103 @@
 package main
 
 import (
+	"embed"
+	"errors"
 	"flag"
 	"log"
+	"strings"
+	_ "time/tzdata"
 
 	"github.com/ultramozg/golang-blog-engine/app"
 )
 
 var gitCommit string
 
+//assets are templates and static files of the blog, TEMPLATES and PUBLIC_DIR
+//environment variables override them with the files on disk
+//
+//go:embed templates public
+var assets embed.FS
+
+//setFlag collects KEY=VALUE configuration overrides given on the command line
+type setFlag map[string]string
+
+func (s setFlag) String() string {
+	return ""
+}
+
+func (s setFlag) Set(value string) error {
+	i := strings.Index(value, "=")
+	if i <= 0 {
+		return errors.New("expected KEY=VALUE")
+	}
+	s[value[:i]] = value[i+1:]
+	return nil
+}
+
 func printVersion() {
 	log.Printf("Current build version: %s", gitCommit)
 }
 
 func main() {
 	versionFlag := flag.Bool("v", false, "Print the current version and exit")
+	checkTemplatesFlag := flag.Bool("check-templates", false, "Verify that templates compile and exit")
+	regenerateSEOFlag := flag.Bool("regenerate-seo", false, "Fill missing SEO fields of existing posts and exit")
+	dryRunFlag := flag.Bool("dry-run", false, "With -regenerate-seo print the changes without storing them")
+	seedDemoFlag := flag.Bool("seed-demo", false, "Fill the database with generated demo content and exit")
+	seedFlag := flag.Int64("seed", 1, "With -seed-demo the seed of the generator, the same seed gives the same content")
+	demoPostsFlag := flag.Int("demo-posts", app.DefaultDemoPosts, "With -seed-demo the number of posts to generate")
+	configFlag := flag.String("config", "", "YAML or TOML config file, overrides CONFIG_FILE")
+	profileFlag := flag.String("profile", "", "Profile of the config file to apply, e.g. dev, staging or prod")
+	set := setFlag{}
+	flag.Var(set, "set", "Override a configuration value, e.g. -set DOMAIN=example.com, can be repeated")
 	flag.Parse()
 
+	if gitCommit != "" {
+		app.Version = gitCommit
+	}
 	if *versionFlag {
 		printVersion()
 		return
 	}
 
 	a := app.NewApp()
+	a.Assets = assets
+	a.ConfigSource = app.ConfigSource{File: *configFlag, Profile: *profileFlag, Set: set}
+	if *checkTemplatesFlag {
+		if err := a.CheckTemplates(); err != nil {
+			log.Fatal("Templates are broken: ", err)
+		}
+		log.Println("Templates are fine")
+		return
+	}
+
 	a.Initialize()
+	if *regenerateSEOFlag {
+		changes, err := a.RegenerateSEO(*dryRunFlag)
+		if err != nil {
+			log.Fatal("Unable to regenerate SEO fields: ", err)
+		}
+		for _, c := range changes {
+			log.Printf("post %d %q: %s = %s", c.PostID, c.Title, c.Field, c.Value)
+		}
+		if *dryRunFlag {
+			log.Printf("Dry run, %d fields would be filled", len(changes))
+		} else {
+			log.Printf("%d fields filled", len(changes))
+		}
+		return
+	}
+	if *seedDemoFlag {
+		sum, err := a.SeedDemo(app.DemoOptions{Seed: *seedFlag, Posts: *demoPostsFlag, DataDir: "data"})
+		if err != nil {
+			log.Fatal("Unable to seed demo content: ", err)
+		}
+		log.Printf("Seeded %d posts, %d comments and %d likes", sum.Posts, sum.Comments, sum.Likes)
+		for _, f := range sum.Files {
+			log.Println("Written", f)
+		}
+		return
+	}
 	a.Run()
 }
@@ -0,0 +1,203 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//Compressor is a writer of a content coding which can be reused for another response after Reset
+type Compressor interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+type encoding struct {
+	name string
+	pool *sync.Pool
+}
+
+var (
+	encodingsMu sync.RWMutex
+	//encodings are content codings in the order of preference of the server
+	encodings = []encoding{newEncoding("gzip", func() Compressor { return gzip.NewWriter(ioutil.Discard) })}
+)
+
+func newEncoding(name string, new func() Compressor) encoding {
+	return encoding{name, &sync.Pool{New: func() interface{} { return new() }}}
+}
+
+//RegisterEncoding adds content coding, e.g. "br" backed by a Brotli library. Codings registered later
+//are preferred when the client accepts several of them with the same quality
+func RegisterEncoding(name string, new func() Compressor) {
+	encodingsMu.Lock()
+	defer encodingsMu.Unlock()
+	encodings = append([]encoding{newEncoding(name, new)}, encodings...)
+}
+
+//negotiate picks the content coding for Accept-Encoding header, ok is false if the response
+//must be sent as is. Codings with zero quality are refused, * stands for the codings not listed
+func negotiate(header string) (encoding, bool) {
+	accepted := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		q := 1.0
+		for _, p := range strings.Split(params, ";") {
+			if k, v, ok := strings.Cut(strings.TrimSpace(p), "="); ok && strings.EqualFold(k, "q") {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+		}
+		accepted[name] = q
+	}
+
+	encodingsMu.RLock()
+	defer encodingsMu.RUnlock()
+	var best encoding
+	bestQ := 0.0
+	for _, e := range encodings {
+		q, ok := accepted[e.name]
+		if !ok {
+			q = accepted["*"]
+		}
+		if q > bestQ {
+			best, bestQ = e, q
+		}
+	}
+	return best, bestQ > 0
+}
+
+//incompressible are media types which are already compressed, compressing them again only wastes cpu
+var incompressible = []string{
+	"image/", "video/", "audio/", "font/woff", "application/zip", "application/gzip", "application/x-gzip",
+	"application/x-bzip2", "application/x-xz", "application/x-7z-compressed", "application/x-rar-compressed",
+	"application/zstd", "application/pdf", "application/octet-stream",
+}
+
+//compressible reports whether responses of the content type are worth compressing, SVG is text unlike other images
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return contentType == ""
+	}
+	if mediaType == "image/svg+xml" {
+		return true
+	}
+	for _, prefix := range incompressible {
+		if strings.HasPrefix(mediaType, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+//compressResponseWriter decides whether to compress the response when its headers are written,
+//by then the handler has set content type and could have disabled the compression
+type compressResponseWriter struct {
+	http.ResponseWriter
+	encoding encoding
+	c        Compressor
+	decided  bool
+	disabled bool
+}
+
+func (w *compressResponseWriter) WriteHeader(status int) {
+	if !w.decided {
+		w.decide(status)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *compressResponseWriter) decide(status int) {
+	w.decided = true
+	h := w.Header()
+	h.Add("Vary", "Accept-Encoding")
+	if w.disabled || status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified ||
+		h.Get("Content-Encoding") != "" || strings.Contains(h.Get("Cache-Control"), "no-transform") || !compressible(h.Get("Content-Type")) {
+		return
+	}
+	h.Set("Content-Encoding", w.encoding.name)
+	h.Del("Content-Length")
+	w.c = w.encoding.pool.Get().(Compressor)
+	w.c.Reset(w.ResponseWriter)
+}
+
+func (w *compressResponseWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.c == nil {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.c.Write(b)
+}
+
+//Flush sends compressed data written so far to the client, e.g. for streamed responses
+func (w *compressResponseWriter) Flush() {
+	if f, ok := w.c.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *compressResponseWriter) close() {
+	if w.c == nil {
+		return
+	}
+	w.c.Close()
+	w.encoding.pool.Put(w.c)
+	w.c = nil
+}
+
+//DisableCompression sends the response uncompressed, it has no effect once the response is written.
+//Responses which reflect secrets next to data an attacker can influence must not be compressed,
+//otherwise the secret can be guessed from the response size (BREACH)
+func DisableCompression(w http.ResponseWriter) {
+	for {
+		switch v := w.(type) {
+		case *compressResponseWriter:
+			v.disabled = true
+			return
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = v.Unwrap()
+		default:
+			return
+		}
+	}
+}
+
+//GzipMiddleware compresses responses with the best content coding the client accepts,
+//already compressed content types are sent as is
+func GzipMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		e, ok := negotiate(r.Header.Get("Accept-Encoding"))
+		if !ok {
+			w.Header().Add("Vary", "Accept-Encoding")
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressResponseWriter{ResponseWriter: w, encoding: e}
+		defer cw.close()
+		h.ServeHTTP(cw, r)
+	})
+}
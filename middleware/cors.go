@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+//CORSOptions configures cross origin access, "*" in Origins allows any origin
+type CORSOptions struct {
+	Origins     []string
+	Methods     []string
+	Headers     []string
+	Credentials bool
+	MaxAge      int
+	//Prefixes are path prefixes the policy is applied to
+	Prefixes []string
+}
+
+func (o CORSOptions) allowed(origin string) (string, bool) {
+	for _, v := range o.Origins {
+		if v == origin {
+			return origin, true
+		}
+		if v == "*" {
+			//wildcard can't be combined with credentials, browsers would reject it anyway
+			if o.Credentials {
+				return "", false
+			}
+			return "*", true
+		}
+	}
+	return "", false
+}
+
+func (o CORSOptions) applies(path string) bool {
+	for _, p := range o.Prefixes {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+//CORSMiddleware adds CORS headers to responses of the matching paths and answers preflight requests,
+//requests from origins which aren't allowed are passed through without the headers
+func CORSMiddleware(o CORSOptions) func(http.Handler) http.Handler {
+	methods := strings.Join(o.Methods, ", ")
+	headers := strings.Join(o.Headers, ", ")
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" || !o.applies(r.URL.Path) {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Origin")
+			allow, ok := o.allowed(origin)
+			if !ok {
+				h.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("Access-Control-Allow-Origin", allow)
+			if o.Credentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				w.Header().Set("Access-Control-Allow-Methods", methods)
+				if headers != "" {
+					w.Header().Set("Access-Control-Allow-Headers", headers)
+				}
+				if o.MaxAge > 0 {
+					w.Header().Set("Access-Control-Max-Age", strconv.Itoa(o.MaxAge))
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
@@ -1,33 +1,22 @@
 package middleware
 
 import (
-	"compress/gzip"
 	"fmt"
-	"io"
-	"io/ioutil"
 	"log"
+	"mime"
 	"net/http"
-	"strings"
-	"sync"
+	"path"
 	"time"
 )
 
-type gzipResponseWriter struct {
-	io.Writer
-	http.ResponseWriter
-}
-
-var gzPool = sync.Pool{
-	New: func() interface{} {
-		w := gzip.NewWriter(ioutil.Discard)
-		return w
-	},
-}
-
+//SetHeaderMiddleware sets content type by the extension of the path, pages without one are html.
+//Handlers may override it, the compression middleware relies on it to skip images and archives
 func SetHeaderMiddleware(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if strings.HasSuffix(r.URL.RequestURI(), ".css") {
+		if ext := path.Ext(r.URL.Path); ext == ".css" {
 			w.Header().Set("Content-Type", "text/css; charset=utf-8")
+		} else if t := mime.TypeByExtension(ext); ext != "" && t != "" {
+			w.Header().Set("Content-Type", t)
 		} else {
 			w.Header().Set("Content-Type", "text/html; charset=utf-8")
 		}
@@ -35,40 +24,6 @@ func SetHeaderMiddleware(h http.Handler) http.Handler {
 	})
 }
 
-func CacheControlMiddleware(h http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Cache-Control", "max-age=2592000")
-		h.ServeHTTP(w, r)
-	})
-}
-
-func (w *gzipResponseWriter) WriteHeader(status int) {
-	w.Header().Del("Content-Lenght")
-	w.ResponseWriter.WriteHeader(status)
-}
-
-func (w gzipResponseWriter) Write(b []byte) (int, error) {
-	return w.Writer.Write(b)
-}
-
-func GzipMiddleware(h http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
-			h.ServeHTTP(w, r)
-			return
-		}
-
-		w.Header().Set("Content-Encoding", "gzip")
-
-		gz := gzPool.Get().(*gzip.Writer)
-		defer gzPool.Put(gz)
-
-		gz.Reset(w)
-		defer gz.Close()
-		h.ServeHTTP(&gzipResponseWriter{ResponseWriter: w, Writer: gz}, r)
-	})
-}
-
 //TODO domain hardcoded need to get it from config.
 func RedirectTLSMiddleware(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -91,12 +46,23 @@ func (l *loggingResponseWriter) WriteHeader(code int) {
 	l.ResponseWriter.WriteHeader(code)
 }
 
+//Flush sends buffered data to the client, e.g. for streamed responses
+func (l *loggingResponseWriter) Flush() {
+	if f, ok := l.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (l *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return l.ResponseWriter
+}
+
 func LogMiddleware(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		l := newLoggingResponseWriter(w)
 		h.ServeHTTP(l, r)
 
-		_, err := fmt.Printf("%s %v %s %s %s\n", time.Now().Format("Mon Jan _2 15:04:05 2006"), l.statusCode, r.RemoteAddr, r.Method, r.URL.RequestURI())
+		_, err := fmt.Printf("%s %v %s %s %s %s\n", time.Now().Format("Mon Jan _2 15:04:05 2006"), l.statusCode, r.RemoteAddr, r.Method, r.URL.RequestURI(), RequestID(r))
 		if err != nil {
 			log.Println("Cannot write to file", err)
 		}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+)
+
+//NormalizeOptions configures URL normalization, paths under Skip prefixes are left as is
+//since static files are case sensitive and subtree routes depend on the trailing slash
+type NormalizeOptions struct {
+	Skip []string
+}
+
+//NormalizePath returns canonical form of the path: single slashes, lowercase,
+//no index.html and no trailing slash except the root
+func (o NormalizeOptions) NormalizePath(path string) string {
+	for _, p := range o.Skip {
+		if strings.HasPrefix(path, p) {
+			return path
+		}
+	}
+
+	for strings.Contains(path, "//") {
+		path = strings.ReplaceAll(path, "//", "/")
+	}
+	path = strings.ToLower(path)
+	path = strings.TrimSuffix(path, "index.html")
+	if len(path) > 1 {
+		path = strings.TrimRight(path, "/")
+	}
+	if path == "" {
+		path = "/"
+	}
+	return path
+}
+
+//NormalizeMiddleware permanently redirects GET and HEAD requests to the canonical form
+//of the url so search engines don't see the same page under several addresses
+func NormalizeMiddleware(o NormalizeOptions) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			path := o.NormalizePath(r.URL.Path)
+			if path == r.URL.Path {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			u := *r.URL
+			u.Path = path
+			u.RawPath = ""
+			http.Redirect(w, r, u.RequestURI(), http.StatusMovedPermanently)
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+)
+
+//Statuses of the announcements
+const (
+	AnnouncementQueued    = "queued"
+	AnnouncementSent      = "sent"
+	AnnouncementFailed    = "failed"
+	AnnouncementCancelled = "cancelled"
+)
+
+//Announcement is a post of the blog shared on a social network, SendAt and SentAt are unix times
+type Announcement struct {
+	ID       int
+	PostID   int
+	Channel  string
+	Text     string
+	Status   string
+	Error    string
+	Attempts int
+	SendAt   int64
+	SentAt   int64
+}
+
+//Date returns time the announcement was or is going to be sent, for the delivery log
+func (an Announcement) Date() string {
+	t := an.SendAt
+	if an.SentAt != 0 {
+		t = an.SentAt
+	}
+	return time.Unix(t, 0).Format("Mon Jan _2 15:04:05 2006")
+}
+
+//QueueAnnouncement queues the announcement unless the post has already been announced on the channel,
+//it reports whether the announcement was queued
+func (an *Announcement) QueueAnnouncement(db *sql.DB) (bool, error) {
+	res, err := db.Exec(`insert or ignore into announcements (postid, channel, text, status, send_at) values ($1, $2, $3, $4, $5)`,
+		an.PostID, an.Channel, an.Text, AnnouncementQueued, an.SendAt)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+//GetDueAnnouncements returns queued announcements which should be sent by the unix time, oldest first
+func GetDueAnnouncements(db *sql.DB, now int64) ([]Announcement, error) {
+	return queryAnnouncements(db, `select id, postid, channel, text, status, error, attempts, send_at, sent_at from announcements
+	where status = ? and send_at <= ? order by send_at, id`, AnnouncementQueued, now)
+}
+
+//GetAnnouncements returns the delivery log, newest first
+func GetAnnouncements(db *sql.DB, limit int) ([]Announcement, error) {
+	return queryAnnouncements(db, `select id, postid, channel, text, status, error, attempts, send_at, sent_at from announcements
+	order by id desc limit ?`, limit)
+}
+
+func queryAnnouncements(db *sql.DB, query string, args ...interface{}) ([]Announcement, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []Announcement{}
+	for rows.Next() {
+		var an Announcement
+		if err := rows.Scan(&an.ID, &an.PostID, &an.Channel, &an.Text, &an.Status, &an.Error, &an.Attempts, &an.SendAt, &an.SentAt); err != nil {
+			return nil, err
+		}
+		list = append(list, an)
+	}
+	return list, rows.Err()
+}
+
+//MarkSent records successful delivery
+func (an *Announcement) MarkSent(db *sql.DB) error {
+	an.Status, an.Error, an.SentAt = AnnouncementSent, "", time.Now().Unix()
+	an.Attempts++
+	_, err := db.Exec(`update announcements set status = $1, error = '', attempts = $2, sent_at = $3 where id = $4`, an.Status, an.Attempts, an.SentAt, an.ID)
+	return err
+}
+
+//MarkFailed records failed delivery, the announcement stays queued for another attempt unless it's final
+func (an *Announcement) MarkFailed(db *sql.DB, reason string, final bool) error {
+	an.Error = reason
+	an.Attempts++
+	if final {
+		an.Status = AnnouncementFailed
+	}
+	_, err := db.Exec(`update announcements set status = $1, error = $2, attempts = $3 where id = $4`, an.Status, an.Error, an.Attempts, an.ID)
+	return err
+}
+
+//CancelAnnouncement cancels the queued announcement and reports whether it was still queued
+func CancelAnnouncement(db *sql.DB, id int) (bool, error) {
+	res, err := db.Exec(`update announcements set status = $1 where id = $2 and status = $3`, AnnouncementCancelled, id, AnnouncementQueued)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
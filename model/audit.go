@@ -0,0 +1,45 @@
+package model
+
+import (
+	"database/sql"
+)
+
+//AuditEntry is struct which holds one record of the admin audit log
+type AuditEntry struct {
+	ID      int
+	Actor   string
+	IP      string
+	Action  string
+	Summary string
+	Date    string
+}
+
+func (e *AuditEntry) CreateAuditEntry(db *sql.DB) error {
+	_, err := db.Exec(`insert into audit_log (actor, ip, action, summary, date) values ($1, $2, $3, $4, $5)`, e.Actor, e.IP, e.Action, e.Summary, e.Date)
+	return err
+}
+
+func GetAuditEntries(db *sql.DB, count, start int) ([]AuditEntry, error) {
+	rows, err := db.Query(`select id, actor, ip, action, summary, date from audit_log order by id desc limit ? offset ?;`, count, start)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+
+	for rows.Next() {
+		var e AuditEntry
+		if err := rows.Scan(&e.ID, &e.Actor, &e.IP, &e.Action, &e.Summary, &e.Date); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func CountAuditEntries(db *sql.DB) (int, error) {
+	var c int
+	err := db.QueryRow(`select count(*) from audit_log`).Scan(&c)
+	return c, err
+}
@@ -0,0 +1,63 @@
+package model
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+//GetPostsByIDs returns posts with the given ids in any status, newest first, unknown ids are skipped
+func GetPostsByIDs(db *sql.DB, ids []int) ([]Post, error) {
+	if len(ids) == 0 {
+		return []Post{}, nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	rows, err := db.Query(`select `+postListColumns+` from posts where id in (?`+strings.Repeat(", ?", len(ids)-1)+`) order by id desc;`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPosts(rows)
+}
+
+//SetPinned pins the post to the top of the lists or unpins it
+func (p *Post) SetPinned(db *sql.DB, pinned bool) error {
+	if _, err := db.Exec(`update posts set pinned = $1 where id = $2`, pinned, p.ID); err != nil {
+		return err
+	}
+	p.Pinned = pinned
+	changed(db, TopicPosts, p.ID)
+	return nil
+}
+
+//SetFeatured adds the post to the featured posts or removes it from there
+func (p *Post) SetFeatured(db *sql.DB, featured bool) error {
+	if _, err := db.Exec(`update posts set featured = $1 where id = $2`, featured, p.ID); err != nil {
+		return err
+	}
+	p.Featured = featured
+	changed(db, TopicPosts, p.ID)
+	return nil
+}
+
+//ClonePost copies the post into a new draft with the given title and returns id of the copy.
+//Likes, comments, pins and the canonical url stay with the original
+func (p *Post) ClonePost(db *sql.DB, title, date string) (int, error) {
+	res, err := db.Exec(`insert into posts (title, body, datepost, noindex, nofollow, cover_image, visibility, status, pinned, featured, canonical_url,
+	content_type, schema_fields, updated_at)
+	select $1, body, $2, noindex, nofollow, cover_image, visibility, $3, 0, 0, '', content_type, schema_fields, $4 from posts where id = $5`,
+		title, date, StatusDraft, time.Now().Unix(), p.ID)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	changed(db, TopicPosts, int(id))
+	return int(id), nil
+}
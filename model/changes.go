@@ -0,0 +1,40 @@
+package model
+
+import (
+	"database/sql"
+	"sync"
+)
+
+//Topics of the change notifications, ids are post ids
+const (
+	TopicPosts    = "posts"
+	TopicComments = "comments"
+)
+
+//changeListeners holds functions notified about writes per database handle, so caches of the
+//application are invalidated on every write path
+type changeListeners struct {
+	mu  sync.RWMutex
+	fns map[*sql.DB][]func(topic string, ids ...int)
+}
+
+var listeners = changeListeners{fns: make(map[*sql.DB][]func(topic string, ids ...int))}
+
+//OnChange registers fn to be called after posts or comments stored in db have changed,
+//no ids means that any of them could have changed
+func OnChange(db *sql.DB, fn func(topic string, ids ...int)) {
+	listeners.mu.Lock()
+	defer listeners.mu.Unlock()
+	listeners.fns[db] = append(listeners.fns[db], fn)
+}
+
+//changed notifies listeners of db about the change
+func changed(db *sql.DB, topic string, ids ...int) {
+	listeners.mu.RLock()
+	fns := listeners.fns[db]
+	listeners.mu.RUnlock()
+
+	for _, fn := range fns {
+		fn(topic, ids...)
+	}
+}
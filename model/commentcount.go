@@ -0,0 +1,26 @@
+package model
+
+import (
+	"database/sql"
+)
+
+//recountComments sets comments_count of the posts which differ from the number of their comments
+const recountComments = `update posts set comments_count = (select count(*) from comments c where c.postid = posts.id)
+	where comments_count != (select count(*) from comments c where c.postid = posts.id)`
+
+//RecountComments corrects comment counts which drifted from the comments, e.g. after comments were
+//edited directly in the database, and returns number of corrected posts
+func RecountComments(db *sql.DB) (int64, error) {
+	res, err := db.Exec(recountComments)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if n > 0 {
+		changed(db, TopicPosts)
+	}
+	return n, nil
+}
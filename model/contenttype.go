@@ -0,0 +1,57 @@
+package model
+
+import (
+	"encoding/json"
+)
+
+//Content types of the post, they select schema.org type of the structured data
+const (
+	ContentArticle  = "article"
+	ContentHowTo    = "howto"
+	ContentFAQ      = "faq"
+	ContentRecipe   = "recipe"
+	ContentSoftware = "software"
+)
+
+//ContentFields lists extra fields of each content type, names are schema.org properties
+var ContentFields = map[string][]string{
+	ContentArticle:  {},
+	ContentHowTo:    {"totalTime", "supply", "tool"},
+	ContentFAQ:      {},
+	ContentRecipe:   {"recipeYield", "prepTime", "cookTime", "recipeCategory", "recipeIngredient"},
+	ContentSoftware: {"codeRepository", "programmingLanguage", "runtimePlatform"},
+}
+
+//IsContentType reports whether the content type is known
+func IsContentType(t string) bool {
+	_, ok := ContentFields[t]
+	return ok
+}
+
+//contentType returns content type of the post, unknown types are treated as articles
+func (p Post) contentType() string {
+	if IsContentType(p.ContentType) {
+		return p.ContentType
+	}
+	return ContentArticle
+}
+
+//schemaJSON encodes extra fields of the content type for storing in DB
+func (p Post) schemaJSON() string {
+	if len(p.Schema) == 0 {
+		return ""
+	}
+	b, err := json.Marshal(p.Schema)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+func parseSchema(s string) map[string]string {
+	fields := map[string]string{}
+	if s != "" {
+		json.Unmarshal([]byte(s), &fields)
+	}
+	return fields
+}
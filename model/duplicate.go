@@ -0,0 +1,78 @@
+package model
+
+import (
+	"database/sql"
+	"math"
+	"sort"
+	"strings"
+)
+
+//SimilarPost is an existing post nearly identical to the one being created
+type SimilarPost struct {
+	ID     int
+	Title  string
+	Status string
+	//Similarity is the higher of title and body similarities, from 0 to 1
+	Similarity float64
+}
+
+//Percent returns the similarity rounded to whole percents
+func (p SimilarPost) Percent() int {
+	return int(math.Round(p.Similarity * 100))
+}
+
+//trigrams counts character trigrams of the text, case and runs of whitespace are ignored
+func trigrams(s string) map[string]int {
+	r := []rune(" " + strings.Join(strings.Fields(strings.ToLower(s)), " ") + " ")
+	grams := map[string]int{}
+	for i := 0; i+3 <= len(r); i++ {
+		grams[string(r[i:i+3])]++
+	}
+	return grams
+}
+
+//Similarity returns cosine similarity of the trigram vectors of the texts,
+//1 for texts that differ only in case and whitespace and 0 for texts sharing nothing
+func Similarity(a, b string) float64 {
+	ga, gb := trigrams(a), trigrams(b)
+	var dot, na, nb float64
+	for g, n := range ga {
+		na += float64(n * n)
+		dot += float64(n * gb[g])
+	}
+	for _, n := range gb {
+		nb += float64(n * n)
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+//FindSimilarPosts returns posts in any status whose title or body is at least threshold similar
+//to the given ones, most similar first
+func FindSimilarPosts(db *sql.DB, title, body string, threshold float64) ([]SimilarPost, error) {
+	rows, err := db.Query(`select id, title, body, status from posts`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	similar := []SimilarPost{}
+	for rows.Next() {
+		var p SimilarPost
+		var postBody string
+		if err := rows.Scan(&p.ID, &p.Title, &postBody, &p.Status); err != nil {
+			return nil, err
+		}
+		p.Similarity = math.Max(Similarity(title, p.Title), Similarity(body, postBody))
+		if p.Similarity >= threshold {
+			similar = append(similar, p)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(similar, func(i, j int) bool { return similar[i].Similarity > similar[j].Similarity })
+	return similar, nil
+}
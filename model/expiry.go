@@ -0,0 +1,67 @@
+package model
+
+import (
+	"database/sql"
+)
+
+//What happens to the post when it expires: it goes back to drafts or stays published marked as outdated
+const (
+	ExpiryUnpublish = "unpublish"
+	ExpiryOutdated  = "outdated"
+)
+
+//IsExpiryAction reports whether the expiry action is known
+func IsExpiryAction(action string) bool {
+	return action == ExpiryUnpublish || action == ExpiryOutdated
+}
+
+//expiryAction returns the action to store, unknown actions fall back to unpublish
+func (p *Post) expiryAction() string {
+	if p.ExpiryAction == ExpiryOutdated {
+		return ExpiryOutdated
+	}
+	return ExpiryUnpublish
+}
+
+//ExpirePosts applies the expiry action to the published posts which expired by now and returns them,
+//unpublished posts become drafts and their expiry is cleared, so they can be published again. Both count as a change of the post, so lastmod of the sitemap follows
+func ExpirePosts(db *sql.DB, now int64) ([]Post, error) {
+	rows, err := db.Query(`select id, title, expiry_action from posts
+	where status = ? and outdated = 0 and expires_at > 0 and expires_at <= ?`, StatusPublished, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []Post{}
+	for rows.Next() {
+		p := Post{ExpiresAt: now}
+		if err := rows.Scan(&p.ID, &p.Title, &p.ExpiryAction); err != nil {
+			return nil, err
+		}
+		posts = append(posts, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	ids := []int{}
+	for i, p := range posts {
+		if p.ExpiryAction == ExpiryOutdated {
+			_, err = db.Exec(`update posts set outdated = 1, updated_at = ? where id = ?`, now, p.ID)
+			posts[i].Status, posts[i].Outdated = StatusPublished, true
+		} else {
+			_, err = db.Exec(`update posts set status = ?, expires_at = 0, updated_at = ? where id = ?`, StatusDraft, now, p.ID)
+			posts[i].Status = StatusDraft
+		}
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, p.ID)
+	}
+	if len(ids) > 0 {
+		changed(db, TopicPosts, ids...)
+	}
+	return posts, nil
+}
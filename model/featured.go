@@ -0,0 +1,38 @@
+package model
+
+import (
+	"database/sql"
+)
+
+//GetFeaturedPosts returns published featured posts in the order set by the admin,
+//posts which haven't been ordered yet go first
+func GetFeaturedPosts(db *sql.DB) ([]Post, error) {
+	rows, err := db.Query(`select ` + postListColumns + ` from posts
+	where featured = 1 and status = 'published' order by featured_position, id desc;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPosts(rows)
+}
+
+//SetFeaturedOrder stores positions of the featured posts, ids are given in the display order
+func SetFeaturedOrder(db *sql.DB, ids []int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i, id := range ids {
+		if _, err := tx.Exec(`update posts set featured_position = $1 where id = $2 and featured = 1`, i+1, id); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	changed(db, TopicPosts, ids...)
+	return nil
+}
@@ -0,0 +1,108 @@
+package model
+
+import (
+	"database/sql"
+	"strings"
+)
+
+//indexes are created by MigrateDatabase, posts and comments are mostly read by post id,
+//status and reader name, primary keys already cover lookups by the leading columns
+const indexes = `
+create index if not exists posts_status_pinned on posts (status, pinned);
+create index if not exists posts_status_updated on posts (status, pinned, updated_at);
+create index if not exists comments_postid on comments (postid);
+create index if not exists comments_name on comments (name);
+create index if not exists comment_reactions_user on comment_reactions (user);
+create index if not exists post_likes_liker on post_likes (liker);
+create index if not exists editorial_notes_postid on editorial_notes (postid);
+create index if not exists login_attempts_name on login_attempts (name, success, date);
+create index if not exists login_attempts_ip on login_attempts (ip, success, date);
+create index if not exists job_runs_name on job_runs (name);
+create index if not exists announcements_due on announcements (status, send_at);
+`
+
+//hotQueries are the queries behind the most visited pages, their plans are shown on the diagnostics page
+var hotQueries = []struct {
+	name, query string
+	args        []interface{}
+}{
+	{"Post page", `select id, title, body, datepost, (select count(*) from post_likes l where l.postid = posts.id) from posts where id = ?`, []interface{}{1}},
+	{"Comments of the post", `select c.postid, c.commentid, c.name, c.date, c.comment, (select count(*) from comment_reactions r where r.commentid = c.commentid)
+	from comments c where c.postid = ? order by c.postid desc`, []interface{}{1}},
+	{"Posts page", `select ` + postSummaryColumns + ` from posts where status = 'published' order by ` + postOrder(SortPublished) + ` limit ? offset ?`, []interface{}{10, 0}},
+	{"Posts page sorted by update", `select ` + postSummaryColumns + ` from posts where status = 'published' order by ` + postOrder(SortUpdated) + ` limit ? offset ?`, []interface{}{10, 0}},
+	{"Published posts count", `select count(*) from posts where status = 'published'`, nil},
+	{"Recent comments", `select c.postid, c.commentid, c.name, c.date, c.comment from comments c join posts p on p.id = c.postid
+	where p.status = 'published' order by c.commentid desc limit ?`, []interface{}{50}},
+	{"Sitemap", `select id, max(updated_at, commented_at) from posts ` + sitemapWhere + ` order by id limit ? offset ?`,
+		[]interface{}{StatusPublished, VisibilityPublic, 50000, 0}},
+	{"Failed logins", `select count(*) from login_attempts where name = ? and success = 0 and date >= ?`, []interface{}{"admin", 0}},
+	{"Due announcements", `select id from announcements where status = ? and send_at <= ? order by send_at`, []interface{}{AnnouncementQueued, 0}},
+}
+
+//QueryPlan is output of EXPLAIN QUERY PLAN of one of the hot queries
+type QueryPlan struct {
+	Name  string
+	Query string
+	//Steps are the plan lines indented by their depth in the plan tree
+	Steps []string
+	//Scan is set when a table is read in full without an index
+	Scan bool
+}
+
+//Index is an index of the database
+type Index struct {
+	Name  string
+	Table string
+}
+
+//ExplainHotQueries returns plans of the hot queries
+func ExplainHotQueries(db *sql.DB) ([]QueryPlan, error) {
+	plans := []QueryPlan{}
+	for _, q := range hotQueries {
+		p := QueryPlan{Name: q.name, Query: q.query}
+		rows, err := db.Query(`explain query plan `+q.query, q.args...)
+		if err != nil {
+			return nil, err
+		}
+		depth := map[int]int{}
+		for rows.Next() {
+			var id, parent, notused int
+			var detail string
+			if err := rows.Scan(&id, &parent, &notused, &detail); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			depth[id] = depth[parent] + 1
+			p.Steps = append(p.Steps, strings.Repeat("  ", depth[id]-1)+detail)
+			if strings.HasPrefix(detail, "SCAN ") && !strings.Contains(detail, " INDEX ") {
+				p.Scan = true
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		plans = append(plans, p)
+	}
+	return plans, nil
+}
+
+//GetIndexes returns indexes created explicitly, indexes of primary keys and unique constraints are omitted
+func GetIndexes(db *sql.DB) ([]Index, error) {
+	rows, err := db.Query(`select name, tbl_name from sqlite_master where type = 'index' and sql is not null order by tbl_name, name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []Index{}
+	for rows.Next() {
+		var i Index
+		if err := rows.Scan(&i.Name, &i.Table); err != nil {
+			return nil, err
+		}
+		list = append(list, i)
+	}
+	return list, rows.Err()
+}
@@ -0,0 +1,55 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+)
+
+//JobRun holds the log record of one scheduled job execution
+type JobRun struct {
+	ID       int
+	Name     string
+	Started  int64
+	Duration time.Duration
+	Error    string
+	//Summary is what the job reports about the run, e.g. how much it has cleaned up
+	Summary string
+}
+
+//Date returns start time of the run in human readable form
+func (j JobRun) Date() string {
+	return time.Unix(j.Started, 0).Format("Mon Jan _2 15:04:05 2006")
+}
+
+func (j *JobRun) CreateJobRun(db *sql.DB) error {
+	_, err := db.Exec(`insert into job_runs (name, started, duration, error, summary) values ($1, $2, $3, $4, $5)`,
+		j.Name, j.Started, int64(j.Duration), j.Error, j.Summary)
+	return err
+}
+
+//GetJobRuns returns the most recent job runs
+func GetJobRuns(db *sql.DB, count int) ([]JobRun, error) {
+	rows, err := db.Query(`select id, name, started, duration, error, summary from job_runs order by id desc limit ?;`, count)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []JobRun{}
+	for rows.Next() {
+		var j JobRun
+		var d int64
+		if err := rows.Scan(&j.ID, &j.Name, &j.Started, &d, &j.Error, &j.Summary); err != nil {
+			return nil, err
+		}
+		j.Duration = time.Duration(d)
+		runs = append(runs, j)
+	}
+	return runs, rows.Err()
+}
+
+//DeleteOldJobRuns keeps only the latest keep runs of the job
+func DeleteOldJobRuns(db *sql.DB, name string, keep int) error {
+	_, err := db.Exec(`delete from job_runs where name = ? and id not in (select id from job_runs where name = ? order by id desc limit ?)`, name, name, keep)
+	return err
+}
@@ -0,0 +1,77 @@
+package model
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+//LinkCheck holds result of the last check of an outbound link found in a post
+type LinkCheck struct {
+	PostID    int
+	PostTitle string
+	URL       string
+	Status    int
+	Error     string
+	CheckedAt int64
+}
+
+//IsBroken reports whether the link was unreachable or returned an error status
+func (l LinkCheck) IsBroken() bool {
+	return l.Status == 0 || l.Status >= 400
+}
+
+//Checked returns time of the last check in human readable form
+func (l LinkCheck) Checked() string {
+	return time.Unix(l.CheckedAt, 0).Format("Mon Jan _2 15:04:05 2006")
+}
+
+//SaveLinkCheck inserts or replaces the check result of the link in the post
+func (l *LinkCheck) SaveLinkCheck(db *sql.DB) error {
+	_, err := db.Exec(`insert or replace into link_checks (postid, url, status, error, checked_at) values ($1, $2, $3, $4, $5)`, l.PostID, l.URL, l.Status, l.Error, l.CheckedAt)
+	return err
+}
+
+//GetRecentLinkCheck returns the latest check of url done after since, sql.ErrNoRows if there is none
+func GetRecentLinkCheck(db *sql.DB, url string, since int64) (LinkCheck, error) {
+	l := LinkCheck{URL: url}
+	err := db.QueryRow(`select status, error, checked_at from link_checks where url = ? and checked_at >= ? order by checked_at desc limit 1`, url, since).Scan(&l.Status, &l.Error, &l.CheckedAt)
+	return l, err
+}
+
+//DeleteStaleLinkChecks removes results of the post which links are not in the post anymore
+func DeleteStaleLinkChecks(db *sql.DB, postID int, urls []string) error {
+	args := []interface{}{postID}
+	placeholders := []string{}
+	for _, u := range urls {
+		args = append(args, u)
+		placeholders = append(placeholders, "?")
+	}
+
+	query := `delete from link_checks where postid = ?`
+	if len(urls) > 0 {
+		query += ` and url not in (` + strings.Join(placeholders, ", ") + `)`
+	}
+	_, err := db.Exec(query, args...)
+	return err
+}
+
+//GetBrokenLinks returns broken links along with posts they are found in
+func GetBrokenLinks(db *sql.DB) ([]LinkCheck, error) {
+	rows, err := db.Query(`select l.postid, p.title, l.url, l.status, l.error, l.checked_at from link_checks l
+	join posts p on p.id = l.postid where l.status = 0 or l.status >= 400 order by l.postid desc, l.url;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []LinkCheck{}
+	for rows.Next() {
+		var l LinkCheck
+		if err := rows.Scan(&l.PostID, &l.PostTitle, &l.URL, &l.Status, &l.Error, &l.CheckedAt); err != nil {
+			return nil, err
+		}
+		links = append(links, l)
+	}
+	return links, rows.Err()
+}
@@ -0,0 +1,30 @@
+package model
+
+import (
+	"database/sql"
+)
+
+//RecordLoginAttempt stores the result of a login attempt
+func RecordLoginAttempt(db *sql.DB, name, ip string, success bool, at int64) error {
+	_, err := db.Exec(`insert into login_attempts (name, ip, success, date) values ($1, $2, $3, $4)`, name, ip, success, at)
+	return err
+}
+
+//CountFailedLogins returns number of failed attempts for the account and from the ip made after since,
+//failures which happened before the last successful login of the account are not counted
+func CountFailedLogins(db *sql.DB, name, ip string, since int64) (byName int, byIP int, err error) {
+	err = db.QueryRow(`select count(*) from login_attempts where name = ? and success = 0
+	and date >= max(?, coalesce((select max(date) from login_attempts where name = ? and success = 1), 0))`, name, since, name).Scan(&byName)
+	if err != nil {
+		return
+	}
+	err = db.QueryRow(`select count(*) from login_attempts where ip = ? and success = 0
+	and date >= max(?, coalesce((select max(date) from login_attempts where ip = ? and success = 1), 0))`, ip, since, ip).Scan(&byIP)
+	return
+}
+
+//DeleteLoginAttempts removes attempts older than before
+func DeleteLoginAttempts(db *sql.DB, before int64) error {
+	_, err := db.Exec(`delete from login_attempts where date < ?`, before)
+	return err
+}
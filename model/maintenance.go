@@ -0,0 +1,113 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+//autoVacuumIncremental is value of auto_vacuum pragma in the incremental mode
+const autoVacuumIncremental = 2
+
+//Maintenance is outcome of one database maintenance run
+type Maintenance struct {
+	//Converted is set when the database was rebuilt with VACUUM to enable incremental vacuum
+	Converted bool
+	//FreedPages is number of free pages returned to the file system by incremental vacuum
+	FreedPages int64
+	//Checkpointed and WALPages are pages moved into the database and pages left in the WAL,
+	//both are -1 when the database isn't in WAL mode
+	Checkpointed int64
+	WALPages     int64
+	//Busy is set when checkpoint couldn't complete because of active readers or writers
+	Busy bool
+	//Size is size of the database file in bytes after the run
+	Size int64
+}
+
+//String summarizes the run for the logs and the jobs page
+func (m Maintenance) String() string {
+	s := fmt.Sprintf("freed %d pages, size %d bytes", m.FreedPages, m.Size)
+	if m.Converted {
+		s = "enabled incremental vacuum, " + s
+	}
+	if m.Checkpointed >= 0 {
+		s += fmt.Sprintf(", checkpointed %d of %d WAL pages", m.Checkpointed, m.WALPages)
+	}
+	if m.Busy {
+		s += ", checkpoint was blocked"
+	}
+	return s
+}
+
+//Maintain refreshes query planner statistics, returns free pages to the file system and truncates the WAL.
+//Databases created without incremental auto vacuum are converted with one full VACUUM on the first run
+func Maintain(db *sql.DB) (Maintenance, error) {
+	var m Maintenance
+
+	//auto_vacuum pragma affects only the connection which runs VACUUM, so all is done on one connection
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return m, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `pragma optimize`); err != nil {
+		return m, fmt.Errorf("optimize: %v", err)
+	}
+	if _, err := conn.ExecContext(ctx, `analyze`); err != nil {
+		return m, fmt.Errorf("analyze: %v", err)
+	}
+
+	var mode int
+	if err := conn.QueryRowContext(ctx, `pragma auto_vacuum`).Scan(&mode); err != nil {
+		return m, err
+	}
+	if mode != autoVacuumIncremental {
+		//the mode of the existing database changes only after it's rebuilt
+		if _, err := conn.ExecContext(ctx, `pragma auto_vacuum = incremental`); err != nil {
+			return m, err
+		}
+		if _, err := conn.ExecContext(ctx, `vacuum`); err != nil {
+			return m, fmt.Errorf("vacuum: %v", err)
+		}
+		m.Converted = true
+	}
+
+	var before, after int64
+	if err := conn.QueryRowContext(ctx, `pragma freelist_count`).Scan(&before); err != nil {
+		return m, err
+	}
+	//every step of incremental_vacuum frees one page, the rows have to be read till the end
+	rows, err := conn.QueryContext(ctx, `pragma incremental_vacuum`)
+	if err != nil {
+		return m, fmt.Errorf("incremental vacuum: %v", err)
+	}
+	for rows.Next() {
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return m, fmt.Errorf("incremental vacuum: %v", err)
+	}
+	if err := conn.QueryRowContext(ctx, `pragma freelist_count`).Scan(&after); err != nil {
+		return m, err
+	}
+	m.FreedPages = before - after
+
+	var busy int
+	if err := conn.QueryRowContext(ctx, `pragma wal_checkpoint(truncate)`).Scan(&busy, &m.WALPages, &m.Checkpointed); err != nil {
+		return m, fmt.Errorf("checkpoint: %v", err)
+	}
+	m.Busy = busy != 0
+
+	var pages, pageSize int64
+	if err := conn.QueryRowContext(ctx, `pragma page_count`).Scan(&pages); err != nil {
+		return m, err
+	}
+	if err := conn.QueryRowContext(ctx, `pragma page_size`).Scan(&pageSize); err != nil {
+		return m, err
+	}
+	m.Size = pages * pageSize
+	return m, nil
+}
@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"io/ioutil"
 	"log"
+	"strings"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 	"gopkg.in/yaml.v2"
@@ -17,60 +19,241 @@ const (
 	GITHUB
 )
 
+//postListColumns are the columns selected for post lists, body is cut to the excerpt,
+//rows are read with scanPosts
+const postListColumns = `id, title, substr(body,1,950), datepost,
+	(select count(*) from post_likes l where l.postid = posts.id), cover_image, visibility, status, pinned, featured, comments_count, expires_at, outdated`
+
 //Post is struct which holds model representation of one post
 type Post struct {
 	ID    int
 	Title string
 	Body  string
 	Date  string
+	Likes int
+
+	NoIndex    bool
+	NoFollow   bool
+	CoverImage string
+	Visibility string
+	Status     string
+	Reviewer   string
+	Pinned     bool
+	Featured   bool
+	//CanonicalURL points to the original of the republished post
+	CanonicalURL string
+	//ContentType selects structured data of the post, Schema holds its extra fields
+	ContentType string
+	Schema      map[string]string
+	//Updated is unix time of the last change
+	Updated int64
+	//Comments is number of comments, it's kept in the posts table so lists don't count them
+	Comments int
+	//ExpiresAt is unix time the post is unpublished or marked Outdated at by ExpiryAction, zero never
+	ExpiresAt    int64
+	ExpiryAction string
+	Outdated     bool
+	//AgeBanner is the age in years from which the post shows its age, AgeBannerDefault uses the site's setting
+	//and AgeBannerNever hides it
+	AgeBanner int
+}
+
+//Sort orders of post lists, pinned posts always go first
+const (
+	SortPublished = "published"
+	SortUpdated   = "updated"
+)
+
+//postOrder returns order by clause of the sort order
+func postOrder(sort string) string {
+	if sort == SortUpdated {
+		return `pinned desc, updated_at desc, id desc`
+	}
+	return `pinned desc, id desc`
+}
+
+//Visibility levels of the post, members only posts are shown in full to logged in users
+const (
+	VisibilityPublic  = "public"
+	VisibilityMembers = "members"
+)
+
+//MembersOnly reports whether full content of the post requires login
+func (p Post) MembersOnly() bool {
+	return p.Visibility == VisibilityMembers
+}
+
+//Robots returns robots directives of the post, empty string means no restrictions
+func (p Post) Robots() string {
+	directives := []string{}
+	if p.NoIndex {
+		directives = append(directives, "noindex")
+	}
+	if p.NoFollow {
+		directives = append(directives, "nofollow")
+	}
+	return strings.Join(directives, ", ")
 }
 
 func (p *Post) GetPost(db *sql.DB) error {
-	return db.QueryRow(`select * from posts where id = ?`, p.ID).Scan(&p.ID, &p.Title, &p.Body, &p.Date)
+	stmt, err := prepare(db, `select id, title, body, datepost, (select count(*) from post_likes l where l.postid = posts.id),
+	noindex, nofollow, cover_image, visibility, status, reviewer, pinned, featured, canonical_url, content_type, schema_fields, updated_at,
+	expires_at, expiry_action, outdated, age_banner from posts where id = ?`)
+	if err != nil {
+		return err
+	}
+	var schema string
+	if err := stmt.QueryRow(p.ID).Scan(&p.ID, &p.Title, &p.Body, &p.Date, &p.Likes, &p.NoIndex, &p.NoFollow, &p.CoverImage, &p.Visibility, &p.Status, &p.Reviewer, &p.Pinned, &p.Featured, &p.CanonicalURL,
+		&p.ContentType, &schema, &p.Updated, &p.ExpiresAt, &p.ExpiryAction, &p.Outdated, &p.AgeBanner); err != nil {
+		return err
+	}
+	p.Schema = parseSchema(schema)
+	return nil
 }
 
 func (p *Post) UpdatePost(db *sql.DB) error {
-	_, err := db.Exec(`update posts set title = $1, body = $2, datepost = $3 where id = $4`, p.Title, p.Body, p.Date, p.ID)
+	p.Updated = time.Now().Unix()
+	//the outdated mark is kept until the expiry is moved
+	_, err := db.Exec(`update posts set title = $1, body = $2, datepost = $3, noindex = $4, nofollow = $5, cover_image = $6, visibility = $7,
+	pinned = $8, featured = $9, canonical_url = $10, content_type = $11, schema_fields = $12, updated_at = $13,
+	outdated = (outdated and expires_at = $14), expires_at = $14, expiry_action = $15, age_banner = $16 where id = $17`,
+		p.Title, p.Body, p.Date, p.NoIndex, p.NoFollow, p.CoverImage, p.visibility(), p.Pinned, p.Featured, p.CanonicalURL, p.contentType(), p.schemaJSON(), p.Updated,
+		p.ExpiresAt, p.expiryAction(), p.ageBanner(), p.ID)
+	if err == nil {
+		changed(db, TopicPosts, p.ID)
+	}
 	return err
 }
 
-func (p *Post) DeletePost(db *sql.DB) error {
-	_, err := db.Exec(`delete from posts where id = ?`, p.ID)
-	return err
+//DeletePost removes the post together with its comments in one transaction
+//and returns number of deleted comments
+func (p *Post) DeletePost(db *sql.DB) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`delete from comment_reactions where commentid in (select commentid from comments where postid = ?)`, p.ID); err != nil {
+		return 0, err
+	}
+
+	res, err := tx.Exec(`delete from comments where postid = ?`, p.ID)
+	if err != nil {
+		return 0, err
+	}
+	comments, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(`delete from link_checks where postid = ?`, p.ID); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`delete from editorial_notes where postid = ?`, p.ID); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`delete from post_likes where postid = ?`, p.ID); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`delete from comment_subscriptions where postid = ?`, p.ID); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`delete from announcements where postid = ? and status = ?`, p.ID, AnnouncementQueued); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`delete from posts where id = ?`, p.ID); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	changed(db, TopicPosts, p.ID)
+	changed(db, TopicComments, p.ID)
+	return comments, nil
+}
+
+//Age banner thresholds of the post besides number of years
+const (
+	AgeBannerDefault = 0
+	AgeBannerNever   = -1
+)
+
+//ageBanner returns the threshold to store, negative thresholds hide the banner
+func (p *Post) ageBanner() int {
+	if p.AgeBanner < 0 {
+		return AgeBannerNever
+	}
+	return p.AgeBanner
+}
+
+//visibility returns visibility to store, unknown levels fall back to public
+func (p *Post) visibility() string {
+	if p.Visibility == VisibilityMembers {
+		return VisibilityMembers
+	}
+	return VisibilityPublic
 }
 
 func (p *Post) CreatePost(db *sql.DB) error {
-	_, err := db.Exec(`insert into posts (title, body, datepost) values ($1, $2, $3)`, p.Title, p.Body, p.Date)
-	return err
+	if p.Status == "" {
+		p.Status = StatusPublished
+	}
+	if !IsStatus(p.Status) {
+		return ErrInvalidStatus
+	}
+	p.Updated = time.Now().Unix()
+	res, err := db.Exec(`insert into posts (title, body, datepost, noindex, nofollow, cover_image, visibility, status, pinned, featured, canonical_url,
+	content_type, schema_fields, updated_at, expires_at, expiry_action, age_banner)
+	values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)`,
+		p.Title, p.Body, p.Date, p.NoIndex, p.NoFollow, p.CoverImage, p.visibility(), p.Status, p.Pinned, p.Featured, p.CanonicalURL,
+		p.contentType(), p.schemaJSON(), p.Updated, p.ExpiresAt, p.expiryAction(), p.ageBanner())
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	p.ID = int(id)
+	changed(db, TopicPosts, p.ID)
+	return nil
 }
 
 func GetPosts(db *sql.DB, count, start int) ([]Post, error) {
-	rows, err := db.Query(`select id, title, substr(body,1,950), datepost from posts order by id desc limit ? offset ?;`, count, start)
+	rows, err := db.Query(`select `+postListColumns+` from posts where status = 'published' order by id desc limit ? offset ?;`, count, start)
 
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	return scanPosts(rows)
+}
+
+func scanPosts(rows *sql.Rows) ([]Post, error) {
 	posts := []Post{}
 
 	for rows.Next() {
 		var p Post
-		if err := rows.Scan(&p.ID, &p.Title, &p.Body, &p.Date); err != nil {
+		if err := rows.Scan(&p.ID, &p.Title, &p.Body, &p.Date, &p.Likes, &p.CoverImage, &p.Visibility, &p.Status, &p.Pinned, &p.Featured, &p.Comments,
+			&p.ExpiresAt, &p.Outdated); err != nil {
 			return nil, err
 		}
 		posts = append(posts, p)
 	}
-	return posts, nil
+	return posts, rows.Err()
 }
 
-func CountPosts(db *sql.DB) int {
+//CountPosts returns total number of published posts
+func CountPosts(db *sql.DB) (int, error) {
 	var c int
-	err := db.QueryRow(`select count(*) from posts`).Scan(&c)
+	stmt, err := prepare(db, `select count(*) from posts where status = 'published'`)
 	if err != nil {
-		log.Println(err)
+		return 0, err
 	}
-	return c
+	err = stmt.QueryRow().Scan(&c)
+	return c, err
 }
 
 //Comment is struct which holds model representation of one comment
@@ -80,10 +263,16 @@ type Comment struct {
 	Name      string
 	Date      string
 	Data      string
+	Likes     int
 }
 
 func GetComments(db *sql.DB, id int) ([]Comment, error) {
-	rows, err := db.Query(`select postid, commentid, name, date, comment from comments where postid = ? order by postid desc;`, id)
+	stmt, err := prepare(db, `select c.postid, c.commentid, c.name, c.date, c.comment, (select count(*) from comment_reactions r where r.commentid = c.commentid)
+	from comments c where c.postid = ? order by c.postid desc;`)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := stmt.Query(id)
 
 	if err != nil {
 		return nil, err
@@ -94,7 +283,7 @@ func GetComments(db *sql.DB, id int) ([]Comment, error) {
 
 	for rows.Next() {
 		var c Comment
-		if err := rows.Scan(&c.PostID, &c.CommentID, &c.Name, &c.Date, &c.Data); err != nil {
+		if err := rows.Scan(&c.PostID, &c.CommentID, &c.Name, &c.Date, &c.Data, &c.Likes); err != nil {
 			return nil, err
 		}
 		comments = append(comments, c)
@@ -103,13 +292,62 @@ func GetComments(db *sql.DB, id int) ([]Comment, error) {
 }
 
 func (c *Comment) DeleteComment(db *sql.DB) error {
-	_, err := db.Exec(`delete from comments where commentid = ?`, c.CommentID)
-	return err
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	//the comment is usually deleted by its id alone, post of it is looked up for the change notification
+	var postID int
+	if err := tx.QueryRow(`select postid from comments where commentid = ?`, c.CommentID).Scan(&postID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	}
+	if _, err := tx.Exec(`delete from comment_reactions where commentid = ?`, c.CommentID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`delete from comments where commentid = ?`, c.CommentID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`update posts set comments_count = comments_count - 1 where id = ?`, postID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	changed(db, TopicComments, postID)
+	return nil
 }
 
+//CreateComment stores the comment, counts it and records the time of the comment activity on the post,
+//the post is shown as modified in the sitemap
 func (c *Comment) CreateComment(db *sql.DB) error {
-	_, err := db.Exec(`insert into comments (postid, name, date, comment) values ($1, $2, $3, $4)`, c.PostID, c.Name, c.Date, c.Data)
-	return err
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`insert into comments (postid, name, date, comment) values ($1, $2, $3, $4)`, c.PostID, c.Name, c.Date, c.Data)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	c.CommentID = int(id)
+	if _, err := tx.Exec(`update posts set commented_at = $1, comments_count = comments_count + 1 where id = $2`, time.Now().Unix(), c.PostID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	changed(db, TopicComments, c.PostID)
+	return nil
 }
 
 func MigrateDatabase(db *sql.DB) {
@@ -127,11 +365,116 @@ func MigrateDatabase(db *sql.DB) {
 	date string not null,
 	comment  string not null);
 
+	create table if not exists comment_reactions (
+	commentid integer not null,
+	user string not null,
+	primary key (commentid, user));
+
+	create table if not exists post_likes (
+	postid integer not null,
+	liker string not null,
+	primary key (postid, liker));
+
+	create table if not exists link_checks (
+	postid integer not null,
+	url string not null,
+	status integer not null,
+	error string not null,
+	checked_at integer not null,
+	primary key (postid, url));
+
+	create table if not exists job_runs (
+	id integer primary key autoincrement,
+	name string not null,
+	started integer not null,
+	duration integer not null,
+	error string not null);
+
+	create table if not exists login_attempts (
+	id integer primary key autoincrement,
+	name string not null,
+	ip string not null,
+	success boolean not null,
+	date integer not null);
+
+	create table if not exists remember_tokens (
+	selector string primary key,
+	hash string not null,
+	name string not null,
+	type integer not null,
+	expires integer not null);
+
+	create table if not exists editorial_notes (
+	id integer primary key autoincrement,
+	postid integer not null,
+	author string not null,
+	quote string not null,
+	note string not null,
+	resolved boolean not null default 0,
+	date string not null);
+
+	create table if not exists notifications (
+	id integer primary key autoincrement,
+	kind string not null,
+	message string not null,
+	link string not null,
+	read boolean not null,
+	date string not null);
+
+	create table if not exists settings (
+	key string primary key,
+	value string not null);
+
 	create table if not exists users (
 	id integer primary key autoincrement,
 	name string not null unique,
 	type integer not null,
 	pass string not null);
+
+	create table if not exists audit_log (
+	id integer primary key autoincrement,
+	actor string not null,
+	ip string not null,
+	action string not null,
+	summary string not null,
+	date string not null);
+
+	create table if not exists not_found (
+	path string primary key,
+	hits integer not null default 0,
+	last_seen integer not null);
+
+	create table if not exists redirects (
+	source string primary key,
+	destination string not null,
+	status integer not null,
+	hits integer not null default 0);
+
+	create table if not exists comment_subscriptions (
+	postid integer not null,
+	name string not null,
+	email string not null,
+	token string not null unique,
+	site string not null,
+	notified_until integer not null,
+	primary key (postid, name));
+
+	create table if not exists snippets (
+	name string primary key,
+	title string not null,
+	body string not null);
+
+	create table if not exists announcements (
+	id integer primary key autoincrement,
+	postid integer not null,
+	channel string not null,
+	text string not null,
+	status string not null,
+	error string not null default '',
+	attempts integer not null default 0,
+	send_at integer not null,
+	sent_at integer not null default 0,
+	unique (postid, channel));
 	`
 
 	_, err := db.Exec(sql)
@@ -139,6 +482,81 @@ func MigrateDatabase(db *sql.DB) {
 	if err != nil {
 		panic(err)
 	}
+
+	//backfill fills the column added to the existing table
+	columns := []struct {
+		table, column, definition, backfill string
+	}{
+		{"posts", "noindex", "boolean not null default 0", ""},
+		{"posts", "nofollow", "boolean not null default 0", ""},
+		{"posts", "cover_image", "string not null default ''", ""},
+		{"posts", "visibility", "string not null default 'public'", ""},
+		{"posts", "status", "string not null default 'published'", ""},
+		{"posts", "reviewer", "string not null default ''", ""},
+		{"posts", "pinned", "boolean not null default 0", ""},
+		{"posts", "updated_at", "integer not null default 0", ""},
+		{"posts", "featured", "boolean not null default 0", ""},
+		{"posts", "featured_position", "integer not null default 0", ""},
+		{"posts", "canonical_url", "string not null default ''", ""},
+		{"posts", "content_type", "string not null default 'article'", ""},
+		{"posts", "schema_fields", "string not null default ''", ""},
+		{"posts", "commented_at", "integer not null default 0", ""},
+		{"job_runs", "summary", "string not null default ''", ""},
+		{"posts", "comments_count", "integer not null default 0", recountComments},
+		{"posts", "expires_at", "integer not null default 0", ""},
+		{"posts", "expiry_action", "string not null default 'unpublish'", ""},
+		{"posts", "outdated", "boolean not null default 0", ""},
+		{"posts", "age_banner", "integer not null default 0", ""},
+		{"remember_tokens", "rotated", "boolean not null default 0", ""},
+	}
+	for _, c := range columns {
+		added, err := addColumn(db, c.table, c.column, c.definition)
+		if err != nil {
+			panic(err)
+		}
+		if added && c.backfill != "" {
+			if _, err := db.Exec(c.backfill); err != nil {
+				panic(err)
+			}
+		}
+	}
+
+	//indexes of the hot queries, they are created after the columns they cover
+	if _, err := db.Exec(indexes); err != nil {
+		panic(err)
+	}
+}
+
+//addColumn adds the column to the existing table unless it's already there, added reports whether it wasn't
+func addColumn(db *sql.DB, table, column, definition string) (added bool, err error) {
+	rows, err := db.Query(`pragma table_info(` + table + `)`)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid, notnull, pk int
+			name, ctype      string
+			dflt             sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &ctype, &notnull, &dflt, &pk); err != nil {
+			return false, err
+		}
+		if name == column {
+			return false, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+	rows.Close()
+
+	if _, err := db.Exec(`alter table ` + table + ` add column ` + column + ` ` + definition); err != nil {
+		return false, err
+	}
+	return true, nil
 }
 
 //User struct holds information about user
@@ -193,7 +611,7 @@ func (u *User) CheckCredentials(db *sql.DB, pswd string) bool {
 	return true
 }
 
-// Course holds information about courses which is located under data/courses.yml
+//Course holds information about courses which is located under data/courses.yml
 type Info struct {
 	Title       string `yaml:"title"`
 	Link        string `yaml:"link"`
@@ -215,3 +633,69 @@ func ConverYamlToStruct(path string) (i Infos, err error) {
 	}
 	return
 }
+
+//GetCommenterNames returns distinct names of the most recent commenters which start with prefix
+func GetCommenterNames(db *sql.DB, prefix string, count int) ([]string, error) {
+	pattern := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix) + "%"
+	rows, err := db.Query(`select name from comments where name like ? escape '\' group by name order by max(commentid) desc limit ?;`, pattern, count)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+//publicExcerptColumn is the part of the body anonymous readers see of members only posts,
+//it's cut the same way as render.Excerpt
+const publicExcerptColumn = `case
+	when instr(body, '<!--more-->') > 0 then substr(body, 1, instr(body, '<!--more-->') - 1)
+	when instr(body, '</p>') > 0 then substr(body, 1, instr(body, '</p>') + 3)
+	when instr(replace(body, char(13, 10), char(10)), char(10, 10)) > 0
+		then substr(replace(body, char(13, 10), char(10)), 1, instr(replace(body, char(13, 10), char(10)), char(10, 10)) - 1)
+	else body end`
+
+//SearchPosts returns posts which title or body contains the query. Unless members is set, only
+//the public excerpt of members only posts is searched so their content can't be probed
+func SearchPosts(db *sql.DB, query string, count int, members bool) ([]Post, error) {
+	pattern := "%" + strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(query) + "%"
+	rows, err := db.Query(`select `+postListColumns+` from posts
+	where status = 'published' and (title like ? escape '\' or
+	(case when ? or visibility != ? then body else `+publicExcerptColumn+` end) like ? escape '\')
+	order by id desc limit ?;`, pattern, members, VisibilityMembers, pattern, count)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPosts(rows)
+}
+
+//GetAllPosts returns all posts with full bodies, ordered by id
+func GetAllPosts(db *sql.DB) ([]Post, error) {
+	rows, err := db.Query(`select id, title, body, datepost, (select count(*) from post_likes l where l.postid = posts.id), cover_image, visibility, status, pinned, featured, comments_count, expires_at, outdated from posts order by id;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPosts(rows)
+}
+
+//SetCoverImage replaces cover image of the post, it's used as og:image of the post page
+func (p *Post) SetCoverImage(db *sql.DB, image string) error {
+	if _, err := db.Exec(`update posts set cover_image = $1 where id = $2`, image, p.ID); err != nil {
+		return err
+	}
+	p.CoverImage = image
+	changed(db, TopicPosts, p.ID)
+	return nil
+}
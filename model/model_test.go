@@ -0,0 +1,52 @@
+package model
+
+import (
+	"testing"
+)
+
+func TestPostSummariesPage(t *testing.T) {
+	db := testDB(t)
+
+	for _, p := range []Post{
+		{Title: "First", Body: "body", Date: "date"},
+		{Title: "Second", Body: "body", Date: "date"},
+		{Title: "Third", Body: "body", Date: "date"},
+		{Title: "Draft", Body: "body", Date: "date", Status: StatusDraft},
+	} {
+		if err := p.CreatePost(db); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	count, err := CountPosts(db)
+	if err != nil || count != 3 {
+		t.Fatalf("wrong number of published posts: got %v, %v want 3", count, err)
+	}
+	seen := 0
+	for page := 0; page < 2; page++ {
+		posts, total, err := GetPostSummaries(db, page, 2, SortPublished)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != count {
+			t.Errorf("total of page %v doesn't agree with the count: got %v want %v", page, total, count)
+		}
+		seen += len(posts)
+	}
+	if seen != count {
+		t.Errorf("pages don't hold every published post: got %v want %v", seen, count)
+	}
+}
+
+func TestPostSummariesError(t *testing.T) {
+	db := testDB(t)
+	CloseStatements(db)
+	db.Close()
+
+	if _, err := CountPosts(db); err == nil {
+		t.Errorf("count of the closed database doesn't return error")
+	}
+	if posts, total, err := GetPostSummaries(db, 0, 2, SortPublished); err == nil || posts != nil || total != 0 {
+		t.Errorf("page of the closed database doesn't return error: got %v posts of %v, %v", len(posts), total, err)
+	}
+}
@@ -0,0 +1,69 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+)
+
+//NotFound is a path readers or crawlers asked for which doesn't exist
+type NotFound struct {
+	Path     string
+	Hits     int
+	LastSeen int64
+}
+
+//Seen returns time of the last hit in human readable form
+func (n NotFound) Seen() string {
+	return time.Unix(n.LastSeen, 0).Format("Mon Jan _2 15:04:05 2006")
+}
+
+//RecordNotFound counts the hit of the missing path
+func RecordNotFound(db *sql.DB, path string) error {
+	_, err := db.Exec(`insert into not_found (path, hits, last_seen) values ($1, 1, $2)
+	on conflict(path) do update set hits = hits + 1, last_seen = excluded.last_seen`, path, time.Now().Unix())
+	return err
+}
+
+//GetNotFound returns the most requested missing paths
+func GetNotFound(db *sql.DB, count int) ([]NotFound, error) {
+	rows, err := db.Query(`select path, hits, last_seen from not_found order by hits desc, last_seen desc limit ?`, count)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []NotFound{}
+	for rows.Next() {
+		var n NotFound
+		if err := rows.Scan(&n.Path, &n.Hits, &n.LastSeen); err != nil {
+			return nil, err
+		}
+		list = append(list, n)
+	}
+	return list, rows.Err()
+}
+
+//DeleteNotFound forgets the missing path, e.g. after redirect for it was created
+func DeleteNotFound(db *sql.DB, path string) error {
+	_, err := db.Exec(`delete from not_found where path = $1`, path)
+	return err
+}
+
+//GetPublishedTitles returns ids and titles of all published posts
+func GetPublishedTitles(db *sql.DB) ([]Post, error) {
+	rows, err := db.Query(`select id, title from posts where status = 'published' order by id desc`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []Post{}
+	for rows.Next() {
+		var p Post
+		if err := rows.Scan(&p.ID, &p.Title); err != nil {
+			return nil, err
+		}
+		posts = append(posts, p)
+	}
+	return posts, rows.Err()
+}
@@ -0,0 +1,65 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+)
+
+//Notification is an event which needs attention of the admin
+type Notification struct {
+	ID      int    `json:"id"`
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+	Link    string `json:"link,omitempty"`
+	Read    bool   `json:"read"`
+	Date    string `json:"date"`
+}
+
+//CreateNotification stores unread notification
+func CreateNotification(db *sql.DB, kind, message, link string) error {
+	_, err := db.Exec(`insert into notifications (kind, message, link, read, date) values ($1, $2, $3, 0, $4)`,
+		kind, message, link, time.Now().Format("Mon Jan _2 15:04:05 2006"))
+	return err
+}
+
+//GetNotifications returns latest notifications, newest first
+func GetNotifications(db *sql.DB, count int) ([]Notification, error) {
+	rows, err := db.Query(`select id, kind, message, link, read, date from notifications order by id desc limit ?`, count)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []Notification{}
+	for rows.Next() {
+		var n Notification
+		if err := rows.Scan(&n.ID, &n.Kind, &n.Message, &n.Link, &n.Read, &n.Date); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+//CountUnreadNotifications returns number of unread notifications
+func CountUnreadNotifications(db *sql.DB) (int, error) {
+	var c int
+	err := db.QueryRow(`select count(*) from notifications where read = 0`).Scan(&c)
+	return c, err
+}
+
+//MarkNotificationsRead marks the notification as read, zero id marks all of them
+func MarkNotificationsRead(db *sql.DB, id int) error {
+	if id == 0 {
+		_, err := db.Exec(`update notifications set read = 1 where read = 0`)
+		return err
+	}
+	_, err := db.Exec(`update notifications set read = 1 where id = ?`, id)
+	return err
+}
+
+//DeleteReadNotifications removes read notifications except the latest keep ones
+func DeleteReadNotifications(db *sql.DB, keep int) error {
+	_, err := db.Exec(`delete from notifications where read = 1 and id not in (select id from notifications order by id desc limit ?)`, keep)
+	return err
+}
@@ -0,0 +1,114 @@
+package model
+
+import (
+	"database/sql"
+)
+
+//UserData is everything the blog stores about the reader, it's exported on their request
+type UserData struct {
+	Name     string    `json:"name"`
+	Comments []Comment `json:"comments"`
+	//LikedComments and LikedPosts are ids of the comments and posts the reader likes
+	LikedComments []int `json:"liked_comments"`
+	LikedPosts    []int `json:"liked_posts"`
+}
+
+//GetUserData collects comments and likes of the reader
+func GetUserData(db *sql.DB, name string) (UserData, error) {
+	d := UserData{Name: name, Comments: []Comment{}}
+
+	rows, err := db.Query(`select c.postid, c.commentid, c.name, c.date, c.comment, (select count(*) from comment_reactions r where r.commentid = c.commentid)
+	from comments c where c.name = ? order by c.commentid`, name)
+	if err != nil {
+		return d, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c Comment
+		if err := rows.Scan(&c.PostID, &c.CommentID, &c.Name, &c.Date, &c.Data, &c.Likes); err != nil {
+			return d, err
+		}
+		d.Comments = append(d.Comments, c)
+	}
+	if err := rows.Err(); err != nil {
+		return d, err
+	}
+
+	if d.LikedComments, err = queryIDs(db, `select commentid from comment_reactions where user = ? order by commentid`, name); err != nil {
+		return d, err
+	}
+	d.LikedPosts, err = queryIDs(db, `select postid from post_likes where liker = ? order by postid`, "user:"+name)
+	return d, err
+}
+
+func queryIDs(db *sql.DB, query string, args ...interface{}) ([]int, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+//DeleteUserData removes comments, likes, subscriptions, remember me tokens and login attempts of the reader
+//in one transaction and returns number of deleted comments
+func DeleteUserData(db *sql.DB, name string) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`delete from comment_reactions where commentid in (select commentid from comments where name = ?)`, name); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`update posts set comments_count = comments_count - (select count(*) from comments c where c.postid = posts.id and c.name = $1)
+	where id in (select postid from comments where name = $1)`, name); err != nil {
+		return 0, err
+	}
+	res, err := tx.Exec(`delete from comments where name = ?`, name)
+	if err != nil {
+		return 0, err
+	}
+	comments, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(`delete from comment_reactions where user = ?`, name); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`delete from post_likes where liker = ?`, "user:"+name); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`delete from remember_tokens where name = ?`, name); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`delete from login_attempts where name = ?`, name); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`delete from comment_subscriptions where name = ?`, name); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	changed(db, TopicComments)
+	changed(db, TopicPosts)
+	return comments, nil
+}
+
+//DeleteNotFoundBefore removes not found statistics of the paths last seen before the unix time
+func DeleteNotFoundBefore(db *sql.DB, before int64) error {
+	_, err := db.Exec(`delete from not_found where last_seen < ?`, before)
+	return err
+}
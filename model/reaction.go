@@ -0,0 +1,96 @@
+package model
+
+import (
+	"database/sql"
+)
+
+//ToggleCommentLike likes the comment on behalf of user or removes the like if it's already set,
+//returns true if the comment is liked after the call
+func ToggleCommentLike(db *sql.DB, commentID int, user string) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`delete from comment_reactions where commentid = ? and user = ?`, commentID, user)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	liked := n == 0
+	if liked {
+		if _, err := tx.Exec(`insert into comment_reactions (commentid, user) values ($1, $2)`, commentID, user); err != nil {
+			return false, err
+		}
+	}
+	var postID int
+	if err := tx.QueryRow(`select postid from comments where commentid = ?`, commentID).Scan(&postID); err != nil && err != sql.ErrNoRows {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	changed(db, TopicComments, postID)
+	return liked, nil
+}
+
+func (c *Comment) IsCommentExist(db *sql.DB) bool {
+	status := 0
+	db.QueryRow(`select count(*) from comments where commentid = ?`, c.CommentID).Scan(&status)
+	return status != 0
+}
+
+//TogglePostLike likes the post on behalf of liker or removes the like if it's already set,
+//returns true if the post is liked after the call
+func TogglePostLike(db *sql.DB, postID int, liker string) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`delete from post_likes where postid = ? and liker = ?`, postID, liker)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	liked := n == 0
+	if liked {
+		if _, err := tx.Exec(`insert into post_likes (postid, liker) values ($1, $2)`, postID, liker); err != nil {
+			return false, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	changed(db, TopicPosts, postID)
+	return liked, nil
+}
+
+//IsPostLiked reports whether liker has liked the post
+func IsPostLiked(db *sql.DB, postID int, liker string) bool {
+	status := 0
+	db.QueryRow(`select count(*) from post_likes where postid = ? and liker = ?`, postID, liker).Scan(&status)
+	return status != 0
+}
+
+//GetMostLikedPosts returns posts ordered by number of likes, posts without likes are omitted
+func GetMostLikedPosts(db *sql.DB, count int) ([]Post, error) {
+	rows, err := db.Query(`select p.id, p.title, '', p.datepost, count(*) as likes, p.cover_image, p.visibility, p.status, p.pinned, p.featured, p.comments_count, p.expires_at, p.outdated from post_likes l
+	join posts p on p.id = l.postid group by p.id order by likes desc, p.id desc limit ?;`, count)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPosts(rows)
+}
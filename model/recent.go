@@ -0,0 +1,40 @@
+package model
+
+import (
+	"database/sql"
+)
+
+//GetRecentPosts returns published posts newest first, only posts with id lower than before
+//are returned unless before is zero
+func GetRecentPosts(db *sql.DB, before, count int) ([]Post, error) {
+	rows, err := db.Query(`select `+postListColumns+` from posts
+	where status = 'published' and (? = 0 or id < ?) order by id desc limit ?;`, before, before, count)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPosts(rows)
+}
+
+//GetRecentComments returns comments of published posts newest first, only comments with id lower
+//than before are returned unless before is zero
+func GetRecentComments(db *sql.DB, before, count int) ([]Comment, error) {
+	rows, err := db.Query(`select c.postid, c.commentid, c.name, c.date, c.comment, (select count(*) from comment_reactions r where r.commentid = c.commentid)
+	from comments c join posts p on p.id = c.postid
+	where p.status = 'published' and (? = 0 or c.commentid < ?) order by c.commentid desc limit ?;`, before, before, count)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []Comment{}
+	for rows.Next() {
+		var c Comment
+		if err := rows.Scan(&c.PostID, &c.CommentID, &c.Name, &c.Date, &c.Data, &c.Likes); err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+	return comments, rows.Err()
+}
@@ -0,0 +1,83 @@
+package model
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+//ErrInvalidRedirect is returned when the redirect can't be stored
+var ErrInvalidRedirect = errors.New("invalid redirect")
+
+//Redirect sends requests of the source path to the destination
+type Redirect struct {
+	Source      string
+	Destination string
+	//Status is either 301 or 302
+	Status int
+	Hits   int
+}
+
+//Permanent reports whether the redirect is 301
+func (rd Redirect) Permanent() bool {
+	return rd.Status == http.StatusMovedPermanently
+}
+
+//SaveRedirect inserts or replaces redirect of the source, source must be site relative path
+//and destination either site relative path or absolute url
+func (rd *Redirect) SaveRedirect(db *sql.DB) error {
+	if !strings.HasPrefix(rd.Source, "/") || rd.Destination == "" || rd.Source == rd.Destination {
+		return ErrInvalidRedirect
+	}
+	if !strings.HasPrefix(rd.Destination, "/") && !strings.HasPrefix(rd.Destination, "http://") && !strings.HasPrefix(rd.Destination, "https://") {
+		return ErrInvalidRedirect
+	}
+	if rd.Status != http.StatusMovedPermanently && rd.Status != http.StatusFound {
+		return ErrInvalidRedirect
+	}
+	_, err := db.Exec(`insert or replace into redirects (source, destination, status) values ($1, $2, $3)`, rd.Source, rd.Destination, rd.Status)
+	return err
+}
+
+//FindRedirect returns redirect of the source, sql.ErrNoRows if there is none
+func FindRedirect(db *sql.DB, source string) (Redirect, error) {
+	stmt, err := prepare(db, `select source, destination, status, hits from redirects where source = ?`)
+	if err != nil {
+		return Redirect{}, err
+	}
+	var rd Redirect
+	err = stmt.QueryRow(source).Scan(&rd.Source, &rd.Destination, &rd.Status, &rd.Hits)
+	return rd, err
+}
+
+//CountRedirectHit increments number of requests sent through the redirect
+func CountRedirectHit(db *sql.DB, source string) error {
+	_, err := db.Exec(`update redirects set hits = hits + 1 where source = $1`, source)
+	return err
+}
+
+//GetRedirects returns all redirects ordered by source
+func GetRedirects(db *sql.DB) ([]Redirect, error) {
+	rows, err := db.Query(`select source, destination, status, hits from redirects order by source`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []Redirect{}
+	for rows.Next() {
+		var rd Redirect
+		if err := rows.Scan(&rd.Source, &rd.Destination, &rd.Status, &rd.Hits); err != nil {
+			return nil, err
+		}
+		list = append(list, rd)
+	}
+	return list, rows.Err()
+}
+
+//DeleteRedirect removes redirect of the source
+func DeleteRedirect(db *sql.DB, source string) error {
+	_, err := db.Exec(`delete from redirects where source = $1`, source)
+	return err
+}
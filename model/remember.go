@@ -0,0 +1,60 @@
+package model
+
+import (
+	"database/sql"
+)
+
+//RememberToken is a long-lived login token, only hash of the validator part is stored
+type RememberToken struct {
+	Selector string
+	Hash     string
+	Name     string
+	Type     int
+	Expires  int64
+	//Rotated tokens have been replaced and are kept valid for a short grace period only
+	Rotated bool
+}
+
+//CreateRememberToken stores the token
+func (t *RememberToken) CreateRememberToken(db *sql.DB) error {
+	_, err := db.Exec(`insert into remember_tokens (selector, hash, name, type, expires) values ($1, $2, $3, $4, $5)`,
+		t.Selector, t.Hash, t.Name, t.Type, t.Expires)
+	return err
+}
+
+//GetRememberToken returns the token by its selector
+func GetRememberToken(db *sql.DB, selector string) (RememberToken, error) {
+	var t RememberToken
+	err := db.QueryRow(`select selector, hash, name, type, expires, rotated from remember_tokens where selector = ?`, selector).
+		Scan(&t.Selector, &t.Hash, &t.Name, &t.Type, &t.Expires, &t.Rotated)
+	return t, err
+}
+
+//RotateRememberToken marks the token replaced and shortens its lifetime to until, it reports false
+//if the token has been rotated already, e.g. by a parallel request
+func RotateRememberToken(db *sql.DB, selector string, until int64) (bool, error) {
+	res, err := db.Exec(`update remember_tokens set rotated = 1, expires = min(expires, ?) where selector = ? and rotated = 0`, until, selector)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+//DeleteRememberToken removes the token by its selector
+func DeleteRememberToken(db *sql.DB, selector string) error {
+	_, err := db.Exec(`delete from remember_tokens where selector = ?`, selector)
+	return err
+}
+
+//DeleteUserRememberTokens removes all tokens of the user
+func DeleteUserRememberTokens(db *sql.DB, name string) error {
+	_, err := db.Exec(`delete from remember_tokens where name = ?`, name)
+	return err
+}
+
+//DeleteExpiredRememberTokens removes tokens which expired before now
+func DeleteExpiredRememberTokens(db *sql.DB, now int64) error {
+	_, err := db.Exec(`delete from remember_tokens where expires < ?`, now)
+	return err
+}
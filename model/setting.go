@@ -0,0 +1,40 @@
+package model
+
+import (
+	"database/sql"
+)
+
+//GetSettings returns all stored site settings
+func GetSettings(db *sql.DB) (map[string]string, error) {
+	rows, err := db.Query(`select key, value from settings`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	settings := map[string]string{}
+	for rows.Next() {
+		var k, v string
+		if err := rows.Scan(&k, &v); err != nil {
+			return nil, err
+		}
+		settings[k] = v
+	}
+	return settings, rows.Err()
+}
+
+//SaveSettings stores the settings in one transaction, existing keys are overwritten
+func SaveSettings(db *sql.DB, settings map[string]string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for k, v := range settings {
+		if _, err := tx.Exec(`insert or replace into settings (key, value) values ($1, $2)`, k, v); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
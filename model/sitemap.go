@@ -0,0 +1,44 @@
+package model
+
+import (
+	"database/sql"
+)
+
+//SitemapPost is a post listed in the sitemap, LastMod is unix time of the last change of the post or its comments
+type SitemapPost struct {
+	ID      int
+	LastMod int64
+}
+
+//sitemapWhere selects published public posts search engines may index,
+//posts with noindex or canonical url pointing elsewhere are left out
+const sitemapWhere = `where status = ? and visibility = ? and noindex = 0 and canonical_url = ''`
+
+//CountSitemapPosts returns number of posts listed in the sitemap
+func CountSitemapPosts(db *sql.DB) (int, error) {
+	var c int
+	err := db.QueryRow(`select count(*) from posts `+sitemapWhere, StatusPublished, VisibilityPublic).Scan(&c)
+	return c, err
+}
+
+//EachSitemapPost calls fn for count posts listed in the sitemap starting at offset, ordered by id.
+//Posts are read one by one so memory use doesn't grow with the number of posts, error of fn stops the iteration
+func EachSitemapPost(db *sql.DB, offset, count int, fn func(SitemapPost) error) error {
+	rows, err := db.Query(`select id, max(updated_at, commented_at) from posts `+sitemapWhere+` order by id limit ? offset ?`,
+		StatusPublished, VisibilityPublic, count, offset)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p SitemapPost
+		if err := rows.Scan(&p.ID, &p.LastMod); err != nil {
+			return err
+		}
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
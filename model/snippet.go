@@ -0,0 +1,58 @@
+package model
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+//ErrInvalidSnippet is returned when the snippet can't be stored
+var ErrInvalidSnippet = errors.New("invalid snippet")
+
+//Snippet is reusable content new posts can start from, Title and Body may contain placeholders like {{date}}
+type Snippet struct {
+	Name  string
+	Title string
+	Body  string
+}
+
+//SaveSnippet inserts or replaces the snippet of the name, name and body are required
+func (s *Snippet) SaveSnippet(db *sql.DB) error {
+	if strings.TrimSpace(s.Name) == "" || strings.TrimSpace(s.Body) == "" {
+		return ErrInvalidSnippet
+	}
+	_, err := db.Exec(`insert or replace into snippets (name, title, body) values ($1, $2, $3)`, s.Name, s.Title, s.Body)
+	return err
+}
+
+//GetSnippet returns the snippet of the name, sql.ErrNoRows if there is none
+func GetSnippet(db *sql.DB, name string) (Snippet, error) {
+	var s Snippet
+	err := db.QueryRow(`select name, title, body from snippets where name = ?`, name).Scan(&s.Name, &s.Title, &s.Body)
+	return s, err
+}
+
+//GetSnippets returns all snippets ordered by name
+func GetSnippets(db *sql.DB) ([]Snippet, error) {
+	rows, err := db.Query(`select name, title, body from snippets order by name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []Snippet{}
+	for rows.Next() {
+		var s Snippet
+		if err := rows.Scan(&s.Name, &s.Title, &s.Body); err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+	}
+	return list, rows.Err()
+}
+
+//DeleteSnippet removes the snippet of the name
+func DeleteSnippet(db *sql.DB, name string) error {
+	_, err := db.Exec(`delete from snippets where name = $1`, name)
+	return err
+}
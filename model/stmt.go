@@ -0,0 +1,67 @@
+package model
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+//stmtCache holds prepared statements of the hot queries per database handle,
+//so the query plan is compiled once instead of on every request
+type stmtCache struct {
+	mu    sync.Mutex
+	stmts map[*sql.DB]map[string]*sql.Stmt
+}
+
+var cache = stmtCache{stmts: make(map[*sql.DB]map[string]*sql.Stmt)}
+
+//prepare returns cached prepared statement for the query, preparing it on first use
+func prepare(db *sql.DB, query string) (*sql.Stmt, error) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	byQuery, ok := cache.stmts[db]
+	if !ok {
+		byQuery = make(map[string]*sql.Stmt)
+		cache.stmts[db] = byQuery
+	}
+	if stmt, ok := byQuery[query]; ok {
+		return stmt, nil
+	}
+
+	stmt, err := db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	byQuery[query] = stmt
+	return stmt, nil
+}
+
+//CloseStatements closes all cached statements which belongs to db, must be called before db.Close()
+func CloseStatements(db *sql.DB) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	for _, stmt := range cache.stmts[db] {
+		stmt.Close()
+	}
+	delete(cache.stmts, db)
+}
+
+//ConfigureDB applies connection pool limits and SQLite journal mode to db
+func ConfigureDB(db *sql.DB, maxOpen, maxIdle int, journalMode string) error {
+	db.SetMaxOpenConns(maxOpen)
+	db.SetMaxIdleConns(maxIdle)
+
+	if journalMode == "" {
+		return nil
+	}
+	switch mode := strings.ToUpper(journalMode); mode {
+	case "DELETE", "TRUNCATE", "PERSIST", "MEMORY", "WAL", "OFF":
+		_, err := db.Exec(`pragma journal_mode = ` + mode)
+		return err
+	default:
+		return fmt.Errorf("unknown journal mode %q", journalMode)
+	}
+}
@@ -0,0 +1,116 @@
+package model
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+//testDB opens migrated database in the temporary directory of the test
+func testDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		CloseStatements(db)
+		db.Close()
+	})
+	MigrateDatabase(db)
+	return db
+}
+
+func TestPrepareCache(t *testing.T) {
+	db := testDB(t)
+
+	query := `select count(*) from posts`
+	first, err := prepare(db, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := prepare(db, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Errorf("statement of the same query is prepared again")
+	}
+
+	//statements prepared by the first read of the post are reused by the second one
+	p := Post{Title: "Cached", Body: "body", Date: "date"}
+	if err := p.CreatePost(db); err != nil {
+		t.Fatal(err)
+	}
+	var cached map[string]*sql.Stmt
+	for i := 0; i < 2; i++ {
+		got := Post{ID: p.ID}
+		if err := got.GetPost(db); err != nil || got.Title != p.Title {
+			t.Errorf("cached statement returned wrong post: got %+v, %v", got, err)
+		}
+		if i == 0 {
+			cached = make(map[string]*sql.Stmt)
+			for q, stmt := range cache.stmts[db] {
+				cached[q] = stmt
+			}
+		}
+	}
+	if len(cache.stmts[db]) != len(cached) {
+		t.Errorf("wrong number of cached statements: got %v want %v", len(cache.stmts[db]), len(cached))
+	}
+	for q, stmt := range cache.stmts[db] {
+		if cached[q] != stmt {
+			t.Errorf("statement is prepared again: %s", q)
+		}
+	}
+}
+
+func TestCloseStatements(t *testing.T) {
+	db := testDB(t)
+
+	query := `select count(*) from posts`
+	stmt, err := prepare(db, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	CloseStatements(db)
+
+	if _, ok := cache.stmts[db]; ok {
+		t.Errorf("statements of the closed database are still cached")
+	}
+	var c int
+	if err := stmt.QueryRow().Scan(&c); err == nil {
+		t.Errorf("closed statement can still be used")
+	}
+
+	//next use prepares the statement again
+	again, err := prepare(db, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == stmt {
+		t.Errorf("closed statement is returned from the cache")
+	}
+	if err := again.QueryRow().Scan(&c); err != nil {
+		t.Errorf("statement prepared after close doesn't work: %v", err)
+	}
+}
+
+func TestConfigureDB(t *testing.T) {
+	db := testDB(t)
+
+	if err := ConfigureDB(db, 4, 2, "bogus"); err == nil {
+		t.Errorf("invalid journal mode is accepted")
+	}
+	if err := ConfigureDB(db, 4, 2, "wal"); err != nil {
+		t.Fatal(err)
+	}
+	if max := db.Stats().MaxOpenConnections; max != 4 {
+		t.Errorf("wrong max open connections: got %v want 4", max)
+	}
+	var mode string
+	if err := db.QueryRow(`pragma journal_mode`).Scan(&mode); err != nil || mode != "wal" {
+		t.Errorf("wrong journal mode: got %q, %v want %q", mode, err, "wal")
+	}
+}
@@ -0,0 +1,80 @@
+package model
+
+import (
+	"database/sql"
+)
+
+//CommentSubscription is opt-in of the commenter to emails about new comments of the post
+type CommentSubscription struct {
+	PostID int
+	Name   string
+	Email  string
+	//Token identifies the subscription in the unsubscribe link
+	Token string
+	//Site is base url of the blog the commenter subscribed on, links of the emails point there
+	Site string
+	//NotifiedUntil is id of the last comment the subscriber knows about
+	NotifiedUntil int
+}
+
+//Subscribe stores the subscription, the subscriber is notified about comments posted from now on.
+//Subscribing to the same post again replaces the email
+func (s *CommentSubscription) Subscribe(db *sql.DB) error {
+	_, err := db.Exec(`insert or replace into comment_subscriptions (postid, name, email, token, site, notified_until)
+	values ($1, $2, $3, $4, $5, (select coalesce(max(commentid), 0) from comments where postid = $1))`, s.PostID, s.Name, s.Email, s.Token, s.Site)
+	return err
+}
+
+//Unsubscribe removes the subscription by its token and reports whether it existed
+func Unsubscribe(db *sql.DB, token string) (bool, error) {
+	res, err := db.Exec(`delete from comment_subscriptions where token = ?`, token)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func GetCommentSubscriptions(db *sql.DB) ([]CommentSubscription, error) {
+	rows, err := db.Query(`select postid, name, email, token, site, notified_until from comment_subscriptions order by postid, name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := []CommentSubscription{}
+	for rows.Next() {
+		var s CommentSubscription
+		if err := rows.Scan(&s.PostID, &s.Name, &s.Email, &s.Token, &s.Site, &s.NotifiedUntil); err != nil {
+			return nil, err
+		}
+		subs = append(subs, s)
+	}
+	return subs, rows.Err()
+}
+
+//GetCommentsAfter returns comments of the post newer than the given comment id, oldest first
+func GetCommentsAfter(db *sql.DB, postID, after int) ([]Comment, error) {
+	rows, err := db.Query(`select postid, commentid, name, date, comment from comments where postid = ? and commentid > ? order by commentid`, postID, after)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []Comment{}
+	for rows.Next() {
+		var c Comment
+		if err := rows.Scan(&c.PostID, &c.CommentID, &c.Name, &c.Date, &c.Data); err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+	return comments, rows.Err()
+}
+
+//SetNotifiedUntil remembers the last comment the subscriber was notified about
+func (s *CommentSubscription) SetNotifiedUntil(db *sql.DB, commentID int) error {
+	s.NotifiedUntil = commentID
+	_, err := db.Exec(`update comment_subscriptions set notified_until = $1 where token = $2`, commentID, s.Token)
+	return err
+}
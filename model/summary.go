@@ -0,0 +1,34 @@
+package model
+
+import (
+	"database/sql"
+)
+
+//excerptColumn cuts the body at the more marker, bodies without it are cut to the length of the post list excerpt
+const excerptColumn = `case when instr(body, '<!--more-->') > 0 then substr(body, 1, instr(body, '<!--more-->') - 1) else substr(body, 1, 950) end`
+
+//postSummaryColumns are the columns of the post list cards followed by the number of all rows matching the query
+const postSummaryColumns = `id, title, ` + excerptColumn + `, datepost, (select count(*) from post_likes l where l.postid = posts.id),
+	cover_image, visibility, status, pinned, featured, comments_count, count(*) over ()`
+
+//GetPostSummaries returns published posts of the given page in the sort order together with the total number
+//of published posts, both are read by one query. Body of the summaries holds the excerpt only.
+//Total is 0 when the page is past the last one
+func GetPostSummaries(db *sql.DB, page, perPage int, sort string) ([]Post, int, error) {
+	rows, err := db.Query(`select `+postSummaryColumns+` from posts where status = 'published' order by `+postOrder(sort)+` limit ? offset ?;`, perPage, page*perPage)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	posts := []Post{}
+	var total int
+	for rows.Next() {
+		var p Post
+		if err := rows.Scan(&p.ID, &p.Title, &p.Body, &p.Date, &p.Likes, &p.CoverImage, &p.Visibility, &p.Status, &p.Pinned, &p.Featured, &p.Comments, &total); err != nil {
+			return nil, 0, err
+		}
+		posts = append(posts, p)
+	}
+	return posts, total, rows.Err()
+}
@@ -0,0 +1,119 @@
+package model
+
+import (
+	"database/sql"
+	"errors"
+)
+
+//Workflow states of the post, only published posts are visible to readers
+const (
+	StatusDraft     = "draft"
+	StatusReview    = "review"
+	StatusApproved  = "approved"
+	StatusPublished = "published"
+)
+
+//ErrInvalidStatus is returned when the status is unknown or the transition isn't allowed
+var ErrInvalidStatus = errors.New("invalid post status")
+
+//Transitions lists states reachable from each state
+var Transitions = map[string][]string{
+	StatusDraft:     {StatusReview, StatusPublished},
+	StatusReview:    {StatusApproved, StatusDraft},
+	StatusApproved:  {StatusPublished, StatusReview, StatusDraft},
+	StatusPublished: {StatusDraft},
+}
+
+//IsStatus reports whether the status is known
+func IsStatus(status string) bool {
+	_, ok := Transitions[status]
+	return ok
+}
+
+//CanTransition reports whether the post may move from one state to another
+func CanTransition(from, to string) bool {
+	for _, s := range Transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+//Published reports whether the post is visible to readers
+func (p Post) Published() bool {
+	return p.Status == StatusPublished
+}
+
+//NextStatuses returns states the post may be moved to
+func (p Post) NextStatuses() []string {
+	return Transitions[p.Status]
+}
+
+//SetStatus moves the post to the new state and assigns the reviewer
+func (p *Post) SetStatus(db *sql.DB, status, reviewer string) error {
+	if status != p.Status && !CanTransition(p.Status, status) {
+		return ErrInvalidStatus
+	}
+	if _, err := db.Exec(`update posts set status = $1, reviewer = $2 where id = $3`, status, reviewer, p.ID); err != nil {
+		return err
+	}
+	p.Status, p.Reviewer = status, reviewer
+	changed(db, TopicPosts, p.ID)
+	return nil
+}
+
+//GetUnpublishedPosts returns posts which are still in the editorial workflow
+func GetUnpublishedPosts(db *sql.DB) ([]Post, error) {
+	rows, err := db.Query(`select ` + postListColumns + ` from posts where status != 'published' order by id desc;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPosts(rows)
+}
+
+//EditorialNote is a comment of the editor on the post, Quote is the fragment of the body it refers to
+type EditorialNote struct {
+	ID       int
+	PostID   int
+	Author   string
+	Quote    string
+	Note     string
+	Resolved bool
+	Date     string
+}
+
+//CreateEditorialNote stores the note
+func (n *EditorialNote) CreateEditorialNote(db *sql.DB) error {
+	_, err := db.Exec(`insert into editorial_notes (postid, author, quote, note, date) values ($1, $2, $3, $4, $5)`,
+		n.PostID, n.Author, n.Quote, n.Note, n.Date)
+	return err
+}
+
+//GetEditorialNotes returns notes of the post, unresolved first
+func GetEditorialNotes(db *sql.DB, postID int) ([]EditorialNote, error) {
+	rows, err := db.Query(`select id, postid, author, quote, note, resolved, date from editorial_notes
+	where postid = ? order by resolved, id`, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notes := []EditorialNote{}
+	for rows.Next() {
+		var n EditorialNote
+		if err := rows.Scan(&n.ID, &n.PostID, &n.Author, &n.Quote, &n.Note, &n.Resolved, &n.Date); err != nil {
+			return nil, err
+		}
+		notes = append(notes, n)
+	}
+	return notes, rows.Err()
+}
+
+//ResolveEditorialNote marks the note of the post as resolved
+func ResolveEditorialNote(db *sql.DB, postID, id int) error {
+	_, err := db.Exec(`update editorial_notes set resolved = 1 where id = $1 and postid = $2`, id, postID)
+	return err
+}
@@ -0,0 +1,74 @@
+package render
+
+import (
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+//CodeStyle is chroma style used to generate the code highlighting stylesheet
+const CodeStyle = "github"
+
+var (
+	fenceRe   = regexp.MustCompile("(?ms)^```([\\w+#.-]*)[ \\t]*\\r?\\n(.*?)\\r?\\n?```[ \\t]*$")
+	formatter = chromahtml.New(chromahtml.WithClasses(true), chromahtml.PreventSurroundingPre(true))
+)
+
+//HighlightCode replaces fenced code blocks (```lang ... ```) with server side highlighted markup,
+//each block is wrapped into a container with a copy button
+func HighlightCode(body string) string {
+	return fenceRe.ReplaceAllStringFunc(body, func(block string) string {
+		m := fenceRe.FindStringSubmatch(block)
+		lang, code := strings.ToLower(m[1]), m[2]
+
+		highlighted, err := highlight(lang, code)
+		if err != nil {
+			highlighted = html.EscapeString(code)
+		}
+
+		var b strings.Builder
+		b.WriteString(`<div class="code-block">`)
+		b.WriteString(`<button class="copy-code" type="button">Copy</button>`)
+		b.WriteString(`<pre class="chroma"><code`)
+		if lang != "" {
+			b.WriteString(` class="language-` + html.EscapeString(lang) + `"`)
+		}
+		b.WriteString(`>`)
+		b.WriteString(highlighted)
+		b.WriteString(`</code></pre></div>`)
+		return b.String()
+	})
+}
+
+func highlight(lang, code string) (string, error) {
+	lexer := lexers.Get(lang)
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	lexer = chroma.Coalesce(lexer)
+
+	it, err := lexer.Tokenise(nil, code)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	if err := formatter.Format(&b, styles.Get(CodeStyle), it); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+//CodeCSS returns stylesheet with the classes used by highlighted code blocks
+func CodeCSS() (string, error) {
+	var b strings.Builder
+	if err := formatter.WriteCSS(&b, styles.Get(CodeStyle)); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
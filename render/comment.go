@@ -0,0 +1,125 @@
+package render
+
+import (
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+)
+
+//Emoji maps supported :shortcode: names to their characters
+var Emoji = map[string]string{
+	"smile":          "😄",
+	"laughing":       "😆",
+	"wink":           "😉",
+	"blush":          "😊",
+	"heart":          "❤️",
+	"thumbsup":       "👍",
+	"+1":             "👍",
+	"thumbsdown":     "👎",
+	"-1":             "👎",
+	"clap":           "👏",
+	"pray":           "🙏",
+	"tada":           "🎉",
+	"rocket":         "🚀",
+	"fire":           "🔥",
+	"eyes":           "👀",
+	"thinking":       "🤔",
+	"confused":       "😕",
+	"cry":            "😢",
+	"joy":            "😂",
+	"sweat_smile":    "😅",
+	"sunglasses":     "😎",
+	"ok_hand":        "👌",
+	"wave":           "👋",
+	"bug":            "🐛",
+	"warning":        "⚠️",
+	"bulb":           "💡",
+	"check":          "✔️",
+	"x":              "❌",
+	"coffee":         "☕",
+	"beer":           "🍺",
+	"gopher":         "🐹",
+	"100":            "💯",
+	"star":           "⭐",
+	"question":       "❓",
+	"exclamation":    "❗",
+	"point_up":       "☝️",
+	"raised_hands":   "🙌",
+	"slightly_smile": "🙂",
+}
+
+var (
+	shortcodeRe = regexp.MustCompile(`:([a-z0-9_+-]+):`)
+	mentionRe   = regexp.MustCompile(`(^|\s)@([A-Za-z0-9](?:[A-Za-z0-9-]{0,38}))`)
+
+	codeSpanRe = regexp.MustCompile("`([^`\n]+)`")
+	linkRe     = regexp.MustCompile(`\[([^\]\n]+)\]\((https?://[^\s()]+)\)`)
+	strongRe   = regexp.MustCompile(`\*\*([^*\n]+)\*\*`)
+	emStarRe   = regexp.MustCompile(`(^|[\s(])\*([^*\s](?:[^*\n]*[^*\s])?)\*`)
+	emUnderRe  = regexp.MustCompile(`(^|[\s(])_([^_\s](?:[^_\n]*[^_\s])?)_`)
+)
+
+//commentPolicy allows only the markup produced by Comment
+var commentPolicy = func() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+	p.AllowElements("strong", "em", "code")
+	p.RequireParseableURLs(true)
+	p.AllowURLSchemes("http", "https")
+	p.AllowAttrs("href").OnElements("a")
+	p.AllowAttrs("rel").Matching(regexp.MustCompile(`^nofollow ugc$`)).OnElements("a")
+	return p
+}()
+
+//Comment converts comment text to safe HTML: escapes markup, renders Markdown subset
+//of **bold**, *italic*, `code` and [links](https://...) which get rel="nofollow ugc",
+//expands emoji shortcodes and links @mentions to GitHub profiles. The result is passed
+//through the sanitizer which allows nothing but that markup
+func Comment(s string) string {
+	s = html.EscapeString(s)
+
+	var b strings.Builder
+	last := 0
+	for _, loc := range codeSpanRe.FindAllStringSubmatchIndex(s, -1) {
+		b.WriteString(commentInline(s[last:loc[0]]))
+		b.WriteString("<code>" + s[loc[2]:loc[3]] + "</code>")
+		last = loc[1]
+	}
+	b.WriteString(commentInline(s[last:]))
+
+	return commentPolicy.Sanitize(b.String())
+}
+
+//commentInline renders escaped comment text outside of code spans
+func commentInline(s string) string {
+	s = ExpandEmoji(s)
+	s = linkRe.ReplaceAllString(s, `<a href="$2" rel="nofollow ugc">$1</a>`)
+	s = strongRe.ReplaceAllString(s, `<strong>$1</strong>`)
+	s = emStarRe.ReplaceAllString(s, `$1<em>$2</em>`)
+	s = emUnderRe.ReplaceAllString(s, `$1<em>$2</em>`)
+	return mentionRe.ReplaceAllString(s, `$1<a href="https://github.com/$2">@$2</a>`)
+}
+
+//ExpandEmoji replaces known :shortcode: entries with emoji, unknown ones are left as is
+func ExpandEmoji(s string) string {
+	return shortcodeRe.ReplaceAllStringFunc(s, func(code string) string {
+		if e, ok := Emoji[strings.Trim(code, ":")]; ok {
+			return e
+		}
+		return code
+	})
+}
+
+//EmojiWithPrefix returns sorted shortcodes which start with prefix
+func EmojiWithPrefix(prefix string) []string {
+	codes := []string{}
+	for code := range Emoji {
+		if strings.HasPrefix(code, prefix) {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+	return codes
+}
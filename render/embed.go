@@ -0,0 +1,255 @@
+package render
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Provider describes a site whose links can be turned into embeds
+type Provider struct {
+	Name string
+	//Match reports whether the link belongs to the provider
+	Match *regexp.Regexp
+	//Endpoint is oEmbed endpoint of the provider, empty if Render is used instead
+	Endpoint string
+	//Params are extra query parameters passed to the oEmbed endpoint
+	Params url.Values
+	//Render builds the embed markup locally without calling the provider
+	Render func(m []string) string
+}
+
+//DefaultProviders is the allow-list of embeddable sites, privacy-enhanced variants are used where available
+var DefaultProviders = []Provider{
+	{
+		Name:  "youtube",
+		Match: regexp.MustCompile(`^https?://(?:www\.|m\.)?(?:youtube\.com/watch\?(?:.*&)?v=|youtu\.be/)([\w-]{11})`),
+		Render: func(m []string) string {
+			return `<iframe src="https://www.youtube-nocookie.com/embed/` + m[1] + `" frameborder="0" allowfullscreen loading="lazy"></iframe>`
+		},
+	},
+	{
+		Name:  "gist",
+		Match: regexp.MustCompile(`^https://gist\.github\.com/([\w-]+/[0-9a-f]+)$`),
+		Render: func(m []string) string {
+			return `<script src="https://gist.github.com/` + m[1] + `.js"></script>`
+		},
+	},
+	{
+		Name:     "twitter",
+		Match:    regexp.MustCompile(`^https://(?:www\.)?(?:twitter|x)\.com/\w+/status/\d+`),
+		Endpoint: "https://publish.twitter.com/oembed",
+		Params:   url.Values{"dnt": {"true"}},
+	},
+	{
+		Name:     "vimeo",
+		Match:    regexp.MustCompile(`^https://(?:www\.)?vimeo\.com/\d+`),
+		Endpoint: "https://vimeo.com/api/oembed.json",
+		Params:   url.Values{"dnt": {"1"}},
+	},
+}
+
+var (
+	embedMarkerRe = regexp.MustCompile(`\[embed:([^\]\s]+)\]`)
+	bareURLRe     = regexp.MustCompile(`(?m)^[ \t]*(https?://[^\s<>"]+)[ \t]*$`)
+)
+
+type cachedEmbed struct {
+	link    string
+	html    string
+	expires time.Time
+}
+
+//Embedder converts [embed:URL] markers and URLs standing alone on a line into embeds.
+//Providers are never requested while rendering, oEmbed markup is fetched by Resolve when the post is saved
+//or in the background on a cache miss, until then the link is left as is.
+//Fetched markup is kept in the cache of at most Size links, the least recently used are evicted first
+type Embedder struct {
+	Providers []Provider
+	TTL       time.Duration
+	Size      int
+	client    *http.Client
+
+	mu      sync.Mutex
+	cache   map[string]*list.Element
+	lru     *list.List
+	pending map[string]bool
+}
+
+func NewEmbedder(providers []Provider, ttl time.Duration, size int) *Embedder {
+	return &Embedder{
+		Providers: providers,
+		TTL:       ttl,
+		Size:      size,
+		client:    &http.Client{Timeout: 5 * time.Second},
+		cache:     make(map[string]*list.Element),
+		lru:       list.New(),
+		pending:   make(map[string]bool),
+	}
+}
+
+//Resolve fetches oEmbed markup of the links in the body which aren't cached yet, it blocks until all are done
+func (e *Embedder) Resolve(body string) {
+	e.links(body, func(p Provider, link string) {
+		if _, fresh := e.cached(link); !fresh {
+			e.fetch(p, link)
+		}
+	})
+}
+
+//links calls fn for every link in the body which is embedded through oEmbed endpoint of the provider
+func (e *Embedder) links(body string, fn func(p Provider, link string)) {
+	var found []string
+	for _, m := range embedMarkerRe.FindAllStringSubmatch(body, -1) {
+		found = append(found, m[1])
+	}
+	for _, m := range bareURLRe.FindAllStringSubmatch(body, -1) {
+		found = append(found, m[1])
+	}
+	for _, link := range found {
+		link = html.UnescapeString(link)
+		for _, p := range e.Providers {
+			if p.Match.MatchString(link) {
+				if p.Render == nil {
+					fn(p, link)
+				}
+				break
+			}
+		}
+	}
+}
+
+//Embed replaces embeddable links in the body, links of unknown providers are left untouched
+func (e *Embedder) Embed(body string) string {
+	body = embedMarkerRe.ReplaceAllStringFunc(body, func(marker string) string {
+		link := embedMarkerRe.FindStringSubmatch(marker)[1]
+		if embed, ok := e.lookup(html.UnescapeString(link)); ok {
+			return embed
+		}
+		return marker
+	})
+	return bareURLRe.ReplaceAllStringFunc(body, func(line string) string {
+		link := strings.TrimSpace(line)
+		if embed, ok := e.lookup(html.UnescapeString(link)); ok {
+			return embed
+		}
+		return line
+	})
+}
+
+func (e *Embedder) lookup(link string) (string, bool) {
+	for _, p := range e.Providers {
+		m := p.Match.FindStringSubmatch(link)
+		if m == nil {
+			continue
+		}
+		if p.Render != nil {
+			return wrapEmbed(p.Name, p.Render(m)), true
+		}
+
+		//stale markup is served while it is refreshed
+		markup, fresh := e.cached(link)
+		if !fresh {
+			e.refresh(p, link)
+		}
+		if markup == "" {
+			return "", false
+		}
+		return wrapEmbed(p.Name, markup), true
+	}
+	return "", false
+}
+
+//cached returns markup of the link from the cache and whether it hasn't expired yet
+func (e *Embedder) cached(link string) (string, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	el, ok := e.cache[link]
+	if !ok {
+		return "", false
+	}
+	e.lru.MoveToFront(el)
+	c := el.Value.(*cachedEmbed)
+	return c.html, time.Now().Before(c.expires)
+}
+
+//refresh fetches the link in the background unless it is being fetched already
+func (e *Embedder) refresh(p Provider, link string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.pending[link] {
+		return
+	}
+	e.pending[link] = true
+	go func() {
+		e.fetch(p, link)
+		e.mu.Lock()
+		delete(e.pending, link)
+		e.mu.Unlock()
+	}()
+}
+
+//fetch requests oEmbed html of the link and caches it, failures are cached too so a broken provider isn't hammered
+func (e *Embedder) fetch(p Provider, link string) {
+	markup, _ := e.request(p, link)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	expires := time.Now().Add(e.TTL)
+	if el, ok := e.cache[link]; ok {
+		c := el.Value.(*cachedEmbed)
+		//a failed refresh keeps the markup fetched before
+		if markup != "" || c.html == "" {
+			c.html = markup
+		}
+		c.expires = expires
+		e.lru.MoveToFront(el)
+		return
+	}
+	e.cache[link] = e.lru.PushFront(&cachedEmbed{link, markup, expires})
+	for e.Size > 0 && e.lru.Len() > e.Size {
+		oldest := e.lru.Back()
+		e.lru.Remove(oldest)
+		delete(e.cache, oldest.Value.(*cachedEmbed).link)
+	}
+}
+
+func (e *Embedder) request(p Provider, link string) (string, error) {
+	q := url.Values{}
+	for k, v := range p.Params {
+		q[k] = v
+	}
+	q.Set("url", link)
+	q.Set("format", "json")
+
+	resp, err := e.client.Get(p.Endpoint + "?" + q.Encode())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("oembed %s returned %s", p.Name, resp.Status)
+	}
+
+	var oembed struct {
+		HTML string `json:"html"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&oembed); err != nil {
+		return "", err
+	}
+	return oembed.HTML, nil
+}
+
+func wrapEmbed(provider, markup string) string {
+	return `<div class="embed embed-` + provider + `">` + markup + `</div>`
+}
@@ -0,0 +1,25 @@
+package render
+
+import (
+	"strings"
+)
+
+//MoreMarker separates the excerpt from the rest of the post body
+const MoreMarker = "<!--more-->"
+
+//Excerpt returns the part of the body before MoreMarker,
+//without the marker it's the first paragraph of the body
+func Excerpt(body string) string {
+	if i := strings.Index(body, MoreMarker); i >= 0 {
+		return strings.TrimSpace(body[:i])
+	}
+
+	body = strings.TrimSpace(strings.ReplaceAll(body, "\r\n", "\n"))
+	if i := strings.Index(body, "</p>"); i >= 0 {
+		return body[:i+len("</p>")]
+	}
+	if i := strings.Index(body, "\n\n"); i >= 0 {
+		return body[:i]
+	}
+	return body
+}
@@ -0,0 +1,55 @@
+package render
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+//FAQ is one question of the [faq] shortcode
+type FAQ struct {
+	Question string
+	Answer   string
+}
+
+//faqShortcode renders [faq question="..."]answer[/faq] as collapsible block
+func faqShortcode(ctx ShortcodeContext) string {
+	return `<details class="faq"><summary>` + html.EscapeString(ctx.Attrs["question"]) + `</summary><div>` + ctx.Content + `</div></details>`
+}
+
+//FAQs returns questions and plain text answers of all [faq] shortcodes in the post body
+func FAQs(body string) []FAQ {
+	faqs := []FAQ{}
+	s := NewShortcodes()
+	s.Register("faq", func(ctx ShortcodeContext) string {
+		if q := strings.TrimSpace(ctx.Attrs["question"]); q != "" {
+			faqs = append(faqs, FAQ{q, plainText(ctx.Content)})
+		}
+		return ""
+	})
+	s.Process(body)
+	return faqs
+}
+
+var (
+	orderedListRe = regexp.MustCompile(`(?is)<ol[\s>].*?</ol>`)
+	listItemRe    = regexp.MustCompile(`(?is)<li[^>]*>(.*?)</li>`)
+)
+
+//Steps returns plain text items of the ordered lists in the post body,
+//they are used as steps of how-to guides and recipes
+func Steps(body string) []string {
+	steps := []string{}
+	for _, list := range orderedListRe.FindAllString(body, -1) {
+		for _, m := range listItemRe.FindAllStringSubmatch(list, -1) {
+			if step := plainText(m[1]); step != "" {
+				steps = append(steps, step)
+			}
+		}
+	}
+	return steps
+}
+
+func plainText(s string) string {
+	return strings.Join(strings.Fields(html.UnescapeString(tagRe.ReplaceAllString(s, " "))), " ")
+}
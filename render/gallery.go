@@ -0,0 +1,72 @@
+package render
+
+import (
+	"html"
+	"strconv"
+	"strings"
+)
+
+//GalleryImage is one image of the [gallery] shortcode
+type GalleryImage struct {
+	URL    string `json:"url"`
+	Alt    string `json:"alt"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+}
+
+//parseGallery reads images of the gallery, one per line or whitespace separated,
+//alt text can be given after a pipe: /public/img/cat.jpg|A cat
+func parseGallery(content string) []GalleryImage {
+	images := []GalleryImage{}
+	for _, line := range strings.Split(tagRe.ReplaceAllString(content, "\n"), "\n") {
+		line = strings.TrimSpace(html.UnescapeString(line))
+		if line == "" {
+			continue
+		}
+		if i := strings.Index(line, "|"); i >= 0 {
+			images = append(images, GalleryImage{URL: strings.TrimSpace(line[:i]), Alt: strings.TrimSpace(line[i+1:])})
+			continue
+		}
+		for _, u := range strings.Fields(line) {
+			images = append(images, GalleryImage{URL: u})
+		}
+	}
+	return images
+}
+
+//galleryShortcode renders [gallery]...[/gallery] as grid of lazy loaded images
+func galleryShortcode(ctx ShortcodeContext) string {
+	var b strings.Builder
+	b.WriteString(`<div class="gallery">`)
+	for i, img := range parseGallery(ctx.Content) {
+		u := html.EscapeString(safeURL(img.URL))
+		b.WriteString(`<a class="gallery-item" href="` + u + `" data-index="` + strconv.Itoa(i) + `">`)
+		b.WriteString(`<img src="` + u + `" alt="` + html.EscapeString(img.Alt) + `" loading="lazy" />`)
+		b.WriteString(`</a>`)
+	}
+	b.WriteString(`</div>`)
+	return b.String()
+}
+
+//GalleryImages returns images of all galleries in the post body in order of appearance
+func GalleryImages(body string) []GalleryImage {
+	images := []GalleryImage{}
+	s := NewShortcodes()
+	s.Register("gallery", func(ctx ShortcodeContext) string {
+		for _, img := range parseGallery(ctx.Content) {
+			img.URL = safeURL(img.URL)
+			images = append(images, img)
+		}
+		return ""
+	})
+	s.Process(body)
+	return images
+}
+
+//safeURL drops urls with scripting schemes
+func safeURL(u string) string {
+	if l := strings.ToLower(strings.TrimSpace(u)); strings.HasPrefix(l, "javascript:") || strings.HasPrefix(l, "data:") || strings.HasPrefix(l, "vbscript:") {
+		return "#"
+	}
+	return u
+}
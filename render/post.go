@@ -0,0 +1,26 @@
+package render
+
+//Pipeline holds the stateful steps of post rendering
+type Pipeline struct {
+	//Sanitizer is applied to the body before shortcodes and embeds are expanded,
+	//nil means the body is trusted
+	Sanitizer  *Sanitizer
+	Shortcodes *Shortcodes
+	Embedder   *Embedder
+}
+
+//Post renders post body html, applying all content processing steps
+func (p *Pipeline) Post(body string) string {
+	body = HighlightCode(body)
+	if p.Sanitizer != nil {
+		body = p.Sanitizer.Sanitize(body)
+	}
+	body = AnchorHeadings(body)
+	if p.Shortcodes != nil {
+		body = p.Shortcodes.Process(body)
+	}
+	if p.Embedder != nil {
+		body = outsidePre(body, p.Embedder.Embed)
+	}
+	return body
+}
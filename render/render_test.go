@@ -0,0 +1,221 @@
+package render
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestComment(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"nice :smile:", "nice 😄"},
+		{"unknown :nope: code", "unknown :nope: code"},
+		{"thanks @ultramozg!", `thanks <a href="https://github.com/ultramozg">@ultramozg</a>!`},
+		{"mail me at me@example.com", "mail me at me@example.com"},
+		{"<script>alert(1)</script>", "&lt;script&gt;alert(1)&lt;/script&gt;"},
+		{"**bold** and *italic* or _italic_", "<strong>bold</strong> and <em>italic</em> or <em>italic</em>"},
+		{"run `go vet ./...` with **care**", "run <code>go vet ./...</code> with <strong>care</strong>"},
+		{"keep `**stars** <b>` as is", "keep <code>**stars** &lt;b&gt;</code> as is"},
+		{"see [docs](https://go.dev/doc?a=1&b=2)", `see <a href="https://go.dev/doc?a=1&amp;b=2" rel="nofollow ugc">docs</a>`},
+		{"[bad](javascript:alert(1))", "[bad](javascript:alert(1))"},
+		{`[x](https://e.com/"onclick=")`, `<a href="https://e.com/%22onclick=%22" rel="nofollow ugc">x</a>`},
+		{"snake_case_name and 2*3*4", "snake_case_name and 2*3*4"},
+		{":sweat_smile: fine", "😅 fine"},
+	}
+
+	for _, tt := range tests {
+		if got := Comment(tt.in); got != tt.want {
+			t.Errorf("Comment(%q) = %q want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestHighlightCode(t *testing.T) {
+	body := "<p>intro</p>\n```go\nfunc main() {}\n```\n<p>outro</p>"
+
+	got := HighlightCode(body)
+	for _, want := range []string{`<div class="code-block">`, `<code class="language-go">`, `<span class="kd">func</span>`, "<p>outro</p>"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("HighlightCode() = %q, want it to contain %q", got, want)
+		}
+	}
+	if strings.Contains(got, "```") {
+		t.Errorf("HighlightCode() left the fence in place: %q", got)
+	}
+}
+
+func TestEmbed(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.FormValue("url"), "/slow") {
+			<-release
+		}
+		w.Write([]byte(`{"html": "<blockquote>embedded ` + r.FormValue("url") + `</blockquote>"}`))
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	providers := append([]Provider{{
+		Name:     "test",
+		Match:    regexp.MustCompile(`^https://example\.com/\w+$`),
+		Endpoint: srv.URL,
+	}}, DefaultProviders...)
+	e := NewEmbedder(providers, time.Hour, 2)
+
+	//providers aren't requested while rendering
+	if got := e.Embed("[embed:https://example.com/slow]"); got != "[embed:https://example.com/slow]" {
+		t.Errorf("Embed() of the link not resolved yet = %q", got)
+	}
+
+	e.Resolve("see [embed:https://example.com/1] here")
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ", `<iframe src="https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ"`},
+		{"see [embed:https://example.com/1] here", `<div class="embed embed-test"><blockquote>embedded https://example.com/1</blockquote></div>`},
+		{"[embed:https://unknown.org/1]", "[embed:https://unknown.org/1]"},
+		{"inline https://example.com/1 link", "inline https://example.com/1 link"},
+	}
+	for _, tt := range tests {
+		if got := e.Embed(tt.in); !strings.Contains(got, tt.want) {
+			t.Errorf("Embed(%q) = %q, want it to contain %q", tt.in, got, tt.want)
+		}
+	}
+
+	//the least recently used link is evicted once the cache is full
+	e.Resolve("https://example.com/2\nhttps://example.com/3")
+	if _, ok := e.cached("https://example.com/1"); ok {
+		t.Errorf("least recently used embed isn't evicted")
+	}
+	if n := e.lru.Len(); n != 2 {
+		t.Errorf("cache holds %v embeds, want 2", n)
+	}
+}
+
+func TestShortcodes(t *testing.T) {
+	s := DefaultShortcodes()
+	s.Register("hello", func(ctx ShortcodeContext) string {
+		return "Hello, " + ctx.Attrs["name"] + ctx.Content
+	})
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`[hello name="gopher"]`, "Hello, gopher"},
+		{`[hello name=gopher]!![/hello]`, "Hello, gopher!!"},
+		{`[unknown a=b]`, `[unknown a=b]`},
+		{`<pre>[hello name=x]</pre>`, `<pre>[hello name=x]</pre>`},
+		{`[button href="/about" style=primary]About[/button]`, `<a class="button button-primary" href="/about">About</a>`},
+		{`[button href="javascript:alert(1)" text="x"]`, `<a class="button" href="#">x</a>`},
+		{`[toc]<h2 id="intro">Intro</h2>`, `<nav class="toc"><ul><li class="toc-h2"><a href="#intro">Intro</a></li></ul></nav>`},
+	}
+	for _, tt := range tests {
+		if got := s.Process(tt.in); !strings.Contains(got, tt.want) {
+			t.Errorf("Process(%q) = %q, want it to contain %q", tt.in, got, tt.want)
+		}
+	}
+
+	got := AnchorHeadings("<h2>Getting Started</h2><h2>Getting Started</h2>")
+	want := `<h2 id="getting-started">Getting Started</h2><h2 id="getting-started-2">Getting Started</h2>`
+	if got != want {
+		t.Errorf("AnchorHeadings() = %q want %q", got, want)
+	}
+}
+
+func TestGallery(t *testing.T) {
+	body := "[gallery]\n/public/img/a.png|First\n/public/img/b.png javascript:alert(1)\n[/gallery]"
+
+	got := DefaultShortcodes().Process(body)
+	want := `<a class="gallery-item" href="/public/img/a.png" data-index="0"><img src="/public/img/a.png" alt="First" loading="lazy" /></a>`
+	if !strings.Contains(got, want) {
+		t.Errorf("gallery shortcode = %q, want it to contain %q", got, want)
+	}
+
+	images := GalleryImages(body)
+	if len(images) != 3 || images[0].Alt != "First" || images[2].URL != "#" {
+		t.Errorf("GalleryImages() = %v", images)
+	}
+}
+
+func TestSanitizer(t *testing.T) {
+	s := NewSanitizer(SanitizePolicy{})
+	got := s.SanitizeSource("<p onclick=\"x()\">hi<script>alert(1)</script></p>\n```html\n<script>kept()</script>\n```")
+	want := "<p>hi</p>\n```html\n<script>kept()</script>\n```"
+	if got != want {
+		t.Errorf("SanitizeSource() = %q want %q", got, want)
+	}
+
+	strict := NewSanitizer(SanitizePolicy{Strict: true, Tags: []string{"h2"}})
+	got = strict.Sanitize(`<h2>Title</h2><img src="/x.png"><a href="https://example.com">link</a>`)
+	want = `<h2>Title</h2><a href="https://example.com" rel="nofollow">link</a>`
+	if got != want {
+		t.Errorf("strict Sanitize() = %q want %q", got, want)
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	tests := []struct {
+		body, want string
+	}{
+		{"intro\n<!--more-->\nsecret", "intro"},
+		{"<p>first</p><p>second</p>", "<p>first</p>"},
+		{"first\r\n\r\nsecond", "first"},
+		{"only", "only"},
+	}
+	for _, tt := range tests {
+		if got := Excerpt(tt.body); got != tt.want {
+			t.Errorf("Excerpt(%q) got %q want %q", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestSEOHelpers(t *testing.T) {
+	if got, want := Slug("  Hello, World! Go 1.16 — embed  "), "hello-world-go-1-16-embed"; got != want {
+		t.Errorf("Slug() got %q want %q", got, want)
+	}
+	if got := Slug(strings.Repeat("word ", 30)); len(got) > MaxSlugLength || strings.HasSuffix(got, "-") {
+		t.Errorf("Slug() of long title got %q", got)
+	}
+
+	if got, want := Description(" short\n text "), "short text"; got != want {
+		t.Errorf("Description() got %q want %q", got, want)
+	}
+	long := Description(strings.Repeat("sentence ", 40))
+	if utf8.RuneCountInString(long) > MaxDescriptionLength || !strings.HasSuffix(long, "sentence…") {
+		t.Errorf("Description() of long text got %q", long)
+	}
+
+	got := Keywords("Golang templates: this post shows golang templates and golang embed", 2)
+	if len(got) != 2 || got[0] != "golang" || got[1] != "templates" {
+		t.Errorf("Keywords() got %v want [golang templates]", got)
+	}
+}
+
+func TestFAQsAndSteps(t *testing.T) {
+	body := `[faq question="Is it free?"]<p>Yes, it&#39;s free.</p>[/faq]
+<ol><li>Boil <b>water</b></li><li>Add pasta</li></ol>`
+
+	faqs := FAQs(body)
+	if len(faqs) != 1 || faqs[0].Question != "Is it free?" || faqs[0].Answer != "Yes, it's free." {
+		t.Errorf("FAQs() got %+v", faqs)
+	}
+
+	steps := Steps(body)
+	if len(steps) != 2 || steps[0] != "Boil water" || steps[1] != "Add pasta" {
+		t.Errorf("Steps() got %q", steps)
+	}
+
+	got := DefaultShortcodes().Process(`[faq question="Q&A"]answer[/faq]`)
+	if want := `<details class="faq"><summary>Q&amp;A</summary><div>answer</div></details>`; got != want {
+		t.Errorf("faq shortcode = %q want %q", got, want)
+	}
+}
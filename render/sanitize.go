@@ -0,0 +1,66 @@
+package render
+
+import (
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+)
+
+//SanitizePolicy describes which html is allowed in sanitized content
+type SanitizePolicy struct {
+	//Strict allows only basic text formatting, links and code
+	Strict bool
+	//Tags and Attrs extend the allow-list, attributes are allowed on every element
+	Tags  []string
+	Attrs []string
+}
+
+//Sanitizer removes html which isn't allowed by the policy
+type Sanitizer struct {
+	policy *bluemonday.Policy
+}
+
+func NewSanitizer(sp SanitizePolicy) *Sanitizer {
+	var p *bluemonday.Policy
+	if sp.Strict {
+		p = bluemonday.NewPolicy()
+		p.AllowElements("p", "br", "b", "strong", "i", "em", "code", "pre", "blockquote", "ul", "ol", "li")
+		p.AllowStandardURLs()
+		p.AllowAttrs("href").OnElements("a")
+	} else {
+		p = bluemonday.UGCPolicy()
+		p.AllowAttrs("class").Globally()
+		p.AllowAttrs("id").Matching(bluemonday.SpaceSeparatedTokens).OnElements("h1", "h2", "h3", "h4", "h5", "h6")
+		p.AllowAttrs("loading").OnElements("img")
+		p.AllowAttrs("type").OnElements("button")
+		p.AllowElements("button")
+	}
+	p.RequireNoFollowOnFullyQualifiedLinks(true)
+
+	if len(sp.Tags) > 0 {
+		p.AllowElements(sp.Tags...)
+	}
+	if len(sp.Attrs) > 0 {
+		p.AllowAttrs(sp.Attrs...).Globally()
+	}
+	return &Sanitizer{policy: p}
+}
+
+//Sanitize returns html with all not allowed elements and attributes removed
+func (s *Sanitizer) Sanitize(html string) string {
+	return s.policy.Sanitize(html)
+}
+
+//SanitizeSource sanitizes post source before it's saved, fenced code blocks are kept intact
+//as they are escaped during rendering anyway
+func (s *Sanitizer) SanitizeSource(body string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range fenceRe.FindAllStringIndex(body, -1) {
+		b.WriteString(s.Sanitize(body[last:loc[0]]))
+		b.WriteString(body[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	b.WriteString(s.Sanitize(body[last:]))
+	return b.String()
+}
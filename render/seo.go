@@ -0,0 +1,106 @@
+package render
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	MaxSlugLength        = 80
+	MaxDescriptionLength = 160
+)
+
+//stopWords are skipped when keywords are picked from the text
+var stopWords = map[string]bool{
+	"about": true, "after": true, "also": true, "been": true, "before": true, "being": true,
+	"could": true, "does": true, "from": true, "have": true, "here": true, "into": true,
+	"just": true, "like": true, "more": true, "most": true, "much": true, "only": true,
+	"other": true, "over": true, "some": true, "such": true, "than": true, "that": true,
+	"their": true, "them": true, "then": true, "there": true, "these": true, "they": true,
+	"this": true, "those": true, "very": true, "were": true, "what": true, "when": true,
+	"where": true, "which": true, "while": true, "will": true, "with": true, "would": true,
+	"your": true,
+}
+
+//Slug returns url friendly form of the title, letters are lowercased
+//and everything else is collapsed into single dashes
+func Slug(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			dash = false
+			b.WriteRune(r)
+			continue
+		}
+		dash = true
+	}
+
+	slug := b.String()
+	for len(slug) > MaxSlugLength {
+		i := strings.LastIndexByte(slug[:MaxSlugLength], '-')
+		if i <= 0 {
+			slug = truncateRunes(slug, MaxSlugLength)
+			break
+		}
+		slug = slug[:i]
+	}
+	return slug
+}
+
+//Description returns meta description made from plain text,
+//long text is cut on the word boundary and ended with ellipsis
+func Description(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) <= MaxDescriptionLength {
+		return text
+	}
+
+	cut := truncateRunes(text, MaxDescriptionLength-1)
+	if i := strings.LastIndexByte(cut, ' '); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " .,;:") + "…"
+}
+
+//Keywords returns up to n most frequent meaningful words of the text,
+//words used equally often keep the order of their first appearance
+func Keywords(text string, n int) []string {
+	counts := map[string]int{}
+	order := []string{}
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if utf8.RuneCountInString(w) < 4 || stopWords[w] {
+			continue
+		}
+		if counts[w] == 0 {
+			order = append(order, w)
+		}
+		counts[w]++
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return counts[order[i]] > counts[order[j]]
+	})
+	if len(order) > n {
+		order = order[:n]
+	}
+	return order
+}
+
+func truncateRunes(s string, n int) string {
+	i := 0
+	for pos := range s {
+		if i == n {
+			return s[:pos]
+		}
+		i++
+	}
+	return s
+}
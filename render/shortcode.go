@@ -0,0 +1,191 @@
+package render
+
+import (
+	"html"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//ShortcodeContext is passed to the shortcode handler
+type ShortcodeContext struct {
+	//Attrs are key=value attributes of the opening tag
+	Attrs map[string]string
+	//Content is the text between [name] and [/name], empty for self-closing shortcodes
+	Content string
+	//Body is the whole post body, useful for shortcodes like [toc]
+	Body string
+}
+
+//ShortcodeFunc renders shortcode into html
+type ShortcodeFunc func(ctx ShortcodeContext) string
+
+//Shortcodes is a registry of the content widgets which can be used in posts as [name attr=value]
+type Shortcodes struct {
+	mu       sync.RWMutex
+	handlers map[string]ShortcodeFunc
+}
+
+func NewShortcodes() *Shortcodes {
+	return &Shortcodes{handlers: make(map[string]ShortcodeFunc)}
+}
+
+//DefaultShortcodes returns registry with the built-in shortcodes
+func DefaultShortcodes() *Shortcodes {
+	s := NewShortcodes()
+	s.Register("toc", tocShortcode)
+	s.Register("button", buttonShortcode)
+	s.Register("gallery", galleryShortcode)
+	s.Register("faq", faqShortcode)
+	return s
+}
+
+//Register adds or replaces the shortcode handler
+func (s *Shortcodes) Register(name string, fn ShortcodeFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[name] = fn
+}
+
+//Names returns sorted names of registered shortcodes
+func (s *Shortcodes) Names() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := []string{}
+	for name := range s.handlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *Shortcodes) handler(name string) (ShortcodeFunc, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	fn, ok := s.handlers[name]
+	return fn, ok
+}
+
+var (
+	shortcodeTagRe  = regexp.MustCompile(`\[([a-z][a-z0-9_-]*)((?:\s+[^\]]*)?)\]`)
+	shortcodeAttrRe = regexp.MustCompile(`([\w-]+)=(?:"([^"]*)"|'([^']*)'|([^\s"']+))`)
+	preRe           = regexp.MustCompile(`(?is)<pre[\s>].*?</pre>`)
+)
+
+//Process replaces registered shortcodes in the body, unknown ones are left as is,
+//shortcodes inside <pre> blocks are not processed
+func (s *Shortcodes) Process(body string) string {
+	full := body
+	return outsidePre(body, func(part string) string {
+		var b strings.Builder
+		for {
+			loc := shortcodeTagRe.FindStringSubmatchIndex(part)
+			if loc == nil {
+				b.WriteString(part)
+				return b.String()
+			}
+
+			name := part[loc[2]:loc[3]]
+			fn, ok := s.handler(name)
+			if !ok {
+				b.WriteString(part[:loc[1]])
+				part = part[loc[1]:]
+				continue
+			}
+
+			ctx := ShortcodeContext{Attrs: parseAttrs(html.UnescapeString(part[loc[4]:loc[5]])), Body: full}
+			rest := part[loc[1]:]
+			closing := "[/" + name + "]"
+			if end := strings.Index(rest, closing); end >= 0 && !strings.Contains(rest[:end], "["+name) {
+				ctx.Content = rest[:end]
+				rest = rest[end+len(closing):]
+			}
+
+			b.WriteString(part[:loc[0]])
+			b.WriteString(fn(ctx))
+			part = rest
+		}
+	})
+}
+
+func parseAttrs(s string) map[string]string {
+	attrs := make(map[string]string)
+	for _, m := range shortcodeAttrRe.FindAllStringSubmatch(s, -1) {
+		attrs[m[1]] = m[2] + m[3] + m[4]
+	}
+	return attrs
+}
+
+//outsidePre applies fn to the parts of the body which are not inside <pre> blocks
+func outsidePre(body string, fn func(string) string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range preRe.FindAllStringIndex(body, -1) {
+		b.WriteString(fn(body[last:loc[0]]))
+		b.WriteString(body[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	b.WriteString(fn(body[last:]))
+	return b.String()
+}
+
+var (
+	headingRe   = regexp.MustCompile(`(?is)<h([23])([^>]*)>(.*?)</h[23]>`)
+	headingIDRe = regexp.MustCompile(`(?i)\sid="([^"]*)"`)
+	tagRe       = regexp.MustCompile(`<[^>]*>`)
+	nonSlugRe   = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+//AnchorHeadings adds id attributes to h2 and h3 headings which don't have one,
+//so they can be linked to from the table of contents
+func AnchorHeadings(body string) string {
+	used := make(map[string]bool)
+	return headingRe.ReplaceAllStringFunc(body, func(h string) string {
+		m := headingRe.FindStringSubmatch(h)
+		if headingIDRe.MatchString(m[2]) {
+			return h
+		}
+
+		id := strings.Trim(nonSlugRe.ReplaceAllString(strings.ToLower(html.UnescapeString(tagRe.ReplaceAllString(m[3], ""))), "-"), "-")
+		if id == "" {
+			id = "section"
+		}
+		for base, i := id, 2; used[id]; i++ {
+			id = base + "-" + strconv.Itoa(i)
+		}
+		used[id] = true
+		return `<h` + m[1] + m[2] + ` id="` + id + `">` + m[3] + `</h` + m[1] + `>`
+	})
+}
+
+//tocShortcode renders [toc] as list of links to the h2/h3 headings of the post
+func tocShortcode(ctx ShortcodeContext) string {
+	var b strings.Builder
+	b.WriteString(`<nav class="toc"><ul>`)
+	for _, m := range headingRe.FindAllStringSubmatch(ctx.Body, -1) {
+		id := headingIDRe.FindStringSubmatch(m[2])
+		if id == nil {
+			continue
+		}
+		b.WriteString(`<li class="toc-h` + m[1] + `"><a href="#` + id[1] + `">` + tagRe.ReplaceAllString(m[3], "") + `</a></li>`)
+	}
+	b.WriteString(`</ul></nav>`)
+	return b.String()
+}
+
+//buttonShortcode renders [button href="/about"]Label[/button] or [button href="/about" text="Label"]
+func buttonShortcode(ctx ShortcodeContext) string {
+	label := ctx.Content
+	if label == "" {
+		label = html.EscapeString(ctx.Attrs["text"])
+	}
+	href := safeURL(ctx.Attrs["href"])
+	class := "button"
+	if ctx.Attrs["style"] == "primary" {
+		class += " button-primary"
+	}
+	return `<a class="` + class + `" href="` + html.EscapeString(href) + `">` + label + `</a>`
+}
@@ -0,0 +1,216 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+//Router dispatches requests by method and path pattern. Patterns consist of literal
+//segments and named parameters, {name} matches one segment and {name...} the rest of the path,
+//e.g. /api/posts/{id}/like or /public/{file...}. Literal segments take precedence over parameters.
+//
+//Paths which are registered for other methods only get 405 with the Allow header,
+//HEAD is served by the GET handler and OPTIONS is answered from the registered methods.
+type Router struct {
+	routes []*route
+	//NotFound handles requests no pattern matches, http.NotFound if nil
+	NotFound http.Handler
+	//MethodNotAllowed handles requests of the methods the matching pattern isn't registered for,
+	//the Allow header is already set. Plain 405 is sent if nil
+	MethodNotAllowed http.Handler
+}
+
+type route struct {
+	segments []string
+	handlers map[string]http.Handler
+}
+
+type paramsKey struct{}
+
+type param struct {
+	name, value string
+}
+
+//New returns empty router
+func New() *Router {
+	return &Router{}
+}
+
+//Handle registers handler for the method and pattern, registering the same pair twice panics
+func (rt *Router) Handle(method, pattern string, h http.Handler) {
+	if !strings.HasPrefix(pattern, "/") {
+		panic("router: pattern must begin with /: " + pattern)
+	}
+	segments := split(pattern)
+	for i, s := range segments {
+		if strings.HasSuffix(s, "...}") && i != len(segments)-1 {
+			panic("router: {name...} must be the last segment: " + pattern)
+		}
+	}
+
+	for _, r := range rt.routes {
+		if strings.Join(r.segments, "/") != strings.Join(segments, "/") {
+			continue
+		}
+		if _, ok := r.handlers[method]; ok {
+			panic("router: " + method + " " + pattern + " is already registered")
+		}
+		r.handlers[method] = h
+		return
+	}
+	rt.routes = append(rt.routes, &route{segments, map[string]http.Handler{method: h}})
+	sort.SliceStable(rt.routes, func(i, j int) bool {
+		return rt.routes[i].before(rt.routes[j])
+	})
+}
+
+//HandleFunc registers handler function for the method and pattern
+func (rt *Router) HandleFunc(method, pattern string, h http.HandlerFunc) {
+	rt.Handle(method, pattern, h)
+}
+
+//Get registers handler for GET requests which serves HEAD as well
+func (rt *Router) Get(pattern string, h http.HandlerFunc) {
+	rt.Handle(http.MethodGet, pattern, h)
+}
+
+//Post registers handler for POST requests
+func (rt *Router) Post(pattern string, h http.HandlerFunc) {
+	rt.Handle(http.MethodPost, pattern, h)
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	segments := split(r.URL.Path)
+	for _, rte := range rt.routes {
+		params, ok := rte.match(segments)
+		if !ok {
+			continue
+		}
+
+		h, ok := rte.handler(r.Method)
+		if !ok {
+			w.Header().Set("Allow", rte.allow())
+			if r.Method == http.MethodOptions {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			if rt.MethodNotAllowed != nil {
+				rt.MethodNotAllowed.ServeHTTP(w, r)
+				return
+			}
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if len(params) > 0 {
+			r = r.WithContext(context.WithValue(r.Context(), paramsKey{}, params))
+		}
+		h.ServeHTTP(w, r)
+		return
+	}
+
+	if rt.NotFound != nil {
+		rt.NotFound.ServeHTTP(w, r)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+//Param returns value of the named path parameter of the request, empty if there is no such parameter
+func Param(r *http.Request, name string) string {
+	params, _ := r.Context().Value(paramsKey{}).([]param)
+	for _, p := range params {
+		if p.name == name {
+			return p.value
+		}
+	}
+	return ""
+}
+
+//WithParam returns copy of the request with the path parameter set, handlers can be tested without the router
+func WithParam(r *http.Request, name, value string) *http.Request {
+	params, _ := r.Context().Value(paramsKey{}).([]param)
+	params = append(append([]param{}, params...), param{name, value})
+	return r.WithContext(context.WithValue(r.Context(), paramsKey{}, params))
+}
+
+func (rte *route) handler(method string) (http.Handler, bool) {
+	if h, ok := rte.handlers[method]; ok {
+		return h, true
+	}
+	if method == http.MethodHead {
+		h, ok := rte.handlers[http.MethodGet]
+		return h, ok
+	}
+	return nil, false
+}
+
+func (rte *route) allow() string {
+	methods := []string{http.MethodOptions}
+	for m := range rte.handlers {
+		methods = append(methods, m)
+	}
+	if _, ok := rte.handlers[http.MethodGet]; ok {
+		if _, ok := rte.handlers[http.MethodHead]; !ok {
+			methods = append(methods, http.MethodHead)
+		}
+	}
+	sort.Strings(methods)
+	return strings.Join(methods, ", ")
+}
+
+func (rte *route) match(segments []string) ([]param, bool) {
+	var params []param
+	for i, s := range rte.segments {
+		if strings.HasSuffix(s, "...}") {
+			if i >= len(segments) {
+				return nil, false
+			}
+			return append(params, param{s[1 : len(s)-4], strings.Join(segments[i:], "/")}), true
+		}
+		if i >= len(segments) {
+			return nil, false
+		}
+		if isParam(s) {
+			if segments[i] == "" {
+				return nil, false
+			}
+			params = append(params, param{s[1 : len(s)-1], segments[i]})
+			continue
+		}
+		if s != segments[i] {
+			return nil, false
+		}
+	}
+	return params, len(rte.segments) == len(segments)
+}
+
+//before orders routes so literal segments are tried before parameters and parameters before the rest of the path
+func (rte *route) before(other *route) bool {
+	for i := 0; i < len(rte.segments) && i < len(other.segments); i++ {
+		a, b := rank(rte.segments[i]), rank(other.segments[i])
+		if a != b {
+			return a < b
+		}
+	}
+	return len(rte.segments) < len(other.segments)
+}
+
+func rank(segment string) int {
+	switch {
+	case strings.HasSuffix(segment, "...}"):
+		return 2
+	case isParam(segment):
+		return 1
+	}
+	return 0
+}
+
+func isParam(s string) bool {
+	return strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}")
+}
+
+func split(path string) []string {
+	return strings.Split(strings.TrimPrefix(path, "/"), "/")
+}
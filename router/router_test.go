@@ -0,0 +1,166 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+//text returns handler writing the text followed by the path parameters
+func text(s string, params ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(s))
+		for _, p := range params {
+			w.Write([]byte(" " + p + "=" + Param(r, p)))
+		}
+	}
+}
+
+func serve(rt *Router, method, path string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	rt.ServeHTTP(rr, httptest.NewRequest(method, path, nil))
+	return rr
+}
+
+func TestPrecedence(t *testing.T) {
+	rt := New()
+	//registered in the reverse order of the precedence
+	rt.Get("/posts/{rest...}", text("rest", "rest"))
+	rt.Get("/posts/{id}", text("param", "id"))
+	rt.Get("/posts/new", text("literal"))
+	rt.Get("/posts/{id}/comments", text("comments", "id"))
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/posts/new", "literal"},
+		{"/posts/42", "param id=42"},
+		{"/posts/42/comments", "comments id=42"},
+		{"/posts/42/likes", "rest rest=42/likes"},
+		{"/posts/a/b/c", "rest rest=a/b/c"},
+	}
+	for _, tt := range tests {
+		if got := serve(rt, http.MethodGet, tt.path).Body.String(); got != tt.want {
+			t.Errorf("GET %s: got %q want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestCatchAll(t *testing.T) {
+	rt := New()
+	rt.Get("/public/{file...}", text("file", "file"))
+
+	if got := serve(rt, http.MethodGet, "/public/css/site.css").Body.String(); got != "file file=css/site.css" {
+		t.Errorf("catch-all doesn't match the rest of the path: got %q", got)
+	}
+	if got := serve(rt, http.MethodGet, "/public/").Body.String(); got != "file file=" {
+		t.Errorf("catch-all doesn't match empty rest: got %q", got)
+	}
+	if rr := serve(rt, http.MethodGet, "/public"); rr.Code != http.StatusNotFound {
+		t.Errorf("catch-all matches the path without its segment: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+	//single segment parameters don't match empty segments
+	rt.Get("/user/{name}", text("user", "name"))
+	if rr := serve(rt, http.MethodGet, "/user/"); rr.Code != http.StatusNotFound {
+		t.Errorf("parameter matches empty segment: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+}
+
+func TestMethods(t *testing.T) {
+	rt := New()
+	rt.Get("/items", text("list"))
+	rt.Post("/items", text("create"))
+	rt.Get("/about", text("about"))
+
+	if got := serve(rt, http.MethodPost, "/items").Body.String(); got != "create" {
+		t.Errorf("POST is served by wrong handler: got %q", got)
+	}
+
+	rr := serve(rt, http.MethodDelete, "/items")
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("unregistered method returned wrong status code: got %v want %v", rr.Code, http.StatusMethodNotAllowed)
+	}
+	if allow := rr.Header().Get("Allow"); allow != "GET, HEAD, OPTIONS, POST" {
+		t.Errorf("wrong Allow header: got %q", allow)
+	}
+
+	rr = serve(rt, http.MethodHead, "/about")
+	if rr.Code != http.StatusOK || rr.Body.String() != "about" {
+		t.Errorf("HEAD isn't served by the GET handler: got %v %q", rr.Code, rr.Body.String())
+	}
+	if rr := serve(rt, http.MethodHead, "/nowhere"); rr.Code != http.StatusNotFound {
+		t.Errorf("HEAD of unknown path returned wrong status code: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+
+	rr = serve(rt, http.MethodOptions, "/about")
+	if rr.Code != http.StatusNoContent || rr.Header().Get("Allow") != "GET, HEAD, OPTIONS" {
+		t.Errorf("OPTIONS isn't answered from the registered methods: got %v %q", rr.Code, rr.Header().Get("Allow"))
+	}
+}
+
+func TestHooks(t *testing.T) {
+	rt := New()
+	rt.Get("/about", text("about"))
+
+	if rr := serve(rt, http.MethodGet, "/missing"); rr.Code != http.StatusNotFound {
+		t.Errorf("default not found returned wrong status code: got %v", rr.Code)
+	}
+	rt.NotFound = text("custom not found")
+	if got := serve(rt, http.MethodGet, "/missing").Body.String(); got != "custom not found" {
+		t.Errorf("NotFound hook isn't called: got %q", got)
+	}
+
+	rt.MethodNotAllowed = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte("custom " + w.Header().Get("Allow")))
+	})
+	rr := serve(rt, http.MethodPost, "/about")
+	if rr.Code != http.StatusMethodNotAllowed || rr.Body.String() != "custom GET, HEAD, OPTIONS" {
+		t.Errorf("MethodNotAllowed hook isn't called with the Allow header: got %v %q", rr.Code, rr.Body.String())
+	}
+	//OPTIONS is answered by the router itself
+	if rr := serve(rt, http.MethodOptions, "/about"); rr.Code != http.StatusNoContent {
+		t.Errorf("OPTIONS is passed to the hook: got %v", rr.Code)
+	}
+}
+
+func TestWithParam(t *testing.T) {
+	r := WithParam(httptest.NewRequest(http.MethodGet, "/", nil), "id", "7")
+	if got := Param(r, "id"); got != "7" {
+		t.Errorf("WithParam doesn't set the parameter: got %q", got)
+	}
+	if got := Param(r, "missing"); got != "" {
+		t.Errorf("missing parameter isn't empty: got %q", got)
+	}
+}
+
+func TestPanics(t *testing.T) {
+	tests := map[string]func(rt *Router){
+		"duplicate route": func(rt *Router) {
+			rt.Get("/about", text("a"))
+			rt.Get("/about", text("b"))
+		},
+		"duplicate route with parameter": func(rt *Router) {
+			rt.Get("/posts/{id}", text("a"))
+			rt.Get("/posts/{id}", text("b"))
+		},
+		"relative pattern":        func(rt *Router) { rt.Get("about", text("a")) },
+		"catch-all in the middle": func(rt *Router) { rt.Get("/files/{path...}/raw", text("a")) },
+	}
+	for name, register := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s doesn't panic", name)
+				}
+			}()
+			register(New())
+		}()
+	}
+
+	//other methods of the same pattern are fine
+	rt := New()
+	rt.Get("/about", text("a"))
+	rt.Post("/about", text("b"))
+}
@@ -0,0 +1,112 @@
+package session
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+)
+
+//CookieOptions holds attributes applied to every cookie issued by the blog
+type CookieOptions struct {
+	//Secret is used to derive the key which encrypts and authenticates cookie values
+	Secret   string
+	Domain   string
+	Path     string
+	Secure   bool
+	SameSite http.SameSite
+}
+
+//Cookies issues and reads encrypted cookies, values are sealed with AES-GCM
+//and bound to the cookie name so they can't be swapped between cookies
+type Cookies struct {
+	opts CookieOptions
+	aead cipher.AEAD
+}
+
+//NewCookies creates cookie codec, random key is used if the secret is empty
+//which means cookies don't survive restarts
+func NewCookies(o CookieOptions) (*Cookies, error) {
+	key := sha256.Sum256([]byte(o.Secret))
+	if o.Secret == "" {
+		if _, err := rand.Read(key[:]); err != nil {
+			return nil, err
+		}
+	}
+	if o.Path == "" {
+		o.Path = "/"
+	}
+
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cookies{opts: o, aead: aead}, nil
+}
+
+//New returns HttpOnly cookie with encrypted value, zero maxAge means session cookie
+func (c *Cookies) New(name, value string, maxAge int) *http.Cookie {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		panic(err)
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(value), []byte(name))
+
+	return &http.Cookie{
+		Name:     name,
+		Value:    base64.RawURLEncoding.EncodeToString(sealed),
+		Domain:   c.opts.Domain,
+		Path:     c.opts.Path,
+		MaxAge:   maxAge,
+		Secure:   c.opts.Secure,
+		HttpOnly: true,
+		SameSite: c.opts.SameSite,
+	}
+}
+
+//Expire returns cookie which removes the named cookie from the browser
+func (c *Cookies) Expire(name string) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    "",
+		Domain:   c.opts.Domain,
+		Path:     c.opts.Path,
+		MaxAge:   -1,
+		Secure:   c.opts.Secure,
+		HttpOnly: true,
+		SameSite: c.opts.SameSite,
+	}
+}
+
+//Read returns decrypted value of the named cookie, forged or tampered cookies are rejected
+func (c *Cookies) Read(r *http.Request, name string) (string, error) {
+	ck, err := r.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	return c.Decode(name, ck.Value)
+}
+
+//Decode decrypts cookie value issued by New
+func (c *Cookies) Decode(name, value string) (string, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return "", err
+	}
+	n := c.aead.NonceSize()
+	if len(sealed) < n {
+		return "", errors.New("cookie value is too short")
+	}
+	plain, err := c.aead.Open(nil, sealed[:n], sealed[n:], []byte(name))
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
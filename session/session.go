@@ -1,7 +1,13 @@
 package session
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
 	"net/http"
+	"sort"
+	"sync"
+	"time"
 
 	uuid "github.com/satori/go.uuid"
 	"github.com/ultramozg/golang-blog-engine/model"
@@ -14,55 +20,170 @@ const (
 	GITHUB
 )
 
-//SessionDB is just a map which holds active sessions
-type SessionDB map[string]model.User
+//Session describes logged in user and the client which uses it
+type Session struct {
+	ID        string
+	User      model.User
+	IP        string
+	UserAgent string
+	Created   time.Time
+	LastSeen  time.Time
+	//Remember is selector of the remember me token which the session was created with
+	Remember string
+	//AsVisitor makes the pages look to the user like to an anonymous visitor
+	AsVisitor bool
+}
 
-//NewSessionDB generate new SessionDB struct
-func NewSessionDB() SessionDB {
-	return make(map[string]model.User)
+//Ref is a public reference of the session which can be shown without leaking the session id
+func (s Session) Ref() string {
+	sum := sha256.Sum256([]byte(s.ID))
+	return hex.EncodeToString(sum[:8])
 }
 
-func (s SessionDB) IsAdmin(r *http.Request) bool {
-	c, err := r.Cookie("session")
-	if err == http.ErrNoCookie {
-		return false
+//SessionDB holds active sessions
+type SessionDB struct {
+	mu       sync.Mutex
+	sessions map[string]*Session
+	cookies  *Cookies
+}
+
+//NewSessionDB generate new SessionDB struct, session cookies are issued by the given codec
+func NewSessionDB(cookies *Cookies) *SessionDB {
+	return &SessionDB{sessions: make(map[string]*Session), cookies: cookies}
+}
+
+//lookup returns session of the request and refreshes its client info
+func (s *SessionDB) lookup(r *http.Request) (Session, bool) {
+	id, err := s.cookies.Read(r, "session")
+	if err != nil {
+		return Session{}, false
 	}
-	if v, ok := s[c.Value]; ok && v.Type == ADMIN {
-		return true
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.sessions[id]
+	if !ok {
+		return Session{}, false
 	}
-	return false
+	v.LastSeen = time.Now()
+	v.IP = clientIP(r)
+	v.UserAgent = r.UserAgent()
+	return *v, true
+}
+
+func (s *SessionDB) IsAdmin(r *http.Request) bool {
+	v, ok := s.lookup(r)
+	return ok && v.User.Type == ADMIN
+}
+
+func (s *SessionDB) IsLoggedin(r *http.Request) bool {
+	_, ok := s.lookup(r)
+	return ok
+}
+
+//GetUser returns user which owns the session of the request
+func (s *SessionDB) GetUser(r *http.Request) (model.User, bool) {
+	v, ok := s.lookup(r)
+	return v.User, ok
+}
+
+//GetSession returns session of the request
+func (s *SessionDB) GetSession(r *http.Request) (Session, bool) {
+	return s.lookup(r)
 }
 
-func (s SessionDB) IsLoggedin(r *http.Request) bool {
-	c, err := r.Cookie("session")
-	if err == http.ErrNoCookie {
+//SetAsVisitor switches the session of the request to the view of an anonymous visitor and back,
+//it reports false if the request has no session
+func (s *SessionDB) SetAsVisitor(r *http.Request, on bool) bool {
+	id, err := s.cookies.Read(r, "session")
+	if err != nil {
 		return false
 	}
-	if _, ok := s[c.Value]; ok {
-		return true
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.sessions[id]
+	if ok {
+		v.AsVisitor = on
 	}
-	return false
+	return ok
+}
+
+//CreateSession starts new session for the user, r is used to record client info and may be nil
+func (s *SessionDB) CreateSession(u model.User, r *http.Request) *http.Cookie {
+	return s.CreateRememberedSession(u, r, "")
 }
 
-func (s SessionDB) CreateSession(u model.User) *http.Cookie {
-	sID := uuid.NewV4()
+//CreateRememberedSession starts new session which is bound to the remember me token
+func (s *SessionDB) CreateRememberedSession(u model.User, r *http.Request, remember string) *http.Cookie {
+	sID := uuid.NewV4().String()
+	now := time.Now()
+	v := &Session{ID: sID, User: u, Created: now, LastSeen: now, Remember: remember}
+	if r != nil {
+		v.IP = clientIP(r)
+		v.UserAgent = r.UserAgent()
+	}
+
+	s.mu.Lock()
+	s.sessions[sID] = v
+	s.mu.Unlock()
+
+	return s.cookies.New("session", sID, 0)
+}
 
-	s[sID.String()] = u
+func (s *SessionDB) DelSession(session string) *http.Cookie {
+	s.mu.Lock()
+	delete(s.sessions, session)
+	s.mu.Unlock()
 
-	c := &http.Cookie{
-		Name:  "session",
-		Value: sID.String(),
+	return s.cookies.Expire("session")
+}
+
+//List returns active sessions, recently seen first
+func (s *SessionDB) List() []Session {
+	s.mu.Lock()
+	list := make([]Session, 0, len(s.sessions))
+	for _, v := range s.sessions {
+		list = append(list, *v)
 	}
-	return c
+	s.mu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].LastSeen.After(list[j].LastSeen) })
+	return list
 }
 
-func (s SessionDB) DelSession(session string) *http.Cookie {
-	delete(s, session)
+//Revoke removes session by its public reference and returns it
+func (s *SessionDB) Revoke(ref string) (Session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, v := range s.sessions {
+		if v.Ref() == ref {
+			delete(s.sessions, id)
+			return *v, true
+		}
+	}
+	return Session{}, false
+}
+
+//RevokeOthers removes all sessions except the given one and returns removed ones
+func (s *SessionDB) RevokeOthers(keep string) []Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var removed []Session
+	for id, v := range s.sessions {
+		if id != keep {
+			delete(s.sessions, id)
+			removed = append(removed, *v)
+		}
+	}
+	return removed
+}
 
-	c := &http.Cookie{
-		Name:   "session",
-		Value:  "",
-		MaxAge: -1,
+//clientIP returns address of the client without port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
 	}
-	return c
+	return host
 }
@@ -0,0 +1,344 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Kinds of the spans, see the OTLP trace protocol
+const (
+	KindInternal = 1
+	KindServer   = 2
+	KindClient   = 3
+)
+
+const (
+	//BatchSize spans are exported at once, spans ended while the queue is full are dropped
+	BatchSize = 512
+	QueueSize = 4 * BatchSize
+)
+
+//Config tells where spans are exported, tracing is disabled if Endpoint is empty
+type Config struct {
+	//Endpoint is base address of the OTLP/HTTP collector, spans are posted to Endpoint/v1/traces
+	Endpoint string
+	Service  string
+	//SampleRatio is share of traces recorded, traces started by a sampled caller are always recorded
+	SampleRatio float64
+	//Interval is how often spans are exported
+	Interval time.Duration
+}
+
+//Tracer records spans and exports them with OTLP/HTTP in JSON encoding.
+//Nil tracer is valid, its spans are not recorded
+type Tracer struct {
+	config Config
+	client *http.Client
+	queue  chan *Span
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+//New starts exporting spans, it returns nil if the endpoint isn't configured
+func New(c Config) *Tracer {
+	if c.Endpoint == "" {
+		return nil
+	}
+	if c.Interval <= 0 {
+		c.Interval = 5 * time.Second
+	}
+	t := &Tracer{
+		config: c,
+		client: &http.Client{Timeout: 10 * time.Second},
+		queue:  make(chan *Span, QueueSize),
+		done:   make(chan struct{}),
+	}
+	t.wg.Add(1)
+	go t.export()
+	return t
+}
+
+//Span is a timed operation of the trace
+type Span struct {
+	tracer  *Tracer
+	traceID [16]byte
+	spanID  [8]byte
+	parent  [8]byte
+	sampled bool
+	name    string
+	kind    int
+	start   time.Time
+	end     time.Time
+	attrs   map[string]interface{}
+	err     string
+	mu      sync.Mutex
+}
+
+type spanKey struct{}
+
+//FromContext returns span of the context, nil if there is none
+func FromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+//Start begins a span which is child of the span of the context, the span must be ended
+func (t *Tracer) Start(ctx context.Context, name string, kind int) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	s := &Span{tracer: t, name: name, kind: kind, start: time.Now(), attrs: map[string]interface{}{}}
+	if parent := FromContext(ctx); parent != nil {
+		s.traceID, s.parent, s.sampled = parent.traceID, parent.spanID, parent.sampled
+	} else {
+		rand.Read(s.traceID[:])
+		s.sampled = t.sample(s.traceID)
+	}
+	rand.Read(s.spanID[:])
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+//sample decides from the random part of the trace id, so every service sampling by ratio agrees
+func (t *Tracer) sample(traceID [16]byte) bool {
+	if t.config.SampleRatio >= 1 {
+		return true
+	}
+	return float64(binary.BigEndian.Uint64(traceID[8:])>>1) < t.config.SampleRatio*(1<<63)
+}
+
+//SetAttribute adds the attribute to the span, values are strings, integers or booleans
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.attrs[key] = value
+	s.mu.Unlock()
+}
+
+//SetError marks the span as failed
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.err = err.Error()
+	s.mu.Unlock()
+}
+
+//End finishes the span and queues it for export
+func (s *Span) End() {
+	if s == nil || !s.sampled {
+		return
+	}
+	s.end = time.Now()
+	select {
+	case s.tracer.queue <- s:
+	default:
+	}
+}
+
+//TraceParent returns the W3C traceparent header value of the span
+func (s *Span) TraceParent() string {
+	if s == nil {
+		return ""
+	}
+	flags := "00"
+	if s.sampled {
+		flags = "01"
+	}
+	return "00-" + hex.EncodeToString(s.traceID[:]) + "-" + hex.EncodeToString(s.spanID[:]) + "-" + flags
+}
+
+//Inject passes the span of the context to the outgoing request in the traceparent header
+func Inject(ctx context.Context, req *http.Request) {
+	if s := FromContext(ctx); s != nil {
+		req.Header.Set("traceparent", s.TraceParent())
+	}
+}
+
+//remoteParent returns context carrying the caller's span given in the traceparent header
+func (t *Tracer) remoteParent(r *http.Request) context.Context {
+	parts := strings.Split(r.Header.Get("traceparent"), "-")
+	if len(parts) != 4 || parts[0] != "00" {
+		return r.Context()
+	}
+	s := &Span{tracer: t}
+	traceID, err1 := hex.DecodeString(parts[1])
+	spanID, err2 := hex.DecodeString(parts[2])
+	flags, err3 := strconv.ParseUint(parts[3], 16, 8)
+	if err1 != nil || err2 != nil || err3 != nil || len(traceID) != 16 || len(spanID) != 8 {
+		return r.Context()
+	}
+	copy(s.traceID[:], traceID)
+	copy(s.spanID[:], spanID)
+	s.sampled = flags&1 == 1 || t.sample(s.traceID)
+	return context.WithValue(r.Context(), spanKey{}, s)
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+//Middleware records server span of every request, the trace is continued if the caller sent traceparent
+func (t *Tracer) Middleware(h http.Handler) http.Handler {
+	if t == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, s := t.Start(t.remoteParent(r), r.Method+" "+r.URL.Path, KindServer)
+		defer s.End()
+		s.SetAttribute("http.request.method", r.Method)
+		s.SetAttribute("url.path", r.URL.Path)
+		s.SetAttribute("user_agent.original", r.UserAgent())
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(sw, r.WithContext(ctx))
+		s.SetAttribute("http.response.status_code", sw.status)
+		if sw.status >= http.StatusInternalServerError {
+			s.SetError(fmt.Errorf("%d %s", sw.status, http.StatusText(sw.status)))
+		}
+	})
+}
+
+//Shutdown exports the queued spans and stops the exporter
+func (t *Tracer) Shutdown() {
+	if t == nil {
+		return
+	}
+	close(t.done)
+	t.wg.Wait()
+}
+
+func (t *Tracer) export() {
+	defer t.wg.Done()
+	ticker := time.NewTicker(t.config.Interval)
+	defer ticker.Stop()
+
+	batch := []*Span{}
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.send(batch); err != nil {
+			log.Printf("Unable to export %d spans: %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case s := <-t.queue:
+			if batch = append(batch, s); len(batch) >= BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-t.done:
+			for {
+				select {
+				case s := <-t.queue:
+					batch = append(batch, s)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+type keyValue struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+func attribute(key string, value interface{}) keyValue {
+	switch v := value.(type) {
+	case int:
+		return keyValue{key, map[string]interface{}{"intValue": strconv.Itoa(v)}}
+	case int64:
+		return keyValue{key, map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}}
+	case bool:
+		return keyValue{key, map[string]interface{}{"boolValue": v}}
+	}
+	return keyValue{key, map[string]interface{}{"stringValue": fmt.Sprint(value)}}
+}
+
+//send posts the spans to the collector in OTLP JSON encoding
+func (t *Tracer) send(spans []*Span) error {
+	out := make([]map[string]interface{}, 0, len(spans))
+	for _, s := range spans {
+		s.mu.Lock()
+		attrs := make([]keyValue, 0, len(s.attrs))
+		for k, v := range s.attrs {
+			attrs = append(attrs, attribute(k, v))
+		}
+		status := map[string]interface{}{"code": 1}
+		if s.err != "" {
+			status = map[string]interface{}{"code": 2, "message": s.err}
+		}
+		s.mu.Unlock()
+
+		span := map[string]interface{}{
+			"traceId":           hex.EncodeToString(s.traceID[:]),
+			"spanId":            hex.EncodeToString(s.spanID[:]),
+			"name":              s.name,
+			"kind":              s.kind,
+			"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+			"attributes":        attrs,
+			"status":            status,
+		}
+		if s.parent != [8]byte{} {
+			span["parentSpanId"] = hex.EncodeToString(s.parent[:])
+		}
+		out = append(out, span)
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource":   map[string]interface{}{"attributes": []keyValue{attribute("service.name", t.config.Service)}},
+			"scopeSpans": []interface{}{map[string]interface{}{"scope": map[string]string{"name": "github.com/ultramozg/golang-blog-engine"}, "spans": out}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := t.client.Post(strings.TrimSuffix(t.config.Endpoint, "/")+"/v1/traces", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}